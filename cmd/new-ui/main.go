@@ -15,11 +15,15 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
+	"os"
 
 	"github.com/GoogleCloudPlatform/deploystack"
+	"github.com/GoogleCloudPlatform/deploystack/config"
+	"github.com/GoogleCloudPlatform/deploystack/gcloud"
 	"github.com/GoogleCloudPlatform/deploystack/tui"
 )
 
@@ -30,6 +34,13 @@ const (
 func main() {
 	verify := flag.Bool("verify", false, "Whether or not to be in verify mode")
 	name := flag.Bool("name", false, "Whether or not to be in drop the name of the stack")
+	strict := flag.Bool("strict", false, "Whether or not to exit with a distinct code when the settings have warnings")
+	from := flag.String("from", "", "The key of the step to resume at, using the answers of an earlier run")
+	copyFrom := flag.String("copy-from", "", "The project to copy region, zone and network defaults from")
+	headless := flag.Bool("headless", false, "Whether or not to write the settings without asking any questions")
+	readOnly := flag.Bool("read-only", false, "Whether or not to collect the settings without changing anything in Google Cloud")
+	runScript := flag.String("run-script", "", "The script in the scripts folder to run with the settings of an earlier run, say after deploying")
+	settings := flag.String("settings", "", "A tfvars file of settings to use instead of asking for them")
 
 	flag.Parse()
 
@@ -38,6 +49,25 @@ func main() {
 		log.Fatalf("could not read initialize deploystack: %s", err)
 	}
 
+	// Settings supplied as DEPLOYSTACK_ variables aren't asked for, so they
+	// are checked up front the way answers would be
+	s.ApplyEnvOverrides(config.EnvPrefix)
+	if err := tui.ValidateOverrides(s); err != nil {
+		log.Fatalf("invalid setting override: %s", err)
+	}
+
+	if *settings != "" {
+		answers, err := config.ReadTFVars(*settings)
+		if err != nil {
+			log.Fatalf("could not read settings: %s", err)
+		}
+		for _, v := range answers {
+			if s.Settings.Find(v.Name) == nil {
+				s.AddSettingComplete(v)
+			}
+		}
+	}
+
 	if *verify {
 		fmt.Printf("%s|%s|%s\n", s.Config.PathTerraform, s.Config.PathMessages, s.Config.PathScripts)
 		return
@@ -48,5 +78,58 @@ func main() {
 		return
 	}
 
+	if *runScript != "" {
+		answers, err := config.ReadTFVars(s.OutputPath(tui.AnswersFile))
+		if err != nil {
+			log.Fatalf("could not read the settings of an earlier run: %s", err)
+		}
+		for _, v := range answers {
+			if s.Settings.Find(v.Name) == nil {
+				s.AddSettingComplete(v)
+			}
+		}
+
+		out, err := s.RunScriptOutput(*runScript)
+		fmt.Print(string(out))
+		if err != nil {
+			log.Fatalf("could not run %s: %s", *runScript, err)
+		}
+		return
+	}
+
+	if *headless {
+		if err := deploystack.RunHeadless(s); err != nil {
+			log.Fatalf("could not write settings: %s", err)
+		}
+		return
+	}
+
+	if *from != "" {
+		client := gcloud.NewClient(context.Background(), fmt.Sprintf("deploystack/%s", s.Config.Name))
+		if err := tui.RunFrom(s, &client, *from); err != nil {
+			log.Fatalf("could not resume at %s: %s", *from, err)
+		}
+		return
+	}
+
+	if *copyFrom != "" {
+		client := gcloud.NewClient(context.Background(), fmt.Sprintf("deploystack/%s", s.Config.Name))
+		if err := tui.RunCopyFrom(s, &client, *copyFrom); err != nil {
+			log.Fatalf("could not copy the config of %s: %s", *copyFrom, err)
+		}
+		return
+	}
+
+	if *readOnly {
+		if err := tui.RunReadOnly(s); err != nil {
+			log.Fatalf("could not collect settings: %s", err)
+		}
+		return
+	}
+
+	if *strict {
+		os.Exit(tui.ExitCode(tui.RunStrict(s, false)))
+	}
+
 	tui.Run(s, false)
 }
@@ -0,0 +1,275 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploystack
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/deploystack/config"
+	"github.com/GoogleCloudPlatform/deploystack/gcloud"
+)
+
+// ErrCLIUnsupported is returned by CollectCLI when a stack asks for something
+// that only the full tui can collect
+var ErrCLIUnsupported = fmt.Errorf("this stack needs the full DeployStack ui")
+
+// CLIClient is the set of Google Cloud calls CollectCLI needs to answer the
+// questions a stack asks
+type CLIClient interface {
+	ProjectList() ([]gcloud.ProjectWithBilling, error)
+	ProjectNumberGet(id string) (string, error)
+	RegionList(project, product string) ([]string, error)
+	ZoneList(project, region string) ([]string, error)
+}
+
+// CollectCLI asks the questions a stack's config calls for with plain line
+// prompts on stdin and stdout, and stores the answers in the stack settings.
+// It is a lightweight alternative to the tui for integrators who can't run
+// a full screen ui.
+func CollectCLI(s *config.Stack, client CLIClient) error {
+	return collectCLI(s, client, os.Stdin, os.Stdout)
+}
+
+func collectCLI(s *config.Stack, client CLIClient, in io.Reader, out io.Writer) error {
+	if err := cliSupported(s); err != nil {
+		return err
+	}
+
+	p := prompter{in: bufio.NewReader(in), out: out}
+
+	projects := s.Config.Projects.Items
+	if s.Config.Project && s.GetSetting("project_id") == "" {
+		projects = append(projects, config.Project{
+			Name:       "project_id",
+			UserPrompt: "Choose a project to use for this application.",
+		})
+	}
+
+	if len(projects) > 0 {
+		list, err := client.ProjectList()
+		if err != nil {
+			return fmt.Errorf("could not list projects: %w", err)
+		}
+
+		options := gcloud.LabeledValues{}
+		for _, v := range list {
+			options = append(options, gcloud.LabeledValue{Value: v.ID, Label: v.Name})
+		}
+
+		for _, v := range projects {
+			if s.GetSetting(v.Name) != "" {
+				continue
+			}
+
+			project, err := p.choose(v.UserPrompt, options, "")
+			if err != nil {
+				return err
+			}
+			s.AddSetting(v.Name, project)
+		}
+	}
+
+	project := s.GetSetting("project_id")
+
+	if s.Config.ProjectNumber && project != "" && s.GetSetting("project_number") == "" {
+		number, err := client.ProjectNumberGet(project)
+		if err != nil {
+			return fmt.Errorf("could not get project number: %w", err)
+		}
+		s.AddSetting("project_number", number)
+	}
+
+	if s.Config.Region && s.GetSetting("region") == "" {
+		regions, err := client.RegionList(project, s.Config.RegionType)
+		if err != nil {
+			return fmt.Errorf("could not list regions: %w", err)
+		}
+
+		region, err := p.choose("Pick a region", labeled(regions), s.Config.RegionDefault)
+		if err != nil {
+			return err
+		}
+		s.AddSetting("region", region)
+	}
+
+	if s.Config.Zone && s.GetSetting("zone") == "" {
+		zones, err := client.ZoneList(project, s.GetSetting("region"))
+		if err != nil {
+			return fmt.Errorf("could not list zones: %w", err)
+		}
+
+		zone, err := p.choose("Pick a zone", labeled(zones), "")
+		if err != nil {
+			return err
+		}
+		s.AddSetting("zone", zone)
+	}
+
+	for _, v := range s.Config.CustomSettings {
+		if s.GetSetting(v.Name) != "" {
+			continue
+		}
+
+		var value string
+		var err error
+
+		if len(v.Options) > 0 {
+			options := gcloud.LabeledValues{}
+			for _, opt := range v.Options {
+				options = append(options, gcloud.NewLabeledValue(opt))
+			}
+			value, err = p.choose(v.Description, options, v.Default)
+		} else {
+			setting := v
+			value, err = p.ask(v.Description, v.Default, func(s string) error {
+				return setting.ValidateAnswer(s)
+			})
+		}
+		if err != nil {
+			return err
+		}
+
+		if v.PrependProject {
+			value = fmt.Sprintf("%s-%s", project, value)
+		}
+
+		s.AddSetting(v.Name, value)
+	}
+
+	return nil
+}
+
+// cliSupported makes sure we can collect everything a stack asks for before
+// asking any questions, so users don't answer half a form for nothing
+func cliSupported(s *config.Stack) error {
+	unsupported := []string{}
+
+	if s.Config.BillingAccount {
+		unsupported = append(unsupported, "billing accounts")
+	}
+	if s.Config.Domain {
+		unsupported = append(unsupported, "domain registration")
+	}
+	if s.Config.ConfigureGCEInstance {
+		unsupported = append(unsupported, "Compute Engine instance configuration")
+	}
+	if s.Config.Accelerator {
+		unsupported = append(unsupported, "accelerators")
+	}
+	if s.Config.CostLabels {
+		unsupported = append(unsupported, "cost labels")
+	}
+	for _, v := range s.Config.CustomSettings {
+		if v.Secret {
+			unsupported = append(unsupported, fmt.Sprintf("secret (%s)", v.Name))
+		}
+		if v.StaticIP {
+			unsupported = append(unsupported, fmt.Sprintf("static IP (%s)", v.Name))
+		}
+		if v.DNSZone {
+			unsupported = append(unsupported, fmt.Sprintf("DNS zone (%s)", v.Name))
+		}
+	}
+
+	if len(unsupported) > 0 {
+		return fmt.Errorf("%w: %s", ErrCLIUnsupported, strings.Join(unsupported, ", "))
+	}
+
+	return nil
+}
+
+func labeled(values []string) gcloud.LabeledValues {
+	result := gcloud.LabeledValues{}
+	for _, v := range values {
+		result = append(result, gcloud.LabeledValue{Value: v, Label: v})
+	}
+	return result
+}
+
+// prompter asks questions one line at a time
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// ask prompts until it gets an answer that passes validate. An empty answer
+// takes the default.
+func (p prompter) ask(question, def string, validate func(string) error) (string, error) {
+	for {
+		if def != "" {
+			fmt.Fprintf(p.out, "%s [%s]: ", question, def)
+		} else {
+			fmt.Fprintf(p.out, "%s: ", question)
+		}
+
+		line, err := p.in.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return "", fmt.Errorf("could not read answer to '%s': %w", question, err)
+		}
+
+		answer := strings.TrimSpace(line)
+		if answer == "" {
+			answer = def
+		}
+
+		if answer == "" {
+			fmt.Fprintln(p.out, "An answer is required.")
+			continue
+		}
+
+		if validate != nil {
+			if err := validate(answer); err != nil {
+				fmt.Fprintln(p.out, err)
+				continue
+			}
+		}
+
+		return answer, nil
+	}
+}
+
+// choose lists the options and prompts until the user picks one, either by
+// number or by value
+func (p prompter) choose(question string, options gcloud.LabeledValues, def string) (string, error) {
+	fmt.Fprintln(p.out, question)
+	for i, v := range options {
+		fmt.Fprintf(p.out, "  %d) %s\n", i+1, v.Label)
+	}
+
+	var chosen string
+
+	_, err := p.ask("Enter a number or value", def, func(answer string) error {
+		if i, err := strconv.Atoi(answer); err == nil && i > 0 && i <= len(options) {
+			chosen = options[i-1].Value
+			return nil
+		}
+
+		for _, v := range options {
+			if v.Value == answer {
+				chosen = v.Value
+				return nil
+			}
+		}
+
+		return fmt.Errorf("'%s' is not one of the choices", answer)
+	})
+
+	return chosen, err
+}
@@ -0,0 +1,182 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploystack
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/deploystack/config"
+	"github.com/GoogleCloudPlatform/deploystack/gcloud"
+)
+
+type fakeCLIClient struct{}
+
+func (fakeCLIClient) ProjectList() ([]gcloud.ProjectWithBilling, error) {
+	return []gcloud.ProjectWithBilling{
+		{Name: "Project One", ID: "ds-one", BillingEnabled: true},
+		{Name: "Project Two", ID: "ds-two", BillingEnabled: true},
+	}, nil
+}
+
+func (fakeCLIClient) ProjectNumberGet(id string) (string, error) {
+	return "123456789", nil
+}
+
+func (fakeCLIClient) RegionList(project, product string) ([]string, error) {
+	return []string{"europe-west1", "us-central1"}, nil
+}
+
+func (fakeCLIClient) ZoneList(project, region string) ([]string, error) {
+	return []string{region + "-a", region + "-b"}, nil
+}
+
+func TestCollectCLI(t *testing.T) {
+	tests := map[string]struct {
+		config   config.Config
+		settings map[string]string
+		input    []string
+		want     map[string]string
+		err      error
+	}{
+		"basic": {
+			config: config.Config{
+				Project:       true,
+				ProjectNumber: true,
+				Region:        true,
+				RegionType:    "compute",
+				RegionDefault: "us-central1",
+				Zone:          true,
+				CustomSettings: config.Customs{
+					{Name: "nodes", Description: "How many nodes?", Default: "3", Validation: "integer"},
+					{Name: "tier", Description: "Pick a tier", Options: []string{"small|Small", "large|Large"}},
+					{Name: "bucket", Description: "Bucket name", PrependProject: true},
+				},
+			},
+			input: []string{
+				"2",             // project, by number
+				"",              // region, take the default
+				"us-central1-b", // zone, by value
+				"lots",          // nodes, not an integer
+				"5",             // nodes
+				"3",             // tier, out of range
+				"large",         // tier
+				"files",         // bucket
+			},
+			want: map[string]string{
+				"project_id":     "ds-two",
+				"project_number": "123456789",
+				"region":         "us-central1",
+				"zone":           "us-central1-b",
+				"nodes":          "5",
+				"tier":           "large",
+				"bucket":         "ds-two-files",
+			},
+		},
+		"alreadySet": {
+			config: config.Config{
+				Project: true,
+				Region:  true,
+				CustomSettings: config.Customs{
+					{Name: "nodes", Description: "How many nodes?", Default: "3"},
+				},
+			},
+			settings: map[string]string{
+				"project_id": "ds-one",
+				"region":     "us-east1",
+			},
+			input: []string{""},
+			want: map[string]string{
+				"project_id": "ds-one",
+				"region":     "us-east1",
+				"nodes":      "3",
+			},
+		},
+		"integerRange": {
+			config: config.Config{
+				CustomSettings: config.Customs{
+					{Name: "nodes", Description: "How many nodes?", Validation: "integer", Min: intPtr(1), Max: intPtr(5)},
+				},
+			},
+			input: []string{
+				"9", // nodes, above the max
+				"0", // nodes, below the min
+				"4", // nodes
+			},
+			want: map[string]string{"nodes": "4"},
+		},
+		"pattern": {
+			config: config.Config{
+				CustomSettings: config.Customs{
+					{Name: "bucket", Description: "Bucket name", ValidationRegex: "[a-z][a-z0-9-]*", ValidationMessage: "Use lower case letters, digits and dashes"},
+				},
+			},
+			input: []string{
+				"My_Bucket", // bucket, doesn't match
+				"my-bucket", // bucket
+			},
+			want: map[string]string{"bucket": "my-bucket"},
+		},
+		"unsupported": {
+			config: config.Config{BillingAccount: true, Domain: true},
+			err:    ErrCLIUnsupported,
+		},
+		"ranOutOfInput": {
+			config: config.Config{
+				CustomSettings: config.Customs{
+					{Name: "nodes", Description: "How many nodes?", Validation: "integer"},
+				},
+			},
+			input: []string{"lots"},
+			err:   errors.New("could not read answer"),
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := config.NewStack()
+			s.Config = tc.config
+			for k, v := range tc.settings {
+				s.AddSetting(k, v)
+			}
+
+			in := strings.NewReader(strings.Join(tc.input, "\n") + "\n")
+			out := &bytes.Buffer{}
+
+			err := collectCLI(&s, fakeCLIClient{}, in, out)
+
+			if tc.err != nil {
+				if err == nil || !strings.Contains(err.Error(), tc.err.Error()) {
+					t.Fatalf("expected: %v got: %v", tc.err, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected: no error got: %v\n%s", err, out.String())
+			}
+
+			for k, v := range tc.want {
+				if got := s.GetSetting(k); got != v {
+					t.Fatalf("%s - expected: %s got: %s", k, v, got)
+				}
+			}
+		})
+	}
+}
+
+func intPtr(i int) *int { return &i }
@@ -8,9 +8,16 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 
+	"github.com/BurntSushi/toml"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/nyaruka/phonenumbers"
+	"github.com/zclconf/go-cty/cty"
 	"gopkg.in/src-d/go-git.v4"
 	"gopkg.in/yaml.v2"
 )
@@ -19,29 +26,121 @@ import (
 // be in a json file. The idea is minimal programming has to be done to setup
 // a DeployStack and export out a tfvars file for terraform part of solution.
 type Config struct {
-	Title                string            `json:"title" yaml:"title"`
-	Name                 string            `json:"name" yaml:"name"`
-	Description          string            `json:"description" yaml:"description"`
-	Duration             int               `json:"duration" yaml:"duration"`
-	Project              bool              `json:"collect_project" yaml:"collect_project"`
-	ProjectNumber        bool              `json:"collect_project_number" yaml:"collect_project_number"`
-	BillingAccount       bool              `json:"collect_billing_account" yaml:"collect_billing_account"`
-	Domain               bool              `json:"register_domain" yaml:"register_domain"`
-	Region               bool              `json:"collect_region" yaml:"collect_region"`
-	RegionType           string            `json:"region_type" yaml:"region_type"`
-	RegionDefault        string            `json:"region_default" yaml:"region_default"`
-	Zone                 bool              `json:"collect_zone" yaml:"collect_zone"`
-	HardSet              map[string]string `json:"hard_settings" yaml:"hard_settings"`
-	CustomSettings       Customs           `json:"custom_settings" yaml:"custom_settings"`
-	AuthorSettings       Settings          `json:"author_settings" yaml:"author_settings"`
-	ConfigureGCEInstance bool              `json:"configure_gce_instance" yaml:"configure_gce_instance"`
-	DocumentationLink    string            `json:"documentation_link" yaml:"documentation_link"`
-	PathTerraform        string            `json:"path_terraform" yaml:"path_terraform"`
-	PathMessages         string            `json:"path_messages" yaml:"path_messages"`
-	PathScripts          string            `json:"path_scripts" yaml:"path_scripts"`
-	Projects             Projects          `json:"projects" yaml:"projects"`
-	Products             []Product         `json:"products" yaml:"products"`
-	WD                   string            `json:"-" yaml:"-"`
+	Title                string            `json:"title" yaml:"title" toml:"title"`
+	Name                 string            `json:"name" yaml:"name" toml:"name"`
+	Description          string            `json:"description" yaml:"description" toml:"description"`
+	Duration             int               `json:"duration" yaml:"duration" toml:"duration"`
+	Project              bool              `json:"collect_project" yaml:"collect_project" toml:"collect_project"`
+	ProjectNumber        bool              `json:"collect_project_number" yaml:"collect_project_number" toml:"collect_project_number"`
+	BillingAccount       bool              `json:"collect_billing_account" yaml:"collect_billing_account" toml:"collect_billing_account"`
+	Domain               bool              `json:"register_domain" yaml:"register_domain" toml:"register_domain"`
+	Region               bool              `json:"collect_region" yaml:"collect_region" toml:"collect_region"`
+	RegionType           string            `json:"region_type" yaml:"region_type" toml:"region_type"`
+	RegionDefault        string            `json:"region_default" yaml:"region_default" toml:"region_default"`
+	Zone                 bool              `json:"collect_zone" yaml:"collect_zone" toml:"collect_zone"`
+	HardSet              map[string]string `json:"hard_settings" yaml:"hard_settings" toml:"hard_settings"`
+	CustomSettings       Customs           `json:"custom_settings" yaml:"custom_settings" toml:"custom_settings"`
+	AuthorSettings       Settings          `json:"author_settings" yaml:"author_settings" toml:"author_settings"`
+	ConfigureGCEInstance bool              `json:"configure_gce_instance" yaml:"configure_gce_instance" toml:"configure_gce_instance"`
+	DocumentationLink    string            `json:"documentation_link" yaml:"documentation_link" toml:"documentation_link"`
+	PathTerraform        string            `json:"path_terraform" yaml:"path_terraform" toml:"path_terraform"`
+	PathMessages         string            `json:"path_messages" yaml:"path_messages" toml:"path_messages"`
+	PathScripts          string            `json:"path_scripts" yaml:"path_scripts" toml:"path_scripts"`
+	Projects             Projects          `json:"projects" yaml:"projects" toml:"projects"`
+	Products             []Product         `json:"products" yaml:"products" toml:"products"`
+	Services             []string          `json:"required_services,omitempty" yaml:"required_services,omitempty" toml:"required_services,omitempty"`
+	ImageProjects        ImageProjects     `json:"image_projects,omitempty" yaml:"image_projects,omitempty" toml:"image_projects,omitempty"`
+	ImageProjectsAppend  bool              `json:"image_projects_append,omitempty" yaml:"image_projects_append,omitempty" toml:"image_projects_append,omitempty"`
+	FreeTierFirst        bool              `json:"free_tier_first,omitempty" yaml:"free_tier_first,omitempty" toml:"free_tier_first,omitempty"`
+	DefaultMachineType   string            `json:"default_machine_type,omitempty" yaml:"default_machine_type,omitempty" toml:"default_machine_type,omitempty"`
+	DefaultMachineFamily string            `json:"default_machine_family,omitempty" yaml:"default_machine_family,omitempty" toml:"default_machine_family,omitempty"`
+	TerraformLocals      bool              `json:"terraform_locals,omitempty" yaml:"terraform_locals,omitempty" toml:"terraform_locals,omitempty"`
+	CostLabels           bool              `json:"collect_cost_labels,omitempty" yaml:"collect_cost_labels,omitempty" toml:"collect_cost_labels,omitempty"`
+	ProjectLabels        map[string]string `json:"project_labels,omitempty" yaml:"project_labels,omitempty" toml:"project_labels,omitempty"`
+	Accelerator          bool              `json:"collect_accelerator,omitempty" yaml:"collect_accelerator,omitempty" toml:"collect_accelerator,omitempty"`
+	InstanceNetwork      bool              `json:"configure_instance_network,omitempty" yaml:"configure_instance_network,omitempty" toml:"configure_instance_network,omitempty"`
+	NetworkBeforeRegion  bool              `json:"network_before_region,omitempty" yaml:"network_before_region,omitempty" toml:"network_before_region,omitempty"`
+	SecondaryRanges      bool              `json:"collect_secondary_ranges,omitempty" yaml:"collect_secondary_ranges,omitempty" toml:"collect_secondary_ranges,omitempty"`
+	InstanceScheduling   bool              `json:"configure_instance_scheduling,omitempty" yaml:"configure_instance_scheduling,omitempty" toml:"configure_instance_scheduling,omitempty"`
+	Base                 string            `json:"base,omitempty" yaml:"base,omitempty" toml:"base,omitempty"`
+	WD                   string            `json:"-" yaml:"-" toml:"-"`
+}
+
+// ErrConfigInvalid is the error when a config fails Validate
+var ErrConfigInvalid = fmt.Errorf("invalid deploystack config")
+
+// regionTypes are the products a stack can collect a region for
+var regionTypes = []string{"compute", "functions", "run"}
+
+// Validate checks the config for mistakes that would otherwise surface late,
+// or not at all, while the stack runs, and reports all of them in one error.
+// A config without a name has to be able to compute one from the git repo it
+// is in, so that check waits until the config knows its folder.
+func (c Config) Validate() error {
+	problems := []string{}
+
+	if c.Name == "" && c.WD != "" {
+		computed := c
+		if err := computed.ComputeName(c.WD); err != nil {
+			problems = append(problems, "the stack has no name and one could not be computed from its git repo, add a 'name'")
+		}
+	}
+
+	seen := map[string]bool{}
+	for i, v := range c.CustomSettings {
+		if v.Name == "" {
+			problems = append(problems, fmt.Sprintf("custom setting %d has no name", i+1))
+			continue
+		}
+
+		if seen[v.Name] {
+			problems = append(problems, fmt.Sprintf("custom setting (%s) is declared more than once", v.Name))
+		}
+		seen[v.Name] = true
+
+		known := v.Validation == ""
+		for _, k := range validations {
+			if v.Validation == k {
+				known = true
+			}
+		}
+		if !known {
+			problems = append(problems, fmt.Sprintf("custom setting (%s) has validation (%s) which is not one of: %s", v.Name, v.Validation, strings.Join(validations, ", ")))
+		}
+
+		if (v.Min != nil || v.Max != nil) && v.Validation != ValidationInteger {
+			problems = append(problems, fmt.Sprintf("custom setting (%s) has a min or max but its validation is not %s", v.Name, ValidationInteger))
+		}
+
+		if _, err := v.Pattern(); err != nil {
+			problems = append(problems, fmt.Sprintf("custom setting (%s) has %s", v.Name, err))
+		}
+
+		if v.Min != nil && v.Max != nil && *v.Min > *v.Max {
+			problems = append(problems, fmt.Sprintf("custom setting (%s) has min (%d) greater than max (%d)", v.Name, *v.Min, *v.Max))
+		}
+	}
+
+	switch {
+	case c.RegionType == "" && c.Region:
+		problems = append(problems, fmt.Sprintf("collect_region is set but region_type is not, it should be one of: %s", strings.Join(regionTypes, ", ")))
+	case c.RegionType != "":
+		known := false
+		for _, v := range regionTypes {
+			if c.RegionType == v {
+				known = true
+			}
+		}
+		if !known {
+			problems = append(problems, fmt.Sprintf("region_type (%s) is not one of: %s", c.RegionType, strings.Join(regionTypes, ", ")))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w:\n  %s", ErrConfigInvalid, strings.Join(problems, "\n  "))
+	}
+
+	return nil
 }
 
 func (c *Config) convertHardset() {
@@ -86,6 +185,23 @@ func (c Config) Copy() Config {
 	out.PathTerraform = c.PathTerraform
 	out.PathMessages = c.PathMessages
 	out.PathScripts = c.PathScripts
+	out.FreeTierFirst = c.FreeTierFirst
+	out.DefaultMachineType = c.DefaultMachineType
+	out.DefaultMachineFamily = c.DefaultMachineFamily
+	out.TerraformLocals = c.TerraformLocals
+	out.CostLabels = c.CostLabels
+	if c.ProjectLabels != nil {
+		out.ProjectLabels = map[string]string{}
+		for k, v := range c.ProjectLabels {
+			out.ProjectLabels[k] = v
+		}
+	}
+	out.Accelerator = c.Accelerator
+	out.InstanceNetwork = c.InstanceNetwork
+	out.NetworkBeforeRegion = c.NetworkBeforeRegion
+	out.SecondaryRanges = c.SecondaryRanges
+	out.InstanceScheduling = c.InstanceScheduling
+	out.Base = c.Base
 
 	for _, v := range c.AuthorSettings {
 		out.AuthorSettings.AddComplete(v)
@@ -99,6 +215,168 @@ func (c Config) Copy() Config {
 		out.Products = append(out.Products, v)
 	}
 
+	for _, v := range c.Services {
+		out.Services = append(out.Services, v)
+	}
+
+	for _, v := range c.ImageProjects {
+		out.ImageProjects = append(out.ImageProjects, v)
+	}
+	out.ImageProjectsAppend = c.ImageProjectsAppend
+
+	return out
+}
+
+// Merge returns c with the fields set in other laid over it, for a stack
+// that extends a shared base config. Strings and numbers set in other win,
+// and booleans set to true in other turn the option on. Custom settings,
+// author settings, projects, products, services and image projects are
+// concatenated, with other's winning when both have one with the same name.
+// Hard settings and project labels are merged the same way.
+func (c Config) Merge(other Config) Config {
+	out := c
+
+	out.Title = mergeString(c.Title, other.Title)
+	out.Name = mergeString(c.Name, other.Name)
+	out.Description = mergeString(c.Description, other.Description)
+	if other.Duration != 0 {
+		out.Duration = other.Duration
+	}
+	out.RegionType = mergeString(c.RegionType, other.RegionType)
+	out.RegionDefault = mergeString(c.RegionDefault, other.RegionDefault)
+	out.DocumentationLink = mergeString(c.DocumentationLink, other.DocumentationLink)
+	out.PathTerraform = mergeString(c.PathTerraform, other.PathTerraform)
+	out.PathMessages = mergeString(c.PathMessages, other.PathMessages)
+	out.PathScripts = mergeString(c.PathScripts, other.PathScripts)
+	out.DefaultMachineType = mergeString(c.DefaultMachineType, other.DefaultMachineType)
+	out.DefaultMachineFamily = mergeString(c.DefaultMachineFamily, other.DefaultMachineFamily)
+	out.Base = mergeString(c.Base, other.Base)
+	out.WD = mergeString(c.WD, other.WD)
+
+	out.Project = c.Project || other.Project
+	out.ProjectNumber = c.ProjectNumber || other.ProjectNumber
+	out.BillingAccount = c.BillingAccount || other.BillingAccount
+	out.Domain = c.Domain || other.Domain
+	out.Region = c.Region || other.Region
+	out.Zone = c.Zone || other.Zone
+	out.ConfigureGCEInstance = c.ConfigureGCEInstance || other.ConfigureGCEInstance
+	out.ImageProjectsAppend = c.ImageProjectsAppend || other.ImageProjectsAppend
+	out.FreeTierFirst = c.FreeTierFirst || other.FreeTierFirst
+	out.TerraformLocals = c.TerraformLocals || other.TerraformLocals
+	out.CostLabels = c.CostLabels || other.CostLabels
+	out.Accelerator = c.Accelerator || other.Accelerator
+	out.InstanceNetwork = c.InstanceNetwork || other.InstanceNetwork
+	out.NetworkBeforeRegion = c.NetworkBeforeRegion || other.NetworkBeforeRegion
+	out.SecondaryRanges = c.SecondaryRanges || other.SecondaryRanges
+	out.InstanceScheduling = c.InstanceScheduling || other.InstanceScheduling
+
+	out.HardSet = mergeMap(c.HardSet, other.HardSet)
+	out.ProjectLabels = mergeMap(c.ProjectLabels, other.ProjectLabels)
+
+	out.CustomSettings = Customs{}
+	for _, v := range append(append(Customs{}, c.CustomSettings...), other.CustomSettings...) {
+		replaced := false
+		for i, existing := range out.CustomSettings {
+			if existing.Name == v.Name {
+				out.CustomSettings[i] = v
+				replaced = true
+			}
+		}
+		if !replaced {
+			out.CustomSettings = append(out.CustomSettings, v)
+		}
+	}
+
+	out.AuthorSettings = Settings{}
+	for _, v := range c.AuthorSettings {
+		out.AuthorSettings.AddComplete(v)
+	}
+	for _, v := range other.AuthorSettings {
+		out.AuthorSettings.AddComplete(v)
+	}
+
+	out.Projects = Projects{
+		AllowDuplicates:     c.Projects.AllowDuplicates || other.Projects.AllowDuplicates,
+		UniqueSuffix:        c.Projects.UniqueSuffix || other.Projects.UniqueSuffix,
+		HideBillingDisabled: c.Projects.HideBillingDisabled || other.Projects.HideBillingDisabled,
+	}
+	for _, v := range append(append([]Project{}, c.Projects.Items...), other.Projects.Items...) {
+		replaced := false
+		for i, existing := range out.Projects.Items {
+			if existing.Name == v.Name {
+				out.Projects.Items[i] = v
+				replaced = true
+			}
+		}
+		if !replaced {
+			out.Projects.Items = append(out.Projects.Items, v)
+		}
+	}
+
+	out.Products = nil
+	for _, v := range append(append([]Product{}, c.Products...), other.Products...) {
+		replaced := false
+		for i, existing := range out.Products {
+			if existing.Product == v.Product {
+				out.Products[i] = v
+				replaced = true
+			}
+		}
+		if !replaced {
+			out.Products = append(out.Products, v)
+		}
+	}
+
+	out.Services = nil
+	seen := map[string]bool{}
+	for _, v := range append(append([]string{}, c.Services...), other.Services...) {
+		if seen[v] {
+			continue
+		}
+		seen[v] = true
+		out.Services = append(out.Services, v)
+	}
+
+	out.ImageProjects = nil
+	for _, v := range append(append(ImageProjects{}, c.ImageProjects...), other.ImageProjects...) {
+		replaced := false
+		for i, existing := range out.ImageProjects {
+			if existing.Value == v.Value {
+				out.ImageProjects[i] = v
+				replaced = true
+			}
+		}
+		if !replaced {
+			out.ImageProjects = append(out.ImageProjects, v)
+		}
+	}
+
+	return out
+}
+
+// mergeString returns override, unless it is empty
+func mergeString(base, override string) string {
+	if override != "" {
+		return override
+	}
+	return base
+}
+
+// mergeMap returns the keys of both base and override, with override's
+// values winning. Two nil maps give nil.
+func mergeMap(base, override map[string]string) map[string]string {
+	if base == nil && override == nil {
+		return nil
+	}
+
+	out := map[string]string{}
+	for k, v := range base {
+		out[k] = v
+	}
+	for k, v := range override {
+		out[k] = v
+	}
+
 	return out
 }
 
@@ -139,6 +417,62 @@ func (c *Config) GetAuthorSettings() Settings {
 	return c.AuthorSettings
 }
 
+// RequiredServices returns the deduplicated list of Google Cloud APIs the
+// stack needs enabled, normalized to their full service names such as
+// `compute.googleapis.com`.
+func (c Config) RequiredServices() []string {
+	result := []string{}
+	seen := map[string]bool{}
+
+	for _, v := range c.Services {
+		name := strings.ToLower(strings.TrimSpace(v))
+		if name == "" {
+			continue
+		}
+
+		if !strings.Contains(name, ".") {
+			name = fmt.Sprintf("%s.googleapis.com", name)
+		}
+
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		result = append(result, name)
+	}
+
+	sort.Strings(result)
+
+	return result
+}
+
+// RequiredSettings returns the names of the settings the stack config itself
+// asks the user for, as opposed to the ones worked out along the way
+func (c Config) RequiredSettings() []string {
+	result := []string{}
+
+	if c.Project {
+		result = append(result, "project_id")
+	}
+	for _, v := range c.Projects.Items {
+		result = append(result, v.Name)
+	}
+	if c.BillingAccount {
+		result = append(result, "billing_account")
+	}
+	if c.Region {
+		result = append(result, "region")
+	}
+	if c.Zone {
+		result = append(result, "zone")
+	}
+	for _, v := range c.CustomSettings {
+		result = append(result, v.Name)
+	}
+
+	return result
+}
+
 // ComputeName uses the git repo in the working directory to compute the
 // shortname for the application.
 func (c *Config) ComputeName(path string) error {
@@ -185,6 +519,25 @@ func NewConfigJSON(content []byte) (Config, error) {
 		return result, fmt.Errorf("unable to convert content to Config: %s", err)
 	}
 
+	if err := result.CustomSettings.ValidateDefaults(); err != nil {
+		return result, fmt.Errorf("unable to convert content to Config: %w", err)
+	}
+
+	return result, nil
+}
+
+// NewConfigTOML returns a Config object from a file read.
+func NewConfigTOML(content []byte) (Config, error) {
+	result := Config{}
+
+	if err := toml.Unmarshal(content, &result); err != nil {
+		return result, fmt.Errorf("unable to convert content to Config: %s", err)
+	}
+
+	if err := result.CustomSettings.ValidateDefaults(); err != nil {
+		return result, fmt.Errorf("unable to convert content to Config: %w", err)
+	}
+
 	return result, nil
 }
 
@@ -196,36 +549,67 @@ func NewConfigYAML(content []byte) (Config, error) {
 		return result, fmt.Errorf("unable to convert content to Config: %s", err)
 	}
 
+	if err := result.CustomSettings.ValidateDefaults(); err != nil {
+		return result, fmt.Errorf("unable to convert content to Config: %w", err)
+	}
+
 	return result, nil
 }
 
 // Product is some info about a GCP product
 type Product struct {
-	Info    string `json:"info" yaml:"info"`
-	Product string `json:"product" yaml:"product"`
+	Info    string `json:"info" yaml:"info" toml:"info"`
+	Product string `json:"product" yaml:"product" toml:"product"`
 }
 
 // Project represets a GCP project for use in a stack
 type Project struct {
-	Name         string `json:"variable_name"  yaml:"variable_name"`
-	UserPrompt   string `json:"user_prompt"  yaml:"user_prompt"`
-	SetAsDefault bool   `json:"set_as_default"  yaml:"set_as_default"`
-	Value        string `json:"value"  yaml:"value"`
+	Name         string `json:"variable_name"  yaml:"variable_name"  toml:"variable_name"`
+	UserPrompt   string `json:"user_prompt"  yaml:"user_prompt"  toml:"user_prompt"`
+	SetAsDefault bool   `json:"set_as_default"  yaml:"set_as_default"  toml:"set_as_default"`
+	Value        string `json:"value"  yaml:"value"  toml:"value"`
 }
 
 // Projects is a list of projects that we will collect info for
 type Projects struct {
-	Items           []Project `json:"items"  yaml:"items"`
-	AllowDuplicates bool      `json:"allow_duplicates"  yaml:"allow_duplicates"`
+	Items           []Project `json:"items"  yaml:"items"  toml:"items"`
+	AllowDuplicates bool      `json:"allow_duplicates"  yaml:"allow_duplicates"  toml:"allow_duplicates"`
+	UniqueSuffix    bool      `json:"unique_suffix,omitempty"  yaml:"unique_suffix,omitempty"  toml:"unique_suffix,omitempty"`
+	// HideBillingDisabled starts project pickers with projects that don't
+	// have billing turned on hidden. Users can still show them.
+	HideBillingDisabled bool `json:"hide_billing_disabled,omitempty"  yaml:"hide_billing_disabled,omitempty"  toml:"hide_billing_disabled,omitempty"`
+}
+
+// ImageProject is a project that hosts disk images for Compute Engine, that a
+// stack wants to offer in place of, or in addition to, the public ones.
+type ImageProject struct {
+	Value   string `json:"value"  yaml:"value"  toml:"value"`
+	Label   string `json:"label"  yaml:"label"  toml:"label"`
+	Default bool   `json:"default,omitempty"  yaml:"default,omitempty"  toml:"default,omitempty"`
+}
+
+// ImageProjects is a list of image projects
+type ImageProjects []ImageProject
+
+// GetDefault returns the value of the image project marked as default, or an
+// empty string if none are.
+func (ips ImageProjects) GetDefault() string {
+	for _, v := range ips {
+		if v.Default {
+			return v.Value
+		}
+	}
+
+	return ""
 }
 
 // Setting is a item that will be translated to a variable in a terraform file
 type Setting struct {
-	Name  string            `json:"name"  yaml:"name"`
-	Value string            `json:"value"  yaml:"value"`
-	Type  string            `json:"type"  yaml:"type"`
-	List  []string          `json:"list"  yaml:"list"`
-	Map   map[string]string `json:"map"  yaml:"map"`
+	Name  string            `json:"name"  yaml:"name"  toml:"name"`
+	Value string            `json:"value"  yaml:"value"  toml:"value"`
+	Type  string            `json:"type"  yaml:"type"  toml:"type"`
+	List  []string          `json:"list"  yaml:"list"  toml:"list"`
+	Map   map[string]string `json:"map"  yaml:"map"  toml:"map"`
 }
 
 // TFVars emits the name value combination here in away that terraform excepts
@@ -240,25 +624,30 @@ func (s Setting) TFvarsName() string {
 	return name
 }
 
-// TFvarsValue formats the value for the tfvars format
+// TFvarsValue formats the value for the tfvars format. Strings, list items
+// and map values are written as HCL quoted strings, so quotes, backslashes,
+// newlines and template sequences in them are escaped.
 func (s Setting) TFvarsValue() string {
 	result := ""
 	// If we used the workaround for lists in strings, convert it to a list
-	// under the covers
-	if s.Value != "" && s.Value[0:1] == "[" {
-		replacer := strings.NewReplacer("[", "", "]", "")
-		s.List = strings.Split(replacer.Replace(s.Value), ",")
+	// under the covers. Lists added with AddList don't need it.
+	if list, ok := s.valueList(); ok {
+		s.List = list
 		s.Type = "list"
 		s.Value = ""
 	}
 
 	switch s.Type {
-	case "string", "":
-		result = fmt.Sprintf("\"%s\"", s.Value)
+	// Secrets are stored as the resource name of a Secret Manager secret, the
+	// terraform reads the value with the google_secret_manager_secret_version
+	// data source Stack.TerraformSecrets writes. Computed settings
+	// only hold an expression when they are written as locals.
+	case "string", "secret", "computed", "":
+		result = hclQuote(s.Value)
 	case "list":
 		tmp := []string{}
 		for _, v := range s.List {
-			tmp = append(tmp, fmt.Sprintf("\"%s\"", v))
+			tmp = append(tmp, hclQuote(v))
 		}
 		str := strings.Join(tmp, ",")
 
@@ -267,7 +656,11 @@ func (s Setting) TFvarsValue() string {
 		tmp := []string{}
 
 		for i, v := range s.Map {
-			tmp = append(tmp, fmt.Sprintf("%s=\"%s\"", i, v))
+			key := i
+			if !hclsyntax.ValidIdentifier(key) {
+				key = hclQuote(key)
+			}
+			tmp = append(tmp, fmt.Sprintf("%s=%s", key, hclQuote(v)))
 		}
 
 		sort.Strings(tmp)
@@ -280,6 +673,62 @@ func (s Setting) TFvarsValue() string {
 	return result
 }
 
+// tfvarsJSONValue returns the value as it goes in a terraform.tfvars.json
+// file
+func (s Setting) tfvarsJSONValue() (interface{}, error) {
+	if list, ok := s.valueList(); ok {
+		return list, nil
+	}
+
+	switch s.Type {
+	case "list":
+		if s.List == nil {
+			return []string{}, nil
+		}
+		return s.List, nil
+	case "map":
+		return s.Map, nil
+	case "boolean", "bool":
+		b, err := strconv.ParseBool(s.Value)
+		if err != nil {
+			return nil, fmt.Errorf("setting (%s) is not a valid boolean: %s", s.Name, s.Value)
+		}
+		return b, nil
+	case "number", "numeric":
+		if _, err := strconv.ParseFloat(s.Value, 64); err != nil {
+			return nil, fmt.Errorf("setting (%s) is not a valid number: %s", s.Name, s.Value)
+		}
+		return json.Number(s.Value), nil
+	}
+
+	return s.Value, nil
+}
+
+// hclQuote writes value as an HCL quoted string
+func hclQuote(value string) string {
+	return string(hclwrite.TokensForValue(cty.StringVal(value)).Bytes())
+}
+
+// valueList reads the workaround for lists in strings: a value wrapped in
+// brackets. Items are either JSON strings, which may hold commas, or bare
+// text separated by commas. Settings that are already lists are left alone.
+func (s Setting) valueList() ([]string, bool) {
+	value := s.Value
+	if s.Type == "list" || s.List != nil {
+		return nil, false
+	}
+	if !strings.HasPrefix(value, "[") || !strings.HasSuffix(value, "]") {
+		return nil, false
+	}
+
+	list := []string{}
+	if err := json.Unmarshal([]byte(value), &list); err == nil {
+		return list, true
+	}
+
+	return strings.Split(value[1:len(value)-1], ","), true
+}
+
 // Settings are a collection of setting
 type Settings []Setting
 
@@ -296,6 +745,14 @@ func (s *Settings) AddComplete(set Setting) {
 	return
 }
 
+// AddList creates or replaces a list setting, holding values as they are
+// rather than as a bracketed string. An empty list is kept, and written to
+// tfvars as [].
+func (s *Settings) AddList(key string, values []string) {
+	list := append([]string{}, values...)
+	s.AddComplete(Setting{Name: strings.ToLower(key), Type: "list", List: list})
+}
+
 // Add either creates a new setting or updates the existing one
 func (s *Settings) Add(key, value string) {
 	k := strings.ToLower(key)
@@ -360,19 +817,234 @@ func (s *Settings) Find(key string) *Setting {
 // Custom represents a custom setting that we would like to collect from a user
 // We will collect these settings from the user before continuing.
 type Custom struct {
-	Setting        `json:"-"  yaml:"-"`
-	Name           string   `json:"name"  yaml:"name"`
-	Description    string   `json:"description"  yaml:"description"`
-	Default        string   `json:"default"  yaml:"default"`
-	Options        []string `json:"options"  yaml:"options"`
-	PrependProject bool     `json:"prepend_project"  yaml:"prepend_project"`
-	Validation     string   `json:"validation,omitempty"  yaml:"validation,omitempty"`
-	Project        string   `json:"-"  yaml:"-"`
+	Setting        `json:"-"  yaml:"-"  toml:"-"`
+	Name           string   `json:"name"  yaml:"name"  toml:"name"`
+	Description    string   `json:"description"  yaml:"description"  toml:"description"`
+	Default        string   `json:"default"  yaml:"default"  toml:"default"`
+	Options        []string `json:"options"  yaml:"options"  toml:"options"`
+	PrependProject bool     `json:"prepend_project"  yaml:"prepend_project"  toml:"prepend_project"`
+	Validation     string   `json:"validation,omitempty"  yaml:"validation,omitempty"  toml:"validation,omitempty"`
+	Secret         bool     `json:"secret,omitempty"  yaml:"secret,omitempty"  toml:"secret,omitempty"`
+	StaticIP       bool     `json:"static_ip,omitempty"  yaml:"static_ip,omitempty"  toml:"static_ip,omitempty"`
+	DNSZone        bool     `json:"dns_zone,omitempty"  yaml:"dns_zone,omitempty"  toml:"dns_zone,omitempty"`
+	Min            *int     `json:"min,omitempty"  yaml:"min,omitempty"  toml:"min,omitempty"`
+	Max            *int     `json:"max,omitempty"  yaml:"max,omitempty"  toml:"max,omitempty"`
+	// ValidationRegex is a pattern answers have to match, on top of any
+	// Validation. ValidationMessage is what users are told when they don't.
+	ValidationRegex   string `json:"validation_regex,omitempty"  yaml:"validation_regex,omitempty"  toml:"validation_regex,omitempty"`
+	ValidationMessage string `json:"validation_message,omitempty"  yaml:"validation_message,omitempty"  toml:"validation_message,omitempty"`
+	Project           string `json:"-"  yaml:"-"  toml:"-"`
+}
+
+// Pattern compiles ValidationRegex to match whole answers, not just part of
+// them. It is nil when there is none.
+func (c Custom) Pattern() (*regexp.Regexp, error) {
+	if c.ValidationRegex == "" {
+		return nil, nil
+	}
+
+	re, err := regexp.Compile("^(?:" + c.ValidationRegex + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("validation_regex (%s) does not compile: %w", c.ValidationRegex, err)
+	}
+
+	return re, nil
+}
+
+// ValidateRange checks n against the setting's Min and Max, where they are
+// set, for settings with the integer validation
+func (c Custom) ValidateRange(n int) error {
+	switch {
+	case c.Min != nil && c.Max != nil && (n < *c.Min || n > *c.Max):
+		return fmt.Errorf("must be between %d and %d", *c.Min, *c.Max)
+	case c.Min != nil && n < *c.Min:
+		return fmt.Errorf("must be at least %d", *c.Min)
+	case c.Max != nil && n > *c.Max:
+		return fmt.Errorf("must be at most %d", *c.Max)
+	}
+
+	return nil
+}
+
+// The validations a custom setting can name
+const (
+	ValidationPhoneNumber = "phonenumber"
+	ValidationYesOrNo     = "yesorno"
+	ValidationInteger     = "integer"
+	ValidationEmail       = "email"
+	ValidationURL         = "url"
+)
+
+// validations are all of the validations a custom setting can name
+var validations = []string{ValidationPhoneNumber, ValidationYesOrNo, ValidationInteger, ValidationEmail, ValidationURL}
+
+// emailPattern is a practical subset of the addresses RFC 5322 allows: a
+// local part of dot separated atoms, and a domain of at least two dot
+// separated labels, with no leading, trailing or doubled dots in either.
+var emailPattern = regexp.MustCompile("^[a-zA-Z0-9!#$%&'*+/=?^_`{|}~-]+(\\.[a-zA-Z0-9!#$%&'*+/=?^_`{|}~-]+)*" +
+	"@[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(\\.[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)+$")
+
+// ValidEmail reports whether s is an email address
+func ValidEmail(s string) bool {
+	return emailPattern.MatchString(strings.TrimSpace(s))
+}
+
+// ValidateURL checks s is an http or https URL with a host, like the
+// callbacks and endpoints stacks ask for. The error says what is wrong.
+func ValidateURL(s string) error {
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil {
+		return fmt.Errorf("is not a valid URL")
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("needs to start with http:// or https://")
+	}
+
+	if u.Host == "" || u.Hostname() == "" {
+		return fmt.Errorf("has no host")
+	}
+
+	return nil
+}
+
+// ValidYesOrNo reports whether s answers yes or no, in any case. Partial
+// answers like ye count.
+func ValidYesOrNo(s string) bool {
+	text := strings.TrimSpace(strings.ToLower(s))
+	yesList := " yes y "
+	noList := " no n "
+
+	return strings.Contains(yesList+noList, text)
+}
+
+// ValidateAnswer checks input against the validation a custom setting names,
+// returning the error users are shown. No validation, or one it doesn't
+// know, accepts anything. It needs no ui, so the tui, the line prompts and
+// headless runs all check answers the same way.
+func ValidateAnswer(validation, input string) error {
+	switch validation {
+	case ValidationPhoneNumber:
+		if _, err := phonenumbers.Parse(input, "US"); err != nil {
+			return fmt.Errorf("Your answer '%s' is not a valid phone number. Please try again", input)
+		}
+	case ValidationYesOrNo:
+		if !ValidYesOrNo(input) {
+			return fmt.Errorf("Your answer '%s' is neither 'yes' nor 'no'", input)
+		}
+	case ValidationInteger:
+		if _, err := strconv.Atoi(input); err != nil {
+			return fmt.Errorf("Your answer '%s' not a valid integer", input)
+		}
+	case ValidationEmail:
+		if !ValidEmail(input) {
+			return fmt.Errorf("Your answer '%s' is not a valid email address", input)
+		}
+	case ValidationURL:
+		if strings.TrimSpace(input) == "" {
+			return fmt.Errorf("You must enter a URL, like https://example.com")
+		}
+		if err := ValidateURL(input); err != nil {
+			return fmt.Errorf("Your answer '%s' %s", input, err)
+		}
+	}
+
+	return nil
+}
+
+// ValidateAnswer checks input against everything c asks of its answers: its
+// validation, then the bounds of an integer, then its pattern
+func (c Custom) ValidateAnswer(input string) error {
+	if err := ValidateAnswer(c.Validation, input); err != nil {
+		return err
+	}
+
+	if c.Validation == ValidationInteger {
+		n, _ := strconv.Atoi(input)
+		if err := c.ValidateRange(n); err != nil {
+			return fmt.Errorf("Your answer '%s' %s", input, err)
+		}
+	}
+
+	re, err := c.Pattern()
+	if err != nil {
+		return err
+	}
+
+	if re != nil && !re.MatchString(input) {
+		if c.ValidationMessage != "" {
+			return fmt.Errorf("%s", c.ValidationMessage)
+		}
+		return fmt.Errorf("Your answer '%s' does not match the pattern %s", input, c.ValidationRegex)
+	}
+
+	return nil
+}
+
+// ErrCustomDefaultInvalid is the error when a custom setting's default fails
+// the setting's own validation
+var ErrCustomDefaultInvalid = fmt.Errorf("custom setting default does not pass its validation")
+
+// ValidateDefault checks the default of a custom setting against the
+// setting's validation, so that accepting the default can't fail. No default
+// passes any validation.
+func (c Custom) ValidateDefault() error {
+	if c.Default == "" {
+		return nil
+	}
+
+	valid := true
+	switch c.Validation {
+	case ValidationInteger:
+		n, err := strconv.Atoi(c.Default)
+		if err == nil {
+			if err := c.ValidateRange(n); err != nil {
+				return fmt.Errorf("%w: %s has default (%s) which %s", ErrCustomDefaultInvalid, c.Name, c.Default, err)
+			}
+		}
+		valid = err == nil
+	case ValidationYesOrNo:
+		switch strings.TrimSpace(strings.ToLower(c.Default)) {
+		case "yes", "y", "no", "n":
+		default:
+			valid = false
+		}
+	case ValidationPhoneNumber:
+		_, err := phonenumbers.Parse(c.Default, "US")
+		valid = err == nil
+	case ValidationEmail:
+		valid = ValidEmail(c.Default)
+	case ValidationURL:
+		valid = ValidateURL(c.Default) == nil
+	}
+
+	if !valid {
+		return fmt.Errorf("%w: %s has default (%s) which is not a valid %s", ErrCustomDefaultInvalid, c.Name, c.Default, c.Validation)
+	}
+
+	// A pattern that doesn't compile is left for Validate to report
+	if re, err := c.Pattern(); err == nil && re != nil && !re.MatchString(c.Default) {
+		return fmt.Errorf("%w: %s has default (%s) which does not match validation_regex (%s)", ErrCustomDefaultInvalid, c.Name, c.Default, c.ValidationRegex)
+	}
+
+	return nil
 }
 
 // Customs are a slice of Custom variables.
 type Customs []Custom
 
+// ValidateDefaults checks the default of every custom setting with
+// ValidateDefault, returning the first that fails
+func (cs Customs) ValidateDefaults() error {
+	for _, v := range cs {
+		if err := v.ValidateDefault(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // Get returns one Custom Variable
 func (cs Customs) Get(name string) Custom {
 	for _, v := range cs {
@@ -414,6 +1086,11 @@ func NewReport(file string) (Report, error) {
 		if err != nil {
 			return result, err
 		}
+	case ".toml":
+		result.Config, err = NewConfigTOML(dat)
+		if err != nil {
+			return result, err
+		}
 	}
 
 	return result, nil
@@ -425,8 +1102,12 @@ func FindConfigReports(dir string) ([]Report, error) {
 
 	var result []Report
 	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
 
-		if info.Name() == "deploystack.json" || info.Name() == "deploystack.yaml" {
+		switch info.Name() {
+		case "deploystack.json", "deploystack.yaml", "deploystack.toml":
 			cr, err := NewReport(path)
 			if err != nil {
 				return err
@@ -439,3 +1120,45 @@ func FindConfigReports(dir string) ([]Report, error) {
 	return result, err
 
 }
+
+// StackInfo is a stack found by DiscoverStacks
+type StackInfo struct {
+	Name  string
+	Title string
+	Path  string
+}
+
+// DiscoverStacks finds all of the stacks under root, for repos that bundle
+// more than one, by looking for their config files. Path is the folder the
+// stack runs from. Stacks without a name in their config are named after
+// that folder.
+func DiscoverStacks(root string) ([]StackInfo, error) {
+	reports, err := FindConfigReports(root)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []StackInfo{}
+	seen := map[string]bool{}
+
+	for _, v := range reports {
+		// A stack with its config in more than one format is still one stack
+		if seen[v.WD] {
+			continue
+		}
+		seen[v.WD] = true
+
+		name := v.Config.Name
+		if name == "" {
+			name = filepath.Base(v.WD)
+		}
+
+		result = append(result, StackInfo{Name: name, Title: v.Config.Title, Path: v.WD})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Path < result[j].Path
+	})
+
+	return result, nil
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/go-test/deep"
@@ -668,6 +669,157 @@ func TestFindConfigReports(t *testing.T) {
 	}
 }
 
+func TestConfigMerge(t *testing.T) {
+	base := Config{
+		Title:         "Base",
+		Name:          "base",
+		Duration:      5,
+		Project:       true,
+		Region:        true,
+		RegionType:    "compute",
+		RegionDefault: "us-central1",
+		HardSet:       map[string]string{"env": "dev", "team": "web"},
+		CustomSettings: Customs{
+			{Name: "nodes", Description: "How many nodes?", Default: "3"},
+			{Name: "tier", Description: "Pick a tier", Default: "small"},
+		},
+		Services: []string{"compute", "storage"},
+	}
+
+	override := Config{
+		Title:         "Production",
+		RegionDefault: "europe-west1",
+		Zone:          true,
+		HardSet:       map[string]string{"env": "prod"},
+		CustomSettings: Customs{
+			{Name: "tier", Description: "Pick a tier", Default: "large"},
+			{Name: "replicas", Description: "How many replicas?", Default: "2"},
+		},
+		Services: []string{"storage", "run"},
+	}
+
+	tests := map[string]struct {
+		base     Config
+		override Config
+		want     Config
+	}{
+		"scalarOverride": {
+			base:     Config{Title: "Base", Name: "base", Duration: 5, Region: true},
+			override: Config{Title: "Production", Zone: true},
+			want:     Config{Title: "Production", Name: "base", Duration: 5, Region: true, Zone: true, CustomSettings: Customs{}, AuthorSettings: Settings{}, Projects: Projects{}},
+		},
+		"listsAndCollisions": {
+			base:     base,
+			override: override,
+			want: Config{
+				Title:         "Production",
+				Name:          "base",
+				Duration:      5,
+				Project:       true,
+				Region:        true,
+				RegionType:    "compute",
+				RegionDefault: "europe-west1",
+				Zone:          true,
+				HardSet:       map[string]string{"env": "prod", "team": "web"},
+				CustomSettings: Customs{
+					{Name: "nodes", Description: "How many nodes?", Default: "3"},
+					{Name: "tier", Description: "Pick a tier", Default: "large"},
+					{Name: "replicas", Description: "How many replicas?", Default: "2"},
+				},
+				AuthorSettings: Settings{},
+				Services:       []string{"compute", "storage", "run"},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := tc.base.Merge(tc.override)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	// Merging doesn't touch either side
+	assert.Equal(t, "small", base.CustomSettings.Get("tier").Default)
+	assert.Equal(t, "dev", base.HardSet["env"])
+}
+
+func TestFindAndReadConfigBase(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"base.yaml":                          "title: Base\nname: shared\ncollect_region: true\ncustom_settings:\n  - name: tier\n    description: Pick a tier\n    default: small\n",
+		"prod/.deploystack/deploystack.yaml": "base: ../../base.yaml\ntitle: Production\ncustom_settings:\n  - name: tier\n    description: Pick a tier\n    default: large\n",
+		"loop/.deploystack/deploystack.yaml": "base: other.yaml\n",
+		"loop/.deploystack/other.yaml":       "base: deploystack.yaml\n",
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("could not set up test: %s", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("could not set up test: %s", err)
+		}
+	}
+
+	s := NewStack()
+	got, err := s.findAndReadConfig(filepath.Join(dir, "prod"))
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+
+	assert.Equal(t, "Production", got.Title)
+	assert.Equal(t, "shared", got.Name)
+	assert.True(t, got.Region)
+	assert.Equal(t, "large", got.CustomSettings.Get("tier").Default)
+	assert.Len(t, got.CustomSettings, 1)
+
+	_, err = s.findAndReadConfig(filepath.Join(dir, "loop"))
+	assert.ErrorIs(t, err, ErrConfigBaseCycle)
+}
+
+func TestDiscoverStacks(t *testing.T) {
+	root := t.TempDir()
+
+	files := map[string]string{
+		"alpha/.deploystack/deploystack.json": `{"title": "Alpha", "name": "alpha-stack"}`,
+		"alpha/.deploystack/deploystack.yaml": "title: Alpha\nname: alpha-stack\n",
+		"beta/deploystack.yaml":               "title: Beta\n",
+		"beta/terraform/main.tf":              "",
+	}
+
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("could not set up test: %s", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("could not set up test: %s", err)
+		}
+	}
+
+	want := []StackInfo{
+		{Name: "alpha-stack", Title: "Alpha", Path: filepath.Join(root, "alpha")},
+		{Name: "beta", Title: "Beta", Path: filepath.Join(root, "beta")},
+	}
+
+	got, err := DiscoverStacks(root)
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("expected: %+v, got: %+v", want, got)
+	}
+
+	_, err = DiscoverStacks(filepath.Join(root, "missing"))
+	if err == nil {
+		t.Fatalf("expected an error for a missing root, got none")
+	}
+}
+
 func TestConfigCopy(t *testing.T) {
 	tests := map[string]struct {
 		in   Config
@@ -710,6 +862,7 @@ func TestConfigCopy(t *testing.T) {
 				Products: []Product{
 					{Info: "A VM", Product: "Compute Engine"},
 				},
+				Services: []string{"compute.googleapis.com"},
 			},
 
 			want: Config{
@@ -744,6 +897,7 @@ func TestConfigCopy(t *testing.T) {
 				Products: []Product{
 					{Info: "A VM", Product: "Compute Engine"},
 				},
+				Services: []string{"compute.googleapis.com"},
 			},
 		},
 	}
@@ -964,3 +1118,442 @@ products:
 		})
 	}
 }
+
+func TestNewConfigFormats(t *testing.T) {
+	yamlContent := `
+title: Formats
+name: formats
+duration: 5
+collect_project: true
+collect_region: true
+region_type: run
+region_default: us-central1
+hard_settings:
+  basename: formats
+custom_settings:
+  - name: nodes
+    description: Nodes
+    default: "3"
+    options: ["1", "2", "3"]
+author_settings:
+  - name: color
+    value: blue
+    type: string
+projects:
+  items:
+    - variable_name: project_id
+      user_prompt: Pick a project
+      set_as_default: true
+  allow_duplicates: true
+products:
+  - info: A VM
+    product: Compute Engine
+required_services:
+  - compute.googleapis.com
+project_labels:
+  team: data
+`
+
+	jsonContent := `{
+	"title": "Formats",
+	"name": "formats",
+	"duration": 5,
+	"collect_project": true,
+	"collect_region": true,
+	"region_type": "run",
+	"region_default": "us-central1",
+	"hard_settings": {"basename": "formats"},
+	"custom_settings": [
+		{"name": "nodes", "description": "Nodes", "default": "3", "options": ["1", "2", "3"]}
+	],
+	"author_settings": [
+		{"name": "color", "value": "blue", "type": "string"}
+	],
+	"projects": {
+		"items": [
+			{"variable_name": "project_id", "user_prompt": "Pick a project", "set_as_default": true}
+		],
+		"allow_duplicates": true
+	},
+	"products": [{"info": "A VM", "product": "Compute Engine"}],
+	"required_services": ["compute.googleapis.com"],
+	"project_labels": {"team": "data"}
+}`
+
+	tomlContent := `
+title = "Formats"
+name = "formats"
+duration = 5
+collect_project = true
+collect_region = true
+region_type = "run"
+region_default = "us-central1"
+required_services = ["compute.googleapis.com"]
+
+[hard_settings]
+basename = "formats"
+
+[[custom_settings]]
+name = "nodes"
+description = "Nodes"
+default = "3"
+options = ["1", "2", "3"]
+
+[[author_settings]]
+name = "color"
+value = "blue"
+type = "string"
+
+[projects]
+allow_duplicates = true
+
+[[projects.items]]
+variable_name = "project_id"
+user_prompt = "Pick a project"
+set_as_default = true
+
+[[products]]
+info = "A VM"
+product = "Compute Engine"
+
+[project_labels]
+team = "data"
+`
+
+	fromYAML, err := NewConfigYAML([]byte(yamlContent))
+	if err != nil {
+		t.Fatalf("yaml: expected no error, got: %s", err)
+	}
+
+	fromJSON, err := NewConfigJSON([]byte(jsonContent))
+	if err != nil {
+		t.Fatalf("json: expected no error, got: %s", err)
+	}
+
+	fromTOML, err := NewConfigTOML([]byte(tomlContent))
+	if err != nil {
+		t.Fatalf("toml: expected no error, got: %s", err)
+	}
+
+	assert.Equal(t, fromYAML, fromJSON)
+	assert.Equal(t, fromYAML, fromTOML)
+	assert.Equal(t, "formats", fromTOML.HardSet["basename"])
+	assert.Equal(t, "3", fromTOML.CustomSettings[0].Default)
+}
+
+func TestNewConfigCustomDefaultValidation(t *testing.T) {
+	tests := map[string]struct {
+		validation string
+		def        string
+		err        error
+	}{
+		"integer":         {validation: "integer", def: "3"},
+		"integerInvalid":  {validation: "integer", def: "abc", err: ErrCustomDefaultInvalid},
+		"yesorno":         {validation: "yesorno", def: "Yes"},
+		"yesornoInvalid":  {validation: "yesorno", def: "maybe", err: ErrCustomDefaultInvalid},
+		"phonenumber":     {validation: "phonenumber", def: "1-555-555-4040"},
+		"phoneInvalid":    {validation: "phonenumber", def: "call me", err: ErrCustomDefaultInvalid},
+		"email":           {validation: "email", def: "person@example.com"},
+		"emailInvalid":    {validation: "email", def: "person@example.com.", err: ErrCustomDefaultInvalid},
+		"url":             {validation: "url", def: "https://example.com/hook"},
+		"urlInvalid":      {validation: "url", def: "example.com/hook", err: ErrCustomDefaultInvalid},
+		"noDefault":       {validation: "integer", def: ""},
+		"unknownValidate": {validation: "", def: "anything"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			content := fmt.Sprintf(`
+title: Validation
+custom_settings:
+  - name: nodes
+    description: Nodes
+    default: "%s"
+    validation: "%s"
+`, tc.def, tc.validation)
+
+			_, err := NewConfigYAML([]byte(content))
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("expected: %v, got: %v", tc.err, err)
+			}
+			if tc.err != nil && !strings.Contains(err.Error(), "nodes") {
+				t.Fatalf("expected the error to name the question, got: %s", err)
+			}
+		})
+	}
+}
+
+func TestCustomValidateAnswer(t *testing.T) {
+	one, ten := 1, 10
+
+	tests := map[string]struct {
+		custom Custom
+		in     string
+		err    string
+	}{
+		"none":          {custom: Custom{}, in: "anything"},
+		"integer":       {custom: Custom{Validation: ValidationInteger}, in: "12"},
+		"integerBad":    {custom: Custom{Validation: ValidationInteger}, in: "twelve", err: "Your answer 'twelve' not a valid integer"},
+		"inRange":       {custom: Custom{Validation: ValidationInteger, Min: &one, Max: &ten}, in: "5"},
+		"outOfRange":    {custom: Custom{Validation: ValidationInteger, Min: &one, Max: &ten}, in: "11", err: "Your answer '11' must be between 1 and 10"},
+		"yesorno":       {custom: Custom{Validation: ValidationYesOrNo}, in: "Y"},
+		"yesornoBad":    {custom: Custom{Validation: ValidationYesOrNo}, in: "maybe", err: "Your answer 'maybe' is neither 'yes' nor 'no'"},
+		"phonenumber":   {custom: Custom{Validation: ValidationPhoneNumber}, in: "800 555 1234"},
+		"email":         {custom: Custom{Validation: ValidationEmail}, in: "person@example.com"},
+		"urlEmpty":      {custom: Custom{Validation: ValidationURL}, in: " ", err: "You must enter a URL, like https://example.com"},
+		"pattern":       {custom: Custom{ValidationRegex: "bucket-[0-9]+"}, in: "bucket-12"},
+		"patternBad":    {custom: Custom{ValidationRegex: "bucket-[0-9]+", ValidationMessage: "Use bucket-1"}, in: "bucket-x", err: "Use bucket-1"},
+		"validateFirst": {custom: Custom{Validation: ValidationInteger, ValidationRegex: "[0-9]{2}"}, in: "ab", err: "Your answer 'ab' not a valid integer"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := tc.custom.ValidateAnswer(tc.in)
+			if tc.err == "" {
+				assert.Nil(t, err)
+				return
+			}
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := map[string]struct {
+		config   Config
+		setwd    bool
+		contains []string
+	}{
+		"valid": {
+			config: Config{
+				Name:       "valid",
+				Region:     true,
+				RegionType: "run",
+				CustomSettings: Customs{
+					{Name: "nodes", Validation: ValidationInteger},
+					{Name: "public", Validation: ValidationYesOrNo},
+				},
+			},
+		},
+		"noNameNoWD": {
+			config: Config{Title: "No name yet"},
+		},
+		"noNameNoGit": {
+			config:   Config{Title: "No name"},
+			setwd:    true,
+			contains: []string{"no name and one could not be computed"},
+		},
+		"duplicateCustom": {
+			config: Config{
+				Name: "duplicate",
+				CustomSettings: Customs{
+					{Name: "nodes"},
+					{Name: "nodes"},
+				},
+			},
+			contains: []string{"custom setting (nodes) is declared more than once"},
+		},
+		"unnamedCustom": {
+			config: Config{
+				Name:           "unnamed",
+				CustomSettings: Customs{{Description: "Nodes"}},
+			},
+			contains: []string{"custom setting 1 has no name"},
+		},
+		"unknownValidation": {
+			config: Config{
+				Name:           "validation",
+				CustomSettings: Customs{{Name: "nodes", Validation: "number"}},
+			},
+			contains: []string{"custom setting (nodes) has validation (number) which is not one of: phonenumber, yesorno, integer, email, url"},
+		},
+		"minOverMax": {
+			config: Config{
+				Name:           "range",
+				CustomSettings: Customs{{Name: "nodes", Validation: ValidationInteger, Min: intPtr(10), Max: intPtr(1)}},
+			},
+			contains: []string{"custom setting (nodes) has min (10) greater than max (1)"},
+		},
+		"rangeNotInteger": {
+			config: Config{
+				Name:           "range",
+				CustomSettings: Customs{{Name: "nodes", Min: intPtr(1)}},
+			},
+			contains: []string{"custom setting (nodes) has a min or max but its validation is not integer"},
+		},
+		"badRegex": {
+			config: Config{
+				Name:           "regex",
+				CustomSettings: Customs{{Name: "bucket", ValidationRegex: "bucket-[0-9"}},
+			},
+			contains: []string{"custom setting (bucket) has validation_regex (bucket-[0-9) does not compile"},
+		},
+		"unknownRegionType": {
+			config:   Config{Name: "region", Region: true, RegionType: "gke"},
+			contains: []string{"region_type (gke) is not one of: compute, functions, run"},
+		},
+		"missingRegionType": {
+			config:   Config{Name: "region", Region: true},
+			contains: []string{"collect_region is set but region_type is not"},
+		},
+		"several": {
+			config: Config{
+				Name:       "several",
+				RegionType: "gke",
+				CustomSettings: Customs{
+					{Name: "nodes", Validation: "number"},
+					{Name: "nodes"},
+				},
+			},
+			contains: []string{
+				"custom setting (nodes) has validation (number)",
+				"custom setting (nodes) is declared more than once",
+				"region_type (gke)",
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := tc.config
+			if tc.setwd {
+				c.Setwd(t.TempDir())
+			}
+
+			err := c.Validate()
+
+			if len(tc.contains) == 0 {
+				if err != nil {
+					t.Fatalf("expected: no error, got: %v", err)
+				}
+				return
+			}
+
+			if !errors.Is(err, ErrConfigInvalid) {
+				t.Fatalf("expected: %v, got: %v", ErrConfigInvalid, err)
+			}
+
+			for _, v := range tc.contains {
+				if !strings.Contains(err.Error(), v) {
+					t.Fatalf("expected the error to contain %q, got: %s", v, err)
+				}
+			}
+		})
+	}
+}
+
+func intPtr(i int) *int { return &i }
+
+func TestNewConfigCustomRange(t *testing.T) {
+	tests := map[string]struct {
+		def string
+		err error
+	}{
+		"inRange":  {def: "5"},
+		"belowMin": {def: "0", err: ErrCustomDefaultInvalid},
+		"aboveMax": {def: "11", err: ErrCustomDefaultInvalid},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			content := fmt.Sprintf(`
+title: Range
+custom_settings:
+  - name: nodes
+    description: Nodes
+    default: "%s"
+    validation: integer
+    min: 1
+    max: 10
+`, tc.def)
+
+			c, err := NewConfigYAML([]byte(content))
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("expected: %v, got: %v", tc.err, err)
+			}
+			if tc.err != nil {
+				if !strings.Contains(err.Error(), "must be between 1 and 10") {
+					t.Fatalf("expected the error to give the range, got: %s", err)
+				}
+				return
+			}
+
+			assert.Equal(t, intPtr(1), c.CustomSettings[0].Min)
+			assert.Equal(t, intPtr(10), c.CustomSettings[0].Max)
+		})
+	}
+}
+
+func TestConfigRequiredServices(t *testing.T) {
+	tests := map[string]struct {
+		in   Config
+		want []string
+	}{
+		"empty": {
+			in:   Config{},
+			want: []string{},
+		},
+		"normalized": {
+			in: Config{Services: []string{
+				"run",
+				"compute.googleapis.com",
+				" Compute ",
+				"",
+				"cloudbuild.googleapis.com",
+			}},
+			want: []string{
+				"cloudbuild.googleapis.com",
+				"compute.googleapis.com",
+				"run.googleapis.com",
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := tc.in.RequiredServices()
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestConfigImageProjects(t *testing.T) {
+	tests := map[string]struct {
+		in          string
+		want        ImageProjects
+		wantDefault string
+	}{
+		"none": {
+			in:          "title: test\n",
+			want:        nil,
+			wantDefault: "",
+		},
+		"override": {
+			in: `title: test
+image_projects:
+  - value: ubuntu-os-cloud
+    label: Ubuntu
+  - value: my-private-images
+    label: Private Images
+    default: true
+`,
+			want: ImageProjects{
+				{Value: "ubuntu-os-cloud", Label: "Ubuntu"},
+				{Value: "my-private-images", Label: "Private Images", Default: true},
+			},
+			wantDefault: "my-private-images",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			c, err := NewConfigYAML([]byte(tc.in))
+			if err != nil {
+				t.Fatalf("expected: no error, got: %s", err)
+			}
+
+			assert.Equal(t, tc.want, c.ImageProjects)
+			assert.Equal(t, tc.wantDefault, c.ImageProjects.GetDefault())
+		})
+	}
+}
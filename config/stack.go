@@ -15,18 +15,51 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"sort"
 	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	"gopkg.in/yaml.v2"
 )
 
 // Stack represents the input config and output settings for this DeployStack
 type Stack struct {
 	Settings Settings
 	Config   Config
+	// ValidateTerraform makes TerraformFile check the output parses as HCL
+	// before writing it
+	ValidateTerraform bool
+	// WorkDir is where generated files are written. When empty they go in
+	// the directory the config was read from.
+	WorkDir string
+
+	// overrides are the names of the settings ApplyEnvOverrides set
+	overrides map[string]bool
+}
+
+// OutputPath resolves the name of a generated file to where it should be
+// written, keeping each stack's files together in its work dir. Absolute
+// paths are left alone.
+func (s Stack) OutputPath(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+
+	dir := s.WorkDir
+	if dir == "" {
+		dir = s.Config.WD
+	}
+
+	return filepath.Join(dir, name)
 }
 
 // NewStack returns an initialized Stack
@@ -36,13 +69,33 @@ func NewStack() Stack {
 	return s
 }
 
+const (
+	// EnvConfig is the environment variable that names the config file to
+	// read, instead of looking for one in the stack folder, so the config can
+	// be kept outside of the repo.
+	EnvConfig = "DEPLOYSTACK_CONFIG"
+
+	// EnvTheme is the environment variable that names the color theme the
+	// tui draws with, light or dark.
+	EnvTheme = "DEPLOYSTACK_THEME"
+)
+
 func (s *Stack) findAndReadConfig(path string) (Config, error) {
 	config := Config{}
 
+	if configPath := os.Getenv(EnvConfig); configPath != "" {
+		if _, err := os.Stat(configPath); err != nil {
+			return config, fmt.Errorf("%w: %s set to (%s) which does not exist", ErrConfigNotExist, EnvConfig, configPath)
+		}
+		return readConfigFile(configPath, map[string]bool{})
+	}
+
 	candidates := []string{
 		".deploystack/deploystack.yaml",
 		".deploystack/deploystack.json",
+		".deploystack/deploystack.toml",
 		"deploystack.json",
+		"deploystack.toml",
 	}
 
 	configPath := ""
@@ -59,32 +112,66 @@ func (s *Stack) findAndReadConfig(path string) (Config, error) {
 		return config, ErrConfigNotExist
 	}
 
-	content, err := ioutil.ReadFile(configPath)
+	return readConfigFile(configPath, map[string]bool{})
+}
+
+// readConfigFile reads the config at path, in the format its extension
+// names, and the base config it extends, if it has one. A relative base is
+// found from the folder the config is in. seen holds the configs already read
+// on the way, to catch configs that extend each other.
+func readConfigFile(path string, seen map[string]bool) (Config, error) {
+	config := Config{}
+
+	abs, err := filepath.Abs(path)
 	if err != nil {
-		return config, fmt.Errorf("unable to find or read config (%s) file: %s", configPath, err)
+		return config, fmt.Errorf("unable to find or read config (%s) file: %s", path, err)
+	}
+	if seen[abs] {
+		return config, fmt.Errorf("%w: %s", ErrConfigBaseCycle, path)
 	}
+	seen[abs] = true
 
-	switch filepath.Ext(configPath) {
-	case ".yaml":
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("unable to find or read config (%s) file: %s", path, err)
+	}
+
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
 		config, err = NewConfigYAML(content)
-		if err != nil {
-			return config, fmt.Errorf("unable to parse config file: %s", err)
-		}
-		return config, nil
+	case ".toml":
+		config, err = NewConfigTOML(content)
 	default:
 		config, err = NewConfigJSON(content)
-		if err != nil {
-			return config, fmt.Errorf("unable to parse config file: %s", err)
-		}
+	}
+	if err != nil {
+		return config, fmt.Errorf("unable to parse config file: %s", err)
+	}
+
+	if config.Base == "" {
+		return config, nil
+	}
 
+	basePath := config.Base
+	if !filepath.IsAbs(basePath) {
+		basePath = filepath.Join(filepath.Dir(path), basePath)
 	}
-	return config, nil
+
+	base, err := readConfigFile(basePath, seen)
+	if err != nil {
+		return config, fmt.Errorf("unable to read base config (%s): %w", config.Base, err)
+	}
+
+	return base.Merge(config), nil
 }
 
 // ErrConfigNotExist is what happens when a config file either does not exist
 // or exists but is not readable.
 var ErrConfigNotExist = fmt.Errorf("could not find and parse a config file")
 
+// ErrConfigBaseCycle is the error when configs name each other as their base
+var ErrConfigBaseCycle = fmt.Errorf("config extends itself")
+
 func (s *Stack) findDSFolder(path, folder string) (string, error) {
 	switch folder {
 	case "messages":
@@ -187,9 +274,14 @@ func (s *Stack) FindAndRead(path string, required bool) error {
 	return nil
 }
 
-// FindAndReadRequired finds and reads in a Config from a json file.
+// FindAndReadRequired finds and reads in a Config from a json file, and
+// checks it with Config.Validate.
 func (s *Stack) FindAndReadRequired(path string) error {
-	return s.FindAndRead(path, true)
+	if err := s.FindAndRead(path, true); err != nil {
+		return err
+	}
+
+	return s.Config.Validate()
 }
 
 // AddSetting stores a setting key/value pair.
@@ -197,6 +289,182 @@ func (s *Stack) AddSetting(key, value string) {
 	s.Settings.Add(key, value)
 }
 
+// AddListSetting stores a list setting, see Settings.AddList
+func (s *Stack) AddListSetting(key string, values []string) {
+	s.Settings.AddList(key, values)
+}
+
+// EnvPrefix is the prefix of the environment variables the deploystack
+// command reads setting overrides from, see ApplyEnvOverrides
+const EnvPrefix = "DEPLOYSTACK_"
+
+// ApplyEnvOverrides sets a setting for every environment variable whose name
+// starts with prefix, so settings can be supplied without being asked for.
+// The rest of the variable name, lowercased, is the setting name: with the
+// prefix DEPLOYSTACK_, DEPLOYSTACK_PROJECT_ID sets project_id. Variable
+// names can't hold -, so a setting the stack or its config already knows
+// whose name matches with its - and spaces read as _ is set under its own
+// name, and the _ in other Compute Engine settings, which all start with
+// instance-, become -: DEPLOYSTACK_INSTANCE_MACHINE_TYPE sets
+// instance-machine-type. Overrides take precedence over the values the
+// author hard set.
+func (s *Stack) ApplyEnvOverrides(prefix string) {
+	s.Config.convertHardset()
+
+	known := map[string]string{}
+	addKnown := func(name string) {
+		name = strings.ToLower(name)
+		known[envSettingName(name)] = name
+	}
+	for _, v := range s.Config.AuthorSettings {
+		addKnown(v.Name)
+	}
+	for _, v := range s.Config.CustomSettings {
+		addKnown(v.Name)
+	}
+	for _, v := range s.Config.Projects.Items {
+		addKnown(v.Name)
+	}
+	for _, v := range s.Settings {
+		addKnown(v.Name)
+	}
+
+	for _, v := range os.Environ() {
+		key, value, _ := strings.Cut(v, "=")
+		if !strings.HasPrefix(key, prefix) || key == prefix || key == EnvConfig || key == EnvTheme {
+			continue
+		}
+
+		name := strings.ToLower(strings.TrimPrefix(key, prefix))
+		if k, ok := known[name]; ok {
+			name = k
+		} else if strings.HasPrefix(name, "instance_") {
+			name = strings.ReplaceAll(name, "_", "-")
+		}
+
+		s.AddSetting(name, value)
+		if s.overrides == nil {
+			s.overrides = map[string]bool{}
+		}
+		s.overrides[name] = true
+
+		// The author settings are copied onto the stack again when the flow
+		// starts, so they have to carry the override as well
+		if set := s.Config.AuthorSettings.Find(name); set != nil {
+			set.Value = value
+			s.Config.AuthorSettings.Replace(*set)
+		}
+	}
+}
+
+// IsOverridden reports whether ApplyEnvOverrides set the setting name, so it
+// shouldn't be asked for or replaced with a default
+func (s *Stack) IsOverridden(name string) bool {
+	return s.overrides[name]
+}
+
+// envSettingName is how a setting name reads as the end of an environment
+// variable name, lowercased
+func envSettingName(name string) string {
+	return strings.NewReplacer("-", "_", " ", "_").Replace(strings.ToLower(name))
+}
+
+// ErrScriptOutsideFolder is the error when RunScript is asked for a script
+// that isn't in the scripts folder
+var ErrScriptOutsideFolder = fmt.Errorf("script is not in the scripts folder")
+
+// ScriptPath resolves the script name to its path in the stack's scripts
+// folder. Names that lead out of the folder, by way of .. or a link, are
+// refused with ErrScriptOutsideFolder.
+func (s Stack) ScriptPath(name string) (string, error) {
+	dir := s.Config.PathScripts
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(s.Config.WD, dir)
+	}
+
+	if filepath.IsAbs(name) {
+		return "", fmt.Errorf("%w: %s", ErrScriptOutsideFolder, name)
+	}
+
+	script := filepath.Join(dir, name)
+
+	// Links are followed, so the check is on where the script really is
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", fmt.Errorf("could not find scripts folder: %w", err)
+	}
+	realScript, err := filepath.EvalSymlinks(script)
+	if err != nil {
+		return "", fmt.Errorf("could not find script (%s): %w", name, err)
+	}
+
+	rel, err := filepath.Rel(realDir, realScript)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %s", ErrScriptOutsideFolder, name)
+	}
+
+	return script, nil
+}
+
+// ScriptEnv returns the settings as environment variables for a script, with
+// names upper cased and - and spaces turned to _: project_id is PROJECT_ID and
+// instance-machine-type is INSTANCE_MACHINE_TYPE. Lists are joined with
+// commas, and maps are written as JSON objects.
+func (s Stack) ScriptEnv() []string {
+	env := []string{}
+
+	for _, v := range s.Settings {
+		value := v.Value
+		switch {
+		case v.List != nil:
+			value = strings.Join(v.List, ",")
+		case v.Map != nil:
+			dat, err := json.Marshal(v.Map)
+			if err != nil {
+				continue
+			}
+			value = string(dat)
+		}
+
+		env = append(env, fmt.Sprintf("%s=%s", strings.ToUpper(envSettingName(v.Name)), value))
+	}
+
+	return env
+}
+
+// RunScriptOutput runs the script name from the stack's scripts folder, in
+// the stack's folder, with the settings added to its environment by
+// ScriptEnv. It returns everything the script wrote to stdout and stderr.
+func (s Stack) RunScriptOutput(name string) ([]byte, error) {
+	script, err := s.ScriptPath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(script)
+	cmd.Dir = s.Config.WD
+	cmd.Env = append(os.Environ(), s.ScriptEnv()...)
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return out, fmt.Errorf("script (%s) failed: %w", name, err)
+	}
+
+	return out, nil
+}
+
+// RunScript runs a script from the stack's scripts folder, say after the
+// stack is deployed, as RunScriptOutput does. If the script fails, what it
+// wrote is part of the error.
+func (s Stack) RunScript(name string) error {
+	out, err := s.RunScriptOutput(name)
+	if err != nil && len(out) > 0 {
+		return fmt.Errorf("%w\n%s", err, out)
+	}
+
+	return err
+}
+
 // AddSettingComplete passes a completely intact setting to the underlying
 // setting structure
 func (s *Stack) AddSettingComplete(set Setting) {
@@ -224,10 +492,88 @@ func (s *Stack) DeleteSetting(key string) {
 
 }
 
+// Reset clears all of the collected settings, keeping the config, and puts
+// back the settings the author hard set so the stack can be collected again
+// from a clean slate.
+func (s *Stack) Reset() {
+	s.Settings = Settings{}
+
+	s.Config.defaultAuthorSettings()
+	for _, v := range s.Config.GetAuthorSettings() {
+		s.AddSettingComplete(v)
+	}
+}
+
+// Kinds of SettingChange
+const (
+	SettingAdded   = "added"
+	SettingChanged = "changed"
+	SettingRemoved = "removed"
+)
+
+// SettingChange describes how a single setting differs from a previous run
+type SettingChange struct {
+	Name string
+	Kind string
+	Old  string
+	New  string
+}
+
+// Diff compares the stack settings against the settings of a previous run,
+// and returns the ones that were added, changed or removed, ordered by name.
+// Values are compared as they would be written to tfvars, so lists and maps
+// compare by content.
+func (s Stack) Diff(previous Settings) []SettingChange {
+	current := map[string]string{}
+	for _, v := range s.Settings {
+		current[v.Name] = v.TFvarsValue()
+	}
+
+	old := map[string]string{}
+	for _, v := range previous {
+		old[v.Name] = v.TFvarsValue()
+	}
+
+	result := []SettingChange{}
+
+	for name, value := range current {
+		prev, ok := old[name]
+		switch {
+		case !ok:
+			result = append(result, SettingChange{Name: name, Kind: SettingAdded, New: value})
+		case prev != value:
+			result = append(result, SettingChange{Name: name, Kind: SettingChanged, Old: prev, New: value})
+		}
+	}
+
+	for name, value := range old {
+		if _, ok := current[name]; !ok {
+			result = append(result, SettingChange{Name: name, Kind: SettingRemoved, Old: value})
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result
+}
+
 // Terraform returns all of the settings as a Terraform variables format.
 func (s Stack) Terraform() string {
 	result := strings.Builder{}
 
+	for _, v := range s.tfvars() {
+		result.WriteString(v.TFVars())
+	}
+
+	return result.String()
+}
+
+// tfvars returns the settings, sorted, that go in the tfvars file
+func (s Stack) tfvars() Settings {
+	result := Settings{}
+
 	s.Settings.Sort()
 
 	for _, v := range s.Settings {
@@ -244,20 +590,413 @@ func (s Stack) Terraform() string {
 			continue
 		}
 
-		if len(v.Value) == 0 && len(v.List) == 0 && v.Map == nil {
+		if len(v.Value) == 0 && v.List == nil && v.Map == nil {
 			continue
 		}
 
-		result.WriteString(v.TFVars())
+		if v.Type == "computed" && s.Config.TerraformLocals {
+			continue
+		}
 
+		result = append(result, v)
 	}
 
-	return result.String()
+	return result
+}
+
+// TerraformJSON returns the settings as the object of a
+// terraform.tfvars.json file, with the same names and skipping the same
+// settings as Terraform. Boolean and number settings are written as JSON
+// booleans and numbers, lists as arrays and maps as objects.
+func (s Stack) TerraformJSON() (string, error) {
+	result := map[string]interface{}{}
+
+	for _, v := range s.tfvars() {
+		value, err := v.tfvarsJSONValue()
+		if err != nil {
+			return "", err
+		}
+		result[v.TFvarsName()] = value
+	}
+
+	dat, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("could not convert settings to json: %w", err)
+	}
+
+	return string(dat) + "\n", nil
+}
+
+// TerraformFileJSON writes TerraformJSON to filename, under the stack's work
+// dir. Like TerraformFile, computed settings written as locals go to
+// LocalsFile, and the data sources for secret settings go to SecretsFile.
+func (s Stack) TerraformFileJSON(filename string) error {
+	content, err := s.TerraformJSON()
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(s.OutputPath(filename), []byte(content), 0o644); err != nil {
+		return err
+	}
+
+	return s.terraformFileExtras()
+}
+
+// SettingsJSON returns every setting on the stack as JSON, sorted by name so
+// the same settings always give the same output. Unlike TerraformJSON, the
+// settings are kept as they are: names aren't changed and nothing, like
+// project_name or stack_name, is left out. LoadSettingsJSON reads it back.
+func (s Stack) SettingsJSON() ([]byte, error) {
+	settings := append(Settings{}, s.Settings...)
+	settings.Sort()
+
+	dat, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("could not convert settings to json: %w", err)
+	}
+
+	return append(dat, '\n'), nil
+}
+
+// SettingsFileJSON writes SettingsJSON to filename, under the stack's work
+// dir
+func (s Stack) SettingsFileJSON(filename string) error {
+	content, err := s.SettingsJSON()
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(s.OutputPath(filename), content, 0o644)
+}
+
+// LoadSettingsJSON reads settings written by SettingsJSON
+func LoadSettingsJSON(content []byte) (Settings, error) {
+	result := Settings{}
+
+	if err := json.Unmarshal(content, &result); err != nil {
+		return nil, fmt.Errorf("could not read settings from json: %w", err)
+	}
+
+	return result, nil
+}
+
+// DeploymentManagerTemplate is the template DeploymentManagerConfig imports
+// and passes the settings to as properties
+const DeploymentManagerTemplate = "deploystack.jinja"
+
+type dmConfig struct {
+	Imports   []dmImport   `yaml:"imports"`
+	Resources []dmResource `yaml:"resources"`
+}
+
+type dmImport struct {
+	Path string `yaml:"path"`
+}
+
+type dmResource struct {
+	Name       string                 `yaml:"name"`
+	Type       string                 `yaml:"type"`
+	Properties map[string]interface{} `yaml:"properties"`
+}
+
+// DeploymentManagerConfig returns the settings as a Deployment Manager
+// config, for stacks that deploy with Deployment Manager rather than
+// Terraform. It has a single resource, named after the stack, of the type
+// DeploymentManagerTemplate, with the same settings Terraform writes as its
+// properties. Boolean and number settings are written as YAML booleans and
+// numbers, lists as sequences and maps as mappings.
+func (s Stack) DeploymentManagerConfig() (string, error) {
+	name := s.Config.Name
+	if name == "" {
+		name = "deploystack"
+	}
+
+	resource := dmResource{
+		Name:       name,
+		Type:       DeploymentManagerTemplate,
+		Properties: map[string]interface{}{},
+	}
+
+	for _, v := range s.tfvars() {
+		value, err := v.tfvarsJSONValue()
+		if err != nil {
+			return "", err
+		}
+
+		// yaml quotes a json.Number like any other string
+		if num, ok := value.(json.Number); ok {
+			if i, err := num.Int64(); err == nil {
+				value = i
+			} else if f, err := num.Float64(); err == nil {
+				value = f
+			}
+		}
+
+		resource.Properties[v.TFvarsName()] = value
+	}
+
+	dat, err := yaml.Marshal(dmConfig{
+		Imports:   []dmImport{{Path: DeploymentManagerTemplate}},
+		Resources: []dmResource{resource},
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not convert settings to yaml: %w", err)
+	}
+
+	return string(dat), nil
+}
+
+// LocalsFile is where TerraformFile writes computed settings when the
+// config asks for them as Terraform locals, in the PathTerraform folder so
+// the stack's terraform reads it. The name is one authors are unlikely to
+// use themselves.
+const LocalsFile = "deploystack_locals.tf"
+
+// generatedHeader starts every terraform file DeployStack writes, marking it
+// as one that can be replaced
+const generatedHeader = "# Generated by DeployStack. Changes to this file are overwritten.\n"
+
+// ErrNotGenerated is returned instead of overwriting a file DeployStack
+// didn't write
+var ErrNotGenerated = fmt.Errorf("file was not generated by DeployStack")
+
+// terraformPath returns where a terraform file DeployStack generates, named
+// name, goes: the PathTerraform folder, under the stack's work dir
+func (s Stack) terraformPath(name string) string {
+	return s.OutputPath(filepath.Join(s.Config.PathTerraform, name))
+}
+
+// writeGenerated writes content to path, after generatedHeader. A file
+// already at path is only replaced if DeployStack wrote it.
+func writeGenerated(path, content string) error {
+	existing, err := os.ReadFile(path)
+	if err == nil && !strings.HasPrefix(string(existing), generatedHeader) {
+		return fmt.Errorf("%w: %s", ErrNotGenerated, path)
+	}
+
+	return os.WriteFile(path, []byte(generatedHeader+content), 0o644)
+}
+
+// removeGenerated deletes the file at path if DeployStack wrote it, so one
+// left from an earlier run doesn't outlive the settings it was written for.
+// A missing file, or one the author wrote, is left alone.
+func removeGenerated(path string) error {
+	existing, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	if !strings.HasPrefix(string(existing), generatedHeader) {
+		return nil
+	}
+
+	return os.Remove(path)
+}
+
+// TerraformLocals returns the computed settings as a Terraform locals block,
+// with each value written as the expression it holds. It is empty unless
+// the config sets TerraformLocals and there are computed settings.
+func (s Stack) TerraformLocals() string {
+	if !s.Config.TerraformLocals {
+		return ""
+	}
+
+	s.Settings.Sort()
+
+	result := strings.Builder{}
+	for _, v := range s.Settings {
+		if v.Type != "computed" || v.Name == "" || v.Value == "" {
+			continue
+		}
+		result.WriteString(fmt.Sprintf("  %s = %s\n", v.TFvarsName(), v.Value))
+	}
+
+	if result.Len() == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("locals {\n%s}\n", result.String())
+}
+
+// SecretsFile is where TerraformFile writes the data sources that read the
+// values of secret settings, in the PathTerraform folder next to LocalsFile
+const SecretsFile = "deploystack_secrets.tf"
+
+// TerraformSecrets returns a google_secret_manager_secret_version data source
+// for each secret setting, named after the setting. The stack's terraform
+// reads the value from data.google_secret_manager_secret_version.NAME, so it
+// never ends up in the tfvars. It is empty if there are no secret settings.
+func (s Stack) TerraformSecrets() string {
+	s.Settings.Sort()
+
+	blocks := []string{}
+	for _, v := range s.Settings {
+		if v.Type != "secret" || v.Name == "" || v.Value == "" {
+			continue
+		}
+		blocks = append(blocks, fmt.Sprintf("data \"google_secret_manager_secret_version\" \"%s\" {\n  secret = \"%s\"\n}\n", v.TFvarsName(), v.Value))
+	}
+
+	return strings.Join(blocks, "\n")
+}
+
+// ValidateConsistency checks the collected settings against each other and
+// against the config, returning a warning for each thing that looks off.
+// These don't stop a stack from being written, but are worth a second look.
+func (s Stack) ValidateConsistency() []string {
+	warnings := []string{}
+
+	region := s.GetSetting("region")
+	zone := s.GetSetting("zone")
+	if region != "" && zone != "" && !strings.HasPrefix(zone, region+"-") {
+		warnings = append(warnings, fmt.Sprintf("zone (%s) is not in region (%s)", zone, region))
+	}
+
+	for _, v := range s.Config.CustomSettings {
+		// Prepended values can't match their options
+		if len(v.Options) == 0 || v.PrependProject {
+			continue
+		}
+
+		value := s.GetSetting(v.Name)
+		if value == "" {
+			continue
+		}
+
+		found := false
+		for _, o := range v.Options {
+			if strings.Split(o, "|")[0] == value {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			warnings = append(warnings, fmt.Sprintf("%s (%s) is not one of its options", v.Name, value))
+		}
+	}
+
+	return warnings
 }
 
-// TerraformFile exports TFVars format to input file.
+// ErrInvalidTerraform is returned when the generated tfvars cannot be parsed
+var ErrInvalidTerraform = fmt.Errorf("generated terraform does not parse as HCL")
+
+// TerraformValidate checks that the output of Terraform parses as HCL, to
+// catch settings whose values do not survive serialization.
+func (s Stack) TerraformValidate() error {
+	_, diags := hclsyntax.ParseConfig([]byte(s.Terraform()), "terraform.tfvars", hcl.InitialPos)
+	if diags.HasErrors() {
+		return fmt.Errorf("%w: %s", ErrInvalidTerraform, diags.Error())
+	}
+
+	return nil
+}
+
+// ReadTFVars reads the settings back out of a tfvars file, like the one
+// TerraformFile writes, so that an earlier run's answers can be reused.
+func ReadTFVars(filename string) (Settings, error) {
+	dat, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	f, diags := hclsyntax.ParseConfig(dat, filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidTerraform, diags.Error())
+	}
+
+	attrs, diags := f.Body.JustAttributes()
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidTerraform, diags.Error())
+	}
+
+	result := Settings{}
+	for name, attr := range attrs {
+		val, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidTerraform, diags.Error())
+		}
+
+		set, err := tfvarsSetting(name, val)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, set)
+	}
+	result.Sort()
+
+	return result, nil
+}
+
+// tfvarsSetting turns a value read from a tfvars file into the setting
+// that would have written it
+func tfvarsSetting(name string, val cty.Value) (Setting, error) {
+	set := Setting{Name: name, Type: "string"}
+
+	if val.IsNull() {
+		return set, nil
+	}
+
+	toString := func(v cty.Value) (string, error) {
+		str, err := convert.Convert(v, cty.String)
+		if err != nil || str.IsNull() {
+			return "", fmt.Errorf("setting (%s) holds a value that isn't a string: %s", name, v.GoString())
+		}
+		return str.AsString(), nil
+	}
+
+	ty := val.Type()
+	switch {
+	case ty.IsListType() || ty.IsTupleType() || ty.IsSetType():
+		set.Type = "list"
+		set.List = []string{}
+		for it := val.ElementIterator(); it.Next(); {
+			_, v := it.Element()
+			str, err := toString(v)
+			if err != nil {
+				return set, err
+			}
+			set.List = append(set.List, str)
+		}
+	case ty.IsMapType() || ty.IsObjectType():
+		set.Type = "map"
+		set.Map = map[string]string{}
+		for it := val.ElementIterator(); it.Next(); {
+			k, v := it.Element()
+			str, err := toString(v)
+			if err != nil {
+				return set, err
+			}
+			set.Map[k.AsString()] = str
+		}
+	default:
+		str, err := toString(val)
+		if err != nil {
+			return set, err
+		}
+		set.Value = str
+	}
+
+	return set, nil
+}
+
+// TerraformFile exports TFVars format to input file, under the stack's work
+// dir. Computed settings written as locals go to LocalsFile in the
+// PathTerraform folder, and the data sources for secret settings go to
+// SecretsFile.
 func (s Stack) TerraformFile(filename string) error {
-	f, err := os.Create(filename)
+	if s.ValidateTerraform {
+		if err := s.TerraformValidate(); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.Create(s.OutputPath(filename))
 	if err != nil {
 		return err
 	}
@@ -267,5 +1006,291 @@ func (s Stack) TerraformFile(filename string) error {
 		return err
 	}
 
+	return s.terraformFileExtras()
+}
+
+// terraformFileExtras writes LocalsFile and SecretsFile to the PathTerraform
+// folder, if there is anything to put in them. Either file generated by an
+// earlier run with nothing to put in it now is removed.
+func (s Stack) terraformFileExtras() error {
+	locals := s.TerraformLocals()
+	if locals == "" {
+		if err := removeGenerated(s.terraformPath(LocalsFile)); err != nil {
+			return err
+		}
+	} else if err := writeGenerated(s.terraformPath(LocalsFile), locals); err != nil {
+		return err
+	}
+
+	secrets := s.TerraformSecrets()
+	if secrets == "" {
+		return removeGenerated(s.terraformPath(SecretsFile))
+	}
+
+	return writeGenerated(s.terraformPath(SecretsFile), secrets)
+}
+
+// postDeployMessage is the file in the messages folder that holds what users
+// should be told once a stack has been deployed.
+const postDeployMessage = "success.txt"
+
+// Markdown returns a Markdown summary of the stack config and the settings
+// collected for it, suitable for a pull request description or runbook.
+func (s Stack) Markdown() string {
+	sb := strings.Builder{}
+
+	title := s.Config.Title
+	if title == "" {
+		title = s.Config.Name
+	}
+	sb.WriteString(fmt.Sprintf("# %s\n\n", title))
+
+	if desc := strings.TrimSpace(s.Config.Description); desc != "" {
+		sb.WriteString(desc)
+		sb.WriteString("\n\n")
+	}
+
+	if len(s.Config.Products) > 0 {
+		sb.WriteString("## Products\n\n")
+		for _, v := range s.Config.Products {
+			sb.WriteString(fmt.Sprintf("- **%s** %s\n", v.Product, v.Info))
+		}
+		sb.WriteString("\n")
+	}
+
+	s.Settings.Sort()
+
+	location := Settings{}
+	instance := Settings{}
+	rest := Settings{}
+	for _, v := range s.Settings {
+		switch {
+		case v.Name == "":
+			continue
+		case v.Name == "region" || v.Name == "zone":
+			location = append(location, v)
+		case strings.HasPrefix(v.Name, "instance-"):
+			instance = append(instance, v)
+		default:
+			rest = append(rest, v)
+		}
+	}
+
+	markdownTable(&sb, "Location", location)
+	markdownTable(&sb, "Compute Engine instance", instance)
+	markdownTable(&sb, "Settings", rest)
+
+	if s.Config.PathMessages != "" {
+		path := filepath.Join(s.Config.WD, s.Config.PathMessages, postDeployMessage)
+		if msg, err := os.ReadFile(path); err == nil && len(strings.TrimSpace(string(msg))) > 0 {
+			sb.WriteString("## After deployment\n\n")
+			sb.WriteString(strings.TrimSpace(string(msg)))
+			sb.WriteString("\n")
+		}
+	}
+
+	return sb.String()
+}
+
+func markdownTable(sb *strings.Builder, heading string, settings Settings) {
+	if len(settings) == 0 {
+		return
+	}
+
+	sb.WriteString(fmt.Sprintf("## %s\n\n", heading))
+	sb.WriteString("| Setting | Value |\n")
+	sb.WriteString("| --- | --- |\n")
+	for _, v := range settings {
+		sb.WriteString(fmt.Sprintf("| %s | %s |\n", v.Name, markdownValue(v)))
+	}
+	sb.WriteString("\n")
+}
+
+func markdownValue(s Setting) string {
+	value := s.Value
+
+	switch {
+	case len(s.List) > 0:
+		value = strings.Join(s.List, ", ")
+	case len(s.Map) > 0:
+		keys := []string{}
+		for k := range s.Map {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		pairs := []string{}
+		for _, k := range keys {
+			pairs = append(pairs, fmt.Sprintf("%s=%s", k, s.Map[k]))
+		}
+		value = strings.Join(pairs, ", ")
+	}
+
+	return strings.ReplaceAll(value, "|", "\\|")
+}
+
+// Output formats understood by WriteAll
+const (
+	FormatHCL      = "hcl"
+	FormatEnv      = "env"
+	FormatMarkdown = "markdown"
+	FormatLocals   = "locals"
+	FormatSecrets  = "secrets"
+)
+
+// ErrUnknownFormat is returned when WriteAll is asked for a format it does
+// not know how to produce
+var ErrUnknownFormat = fmt.Errorf("unknown output format")
+
+// DotEnv returns the settings as the KEY=value lines of a .env file, for
+// shell scripts to source. Keys are the Terraform names uppercased, with -
+// turned to _ as shell variables can't hold it, the same names ScriptEnv
+// uses. Each value is single quoted, so spaces, quotes and $ come through as
+// they are. Empty settings are left out.
+func (s Stack) DotEnv() string {
+	result := strings.Builder{}
+
+	s.Settings.Sort()
+
+	for _, v := range s.Settings {
+		if v.Name == "" {
+			continue
+		}
+
+		if len(v.Value) == 0 && len(v.List) == 0 && v.Map == nil {
+			continue
+		}
+
+		value := v.Value
+		if v.Type == "list" || v.Type == "map" || len(v.List) > 0 || v.Map != nil {
+			value = v.TFvarsValue()
+		}
+		value = strings.ReplaceAll(value, "'", `'\''`)
+
+		result.WriteString(fmt.Sprintf("%s='%s'\n", strings.ToUpper(envSettingName(v.TFvarsName())), value))
+	}
+
+	return result.String()
+}
+
+// DotEnvFile writes DotEnv to filename, under the stack's work dir
+func (s Stack) DotEnvFile(filename string) error {
+	return os.WriteFile(s.OutputPath(filename), []byte(s.DotEnv()), 0o644)
+}
+
+func (s Stack) render(format string) (string, error) {
+	switch format {
+	case FormatHCL:
+		if s.ValidateTerraform {
+			if err := s.TerraformValidate(); err != nil {
+				return "", err
+			}
+		}
+		return s.Terraform(), nil
+	case FormatEnv:
+		return s.DotEnv(), nil
+	case FormatLocals:
+		return s.TerraformLocals(), nil
+	case FormatSecrets:
+		return s.TerraformSecrets(), nil
+	case FormatMarkdown:
+		return s.Markdown(), nil
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+}
+
+// WriteAll writes the stack out to several files in one go. targets maps
+// filenames, relative to the work dir, to the format to write to them. Each
+// file is staged next to its destination and only moved into place once
+// every target has been rendered. Files that already exist are moved aside
+// first and keep their mode. If a later file fails, the ones already moved
+// are removed and the originals put back, so a failure never leaves a
+// partial set behind.
+func (s Stack) WriteAll(targets map[string]string) error {
+	resolved := map[string]string{}
+	filenames := []string{}
+	for k, v := range targets {
+		resolved[s.OutputPath(k)] = v
+		filenames = append(filenames, s.OutputPath(k))
+	}
+	targets = resolved
+	sort.Strings(filenames)
+
+	staged := map[string]string{}
+	cleanup := func() {
+		for _, v := range staged {
+			os.Remove(v)
+		}
+	}
+
+	for _, filename := range filenames {
+		content, err := s.render(targets[filename])
+		if err != nil {
+			cleanup()
+			return err
+		}
+
+		f, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+"-*")
+		if err != nil {
+			cleanup()
+			return fmt.Errorf("could not write %s: %w", filename, err)
+		}
+		staged[filename] = f.Name()
+
+		// CreateTemp makes files only the owner can read, so give the file
+		// the mode of the one it replaces, or the usual one for a new file
+		mode := os.FileMode(0o644)
+		if info, err := os.Stat(filename); err == nil && info.Mode().IsRegular() {
+			mode = info.Mode().Perm()
+		}
+
+		_, err = f.WriteString(content)
+		if err == nil {
+			err = f.Chmod(mode)
+		}
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			cleanup()
+			return fmt.Errorf("could not write %s: %w", filename, err)
+		}
+	}
+
+	written := []string{}
+	originals := map[string]string{}
+	rollback := func() {
+		for _, v := range written {
+			os.Remove(v)
+		}
+		for k, v := range originals {
+			os.Rename(v, k)
+		}
+		cleanup()
+	}
+
+	for _, filename := range filenames {
+		if info, err := os.Lstat(filename); err == nil && info.Mode().IsRegular() {
+			original := staged[filename] + ".orig"
+			if err := os.Rename(filename, original); err != nil {
+				rollback()
+				return fmt.Errorf("could not write %s: %w", filename, err)
+			}
+			originals[filename] = original
+		}
+
+		if err := os.Rename(staged[filename], filename); err != nil {
+			rollback()
+			return fmt.Errorf("could not write %s: %w", filename, err)
+		}
+		written = append(written, filename)
+		delete(staged, filename)
+	}
+
+	for _, v := range originals {
+		os.Remove(v)
+	}
+
 	return nil
 }
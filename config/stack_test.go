@@ -18,12 +18,15 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/kylelemons/godebug/diff"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
 )
 
 func TestFindAndReadConfig(t *testing.T) {
@@ -46,6 +49,9 @@ func TestFindAndReadConfig(t *testing.T) {
 		"PerferredYAML": {
 			pwd: "preferredyaml",
 		},
+		"PerferredTOML": {
+			pwd: "preferredtoml",
+		},
 		"Configed": {
 			pwd: "configed",
 		},
@@ -82,6 +88,62 @@ func TestFindAndReadConfig(t *testing.T) {
 	}
 }
 
+func TestFindAndReadConfigEnv(t *testing.T) {
+	wd, err := filepath.Abs("../")
+	if err != nil {
+		t.Fatalf("error setting up environment for testing %v", err)
+	}
+	testdata := fmt.Sprintf("%s/testdata/configs", wd)
+
+	tests := map[string]struct {
+		file  string
+		title string
+		err   error
+	}{
+		"YAML": {
+			file:  "preferredyaml/.deploystack/deploystack.yaml",
+			title: "Three Tier App (TODO)",
+		},
+		"JSON": {
+			file:  "original/deploystack.json",
+			title: "Three Tier App (TODO)",
+		},
+		"Missing": {
+			file: "error/nothere.yaml",
+			err:  ErrConfigNotExist,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			file := fmt.Sprintf("%s/%s", testdata, tc.file)
+			t.Setenv(EnvConfig, file)
+
+			s := NewStack()
+
+			// The folder has no config of its own, so only the variable can
+			// have pointed at one
+			config, err := s.findAndReadConfig(t.TempDir())
+
+			if tc.err != nil {
+				if !errors.Is(err, tc.err) {
+					t.Fatalf("expected: %v, got: %v", tc.err, err)
+				}
+				if !strings.Contains(err.Error(), file) {
+					t.Fatalf("expected the error to name %s, got: %s", file, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("could not read config file: %s", err)
+			}
+
+			assert.Equal(t, tc.title, config.Title)
+		})
+	}
+}
+
 func TestFindTFFolder(t *testing.T) {
 	testdata := filepath.Join(testFilesDir, "terraform")
 	tests := map[string]struct {
@@ -216,6 +278,33 @@ number=3
 object={email="item2@example.com",nickname="item2"}
 project="testproject"
 set=["item1","item2"]
+`,
+		},
+		"regional disk": {
+			in: Settings{
+				Setting{Name: "instance-disk-replication", Value: "regional", Type: "string"},
+				Setting{Name: "instance-disk-replica-zones", List: []string{"us-central1-a", "us-central1-b"}, Type: "list"},
+			},
+			want: `instance-disk-replica-zones=["us-central1-a","us-central1-b"]
+instance-disk-replication="regional"
+`,
+		},
+		"secret": {
+			in: Settings{
+				Setting{Name: "db_password", Value: "projects/testproject/secrets/db-password", Type: "secret"},
+			},
+			want: `db_password="projects/testproject/secrets/db-password"
+`,
+		},
+		"shielded vm": {
+			in: Settings{
+				Setting{Name: "instance-shielded-instance-config", Type: "map", Map: map[string]string{
+					"enable_secure_boot":          "true",
+					"enable_vtpm":                 "true",
+					"enable_integrity_monitoring": "true",
+				}},
+			},
+			want: `instance-shielded-instance-config={enable_integrity_monitoring="true",enable_secure_boot="true",enable_vtpm="true"}
 `,
 		},
 		"ingnore fields": {
@@ -252,6 +341,82 @@ set=["item1","item2"]
 	}
 }
 
+func TestAddListSetting(t *testing.T) {
+	tests := map[string]struct {
+		in   []string
+		want string
+	}{
+		"empty": {
+			in:   []string{},
+			want: "zones=[]\n",
+		},
+		"nil": {
+			in:   nil,
+			want: "zones=[]\n",
+		},
+		"single": {
+			in:   []string{"us-central1-a"},
+			want: "zones=[\"us-central1-a\"]\n",
+		},
+		"specialCharacters": {
+			in:   []string{"a,b", `say "hi"`, `back\slash`, "[bracketed]", "${var.zone}"},
+			want: `zones=["a,b","say \"hi\"","back\\slash","[bracketed]","$${var.zone}"]` + "\n",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := NewStack()
+			s.AddListSetting("Zones", tc.in)
+
+			assert.Equal(t, tc.want, s.Terraform())
+			assert.Nil(t, s.TerraformValidate())
+
+			set := s.Settings.Find("zones")
+			assert.Equal(t, "list", set.Type)
+			assert.Equal(t, len(tc.in), len(set.List))
+		})
+	}
+}
+
+func TestApplyEnvOverrides(t *testing.T) {
+	t.Setenv("DSTEST_PROJECT_ID", "ds-env-project")
+	t.Setenv("DSTEST_INSTANCE_MACHINE_TYPE", "e2-small")
+	t.Setenv("DSTEST_BASENAME", "from-env")
+	t.Setenv("DSTEST_NODES", "5")
+	t.Setenv("DSTEST_INSTANCE_DISKSIZE", "200")
+	t.Setenv("DSTEST_", "ignored")
+	t.Setenv("OTHER_REGION", "ignored")
+
+	s := NewStack()
+	s.Config.HardSet = map[string]string{"basename": "hard-set"}
+	s.Config.CustomSettings = Customs{{Name: "nodes", Default: "3"}}
+	s.AddSetting("instance-machine-type", "n1-standard-1")
+	s.AddSetting("region", "us-central1")
+
+	s.ApplyEnvOverrides("DSTEST_")
+
+	assert.Equal(t, "ds-env-project", s.GetSetting("project_id"))
+	assert.Equal(t, "e2-small", s.GetSetting("instance-machine-type"))
+	assert.Nil(t, s.Settings.Find("instance_machine_type"))
+	assert.Equal(t, "200", s.GetSetting("instance-disksize"))
+	assert.Equal(t, "from-env", s.GetSetting("basename"))
+	assert.Equal(t, "5", s.GetSetting("nodes"))
+	assert.Equal(t, "us-central1", s.GetSetting("region"))
+	assert.Nil(t, s.Settings.Find(""))
+
+	assert.True(t, s.IsOverridden("instance-machine-type"))
+	assert.True(t, s.IsOverridden("nodes"))
+	assert.False(t, s.IsOverridden("region"))
+
+	// The flow copies the author settings onto the stack again, the
+	// override has to survive that
+	for _, v := range s.Config.GetAuthorSettings() {
+		s.AddSettingComplete(v)
+	}
+	assert.Equal(t, "from-env", s.GetSetting("basename"))
+}
+
 func TestTerraformFile(t *testing.T) {
 	tests := map[string]struct {
 		filename string
@@ -288,6 +453,232 @@ func TestTerraformFile(t *testing.T) {
 	}
 }
 
+func TestTerraformJSON(t *testing.T) {
+	wd := t.TempDir()
+
+	s := NewStack()
+	s.WorkDir = wd
+	s.Settings = Settings{
+		{Name: "project", Value: "testproject", Type: "string"},
+		{Name: "boolean", Value: "true", Type: "boolean"},
+		{Name: "nodes", Value: "3", Type: "number"},
+		{Name: "ratio", Value: "2.5", Type: "numeric"},
+		{Name: "greeting", Value: `say "hi", then C:\temp`, Type: "string"},
+		{Name: "Instance Name", Value: "web-1", Type: "string"},
+		{Name: "set", Value: "[item1,item2]", Type: "string"},
+		{Name: "zones", List: []string{"us-central1-a", "us-central1-b"}, Type: "list"},
+		{Name: "object", Map: map[string]string{"nickname": "item2", "email": "item2@example.com"}, Type: "map"},
+		{Name: "project_name", Value: "dontshow", Type: "string"},
+		{Name: "stack_name", Value: "dontshow", Type: "string"},
+		{Name: "empty", Value: "", Type: "string"},
+	}
+
+	want, err := os.ReadFile(filepath.Join(testFilesDir, "tfvars", "terraform.tfvars.json"))
+	if err != nil {
+		t.Fatalf("could not read golden file: %s", err)
+	}
+
+	got, err := s.TerraformJSON()
+	if err != nil {
+		t.Fatalf("expected: no error got: %s", err)
+	}
+	assert.Equal(t, string(want), got)
+
+	if err := s.TerraformFileJSON("terraform.tfvars.json"); err != nil {
+		t.Fatalf("expected: no error got: %s", err)
+	}
+	written, err := os.ReadFile(filepath.Join(wd, "terraform.tfvars.json"))
+	if err != nil {
+		t.Fatalf("expected: no error got: %s", err)
+	}
+	assert.Equal(t, string(want), string(written))
+
+	s.Settings = Settings{{Name: "boolean", Value: "maybe", Type: "boolean"}}
+	_, err = s.TerraformJSON()
+	assert.NotNil(t, err)
+}
+
+func TestSettingsJSON(t *testing.T) {
+	wd := t.TempDir()
+
+	s := NewStack()
+	s.WorkDir = wd
+	s.Settings = Settings{
+		{Name: "zones", List: []string{"us-central1-a", "us-central1-b"}, Type: "list"},
+		{Name: "project_id", Value: "testproject", Type: "string"},
+		{Name: "Instance Name", Value: "web-1", Type: "string"},
+		{Name: "project_name", Value: "Test Project", Type: "string"},
+		{Name: "stack_name", Value: "teststack", Type: "string"},
+		{Name: "nodes", Value: "3", Type: "number"},
+		{Name: "empty", List: []string{}, Type: "list"},
+		{Name: "object", Map: map[string]string{"nickname": "item2", "email": "item2@example.com"}, Type: "map"},
+	}
+
+	got, err := s.SettingsJSON()
+	if err != nil {
+		t.Fatalf("expected: no error got: %s", err)
+	}
+
+	// The same settings in a different order give the same output
+	reversed := NewStack()
+	for i := len(s.Settings) - 1; i >= 0; i-- {
+		reversed.Settings = append(reversed.Settings, s.Settings[i])
+	}
+	again, err := reversed.SettingsJSON()
+	if err != nil {
+		t.Fatalf("expected: no error got: %s", err)
+	}
+	assert.Equal(t, string(got), string(again))
+
+	loaded, err := LoadSettingsJSON(got)
+	if err != nil {
+		t.Fatalf("expected: no error got: %s", err)
+	}
+
+	want := append(Settings{}, s.Settings...)
+	want.Sort()
+	assert.Equal(t, want, loaded)
+
+	if err := s.SettingsFileJSON("settings.json"); err != nil {
+		t.Fatalf("expected: no error got: %s", err)
+	}
+	written, err := os.ReadFile(filepath.Join(wd, "settings.json"))
+	if err != nil {
+		t.Fatalf("expected: no error got: %s", err)
+	}
+	assert.Equal(t, string(got), string(written))
+
+	_, err = LoadSettingsJSON([]byte("not json"))
+	assert.NotNil(t, err)
+}
+
+func TestDeploymentManagerConfig(t *testing.T) {
+	s := NewStack()
+	s.Config.Name = "singlevm"
+	s.Settings = Settings{
+		{Name: "project_id", Value: "ds-test", Type: "string"},
+		{Name: "zone", Value: "us-central1-a", Type: "string"},
+		{Name: "instance-name", Value: "singlevm-instance", Type: "string"},
+		{Name: "instance-machine-type", Value: "n1-standard-1", Type: "string"},
+		{Name: "instance-disksize", Value: "200", Type: "number"},
+		{Name: "instance-preemptible", Value: "false", Type: "boolean"},
+		{Name: "instance-tags", List: []string{"http-server", "https-server"}, Type: "list"},
+		{Name: "instance-labels", Map: map[string]string{"team": "web", "env": "dev"}, Type: "map"},
+		{Name: "project_name", Value: "dontshow", Type: "string"},
+	}
+
+	got, err := s.DeploymentManagerConfig()
+	if err != nil {
+		t.Fatalf("expected: no error got: %s", err)
+	}
+
+	parsed := struct {
+		Imports []struct {
+			Path string `yaml:"path"`
+		} `yaml:"imports"`
+		Resources []struct {
+			Name       string                 `yaml:"name"`
+			Type       string                 `yaml:"type"`
+			Properties map[string]interface{} `yaml:"properties"`
+		} `yaml:"resources"`
+	}{}
+	if err := yaml.Unmarshal([]byte(got), &parsed); err != nil {
+		t.Fatalf("expected valid yaml, got: %s\n%s", err, got)
+	}
+
+	assert.Equal(t, DeploymentManagerTemplate, parsed.Imports[0].Path)
+	assert.Len(t, parsed.Resources, 1)
+	assert.Equal(t, "singlevm", parsed.Resources[0].Name)
+	assert.Equal(t, DeploymentManagerTemplate, parsed.Resources[0].Type)
+	assert.Equal(t, map[string]interface{}{
+		"project_id":            "ds-test",
+		"zone":                  "us-central1-a",
+		"instance-name":         "singlevm-instance",
+		"instance-machine-type": "n1-standard-1",
+		"instance-disksize":     200,
+		"instance-preemptible":  false,
+		"instance-tags":         []interface{}{"http-server", "https-server"},
+		"instance-labels":       map[interface{}]interface{}{"team": "web", "env": "dev"},
+	}, parsed.Resources[0].Properties)
+}
+
+func TestTerraformFileLocals(t *testing.T) {
+	wd := t.TempDir()
+
+	s := NewStack()
+	s.WorkDir = wd
+	s.Config.TerraformLocals = true
+	s.AddSetting("project_id", "ds-test-project")
+	s.AddSetting("region", "us-central1")
+	s.AddSettingComplete(Setting{Name: "bucket_name", Value: `format("%s-files", var.project_id)`, Type: "computed"})
+	s.AddSettingComplete(Setting{Name: "zone", Value: `"${var.region}-a"`, Type: "computed"})
+
+	if err := s.TerraformFile("terraform.tfvars"); err != nil {
+		t.Fatalf("expected: no error got: %s", err)
+	}
+
+	tfvars, err := os.ReadFile(filepath.Join(wd, "terraform.tfvars"))
+	if err != nil {
+		t.Fatalf("could not read tfvars: %s", err)
+	}
+	locals, err := os.ReadFile(filepath.Join(wd, LocalsFile))
+	if err != nil {
+		t.Fatalf("could not read locals: %s", err)
+	}
+
+	assert.Equal(t, "project_id=\"ds-test-project\"\nregion=\"us-central1\"\n", string(tfvars))
+	assert.Equal(t, generatedHeader+"locals {\n  bucket_name = format(\"%s-files\", var.project_id)\n  zone = \"${var.region}-a\"\n}\n", string(locals))
+
+	// Writing again replaces the file it generated
+	if err := s.TerraformFile("terraform.tfvars"); err != nil {
+		t.Fatalf("expected: no error got: %s", err)
+	}
+
+	s.Config.TerraformLocals = false
+	assert.Contains(t, s.Terraform(), "bucket_name=")
+	assert.Empty(t, s.TerraformLocals())
+
+	// The locals file written before is no longer needed
+	if err := s.TerraformFile("terraform.tfvars"); err != nil {
+		t.Fatalf("expected: no error got: %s", err)
+	}
+	_, err = os.Stat(filepath.Join(wd, LocalsFile))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestTerraformFileLocalsPath(t *testing.T) {
+	wd := t.TempDir()
+	tfdir := filepath.Join(wd, "terraform")
+	if err := os.Mkdir(tfdir, 0o755); err != nil {
+		t.Fatalf("could not make terraform folder: %s", err)
+	}
+
+	s := NewStack()
+	s.WorkDir = wd
+	s.Config.PathTerraform = "terraform"
+	s.Config.TerraformLocals = true
+	s.AddSettingComplete(Setting{Name: "bucket_name", Value: `"files"`, Type: "computed"})
+
+	if err := s.TerraformFile("terraform.tfvars"); err != nil {
+		t.Fatalf("expected: no error got: %s", err)
+	}
+
+	_, err := os.Stat(filepath.Join(tfdir, LocalsFile))
+	assert.Nil(t, err)
+
+	// A file of the same name the author wrote is left alone
+	authored := "locals {\n  mine = true\n}\n"
+	if err := os.WriteFile(filepath.Join(tfdir, LocalsFile), []byte(authored), 0o644); err != nil {
+		t.Fatalf("could not write locals: %s", err)
+	}
+
+	err = s.TerraformFile("terraform.tfvars")
+	assert.ErrorIs(t, err, ErrNotGenerated)
+
+	got, _ := os.ReadFile(filepath.Join(tfdir, LocalsFile))
+	assert.Equal(t, authored, string(got))
+}
+
 func TestStackAddSettings(t *testing.T) {
 	tests := map[string]struct {
 		in []struct {
@@ -395,3 +786,591 @@ func TestStackGetSettings(t *testing.T) {
 		})
 	}
 }
+
+func TestStackMarkdown(t *testing.T) {
+	wd := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(wd, "messages"), 0o755); err != nil {
+		t.Fatalf("could not create messages folder: %s", err)
+	}
+	if err := os.WriteFile(filepath.Join(wd, "messages", "success.txt"), []byte("Visit your new site at the instance IP\n"), 0o644); err != nil {
+		t.Fatalf("could not write success message: %s", err)
+	}
+
+	s := NewStack()
+	s.Config.Title = "Single VM"
+	s.Config.Description = "A single Compute Engine instance"
+	s.Config.WD = wd
+	s.Config.PathMessages = "messages"
+	s.Config.Products = []Product{{Product: "Compute Engine", Info: "Runs the VM"}}
+	s.AddSetting("project_id", "ds-test-project")
+	s.AddSetting("region", "us-central1")
+	s.AddSetting("zone", "us-central1-a")
+	s.AddSetting("instance-machine-type", "n1-standard-1")
+	s.AddSetting("instance-disksize", "200")
+	s.AddSettingComplete(Setting{Name: "instance-tags", Type: "list", List: []string{"http-server", "https-server"}})
+
+	got := s.Markdown()
+
+	want := []string{
+		"# Single VM",
+		"A single Compute Engine instance",
+		"- **Compute Engine** Runs the VM",
+		"## Location",
+		"| region | us-central1 |",
+		"| zone | us-central1-a |",
+		"## Compute Engine instance",
+		"| instance-machine-type | n1-standard-1 |",
+		"| instance-disksize | 200 |",
+		"| instance-tags | http-server, https-server |",
+		"## Settings",
+		"| project_id | ds-test-project |",
+		"## After deployment",
+		"Visit your new site at the instance IP",
+	}
+
+	for _, v := range want {
+		assert.Contains(t, got, v)
+	}
+}
+
+func TestStackReset(t *testing.T) {
+	s := NewStack()
+	s.Config.Title = "Reset Test"
+	s.Config.HardSet = map[string]string{"nodes": "3"}
+	s.Config.AuthorSettings = Settings{{Name: "machine_type", Value: "n1-standard-1"}}
+
+	s.AddSetting("project_id", "ds-test-project")
+	s.AddSetting("region", "us-central1")
+	s.AddSetting("nodes", "5")
+
+	s.Reset()
+
+	assert.Equal(t, "Reset Test", s.Config.Title)
+	assert.Equal(t, "", s.GetSetting("project_id"))
+	assert.Equal(t, "", s.GetSetting("region"))
+	assert.Equal(t, "3", s.GetSetting("nodes"))
+	assert.Equal(t, "n1-standard-1", s.GetSetting("machine_type"))
+	assert.Len(t, s.Settings, 2)
+}
+
+func TestStackDiff(t *testing.T) {
+	s := NewStack()
+	s.AddSetting("project_id", "ds-test-project")
+	s.AddSetting("region", "us-east1")
+	s.AddSetting("zones", "[us-east1-b,us-east1-c]")
+	s.AddSetting("nodes", "3")
+
+	previous := Settings{}
+	previous.Add("project_id", "ds-test-project")
+	previous.Add("region", "us-central1")
+	previous.Add("zones", "[us-east1-b,us-east1-c]")
+	previous.Add("bucket", "ds-test-project-files")
+
+	want := []SettingChange{
+		{Name: "bucket", Kind: SettingRemoved, Old: `"ds-test-project-files"`},
+		{Name: "nodes", Kind: SettingAdded, New: `"3"`},
+		{Name: "region", Kind: SettingChanged, Old: `"us-central1"`, New: `"us-east1"`},
+	}
+
+	assert.Equal(t, want, s.Diff(previous))
+	assert.Empty(t, s.Diff(s.Settings))
+}
+
+func TestStackValidateConsistency(t *testing.T) {
+	tests := map[string]struct {
+		settings map[string]string
+		custom   Customs
+		want     []string
+	}{
+		"clean": {
+			settings: map[string]string{"region": "us-central1", "zone": "us-central1-a", "size": "small"},
+			custom:   Customs{{Name: "size", Options: []string{"small|Small", "large|Large"}}},
+			want:     []string{},
+		},
+		"zoneOutsideRegion": {
+			settings: map[string]string{"region": "us-central1", "zone": "us-east1-b"},
+			want:     []string{"zone (us-east1-b) is not in region (us-central1)"},
+		},
+		"notAnOption": {
+			settings: map[string]string{"size": "huge"},
+			custom:   Customs{{Name: "size", Options: []string{"small", "large"}}},
+			want:     []string{"size (huge) is not one of its options"},
+		},
+		"prependedOption": {
+			settings: map[string]string{"bucket": "ds-test-files"},
+			custom:   Customs{{Name: "bucket", Options: []string{"files"}, PrependProject: true}},
+			want:     []string{},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := NewStack()
+			s.Config.CustomSettings = tc.custom
+			for k, v := range tc.settings {
+				s.AddSetting(k, v)
+			}
+
+			assert.Equal(t, tc.want, s.ValidateConsistency())
+		})
+	}
+}
+
+func TestStackTerraformValidate(t *testing.T) {
+	tests := map[string]struct {
+		settings map[string]string
+		want     error
+	}{
+		"clean": {
+			settings: map[string]string{
+				"project_id": "test-project",
+				"region":     "us-central1",
+				"zones":      "[us-central1-a,us-central1-b]",
+			},
+		},
+		"unescaped_quote": {
+			settings: map[string]string{"greeting": `say "hi"`},
+			want:     ErrInvalidTerraform,
+		},
+		"escaped_quote": {
+			settings: map[string]string{"greeting": `say \"hi\"`},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := NewStack()
+			for k, v := range tc.settings {
+				s.AddSetting(k, v)
+			}
+
+			got := s.TerraformValidate()
+			if tc.want == nil {
+				assert.Nil(t, got)
+				return
+			}
+			assert.ErrorIs(t, got, tc.want)
+		})
+	}
+}
+
+func TestStackTerraformFileValidate(t *testing.T) {
+	testfile := filepath.Join(testFilesDir, "file/invalid.tfvars")
+	s := NewStack()
+	s.ValidateTerraform = true
+	s.AddSetting("greeting", `say "hi"`)
+
+	err := s.TerraformFile(testfile)
+	assert.ErrorIs(t, err, ErrInvalidTerraform)
+
+	_, err = os.Stat(testfile)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestTerraformSecrets(t *testing.T) {
+	wd := t.TempDir()
+
+	s := NewStack()
+	s.WorkDir = wd
+	s.AddSetting("project_id", "ds-test-project")
+	s.AddSettingComplete(Setting{Name: "db_password", Value: "projects/ds-test-project/secrets/db-password", Type: "secret"})
+	s.AddSettingComplete(Setting{Name: "api_key", Value: "projects/ds-test-project/secrets/api-key", Type: "secret"})
+
+	want := `data "google_secret_manager_secret_version" "api_key" {
+  secret = "projects/ds-test-project/secrets/api-key"
+}
+
+data "google_secret_manager_secret_version" "db_password" {
+  secret = "projects/ds-test-project/secrets/db-password"
+}
+`
+	assert.Equal(t, want, s.TerraformSecrets())
+
+	// The tfvars only ever hold the name of the secret, the value is read
+	// through the data source
+	assert.Contains(t, s.Terraform(), "db_password=\"projects/ds-test-project/secrets/db-password\"\n")
+
+	if err := s.TerraformFile("terraform.tfvars"); err != nil {
+		t.Fatalf("expected: no error got: %s", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(wd, SecretsFile))
+	if err != nil {
+		t.Fatalf("could not read secrets: %s", err)
+	}
+	assert.Equal(t, generatedHeader+want, string(got))
+
+	plain := NewStack()
+	plain.AddSetting("project_id", "ds-test-project")
+	assert.Empty(t, plain.TerraformSecrets())
+}
+
+func TestTerraformFileSecretsRemoved(t *testing.T) {
+	wd := t.TempDir()
+
+	s := NewStack()
+	s.WorkDir = wd
+	s.AddSetting("project_id", "ds-test-project")
+	s.AddSettingComplete(Setting{Name: "db_password", Value: "projects/ds-test-project/secrets/db-password", Type: "secret"})
+
+	if err := s.TerraformFile("terraform.tfvars"); err != nil {
+		t.Fatalf("expected: no error got: %s", err)
+	}
+
+	// Once the stack has no secret settings, the data sources written for
+	// them go too
+	s.DeleteSetting("db_password")
+	if err := s.TerraformFile("terraform.tfvars"); err != nil {
+		t.Fatalf("expected: no error got: %s", err)
+	}
+
+	_, err := os.Stat(filepath.Join(wd, SecretsFile))
+	assert.True(t, os.IsNotExist(err))
+
+	// A file of the same name the author wrote is left alone
+	authored := "data \"google_secret_manager_secret_version\" \"mine\" {}\n"
+	if err := os.WriteFile(filepath.Join(wd, SecretsFile), []byte(authored), 0o644); err != nil {
+		t.Fatalf("could not write secrets: %s", err)
+	}
+
+	if err := s.TerraformFile("terraform.tfvars"); err != nil {
+		t.Fatalf("expected: no error got: %s", err)
+	}
+
+	got, _ := os.ReadFile(filepath.Join(wd, SecretsFile))
+	assert.Equal(t, authored, string(got))
+}
+
+func TestReadTFVars(t *testing.T) {
+	s := NewStack()
+	s.AddSetting("project_id", "ds-test-project")
+	s.AddSetting("region", "us-central1")
+	s.AddSettingComplete(Setting{Name: "zones", List: []string{"us-central1-a", "us-central1-b"}, Type: "list"})
+	s.AddSettingComplete(Setting{Name: "labels", Map: map[string]string{"team": "web", "env": "dev"}, Type: "map"})
+
+	filename := filepath.Join(t.TempDir(), "terraform.tfvars")
+	if err := os.WriteFile(filename, []byte(s.Terraform()+"nodes=3\n"), 0o644); err != nil {
+		t.Fatalf("expected: no error got: %s", err)
+	}
+
+	got, err := ReadTFVars(filename)
+	if err != nil {
+		t.Fatalf("expected: no error got: %s", err)
+	}
+
+	want := Settings{
+		{Name: "labels", Map: map[string]string{"team": "web", "env": "dev"}, Type: "map"},
+		{Name: "nodes", Value: "3", Type: "string"},
+		{Name: "project_id", Value: "ds-test-project", Type: "string"},
+		{Name: "region", Value: "us-central1", Type: "string"},
+		{Name: "zones", List: []string{"us-central1-a", "us-central1-b"}, Type: "list"},
+	}
+	assert.Equal(t, want, got)
+
+	_, err = ReadTFVars(filepath.Join(t.TempDir(), "missing.tfvars"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestTerraformEscaping(t *testing.T) {
+	s := NewStack()
+	s.AddSetting("quote", `say "hi"`)
+	s.AddSetting("backslash", `C:\temp\`)
+	s.AddSetting("newline", "line one\nline two\ttabbed")
+	s.AddSetting("comma", "red, green")
+	s.AddSetting("template", "${var.region} and %{if true}x%{endif}")
+	s.AddSetting("bracket", "[draft notes")
+	s.AddSetting("jsonlist", `["us-central1-a,b","with \"quote\""]`)
+	s.AddSettingComplete(Setting{Name: "list", List: []string{"a,b", `c"d`, `e\f`}, Type: "list"})
+	s.AddSettingComplete(Setting{Name: "labels", Map: map[string]string{"team name": `"web"`, "env": "dev\nprod"}, Type: "map"})
+
+	if err := s.TerraformValidate(); err != nil {
+		t.Fatalf("expected the output to parse, got: %s\n%s", err, s.Terraform())
+	}
+
+	filename := filepath.Join(t.TempDir(), "terraform.tfvars")
+	if err := os.WriteFile(filename, []byte(s.Terraform()), 0o644); err != nil {
+		t.Fatalf("expected: no error got: %s", err)
+	}
+
+	got, err := ReadTFVars(filename)
+	if err != nil {
+		t.Fatalf("expected: no error got: %s", err)
+	}
+
+	want := Settings{
+		{Name: "backslash", Value: `C:\temp\`, Type: "string"},
+		{Name: "bracket", Value: "[draft notes", Type: "string"},
+		{Name: "comma", Value: "red, green", Type: "string"},
+		{Name: "jsonlist", List: []string{"us-central1-a,b", `with "quote"`}, Type: "list"},
+		{Name: "labels", Map: map[string]string{"team name": `"web"`, "env": "dev\nprod"}, Type: "map"},
+		{Name: "list", List: []string{"a,b", `c"d`, `e\f`}, Type: "list"},
+		{Name: "newline", Value: "line one\nline two\ttabbed", Type: "string"},
+		{Name: "quote", Value: `say "hi"`, Type: "string"},
+		{Name: "template", Value: "${var.region} and %{if true}x%{endif}", Type: "string"},
+	}
+	assert.Equal(t, want, got)
+}
+
+func TestStackWriteAll(t *testing.T) {
+	dir := t.TempDir()
+	s := NewStack()
+	s.AddSetting("project_id", "test-project")
+	s.AddSetting("greeting", "it's here")
+
+	tfvars := filepath.Join(dir, "terraform.tfvars")
+	env := filepath.Join(dir, "deploystack.env")
+
+	err := s.WriteAll(map[string]string{
+		tfvars: FormatHCL,
+		env:    FormatEnv,
+	})
+	if err != nil {
+		t.Fatalf("expected: no error got: %+v", err)
+	}
+
+	got, err := os.ReadFile(tfvars)
+	assert.Nil(t, err)
+	assert.Equal(t, s.Terraform(), string(got))
+
+	got, err = os.ReadFile(env)
+	assert.Nil(t, err)
+	assert.Equal(t, "GREETING='it'\\''s here'\nPROJECT_ID='test-project'\n", string(got))
+
+	entries, err := os.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Len(t, entries, 2)
+}
+
+func TestStackDotEnv(t *testing.T) {
+	tests := map[string]struct {
+		name  string
+		value string
+		want  string
+	}{
+		"plain":   {name: "region", value: "us-central1", want: "REGION='us-central1'\n"},
+		"dashes":  {name: "instance-machine-type", value: "e2-small", want: "INSTANCE_MACHINE_TYPE='e2-small'\n"},
+		"spaces":  {name: "greeting", value: "hello there world", want: "GREETING='hello there world'\n"},
+		"single":  {name: "greeting", value: "it's here", want: "GREETING='it'\\''s here'\n"},
+		"double":  {name: "greeting", value: `say "hi"`, want: "GREETING='say \"hi\"'\n"},
+		"dollar":  {name: "password", value: "pa$HOME`id`", want: "PASSWORD='pa$HOME`id`'\n"},
+		"empty":   {name: "nothing", value: "", want: ""},
+		"unnamed": {name: "", value: "orphan", want: ""},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := NewStack()
+			s.AddSetting(tc.name, tc.value)
+
+			assert.Equal(t, tc.want, s.DotEnv())
+
+			if tc.want == "" {
+				return
+			}
+
+			dir := t.TempDir()
+			s.Config.Setwd(dir)
+			if err := s.DotEnvFile(".env"); err != nil {
+				t.Fatalf("expected: no error got: %+v", err)
+			}
+
+			// Sourcing the file has to give back the value unchanged
+			key, _, _ := strings.Cut(tc.want, "=")
+			cmd := exec.Command("sh", "-c", fmt.Sprintf(". ./.env && printf %%s \"$%s\"", key))
+			cmd.Dir = dir
+			out, err := cmd.CombinedOutput()
+			if err != nil {
+				t.Skipf("could not run sh: %v %s", err, out)
+			}
+			assert.Equal(t, tc.value, string(out))
+		})
+	}
+}
+
+func TestStackWriteAllRollback(t *testing.T) {
+	tests := map[string]struct {
+		targets func(dir string) map[string]string
+		want    error
+	}{
+		"unknown_format": {
+			targets: func(dir string) map[string]string {
+				return map[string]string{
+					filepath.Join(dir, "a.tfvars"): FormatHCL,
+					filepath.Join(dir, "b.txt"):    "nope",
+				}
+			},
+			want: ErrUnknownFormat,
+		},
+		"mid_sequence": {
+			// b.env is a directory, so moving the file into place fails after
+			// a.tfvars has already been written.
+			targets: func(dir string) map[string]string {
+				os.Mkdir(filepath.Join(dir, "b.env"), 0o755)
+				return map[string]string{
+					filepath.Join(dir, "a.tfvars"): FormatHCL,
+					filepath.Join(dir, "b.env"):    FormatEnv,
+					filepath.Join(dir, "c.md"):     FormatMarkdown,
+				}
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			s := NewStack()
+			s.AddSetting("project_id", "test-project")
+
+			err := s.WriteAll(tc.targets(dir))
+			assert.NotNil(t, err)
+			if tc.want != nil {
+				assert.ErrorIs(t, err, tc.want)
+			}
+
+			entries, err := os.ReadDir(dir)
+			assert.Nil(t, err)
+			for _, v := range entries {
+				assert.True(t, v.IsDir(), "file %s was left behind", v.Name())
+			}
+		})
+	}
+}
+
+func TestStackWriteAllExisting(t *testing.T) {
+	tests := map[string]struct {
+		blocked bool
+		want    string
+	}{
+		"replaced": {},
+		"restored": {blocked: true, want: "original\n"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			s := NewStack()
+			s.AddSetting("project_id", "test-project")
+
+			tfvars := filepath.Join(dir, "a.tfvars")
+			if err := os.WriteFile(tfvars, []byte("original\n"), 0o640); err != nil {
+				t.Fatalf("expected: no error got: %+v", err)
+			}
+			if err := os.Chmod(tfvars, 0o640); err != nil {
+				t.Fatalf("expected: no error got: %+v", err)
+			}
+
+			targets := map[string]string{tfvars: FormatHCL}
+			if tc.blocked {
+				// b.env is a directory, so moving it into place fails after
+				// a.tfvars has been replaced
+				os.Mkdir(filepath.Join(dir, "b.env"), 0o755)
+				targets[filepath.Join(dir, "b.env")] = FormatEnv
+			}
+
+			err := s.WriteAll(targets)
+			assert.Equal(t, tc.blocked, err != nil)
+
+			want := tc.want
+			if !tc.blocked {
+				want = s.Terraform()
+			}
+
+			got, err := os.ReadFile(tfvars)
+			assert.Nil(t, err)
+			assert.Equal(t, want, string(got))
+
+			info, err := os.Stat(tfvars)
+			assert.Nil(t, err)
+			assert.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+
+			entries, err := os.ReadDir(dir)
+			assert.Nil(t, err)
+			for _, v := range entries {
+				assert.True(t, v.IsDir() || v.Name() == "a.tfvars", "file %s was left behind", v.Name())
+			}
+		})
+	}
+}
+
+func TestStackWorkDir(t *testing.T) {
+	configDir := t.TempDir()
+	workDir := t.TempDir()
+
+	tests := map[string]struct {
+		workDir string
+		want    string
+	}{
+		"default": {want: configDir},
+		"custom":  {workDir: workDir, want: workDir},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := NewStack()
+			s.Config.WD = configDir
+			s.WorkDir = tc.workDir
+			s.AddSetting("project_id", "test-project")
+
+			assert.Equal(t, filepath.Join(tc.want, "terraform.tfvars"), s.OutputPath("terraform.tfvars"))
+			assert.Equal(t, "/elsewhere/terraform.tfvars", s.OutputPath("/elsewhere/terraform.tfvars"))
+
+			if err := s.TerraformFile("terraform.tfvars"); err != nil {
+				t.Fatalf("expected: no error got: %+v", err)
+			}
+			if err := s.WriteAll(map[string]string{"deploystack.env": FormatEnv}); err != nil {
+				t.Fatalf("expected: no error got: %+v", err)
+			}
+
+			for _, v := range []string{"terraform.tfvars", "deploystack.env"} {
+				_, err := os.Stat(filepath.Join(tc.want, v))
+				assert.Nil(t, err)
+				os.Remove(filepath.Join(tc.want, v))
+			}
+		})
+	}
+}
+
+func TestRunScript(t *testing.T) {
+	dir := t.TempDir()
+
+	scripts := map[string]string{
+		"scripts/post.sh": "#!/bin/sh\necho \"$PROJECT_ID|$INSTANCE_MACHINE_TYPE|$ZONES\"\n",
+		"scripts/fail.sh": "#!/bin/sh\necho \"could not reach $PROJECT_ID\"\nexit 1\n",
+		"secret.sh":       "#!/bin/sh\necho secret\n",
+	}
+	for name, content := range scripts {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("could not set up test: %s", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o755); err != nil {
+			t.Fatalf("could not set up test: %s", err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(dir, "secret.sh"), filepath.Join(dir, "scripts", "link.sh")); err != nil {
+		t.Fatalf("could not set up test: %s", err)
+	}
+
+	s := NewStack()
+	s.Config.WD = dir
+	s.Config.PathScripts = "scripts"
+	s.AddSetting("project_id", "testproject")
+	s.AddSetting("instance-machine-type", "n1-standard-1")
+	s.AddListSetting("zones", []string{"us-central1-a", "us-central1-b"})
+
+	out, err := s.RunScriptOutput("post.sh")
+	if err != nil {
+		t.Fatalf("expected: no error got: %s", err)
+	}
+	assert.Equal(t, "testproject|n1-standard-1|us-central1-a,us-central1-b\n", string(out))
+	assert.Nil(t, s.RunScript("post.sh"))
+
+	err = s.RunScript("fail.sh")
+	assert.ErrorContains(t, err, "could not reach testproject")
+
+	for _, name := range []string{"../secret.sh", "link.sh", filepath.Join(dir, "secret.sh")} {
+		t.Run(name, func(t *testing.T) {
+			assert.ErrorIs(t, s.RunScript(name), ErrScriptOutsideFolder)
+		})
+	}
+}
@@ -67,13 +67,13 @@ func Precheck() error {
 		return err
 	}
 
-	reports, err := config.FindConfigReports(wd)
+	stacks, err := config.DiscoverStacks(wd)
 	if err != nil {
 		return err
 	}
 
-	if len(reports) > 1 {
-		stackPath := tui.PreCheck(reports)
+	if len(stacks) > 1 {
+		stackPath := tui.PickStack(stacks)
 		if err := os.Chdir(stackPath); err != nil {
 			return err
 		}
@@ -85,9 +85,19 @@ func Precheck() error {
 // ContactCheck checks the local file system for a file containing domain
 // registar contact info
 func ContactCheck() gcloud.ContactData {
+	return contactCheck(contactfile)
+}
+
+// ContactCheckStack checks the stack's work dir for a file containing domain
+// registar contact info
+func ContactCheckStack(s *config.Stack) gcloud.ContactData {
+	return contactCheck(s.OutputPath(contactfile))
+}
+
+func contactCheck(path string) gcloud.ContactData {
 	contact := gcloud.ContactData{}
-	if _, err := os.Stat(contactfile); err == nil {
-		f, err := os.Open(contactfile)
+	if _, err := os.Stat(path); err == nil {
+		f, err := os.Open(path)
 		if err != nil {
 			return contact
 		}
@@ -102,6 +112,16 @@ func ContactCheck() gcloud.ContactData {
 // ContactSave writes a file containing domain registar contact info to disk
 // if it exists
 func ContactSave(i interface{}) {
+	contactSave(contactfile, i)
+}
+
+// ContactSaveStack writes a file containing domain registar contact info to
+// the stack's work dir if it exists
+func ContactSaveStack(s *config.Stack, i interface{}) {
+	contactSave(s.OutputPath(contactfile), i)
+}
+
+func contactSave(path string, i interface{}) {
 	// We can ignore errors - this is an convenience to the user
 	// not a necessity
 	switch v := i.(type) {
@@ -110,7 +130,7 @@ func ContactSave(i interface{}) {
 			return
 		}
 
-		f, err := os.Create(contactfile)
+		f, err := os.Create(path)
 		if err != nil {
 			return
 		}
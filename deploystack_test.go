@@ -605,6 +605,40 @@ func TestCheckForContact(t *testing.T) {
 	}
 }
 
+func TestContactStack(t *testing.T) {
+	s := config.NewStack()
+	s.WorkDir = t.TempDir()
+
+	contact := gcloud.ContactData{
+		AllContacts: gcloud.DomainRegistrarContact{
+			Email: "test@example.com",
+			Phone: "+155555551212",
+			PostalAddress: gcloud.PostalAddress{
+				RegionCode:         "US",
+				PostalCode:         "94502",
+				AdministrativeArea: "CA",
+				Locality:           "San Francisco",
+				AddressLines:       []string{"345 Spear Street"},
+				Recipients:         []string{"Googler"},
+			},
+		},
+	}
+
+	ContactSaveStack(&s, contact)
+
+	if _, err := os.Stat(filepath.Join(s.WorkDir, contactfile)); err != nil {
+		t.Fatalf("expected contact file in work dir, got: %+v", err)
+	}
+	if _, err := os.Stat(contactfile); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected no contact file in current dir, got: %+v", err)
+	}
+
+	got := ContactCheckStack(&s)
+	if !reflect.DeepEqual(contact, got) {
+		t.Fatalf("expected: %+v, got: %+v", contact, got)
+	}
+}
+
 func TestInit(t *testing.T) {
 	errUnableToRead := errors.New("unable to read config file: ")
 	tests := map[string]struct {
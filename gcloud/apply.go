@@ -0,0 +1,285 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcloud
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/deploystack/config"
+	"google.golang.org/api/compute/v1"
+)
+
+// ApplyResult describes what Apply did, and what it found already in place
+type ApplyResult struct {
+	Project         string
+	ProjectCreated  bool
+	ServicesEnabled []string
+	Instance        string
+	InstanceCreated bool
+	IPs             []string
+	URLs            []string
+}
+
+// costLabelsSetting is the setting holding the labels for cost attribution
+// that a new project is created with
+const costLabelsSetting = "cost_labels"
+
+// Apply sets up what the collected settings of a stack describe: it makes
+// sure the project exists, enables the APIs the stack needs and creates the
+// Compute Engine instance, if the stack collected one. Anything that already
+// exists is left alone, so Apply can be rerun safely.
+func (c *Client) Apply(stack *config.Stack) (ApplyResult, error) {
+	if err := c.checkWritable("Apply"); err != nil {
+		return ApplyResult{}, err
+	}
+
+	result := ApplyResult{}
+
+	project := stack.GetSetting("project_id")
+	if project == "" {
+		return result, ErrorProjectRequired
+	}
+	result.Project = project
+
+	if !c.ProjectExists(project) {
+		// The labels the stack declares go on first, the cost labels the
+		// user gave win over them
+		labels := stack.Config.ProjectLabels
+		if cost := stack.Settings.Find(costLabelsSetting); cost != nil {
+			labels = mergeLabels(labels, cost.Map)
+		}
+
+		if err := c.ProjectCreateWithLabels(project, "", "", stack.GetSetting("billing_account"), labels); err != nil {
+			return result, fmt.Errorf("could not create project (%s): %w", project, err)
+		}
+		result.ProjectCreated = true
+	}
+
+	name := stack.GetSetting("instance-name")
+
+	services, err := applyServices(stack.Config.RequiredServices(), name != "")
+	if err != nil {
+		return result, err
+	}
+
+	for _, v := range services {
+		enabled, err := c.ServiceIsEnabled(project, v)
+		if err != nil {
+			return result, err
+		}
+		if enabled {
+			continue
+		}
+
+		if err := c.ServiceEnable(project, v); err != nil {
+			return result, err
+		}
+		result.ServicesEnabled = append(result.ServicesEnabled, v.String())
+	}
+
+	if name == "" {
+		return result, nil
+	}
+
+	zone := stack.GetSetting("zone")
+	if zone == "" {
+		return result, ErrorZoneRequired
+	}
+	result.Instance = name
+
+	inst, err := c.InstanceGet(project, zone, name)
+	if err != nil {
+		return result, err
+	}
+
+	if inst == nil {
+		req, err := applyInstance(stack)
+		if err != nil {
+			return result, err
+		}
+
+		if err := c.InstanceCreate(project, zone, req); err != nil {
+			return result, err
+		}
+		result.InstanceCreated = true
+
+		inst, err = c.InstanceGet(project, zone, name)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	if inst != nil {
+		result.IPs, result.URLs = instanceAddresses(inst)
+	}
+
+	return result, nil
+}
+
+// applyServices turns the services a stack requires into the ones Apply
+// enables, adding Compute Engine when there is an instance to create
+func applyServices(required []string, instance bool) ([]Service, error) {
+	result := []Service{}
+	seen := map[Service]bool{}
+
+	if instance {
+		required = append(required, Compute.String())
+	}
+
+	for _, v := range required {
+		s, err := ParseService(v)
+		if err != nil {
+			return nil, err
+		}
+		if seen[s] {
+			continue
+		}
+		seen[s] = true
+		result = append(result, s)
+	}
+
+	return result, nil
+}
+
+// applyInstance builds the instance request from the instance settings a
+// stack collected. Settings only terraform acts on, like shielded VM or
+// accelerators, are left out.
+func applyInstance(stack *config.Stack) (*compute.Instance, error) {
+	zone := stack.GetSetting("zone")
+	region := stack.GetSetting("region")
+
+	machineType := stack.GetSetting("instance-machine-type")
+	if machineType == "" {
+		machineType = DefaultInstanceType
+	}
+
+	image := stack.GetSetting("instance-image")
+	if image == "" {
+		return nil, fmt.Errorf("no image set for instance")
+	}
+	if !strings.HasPrefix(image, "projects/") && !strings.HasPrefix(image, "https://") {
+		parts := strings.SplitN(image, "/", 2)
+		if len(parts) == 2 {
+			image = fmt.Sprintf("projects/%s/global/images/%s", parts[0], parts[1])
+		}
+	}
+
+	params := &compute.AttachedDiskInitializeParams{
+		SourceImage: image,
+	}
+
+	if size := stack.GetSetting("instance-disksize"); size != "" {
+		gb, err := strconv.ParseInt(size, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("disk size (%s) is not a number", size)
+		}
+		params.DiskSizeGb = gb
+	}
+
+	if disktype := stack.GetSetting("instance-disktype"); disktype != "" {
+		params.DiskType = fmt.Sprintf("zones/%s/diskTypes/%s", zone, disktype)
+	}
+
+	if labels := stack.Settings.Find("instance-disk-labels"); labels != nil {
+		params.Labels = labels.Map
+	}
+
+	network := stack.GetSetting("instance-network")
+	if network == "" {
+		network = "default"
+	}
+
+	nic := &compute.NetworkInterface{
+		Network: fmt.Sprintf("global/networks/%s", network),
+		AccessConfigs: []*compute.AccessConfig{
+			{Name: "External NAT", Type: "ONE_TO_ONE_NAT"},
+		},
+	}
+
+	if subnet := stack.GetSetting("instance-subnet"); subnet != "" {
+		nic.Subnetwork = fmt.Sprintf("regions/%s/subnetworks/%s", region, subnet)
+	}
+
+	inst := &compute.Instance{
+		Name:        stack.GetSetting("instance-name"),
+		MachineType: fmt.Sprintf("zones/%s/machineTypes/%s", zone, machineType),
+		Disks: []*compute.AttachedDisk{
+			{Boot: true, AutoDelete: true, InitializeParams: params},
+		},
+		NetworkInterfaces: []*compute.NetworkInterface{nic},
+	}
+
+	if tags := parseTags(stack.GetSetting("instance-tags")); len(tags) > 0 {
+		inst.Tags = &compute.Tags{Items: tags}
+	}
+
+	if labels := stack.Settings.Find("instance-labels"); labels != nil {
+		inst.Labels = labels.Map
+	}
+
+	return inst, nil
+}
+
+// parseTags reads instance tags written like "[http-server,https-server]"
+func parseTags(s string) []string {
+	result := []string{}
+
+	for _, v := range strings.Split(strings.Trim(s, "[]"), ",") {
+		v = strings.TrimSpace(v)
+		if v != "" {
+			result = append(result, v)
+		}
+	}
+
+	return result
+}
+
+// instanceAddresses lists the IPs of an instance, and the URLs it serves on
+// if it is tagged as a web server
+func instanceAddresses(inst *compute.Instance) ([]string, []string) {
+	ips := []string{}
+	urls := []string{}
+
+	web := map[string]bool{}
+	if inst.Tags != nil {
+		for _, v := range inst.Tags.Items {
+			web[v] = true
+		}
+	}
+
+	for _, nic := range inst.NetworkInterfaces {
+		if nic.NetworkIP != "" {
+			ips = append(ips, nic.NetworkIP)
+		}
+
+		for _, ac := range nic.AccessConfigs {
+			if ac.NatIP == "" {
+				continue
+			}
+			ips = append(ips, ac.NatIP)
+
+			if web["http-server"] {
+				urls = append(urls, fmt.Sprintf("http://%s", ac.NatIP))
+			}
+			if web["https-server"] {
+				urls = append(urls, fmt.Sprintf("https://%s", ac.NatIP))
+			}
+		}
+	}
+
+	return ips, urls
+}
@@ -0,0 +1,214 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcloud
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/deploystack/config"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/cloudresourcemanager/v1"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+	"google.golang.org/api/serviceusage/v1"
+)
+
+// fakeCloud stands in for Resource Manager, Service Usage and Compute Engine,
+// keeping track of what has been created so that reruns find it
+type fakeCloud struct {
+	mu       sync.Mutex
+	project  bool
+	labels   map[string]string
+	enabled  map[string]bool
+	instance *compute.Instance
+	creates  map[string]int
+}
+
+func (f *fakeCloud) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	notFound := func() {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":{"code":404,"message":"not found"}}`)
+	}
+
+	path := r.URL.Path
+	switch {
+	case path == "/v1/projects/ds-test" && r.Method == http.MethodGet:
+		if !f.project {
+			notFound()
+			return
+		}
+		fmt.Fprint(w, `{"projectId":"ds-test"}`)
+	case path == "/v1/projects" && r.Method == http.MethodPost:
+		proj := &cloudresourcemanager.Project{}
+		json.NewDecoder(r.Body).Decode(proj)
+		f.labels = proj.Labels
+		f.project = true
+		f.creates["project"]++
+		fmt.Fprint(w, `{"name":"operations/cp.1"}`)
+	case path == "/v1/operations/cp.1":
+		fmt.Fprint(w, `{"name":"operations/cp.1","done":true}`)
+	case strings.HasPrefix(path, "/v1/projects/ds-test/services/") && strings.HasSuffix(path, ":enable"):
+		name := strings.TrimSuffix(strings.TrimPrefix(path, "/v1/projects/ds-test/services/"), ":enable")
+		f.enabled[name] = true
+		f.creates[name]++
+		fmt.Fprint(w, `{"done":true,"response":{"service":{"state":"ENABLED"}}}`)
+	case strings.HasPrefix(path, "/v1/projects/ds-test/services/"):
+		state := "DISABLED"
+		if f.enabled[strings.TrimPrefix(path, "/v1/projects/ds-test/services/")] {
+			state = "ENABLED"
+		}
+		fmt.Fprintf(w, `{"state":%q}`, state)
+	case path == "/projects/ds-test/zones/us-central1-a/instances/ds-test-instance":
+		if f.instance == nil {
+			notFound()
+			return
+		}
+		json.NewEncoder(w).Encode(f.instance)
+	case path == "/projects/ds-test/zones/us-central1-a/instances" && r.Method == http.MethodPost:
+		inst := &compute.Instance{}
+		json.NewDecoder(r.Body).Decode(inst)
+		inst.NetworkInterfaces[0].NetworkIP = "10.128.0.2"
+		inst.NetworkInterfaces[0].AccessConfigs[0].NatIP = "34.1.2.3"
+		f.instance = inst
+		f.creates["instance"]++
+		fmt.Fprint(w, `{"name":"op-1","status":"RUNNING"}`)
+	case path == "/projects/ds-test/zones/us-central1-a/operations/op-1/wait":
+		fmt.Fprint(w, `{"name":"op-1","status":"DONE"}`)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, `{"error":{"code":500,"message":"unexpected %s %s"}}`, r.Method, path)
+	}
+}
+
+func fakeCloudClient(t *testing.T, srv *httptest.Server) Client {
+	opts := []option.ClientOption{
+		option.WithEndpoint(srv.URL + "/"),
+		option.WithHTTPClient(srv.Client()),
+	}
+
+	c := NewClient(ctx, defaultUserAgent)
+
+	var err error
+	if c.services.resourceManager, err = cloudresourcemanager.NewService(ctx, opts...); err != nil {
+		t.Fatalf("could not create fake resource manager: %s", err)
+	}
+	if c.services.serviceUsage, err = serviceusage.NewService(ctx, opts...); err != nil {
+		t.Fatalf("could not create fake service usage: %s", err)
+	}
+	if c.services.computeService, err = compute.NewService(ctx, opts...); err != nil {
+		t.Fatalf("could not create fake compute: %s", err)
+	}
+
+	return c
+}
+
+func TestApply(t *testing.T) {
+	fake := &fakeCloud{enabled: map[string]bool{}, creates: map[string]int{}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	stack := config.NewStack()
+	stack.Config.Services = []string{"run", "compute"}
+	stack.AddSetting("project_id", "ds-test")
+	stack.AddSetting("region", "us-central1")
+	stack.AddSetting("zone", "us-central1-a")
+	stack.AddSetting("instance-name", "ds-test-instance")
+	stack.AddSetting("instance-image", "debian-cloud/debian-11-bullseye-v20230306")
+	stack.AddSetting("instance-machine-type", "n1-standard-1")
+	stack.AddSetting("instance-disksize", "200")
+	stack.AddSetting("instance-disktype", "pd-standard")
+	stack.AddSetting("instance-tags", HTTPServerTags)
+	stack.AddSettingComplete(config.Setting{Name: "cost_labels", Type: "map", Map: map[string]string{"team": "data"}})
+	stack.AddSettingComplete(config.Setting{Name: "instance-labels", Type: "map", Map: map[string]string{"team": "data"}})
+
+	c := fakeCloudClient(t, srv)
+	got, err := c.Apply(&stack)
+	if err != nil {
+		t.Fatalf("expected: no error, got: %s", err)
+	}
+
+	want := ApplyResult{
+		Project:         "ds-test",
+		ProjectCreated:  true,
+		ServicesEnabled: []string{"compute.googleapis.com", "run.googleapis.com"},
+		Instance:        "ds-test-instance",
+		InstanceCreated: true,
+		IPs:             []string{"10.128.0.2", "34.1.2.3"},
+		URLs:            []string{"http://34.1.2.3", "https://34.1.2.3"},
+	}
+	assert.Equal(t, want, got)
+
+	assert.Equal(t, "projects/debian-cloud/global/images/debian-11-bullseye-v20230306", fake.instance.Disks[0].InitializeParams.SourceImage)
+	assert.Equal(t, "zones/us-central1-a/machineTypes/n1-standard-1", fake.instance.MachineType)
+	assert.Equal(t, "global/networks/default", fake.instance.NetworkInterfaces[0].Network)
+	assert.Equal(t, int64(200), fake.instance.Disks[0].InitializeParams.DiskSizeGb)
+	assert.Equal(t, map[string]string{"team": "data"}, fake.labels)
+	assert.Equal(t, map[string]string{"team": "data"}, fake.instance.Labels)
+
+	c = fakeCloudClient(t, srv)
+	got, err = c.Apply(&stack)
+	if err != nil {
+		t.Fatalf("expected: no error on rerun, got: %s", err)
+	}
+
+	want.ProjectCreated = false
+	want.ServicesEnabled = nil
+	want.InstanceCreated = false
+	assert.Equal(t, want, got)
+
+	assert.Equal(t, map[string]int{
+		"project":                1,
+		"compute.googleapis.com": 1,
+		"run.googleapis.com":     1,
+		"instance":               1,
+	}, fake.creates)
+}
+
+func TestApplyNoProject(t *testing.T) {
+	stack := config.NewStack()
+	c := NewClient(ctx, defaultUserAgent)
+
+	if _, err := c.Apply(&stack); err != ErrorProjectRequired {
+		t.Fatalf("expected: %s, got: %v", ErrorProjectRequired, err)
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	tests := map[string]struct {
+		in   string
+		want []string
+	}{
+		"basic":  {in: "[http-server,https-server]", want: []string{"http-server", "https-server"}},
+		"spaces": {in: "[ http-server , ssh ]", want: []string{"http-server", "ssh"}},
+		"bare":   {in: "web", want: []string{"web"}},
+		"empty":  {in: "[]", want: []string{}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, parseTags(tc.in))
+		})
+	}
+}
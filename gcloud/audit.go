@@ -0,0 +1,103 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcloud
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultAuditLogPath is where the audit log is written unless told otherwise
+var DefaultAuditLogPath = filepath.Join(".deploystack", "audit.log")
+
+// AuditEntry is a single line in the audit log, recording one mutating action
+// taken against Google Cloud.
+type AuditEntry struct {
+	Time    time.Time      `json:"time"`
+	Account string         `json:"account"`
+	Action  string         `json:"action"`
+	Details map[string]any `json:"details"`
+}
+
+// AuditLogEnable turns on recording every mutating action the client takes
+// to the file at path, as JSON lines. Pass an empty path to use
+// DefaultAuditLogPath.
+func (c *Client) AuditLogEnable(path string) {
+	if path == "" {
+		path = DefaultAuditLogPath
+	}
+	c.auditPath = path
+}
+
+// AuditLogDisable stops recording actions to the audit log
+func (c *Client) AuditLogDisable() {
+	c.auditPath = ""
+}
+
+// accountGet returns the account gcloud is currently authenticated as.
+func (c *Client) accountGet() string {
+	if i := c.get("account"); i != nil {
+		if account, ok := i.(string); ok {
+			return account
+		}
+	}
+
+	cmd := exec.Command("gcloud", "config", "get-value", "account")
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+
+	account := strings.TrimSpace(string(out))
+	c.save("account", account)
+
+	return account
+}
+
+// auditLog records an action to the audit log if it is enabled. Failing to
+// write the audit log never fails the action itself.
+func (c *Client) auditLog(action string, details map[string]any) {
+	if c.auditPath == "" {
+		return
+	}
+
+	entry := AuditEntry{
+		Time:    time.Now().UTC(),
+		Account: c.accountGet(),
+		Action:  action,
+		Details: details,
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.auditPath), 0o755); err != nil {
+		return
+	}
+
+	f, err := os.OpenFile(c.auditPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	f.Write(append(line, '\n'))
+}
@@ -0,0 +1,118 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcloud
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readAuditLog(t *testing.T, path string) []AuditEntry {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("could not open audit log: %s", err)
+	}
+	defer f.Close()
+
+	entries := []AuditEntry{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		entry := AuditEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("could not parse audit log line: %s", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+func TestAuditLog(t *testing.T) {
+	tests := map[string]struct {
+		enabled bool
+		action  string
+		details map[string]any
+		want    int
+	}{
+		"enabled": {
+			enabled: true,
+			action:  "ProjectCreate",
+			details: map[string]any{"project": "ds-test-project"},
+			want:    1,
+		},
+		"disabled": {
+			enabled: false,
+			action:  "ProjectCreate",
+			details: map[string]any{"project": "ds-test-project"},
+			want:    0,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := NewClient(ctx, defaultUserAgent)
+			c.save("account", "tester@example.com")
+
+			path := filepath.Join(t.TempDir(), ".deploystack", "audit.log")
+			if tc.enabled {
+				c.AuditLogEnable(path)
+			}
+
+			c.auditLog(tc.action, tc.details)
+
+			if tc.want == 0 {
+				_, err := os.Stat(path)
+				assert.True(t, os.IsNotExist(err))
+				return
+			}
+
+			got := readAuditLog(t, path)
+			assert.Len(t, got, tc.want)
+			assert.Equal(t, tc.action, got[0].Action)
+			assert.Equal(t, "tester@example.com", got[0].Account)
+			assert.Equal(t, tc.details, got[0].Details)
+			assert.False(t, got[0].Time.IsZero())
+		})
+	}
+}
+
+func TestAuditLogProjectCreate(t *testing.T) {
+	c := NewClient(ctx, defaultUserAgent)
+	path := filepath.Join(t.TempDir(), "audit.log")
+	c.AuditLogEnable(path)
+
+	name := "ds-audit-" + randSeq(5)
+	if err := c.ProjectCreate(name, creds["parent"], creds["parent_type"], ""); err != nil {
+		t.Fatalf("expected: no error, got: %v", err)
+	}
+	defer c.ProjectDelete(name)
+
+	got := readAuditLog(t, path)
+	if len(got) == 0 {
+		t.Fatalf("expected an audit entry, got none")
+	}
+
+	entry := got[0]
+	assert.Equal(t, "ProjectCreate", entry.Action)
+	assert.Equal(t, name, entry.Details["project"])
+	assert.Equal(t, creds["parent"], entry.Details["parent"])
+	assert.Equal(t, creds["parent_type"], entry.Details["parentType"])
+	assert.False(t, entry.Time.IsZero())
+}
@@ -17,10 +17,8 @@ package gcloud
 import (
 	"context"
 	"fmt"
-	"math/rand"
 	"strings"
 	"sync"
-	"time"
 
 	"google.golang.org/api/cloudbilling/v1"
 	"google.golang.org/api/cloudresourcemanager/v1"
@@ -45,7 +43,9 @@ func (c *Client) getCloudbillingService() (*cloudbilling.APIService, error) {
 	return svc, nil
 }
 
-// BillingAccountList gets a list of the billing accounts a user has access to
+// BillingAccountList gets a list of the open billing accounts a user has
+// access to. Closed accounts can't be linked to projects, so they are left
+// out.
 func (c *Client) BillingAccountList() ([]*cloudbilling.BillingAccount, error) {
 	resp := []*cloudbilling.BillingAccount{}
 
@@ -60,18 +60,40 @@ func (c *Client) BillingAccountList() ([]*cloudbilling.BillingAccount, error) {
 		return resp, err
 	}
 
-	results, err := svc.BillingAccounts.List().Do()
-	if err != nil {
-		return resp, err
+	token := ""
+	for {
+		var results *cloudbilling.ListBillingAccountsResponse
+		err = c.retry(c.ctx, func() error {
+			results, err = svc.BillingAccounts.List().PageToken(token).Do()
+			return err
+		})
+		if err != nil {
+			return resp, err
+		}
+
+		for _, v := range results.BillingAccounts {
+			if v.Open {
+				resp = append(resp, v)
+			}
+		}
+
+		token = results.NextPageToken
+		if token == "" {
+			break
+		}
 	}
 
-	c.save("BillingAccountList", results.BillingAccounts)
+	c.save("BillingAccountList", resp)
 
-	return results.BillingAccounts, nil
+	return resp, nil
 }
 
 // BillingAccountAttach will enable billing in a given project
 func (c *Client) BillingAccountAttach(project, account string) error {
+	if err := c.checkWritable("BillingAccountAttach"); err != nil {
+		return err
+	}
+
 	retries := 10
 	svc, err := c.getCloudbillingService()
 	if err != nil {
@@ -89,6 +111,10 @@ func (c *Client) BillingAccountAttach(project, account string) error {
 	for i := 0; i < retries; i++ {
 		_, looperr = svc.Projects.UpdateBillingInfo(proj, &cfg).Do()
 		if looperr == nil {
+			c.auditLog("BillingAccountAttach", map[string]any{
+				"project": project,
+				"account": account,
+			})
 			return nil
 		}
 		if strings.Contains(looperr.Error(), "User is not authorized to get billing info") {
@@ -111,8 +137,19 @@ func (c *Client) BillingAccountAttach(project, account string) error {
 	return looperr
 }
 
-// ProjectListWithBilling gets a list of projects with their billing information
+// billingLookupWorkers is how many projects ProjectListWithBilling looks up
+// billing for at once. Enough to make long project lists quick, few enough
+// not to trip the billing API quota.
+const billingLookupWorkers = 10
+
+// ProjectListWithBilling gets a list of projects with their billing
+// information, in the same order as p. Projects that aren't active, or whose
+// billing the user can't read, are left out.
 func (c *Client) ProjectListWithBilling(p []*cloudresourcemanager.Project) ([]ProjectWithBilling, error) {
+	return c.projectListWithBilling(c.ctx, p)
+}
+
+func (c *Client) projectListWithBilling(ctx context.Context, p []*cloudresourcemanager.Project) ([]ProjectWithBilling, error) {
 	res := []ProjectWithBilling{}
 
 	svc, err := c.getCloudbillingService()
@@ -121,51 +158,80 @@ func (c *Client) ProjectListWithBilling(p []*cloudresourcemanager.Project) ([]Pr
 	}
 
 	projs, _ := c.ProjectListWithBillingEnabled()
-	// if err != nil {
-	// 	return res, err
-	// }
 
-	var wg sync.WaitGroup
-	wg.Add(len(p))
+	found := make([]*ProjectWithBilling, len(p))
+	jobs := make(chan int)
 
-	for _, v := range p {
-		go func(p *cloudresourcemanager.Project) {
+	var wg sync.WaitGroup
+	for w := 0; w < billingLookupWorkers; w++ {
+		wg.Add(1)
+		go func() {
 			defer wg.Done()
-
-			if _, ok := projs[p.ProjectId]; ok {
-				pwb := ProjectWithBilling{Name: p.Name, ID: p.ProjectId, BillingEnabled: true}
-				res = append(res, pwb)
-				return
+			for i := range jobs {
+				found[i] = c.projectBilling(ctx, svc, projs, p[i])
 			}
+		}()
+	}
 
-			// Getting random quota errors when somebody had too many projects.
-			// sleeping randoming for a second fixed it.
-			// I don't think these requests can be fixed by batching.
-			sleepRandom()
-			if p.LifecycleState == "ACTIVE" && p.Name != "" {
-				proj := fmt.Sprintf("projects/%s", p.ProjectId)
-				tmp, err := svc.Projects.GetBillingInfo(proj).Do()
-				if err != nil {
-					if strings.Contains(err.Error(), "The caller does not have permission") {
-						// fmt.Printf("project: %+v\n", p)
-						return
-					}
-
-					fmt.Printf("error getting billing information: %s\n", err)
-					return
-				}
-
-				pwb := ProjectWithBilling{Name: p.Name, ID: p.ProjectId, BillingEnabled: tmp.BillingEnabled}
-				res = append(res, pwb)
-				return
-			}
-		}(v)
+	for i := range p {
+		jobs <- i
 	}
+	close(jobs)
 	wg.Wait()
 
+	for _, v := range found {
+		if v != nil {
+			res = append(res, *v)
+		}
+	}
+
 	return res, nil
 }
 
+// projectBilling works out the billing status of a single project, using
+// the projects already known to have billing enabled to skip a lookup
+func (c *Client) projectBilling(ctx context.Context, svc *cloudbilling.APIService, enabled map[string]bool, p *cloudresourcemanager.Project) *ProjectWithBilling {
+	if _, ok := enabled[p.ProjectId]; ok {
+		return &ProjectWithBilling{Name: p.Name, ID: p.ProjectId, BillingEnabled: true}
+	}
+
+	if p.LifecycleState != "ACTIVE" || p.Name == "" {
+		return nil
+	}
+
+	proj := fmt.Sprintf("projects/%s", p.ProjectId)
+	var tmp *cloudbilling.ProjectBillingInfo
+	err := c.retry(ctx, func() error {
+		var err error
+		tmp, err = svc.Projects.GetBillingInfo(proj).Do()
+		return err
+	})
+	if err != nil {
+		if !strings.Contains(err.Error(), "The caller does not have permission") {
+			fmt.Printf("error getting billing information: %s\n", err)
+		}
+		return nil
+	}
+
+	return &ProjectWithBilling{Name: p.Name, ID: p.ProjectId, BillingEnabled: tmp.BillingEnabled}
+}
+
+// BillingInfoGet retrieves the billing status of a project
+func (c *Client) BillingInfoGet(project string) (*cloudbilling.ProjectBillingInfo, error) {
+	svc, err := c.getCloudbillingService()
+	if err != nil {
+		return nil, err
+	}
+
+	proj := fmt.Sprintf("projects/%s", project)
+	result, err := svc.Projects.GetBillingInfo(proj).Do()
+	if err != nil {
+		return nil, fmt.Errorf("could not get billing info for project (%s): %w", project, err)
+	}
+
+	return result, nil
+}
+
 // ProjectListWithBillingEnabled queries the billing accounts a user has access to
 // to generate a list of projects for each billing account. Will hopefully
 // reduce the number of calls made to billing api
@@ -182,7 +248,12 @@ func (c *Client) ProjectListWithBillingEnabled() (map[string]bool, error) {
 	}
 
 	for _, v := range bas {
-		result, err := svc.BillingAccounts.Projects.List(v.Name).Do()
+		var result *cloudbilling.ListProjectBillingInfoResponse
+		err := c.retry(c.ctx, func() error {
+			var err error
+			result, err = svc.BillingAccounts.Projects.List(v.Name).Do()
+			return err
+		})
 		if err != nil {
 			return r, err
 		}
@@ -195,13 +266,3 @@ func (c *Client) ProjectListWithBillingEnabled() (map[string]bool, error) {
 
 	return r, nil
 }
-
-func randomInRange(min, max int) int {
-	rand.Seed(time.Now().UnixNano())
-	return rand.Intn(max-min+1) + min
-}
-
-func sleepRandom() {
-	d := time.Second * time.Duration(randomInRange(0, 1))
-	time.Sleep(d)
-}
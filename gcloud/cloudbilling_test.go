@@ -17,13 +17,22 @@ package gcloud
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"reflect"
 	"sort"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
 	"google.golang.org/api/cloudbilling/v1"
+	"google.golang.org/api/cloudresourcemanager/v1"
+	"google.golang.org/api/option"
 )
 
 func TestGetBillingAccounts(t *testing.T) {
@@ -110,3 +119,160 @@ func TestLinkProjectToBillingAccount(t *testing.T) {
 		})
 	}
 }
+
+func TestBillingAccountListOpen(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Query().Get("pageToken") {
+		case "":
+			fmt.Fprint(w, `{"billingAccounts":[
+				{"name":"billingAccounts/000000-000000-00000A","displayName":"Open One","open":true},
+				{"name":"billingAccounts/000000-000000-00000B","displayName":"Closed","open":false}
+			],"nextPageToken":"2"}`)
+		default:
+			fmt.Fprint(w, `{"billingAccounts":[
+				{"name":"billingAccounts/000000-000000-00000C","displayName":"Open Two","open":true}
+			]}`)
+		}
+	}))
+	defer srv.Close()
+
+	svc, err := cloudbilling.NewService(ctx,
+		option.WithEndpoint(srv.URL+"/"),
+		option.WithHTTPClient(srv.Client()),
+	)
+	if err != nil {
+		t.Fatalf("could not create fake billing: %s", err)
+	}
+
+	c := NewClient(ctx, defaultUserAgent)
+	c.services.billing = svc
+
+	got, err := c.BillingAccountList()
+	if err != nil {
+		t.Fatalf("expected: no error, got: %s", err)
+	}
+
+	names := []string{}
+	for _, v := range got {
+		names = append(names, v.DisplayName)
+	}
+	assert.Equal(t, []string{"Open One", "Open Two"}, names)
+}
+
+// fakeBillingServer answers billing lookups for projects named ds-NN: even
+// ones have billing, multiples of 7 can't be read, and ds-03 is found through
+// its billing account. It tracks how many lookups run at once.
+func fakeBillingServer(delay time.Duration) (*httptest.Server, *int32) {
+	var inflight, peak int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/v1/billingAccounts":
+			fmt.Fprint(w, `{"billingAccounts":[{"name":"billingAccounts/000000-000000-00000X","open":true}]}`)
+		case r.URL.Path == "/v1/billingAccounts/000000-000000-00000X/projects":
+			fmt.Fprint(w, `{"projectBillingInfo":[{"projectId":"ds-03","billingEnabled":true}]}`)
+		case strings.HasSuffix(r.URL.Path, "/billingInfo"):
+			n := atomic.AddInt32(&inflight, 1)
+			defer atomic.AddInt32(&inflight, -1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			time.Sleep(delay)
+
+			var num int
+			fmt.Sscanf(r.URL.Path, "/v1/projects/ds-%d/billingInfo", &num)
+			if num%7 == 0 {
+				w.WriteHeader(http.StatusForbidden)
+				fmt.Fprint(w, `{"error":{"code":403,"message":"The caller does not have permission"}}`)
+				return
+			}
+			fmt.Fprintf(w, `{"billingEnabled":%t}`, num%2 == 0)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	return srv, &peak
+}
+
+func fakeBillingProjects(n int) []*cloudresourcemanager.Project {
+	projects := []*cloudresourcemanager.Project{}
+	for i := 1; i <= n; i++ {
+		id := fmt.Sprintf("ds-%02d", i)
+		state := "ACTIVE"
+		if i%5 == 0 {
+			state = "DELETE_REQUESTED"
+		}
+		projects = append(projects, &cloudresourcemanager.Project{ProjectId: id, Name: id, LifecycleState: state})
+	}
+	return projects
+}
+
+func fakeBillingClient(tb testing.TB, srv *httptest.Server) Client {
+	svc, err := cloudbilling.NewService(ctx,
+		option.WithEndpoint(srv.URL+"/"),
+		option.WithHTTPClient(srv.Client()),
+	)
+	if err != nil {
+		tb.Fatalf("could not create fake billing: %s", err)
+	}
+
+	c := NewClient(ctx, defaultUserAgent)
+	c.services.billing = svc
+	return c
+}
+
+func TestProjectListWithBillingParallel(t *testing.T) {
+	srv, peak := fakeBillingServer(5 * time.Millisecond)
+	defer srv.Close()
+
+	projects := fakeBillingProjects(40)
+
+	// The serial version: one lookup after another
+	c := fakeBillingClient(t, srv)
+	enabled, err := c.ProjectListWithBillingEnabled()
+	if err != nil {
+		t.Fatalf("expected: no error, got: %s", err)
+	}
+	want := []ProjectWithBilling{}
+	for _, v := range projects {
+		if pwb := c.projectBilling(ctx, c.services.billing, enabled, v); pwb != nil {
+			want = append(want, *pwb)
+		}
+	}
+	atomic.StoreInt32(peak, 0)
+
+	c = fakeBillingClient(t, srv)
+	got, err := c.ProjectListWithBilling(projects)
+	if err != nil {
+		t.Fatalf("expected: no error, got: %s", err)
+	}
+
+	assert.Equal(t, want, got)
+	assert.Contains(t, got, ProjectWithBilling{Name: "ds-03", ID: "ds-03", BillingEnabled: true})
+	assert.NotContains(t, got, ProjectWithBilling{Name: "ds-07", ID: "ds-07"})
+	assert.NotContains(t, got, ProjectWithBilling{Name: "ds-10", ID: "ds-10", BillingEnabled: true})
+
+	assert.Greater(t, atomic.LoadInt32(peak), int32(1))
+	assert.LessOrEqual(t, atomic.LoadInt32(peak), int32(billingLookupWorkers))
+}
+
+func BenchmarkProjectListWithBilling(b *testing.B) {
+	srv, _ := fakeBillingServer(2 * time.Millisecond)
+	defer srv.Close()
+
+	projects := fakeBillingProjects(50)
+	c := fakeBillingClient(b, srv)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.ProjectListWithBilling(projects); err != nil {
+			b.Fatalf("expected: no error, got: %s", err)
+		}
+	}
+}
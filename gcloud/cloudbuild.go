@@ -45,6 +45,10 @@ func (c *Client) getCloudBuildService(project string) (*cloudbuild.Service, erro
 
 // CloudBuildTriggerCreate creates a build trigger in a given project
 func (c *Client) CloudBuildTriggerCreate(project string, trigger cloudbuild.BuildTrigger) (*cloudbuild.BuildTrigger, error) {
+	if err := c.checkWritable("CloudBuildTriggerCreate"); err != nil {
+		return nil, err
+	}
+
 	svc, err := c.getCloudBuildService(project)
 	if err != nil {
 		return nil, err
@@ -56,11 +60,20 @@ func (c *Client) CloudBuildTriggerCreate(project string, trigger cloudbuild.Buil
 		return nil, fmt.Errorf("cannot create trigger: %s", err)
 	}
 
+	c.auditLog("CloudBuildTriggerCreate", map[string]any{
+		"project": project,
+		"trigger": result.Id,
+	})
+
 	return result, nil
 }
 
 // CloudBuildTriggerDelete deletes a build trigger in a given project
 func (c *Client) CloudBuildTriggerDelete(project string, triggerid string) error {
+	if err := c.checkWritable("CloudBuildTriggerDelete"); err != nil {
+		return err
+	}
+
 	svc, err := c.getCloudBuildService(project)
 	if err != nil {
 		return err
@@ -70,5 +83,10 @@ func (c *Client) CloudBuildTriggerDelete(project string, triggerid string) error
 		return fmt.Errorf("cannot delete trigger: %s", err)
 	}
 
+	c.auditLog("CloudBuildTriggerDelete", map[string]any{
+		"project": project,
+		"trigger": triggerid,
+	})
+
 	return nil
 }
@@ -19,10 +19,12 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"strings"
 	"text/template"
 
 	domains "cloud.google.com/go/domains/apiv1beta1"
 	"google.golang.org/api/iterator"
+	"google.golang.org/api/siteverification/v1"
 	domainspb "google.golang.org/genproto/googleapis/cloud/domains/v1beta1"
 	"google.golang.org/genproto/googleapis/type/postaladdress"
 	"gopkg.in/yaml.v2"
@@ -232,8 +234,69 @@ func (c Client) DomainIsVerified(project, domain string) (bool, error) {
 	return false, nil
 }
 
+// DomainVerificationURL is where users prove they own a domain, which
+// Google Cloud needs before a domain can be mapped to a service.
+const DomainVerificationURL = "https://search.google.com/search-console/welcome"
+
+func (c *Client) getSiteVerificationService() (*siteverification.Service, error) {
+	var err error
+	svc := c.services.siteVerification
+
+	if svc != nil {
+		return svc, nil
+	}
+
+	svc, err = siteverification.NewService(c.ctx, c.opts)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve service: %w", err)
+	}
+
+	svc.UserAgent = c.userAgent
+	c.services.siteVerification = svc
+
+	return svc, nil
+}
+
+// DomainVerified checks whether the user has proven ownership of a domain
+// through Site Verification, which covers domains bought outside of Cloud
+// Domains. Verifying a domain also verifies its subdomains.
+func (c Client) DomainVerified(domain string) (bool, error) {
+	svc, err := c.getSiteVerificationService()
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := svc.WebResource.List().Do()
+	if err != nil {
+		return false, fmt.Errorf("listing verified sites failed: %s", err)
+	}
+
+	return domainVerified(resp.Items, domain), nil
+}
+
+func domainVerified(resources []*siteverification.SiteVerificationWebResourceResource, domain string) bool {
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+
+	for _, v := range resources {
+		if v.Site == nil || v.Site.Type != "INET_DOMAIN" {
+			continue
+		}
+
+		verified := strings.TrimSuffix(strings.ToLower(v.Site.Identifier), ".")
+		if domain == verified || strings.HasSuffix(domain, "."+verified) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // DomainRegister handles registring a domain on behalf of the user.
 func (c Client) DomainRegister(project string, domaininfo *domainspb.RegisterParameters, contact ContactData) error {
+	if err := c.checkWritable("DomainRegister"); err != nil {
+		return err
+	}
+
 	parent := fmt.Sprintf("projects/%s/locations/global", project)
 
 	svc, err := c.getDomainsClient(project)
@@ -273,5 +336,10 @@ func (c Client) DomainRegister(project string, domaininfo *domainspb.RegisterPar
 		return err
 	}
 
+	c.auditLog("DomainRegister", map[string]any{
+		"project": project,
+		"domain":  domaininfo.DomainName,
+	})
+
 	return nil
 }
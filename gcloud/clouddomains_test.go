@@ -25,6 +25,7 @@ import (
 	"github.com/go-test/deep"
 	"github.com/kylelemons/godebug/diff"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/siteverification/v1"
 	domainspb "google.golang.org/genproto/googleapis/cloud/domains/v1beta1"
 	"google.golang.org/genproto/googleapis/type/postaladdress"
 )
@@ -261,6 +262,32 @@ func TestDomainIsVerified(t *testing.T) {
 	}
 }
 
+func TestDomainVerified(t *testing.T) {
+	resources := []*siteverification.SiteVerificationWebResourceResource{
+		{Site: &siteverification.SiteVerificationWebResourceResourceSite{Type: "INET_DOMAIN", Identifier: "example.com"}},
+		{Site: &siteverification.SiteVerificationWebResourceResourceSite{Type: "SITE", Identifier: "http://example.org/"}},
+	}
+
+	tests := map[string]struct {
+		domain string
+		want   bool
+	}{
+		"verified":        {domain: "example.com", want: true},
+		"subdomain":       {domain: "app.example.com", want: true},
+		"trailingDot":     {domain: "Example.com.", want: true},
+		"unverified":      {domain: "example.net", want: false},
+		"siteNotDomain":   {domain: "example.org", want: false},
+		"lookalikeSuffix": {domain: "notexample.com", want: false},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := domainVerified(resources, tc.domain)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
 func TestDomainContact(t *testing.T) {
 	t.Parallel()
 	contact := &domainspb.ContactSettings_Contact{
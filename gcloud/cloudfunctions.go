@@ -73,6 +73,10 @@ func (c *Client) FunctionRegionList(project string) ([]string, error) {
 
 // FunctionDeploy deploys a Cloud Function.
 func (c *Client) FunctionDeploy(project, region string, f cloudfunctions.CloudFunction) error {
+	if err := c.checkWritable("FunctionDeploy"); err != nil {
+		return err
+	}
+
 	svc, err := c.getCloudFunctionsService(project)
 	if err != nil {
 		return err
@@ -83,11 +87,21 @@ func (c *Client) FunctionDeploy(project, region string, f cloudfunctions.CloudFu
 		return fmt.Errorf("could not create function: %s", err)
 	}
 
+	c.auditLog("FunctionDeploy", map[string]any{
+		"project":  project,
+		"region":   region,
+		"function": f.Name,
+	})
+
 	return nil
 }
 
 // FunctionDelete deletes a Cloud Function.
 func (c *Client) FunctionDelete(project, region, name string) error {
+	if err := c.checkWritable("FunctionDelete"); err != nil {
+		return err
+	}
+
 	svc, err := c.getCloudFunctionsService(project)
 	if err != nil {
 		return err
@@ -97,6 +111,12 @@ func (c *Client) FunctionDelete(project, region, name string) error {
 		return fmt.Errorf("could not create function: %s", err)
 	}
 
+	c.auditLog("FunctionDelete", map[string]any{
+		"project":  project,
+		"region":   region,
+		"function": name,
+	})
+
 	return nil
 }
 
@@ -15,14 +15,19 @@
 package gcloud
 
 import (
+	"context"
 	"fmt"
+	"math/rand"
 	"os/exec"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"google.golang.org/api/cloudbilling/v1"
 	"google.golang.org/api/cloudresourcemanager/v1"
+	resourcemanagerfolders "google.golang.org/api/cloudresourcemanager/v2"
 )
 
 func (c *Client) getCloudResourceManagerService() (*cloudresourcemanager.Service, error) {
@@ -44,6 +49,25 @@ func (c *Client) getCloudResourceManagerService() (*cloudresourcemanager.Service
 	return svc, nil
 }
 
+func (c *Client) getFoldersService() (*resourcemanagerfolders.Service, error) {
+	var err error
+	svc := c.services.folders
+
+	if svc != nil {
+		return svc, nil
+	}
+
+	svc, err = resourcemanagerfolders.NewService(c.ctx, c.opts)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve service: %w", err)
+	}
+
+	svc.UserAgent = c.userAgent
+	c.services.folders = svc
+
+	return svc, nil
+}
+
 // ProjectNumberGet will get the project_number for the input projectid
 func (c *Client) ProjectNumberGet(id string) (string, error) {
 	resp := ""
@@ -77,8 +101,156 @@ func (c *Client) ProjectParentGet(id string) (*cloudresourcemanager.ResourceId,
 	return results.Parent, nil
 }
 
+// OrganizationList returns the organizations the user can see, with the
+// organization id as the value and the display name as the label
+func (c *Client) OrganizationList() (LabeledValues, error) {
+	resp := LabeledValues{}
+
+	svc, err := c.getCloudResourceManagerService()
+	if err != nil {
+		return resp, err
+	}
+
+	orgs := []*cloudresourcemanager.Organization{}
+	req := &cloudresourcemanager.SearchOrganizationsRequest{}
+	err = svc.Organizations.Search(req).Pages(c.ctx, func(page *cloudresourcemanager.SearchOrganizationsResponse) error {
+		orgs = append(orgs, page.Organizations...)
+		return nil
+	})
+	if err != nil {
+		return resp, err
+	}
+
+	return organizationValues(orgs), nil
+}
+
+func organizationValues(orgs []*cloudresourcemanager.Organization) LabeledValues {
+	resp := LabeledValues{}
+
+	for _, v := range orgs {
+		id := strings.TrimPrefix(v.Name, "organizations/")
+		label := v.DisplayName
+		if label == "" {
+			label = id
+		}
+		resp = append(resp, LabeledValue{Value: id, Label: label})
+	}
+	resp.Sort()
+
+	return resp
+}
+
+// FolderList returns the active folders directly under parent, an
+// organization or folder like `organizations/123`, with the folder id as the
+// value and the display name as the label
+func (c *Client) FolderList(parent string) (LabeledValues, error) {
+	resp := LabeledValues{}
+
+	svc, err := c.getFoldersService()
+	if err != nil {
+		return resp, err
+	}
+
+	folders := []*resourcemanagerfolders.Folder{}
+	err = svc.Folders.List().Parent(parent).Pages(c.ctx, func(page *resourcemanagerfolders.ListFoldersResponse) error {
+		folders = append(folders, page.Folders...)
+		return nil
+	})
+	if err != nil {
+		return resp, err
+	}
+
+	return folderValues(folders), nil
+}
+
+func folderValues(folders []*resourcemanagerfolders.Folder) LabeledValues {
+	resp := LabeledValues{}
+
+	for _, v := range folders {
+		if v.LifecycleState != "" && v.LifecycleState != "ACTIVE" {
+			continue
+		}
+		id := strings.TrimPrefix(v.Name, "folders/")
+		label := v.DisplayName
+		if label == "" {
+			label = id
+		}
+		resp = append(resp, LabeledValue{Value: id, Label: label})
+	}
+	resp.Sort()
+
+	return resp
+}
+
+// ProjectDescription is a read only snapshot of a project
+type ProjectDescription struct {
+	ID              string
+	Number          string
+	ParentType      string
+	ParentID        string
+	BillingEnabled  bool
+	BillingAccount  string
+	EnabledServices int
+}
+
+// ProjectDescribe gathers up the number, parent, billing status and count of
+// enabled services of a project
+func (c *Client) ProjectDescribe(projectID string) (ProjectDescription, error) {
+	number, err := c.ProjectNumberGet(projectID)
+	if err != nil {
+		return ProjectDescription{}, fmt.Errorf("could not get project number: %w", err)
+	}
+
+	parent, err := c.ProjectParentGet(projectID)
+	if err != nil {
+		return ProjectDescription{}, fmt.Errorf("could not get project parent: %w", err)
+	}
+
+	billing, err := c.BillingInfoGet(projectID)
+	if err != nil {
+		return ProjectDescription{}, err
+	}
+
+	enabled, err := c.ServiceEnabledCount(projectID)
+	if err != nil {
+		return ProjectDescription{}, err
+	}
+
+	return newProjectDescription(projectID, number, parent, billing, enabled), nil
+}
+
+func newProjectDescription(id, number string, parent *cloudresourcemanager.ResourceId, billing *cloudbilling.ProjectBillingInfo, enabled int) ProjectDescription {
+	d := ProjectDescription{
+		ID:              id,
+		Number:          number,
+		EnabledServices: enabled,
+	}
+
+	if parent != nil {
+		d.ParentType = parent.Type
+		d.ParentID = parent.Id
+	}
+
+	if billing != nil {
+		d.BillingEnabled = billing.BillingEnabled
+		d.BillingAccount = strings.TrimPrefix(billing.BillingAccountName, "billingAccounts/")
+	}
+
+	return d
+}
+
 // ProjectList gets a list of the ProjectList a user has access to
 func (c *Client) ProjectList() ([]ProjectWithBilling, error) {
+	return c.projectList(c.ctx)
+}
+
+// ProjectListNotify is ProjectList, calling notify each time a lookup is
+// about to be retried, so that the caller can say why the list is slow
+func (c *Client) ProjectListNotify(notify func(attempt int, wait time.Duration)) ([]ProjectWithBilling, error) {
+	return c.projectList(WithRetryNotify(c.ctx, notify))
+}
+
+func (c *Client) projectList(ctx context.Context) ([]ProjectWithBilling, error) {
 	resp := []ProjectWithBilling{}
 
 	i := c.get("ProjectList")
@@ -92,12 +264,26 @@ func (c *Client) ProjectList() ([]ProjectWithBilling, error) {
 		return resp, err
 	}
 
-	results, err := svc.Projects.List().Filter("lifecycleState=ACTIVE").Do()
-	if err != nil {
-		return resp, err
+	projects := []*cloudresourcemanager.Project{}
+	token := ""
+	for {
+		var results *cloudresourcemanager.ListProjectsResponse
+		err = c.retry(ctx, func() error {
+			results, err = svc.Projects.List().Filter("lifecycleState=ACTIVE").PageToken(token).Do()
+			return err
+		})
+		if err != nil {
+			return resp, err
+		}
+		projects = append(projects, results.Projects...)
+
+		token = results.NextPageToken
+		if token == "" {
+			break
+		}
 	}
 
-	pwb, err := c.ProjectListWithBilling(results.Projects)
+	pwb, err := c.projectListWithBilling(ctx, projects)
 	if err != nil {
 		return resp, err
 	}
@@ -111,6 +297,56 @@ func (c *Client) ProjectList() ([]ProjectWithBilling, error) {
 	return pwb, nil
 }
 
+// ProjectListPage gets a single page of active projects, of up to pageSize,
+// along with the token for the next page, which is empty on the last one.
+// Pass an empty pageToken for the first page. Looking up billing for each
+// project is slow, so it is only done if SetProjectPageBilling turned it on;
+// otherwise BillingEnabled is always false.
+func (c *Client) ProjectListPage(pageToken string, pageSize int64) ([]ProjectWithBilling, string, error) {
+	resp := []ProjectWithBilling{}
+
+	svc, err := c.getCloudResourceManagerService()
+	if err != nil {
+		return resp, "", err
+	}
+
+	var results *cloudresourcemanager.ListProjectsResponse
+	err = c.retry(c.ctx, func() error {
+		call := svc.Projects.List().Filter("lifecycleState=ACTIVE").PageToken(pageToken)
+		if pageSize > 0 {
+			call = call.PageSize(pageSize)
+		}
+		results, err = call.Do()
+		return err
+	})
+	if err != nil {
+		return resp, "", err
+	}
+
+	if c.projectPageBilling {
+		resp, err = c.ProjectListWithBilling(results.Projects)
+		if err != nil {
+			return resp, "", err
+		}
+	} else {
+		for _, v := range results.Projects {
+			resp = append(resp, ProjectWithBilling{Name: v.Name, ID: v.ProjectId})
+		}
+	}
+
+	sort.Slice(resp, func(i, j int) bool {
+		return strings.ToLower(resp[i].Name) < strings.ToLower(resp[j].Name)
+	})
+
+	return resp, results.NextPageToken, nil
+}
+
+// SetProjectPageBilling sets whether ProjectListPage looks up the billing
+// status of each project it returns
+func (c *Client) SetProjectPageBilling(on bool) {
+	c.projectPageBilling = on
+}
+
 // ProjectWithBilling is a project with it's billing status
 type ProjectWithBilling struct {
 	Name           string
@@ -118,24 +354,280 @@ type ProjectWithBilling struct {
 	BillingEnabled bool
 }
 
-// ProjectCreate does the work of actually creating a new project in your
-// GCP account
-func (c *Client) ProjectCreate(project, parent, parentType string) error {
+// ProjectIDValidate checks a project id against the rules Google Cloud
+// enforces on creation so that bad ids can be caught without a round trip.
+// Project ids must be 6 to 30 characters, start with a lowercase letter, and
+// contain only lowercase letters, digits or hyphens, without a trailing hyphen.
+func ProjectIDValidate(project string) error {
+	if len(project) > 30 {
+		return ErrorProjectCreateTooLong
+	}
+
+	if len(project) < 6 {
+		return ErrorProjectCreateTooShort
+	}
+
+	if project[0] < 'a' || project[0] > 'z' || strings.HasSuffix(project, "-") {
+		return ErrorProjectInvalidCharacters
+	}
+
+	for _, r := range project {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+			return ErrorProjectInvalidCharacters
+		}
+	}
+
+	return nil
+}
+
+const (
+	// OrgPolicyResourceLocations is the constraint restricting the locations
+	// resources can be created in
+	OrgPolicyResourceLocations = "constraints/gcp.resourceLocations"
+	// OrgPolicyRequireShieldedVM is the constraint requiring Compute Engine
+	// instances to be Shielded VMs
+	OrgPolicyRequireShieldedVM = "constraints/compute.requireShieldedVm"
+	// OrgPolicyTrustedImageProjects is the constraint restricting which
+	// projects Compute Engine images can come from
+	OrgPolicyTrustedImageProjects = "constraints/compute.trustedImageProjects"
+)
+
+// OrgPolicyDenied returns the key OrgPolicyConstraints stores the denied
+// values of a list constraint under
+func OrgPolicyDenied(constraint string) string {
+	return constraint + ":denied"
+}
+
+// OrgPolicyConstraints retrieves the effective organization policy
+// constraints that affect the choices DeployStack offers. List constraints
+// map to their allowed values, and their denied values are under the key
+// OrgPolicyDenied returns. Enforced boolean constraints map to "true".
+// Constraints that aren't set are left out of the result.
+func (c *Client) OrgPolicyConstraints(project string) (map[string][]string, error) {
+	key := "OrgPolicyConstraints" + project
+	if i := c.get(key); i != nil {
+		if res, ok := i.(map[string][]string); ok {
+			return res, nil
+		}
+	}
+
 	svc, err := c.getCloudResourceManagerService()
 	if err != nil {
+		return nil, err
+	}
+
+	result := map[string][]string{}
+	resource := fmt.Sprintf("projects/%s", project)
+	constraints := []string{
+		OrgPolicyResourceLocations,
+		OrgPolicyRequireShieldedVM,
+		OrgPolicyTrustedImageProjects,
+	}
+
+	for _, v := range constraints {
+		req := &cloudresourcemanager.GetEffectiveOrgPolicyRequest{Constraint: v}
+		policy, err := svc.Projects.GetEffectiveOrgPolicy(resource, req).Do()
+		if err != nil {
+			return nil, fmt.Errorf("could not get org policy (%s): %w", v, err)
+		}
+
+		if policy.BooleanPolicy != nil && policy.BooleanPolicy.Enforced {
+			result[v] = []string{"true"}
+		}
+
+		if policy.ListPolicy != nil && len(policy.ListPolicy.AllowedValues) > 0 {
+			result[v] = policy.ListPolicy.AllowedValues
+		}
+
+		if policy.ListPolicy != nil && len(policy.ListPolicy.DeniedValues) > 0 {
+			result[OrgPolicyDenied(v)] = policy.ListPolicy.DeniedValues
+		}
+	}
+
+	c.save(key, result)
+
+	return result, nil
+}
+
+// orgPolicyValueGroups are the location value groups that don't just name
+// the prefix of the regions in them, mapped to the prefixes or regions that
+// they do cover. Region groups like `in:us-central1-locations` are worked
+// out from the name.
+var orgPolicyValueGroups = map[string][]string{
+	"us":           {"us-"},
+	"asia":         {"asia-"},
+	"australia":    {"australia-"},
+	"europe":       {"europe-"},
+	"me":           {"me-"},
+	"africa":       {"africa-"},
+	"northamerica": {"northamerica-", "us-"},
+	"southamerica": {"southamerica-"},
+	// The EU group only holds the regions inside the European Union, so not
+	// London or Zurich
+	"eu": {
+		"europe-central2",
+		"europe-north1",
+		"europe-north2",
+		"europe-southwest1",
+		"europe-west1",
+		"europe-west3",
+		"europe-west4",
+		"europe-west8",
+		"europe-west9",
+		"europe-west10",
+		"europe-west12",
+	},
+}
+
+// orgPolicyMatch reports whether value is covered by entry, one of the values
+// of a list constraint. known is false for value groups it can't resolve.
+func orgPolicyMatch(entry, value string) (matched, known bool) {
+	replacer := strings.NewReplacer("in:", "", "zones:", "", "regions:", "", "projects/", "")
+
+	a := replacer.Replace(entry)
+	names := []string{a}
+
+	if strings.HasPrefix(entry, "in:") {
+		group := strings.TrimSuffix(a, "-locations")
+		switch {
+		case orgPolicyValueGroups[group] != nil:
+			names = orgPolicyValueGroups[group]
+		case strings.Count(group, "-") == 1 && strings.IndexAny(group[len(group)-1:], "0123456789") == 0:
+			// A single region, like us-central1
+			names = []string{group}
+		default:
+			return false, false
+		}
+	}
+
+	for _, v := range names {
+		if strings.HasSuffix(v, "-") {
+			if strings.HasPrefix(value, v) {
+				return true, true
+			}
+			continue
+		}
+		if value == v || strings.HasPrefix(value, v+"-") {
+			return true, true
+		}
+	}
+
+	return false, true
+}
+
+// OrgPolicyAllows reports whether value is permitted by the allowed and
+// denied values of a list constraint. Value groups like `in:eu-locations` and
+// prefixes like `zones:` or `projects/` are understood, so `us-central1-a` is
+// allowed by `in:us-central1-locations`. Denied values win over allowed ones.
+// An empty allowed list allows everything, and so does a list made up only of
+// value groups that can't be resolved, leaving the API to reject anything
+// disallowed.
+func OrgPolicyAllows(allowed, denied []string, value string) bool {
+	for _, v := range denied {
+		if matched, _ := orgPolicyMatch(v, value); matched {
+			return false
+		}
+	}
+
+	if len(allowed) == 0 {
+		return true
+	}
+
+	resolved := false
+	for _, v := range allowed {
+		matched, known := orgPolicyMatch(v, value)
+		if matched {
+			return true
+		}
+		resolved = resolved || known
+	}
+
+	return !resolved
+}
+
+// ProjectIDSuffixLength is the number of random characters ProjectIDSuffix
+// generates
+const ProjectIDSuffixLength = 5
+
+func randSeq(n int) string {
+	letters := []rune("abcdefghijklmnopqrstuvwxyz")
+	b := make([]rune, n)
+	for i := range b {
+		b[i] = letters[rand.Intn(len(letters))]
+	}
+	return string(b)
+}
+
+// ProjectIDSuffix returns a random suffix that can be added to a project id
+// to make collisions with existing projects unlikely.
+func ProjectIDSuffix() string {
+	return randSeq(ProjectIDSuffixLength)
+}
+
+// ProjectIDAddSuffix appends suffix to base, truncating base so that the
+// result still fits in the 30 character limit on project ids.
+func ProjectIDAddSuffix(base, suffix string) string {
+	limit := 30 - len(suffix) - 1
+	if len(base) > limit {
+		base = base[:limit]
+	}
+	base = strings.TrimRight(base, "-")
+
+	return fmt.Sprintf("%s-%s", base, suffix)
+}
+
+// ProjectCreate does the work of actually creating a new project in your
+// GCP account. The project goes in the folder or organization with the id
+// parent, parentType saying which, or in no parent if parent is empty. If
+// billingAccount isn't empty the new project is linked to it, and failing to
+// do so returns an error wrapping ErrorProjectBillingLink with the project
+// left in place.
+func (c *Client) ProjectCreate(project, parent, parentType, billingAccount string) error {
+	return c.ProjectCreateWithLabels(project, parent, parentType, billingAccount, nil)
+}
+
+// projectParent returns where to create a project: in the folder or
+// organization with the id parent, or nowhere in particular if parent is
+// empty
+func projectParent(parent, parentType string) (*cloudresourcemanager.ResourceId, error) {
+	if parent == "" {
+		return nil, nil
+	}
+
+	switch parentType {
+	case "folder", "organization":
+		return &cloudresourcemanager.ResourceId{Id: parent, Type: parentType}, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrorProjectParentType, parentType)
+}
+
+// ProjectCreateWithLabels creates a new project like ProjectCreate, putting
+// labels on it, say the cost labels the stack collected
+func (c *Client) ProjectCreateWithLabels(project, parent, parentType, billingAccount string, labels map[string]string) error {
+	if err := c.checkWritable("ProjectCreateWithLabels"); err != nil {
+		return err
+	}
+
+	par, err := projectParent(parent, parentType)
+	if err != nil {
+		return err
+	}
+
+	if err := LabelsValidate(labels); err != nil {
 		return err
 	}
 
-	par := &cloudresourcemanager.ResourceId{}
-	if parent != "" && parentType != "" {
-		par.Id = parent
-		par.Type = parentType
+	svc, err := c.getCloudResourceManagerService()
+	if err != nil {
+		return err
 	}
 
 	proj := cloudresourcemanager.Project{
 		Name:      project,
 		ProjectId: project,
 		Parent:    par,
+		Labels:    labels,
 	}
 
 	result, err := svc.Projects.Create(&proj).Do()
@@ -165,6 +657,20 @@ func (c *Client) ProjectCreate(project, parent, parentType string) error {
 			if op.Error != nil {
 				return fmt.Errorf("project creation was unsuccessful, reason: %s ", op.Error.Message)
 			}
+			c.auditLog("ProjectCreate", map[string]any{
+				"project":    project,
+				"parent":     parent,
+				"parentType": parentType,
+			})
+
+			if billingAccount == "" {
+				return nil
+			}
+
+			account := strings.TrimPrefix(billingAccount, "billingAccounts/")
+			if err := c.BillingAccountAttach(project, account); err != nil {
+				return fmt.Errorf("%w: project (%s) account (%s): %w", ErrorProjectBillingLink, project, account, err)
+			}
 			return nil
 		}
 		time.Sleep(2 * time.Second)
@@ -188,9 +694,112 @@ func (c *Client) ProjectGet(project string) (*cloudresourcemanager.Project, erro
 	return proj, nil
 }
 
+var (
+	labelKeyRegex   = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,62}$`)
+	labelValueRegex = regexp.MustCompile(`^[a-z0-9_-]{0,63}$`)
+)
+
+// LabelValidate checks a single label against the Google Cloud label rules:
+// keys start with a lowercase letter, and keys and values only hold
+// lowercase letters, numbers, _ or -, up to 63 characters
+func LabelValidate(key, value string) error {
+	if !labelKeyRegex.MatchString(key) {
+		return fmt.Errorf("%w: key (%s) must start with a lowercase letter and only hold up to 63 lowercase letters, numbers, _ or -", ErrorLabelInvalid, key)
+	}
+	if !labelValueRegex.MatchString(value) {
+		return fmt.Errorf("%w: value (%s) must only hold up to 63 lowercase letters, numbers, _ or -", ErrorLabelInvalid, value)
+	}
+
+	return nil
+}
+
+// LabelsValidate checks every label in labels with LabelValidate
+func LabelsValidate(labels map[string]string) error {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if err := LabelValidate(k, labels[k]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ProjectLabelsGet returns the labels on a project
+func (c *Client) ProjectLabelsGet(id string) (map[string]string, error) {
+	proj, err := c.ProjectGet(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if proj.Labels == nil {
+		return map[string]string{}, nil
+	}
+
+	return proj.Labels, nil
+}
+
+// ProjectLabelsSet puts labels on a project. Labels already on the project
+// are kept, unless labels has a new value for them.
+func (c *Client) ProjectLabelsSet(id string, labels map[string]string) error {
+	if err := c.checkWritable("ProjectLabelsSet"); err != nil {
+		return err
+	}
+
+	if err := LabelsValidate(labels); err != nil {
+		return err
+	}
+
+	svc, err := c.getCloudResourceManagerService()
+	if err != nil {
+		return err
+	}
+
+	proj, err := svc.Projects.Get(id).Do()
+	if err != nil {
+		return err
+	}
+
+	proj.Labels = mergeLabels(proj.Labels, labels)
+
+	if _, err := svc.Projects.Update(id, proj).Do(); err != nil {
+		return err
+	}
+
+	c.auditLog("ProjectLabelsSet", map[string]any{
+		"project": id,
+		"labels":  labels,
+	})
+
+	return nil
+}
+
+// mergeLabels returns the labels in existing, overwritten and added to by
+// those in labels
+func mergeLabels(existing, labels map[string]string) map[string]string {
+	out := make(map[string]string, len(existing)+len(labels))
+	for k, v := range existing {
+		out[k] = v
+	}
+	for k, v := range labels {
+		out[k] = v
+	}
+
+	return out
+}
+
 // ProjectDelete does the work of actually deleting an existing project in
 // your GCP account
 func (c *Client) ProjectDelete(project string) error {
+	if err := c.checkWritable("ProjectDelete"); err != nil {
+		return err
+	}
+
 	svc, err := c.getCloudResourceManagerService()
 	if err != nil {
 		return err
@@ -201,11 +810,17 @@ func (c *Client) ProjectDelete(project string) error {
 		return err
 	}
 
+	c.auditLog("ProjectDelete", map[string]any{"project": project})
+
 	return nil
 }
 
 // ProjectGrantIAMRole grants a given principal a given role in a given project
 func (c *Client) ProjectGrantIAMRole(project, role, principal string) error {
+	if err := c.checkWritable("ProjectGrantIAMRole"); err != nil {
+		return err
+	}
+
 	svc, err := c.getCloudResourceManagerService()
 	if err != nil {
 		return err
@@ -229,6 +844,12 @@ func (c *Client) ProjectGrantIAMRole(project, role, principal string) error {
 		return fmt.Errorf("cannot set iam policy role (%s) for project (%s): %s", role, project, err)
 	}
 
+	c.auditLog("ProjectGrantIAMRole", map[string]any{
+		"project":   project,
+		"role":      role,
+		"principal": principal,
+	})
+
 	return nil
 }
 
@@ -245,6 +866,10 @@ func (c Client) ProjectIDGet() (string, error) {
 
 // ProjectIDSet sets the currently set default project
 func (c *Client) ProjectIDSet(project string) error {
+	if err := c.checkWritable("ProjectIDSet"); err != nil {
+		return err
+	}
+
 	cmd := exec.Command("gcloud", "config", "set", "project", project)
 	_, err := cmd.Output()
 	if err != nil {
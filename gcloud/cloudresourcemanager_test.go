@@ -15,13 +15,25 @@
 package gcloud
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"path/filepath"
 	"reflect"
 	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/cloudbilling/v1"
 	"google.golang.org/api/cloudresourcemanager/v1"
+	resourcemanagerfolders "google.golang.org/api/cloudresourcemanager/v2"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
 )
 
 func TestGetProjectNumbers(t *testing.T) {
@@ -201,7 +213,7 @@ func TestCreateProject(t *testing.T) {
 				name = tc.input
 			}
 
-			err := c.ProjectCreate(name, creds["parent"], creds["parent_type"])
+			err := c.ProjectCreate(name, creds["parent"], creds["parent_type"], "")
 
 			// Don't accidentally delete the project that you are using to run
 			// these tests. Yes I found out the hard way
@@ -243,3 +255,669 @@ func TestGetProject(t *testing.T) {
 		t.Fatalf("resetting old project: expected: no error, got: %v", err)
 	}
 }
+
+func TestOrgPolicyConstraints(t *testing.T) {
+	c := NewClient(ctx, defaultUserAgent)
+
+	got, err := c.OrgPolicyConstraints(projectID)
+	if err != nil {
+		t.Fatalf("expected: no error, got: %v", err)
+	}
+
+	cached, err := c.OrgPolicyConstraints(projectID)
+	if err != nil {
+		t.Fatalf("expected: no error, got: %v", err)
+	}
+
+	if !reflect.DeepEqual(got, cached) {
+		t.Fatalf("expected: %v, got: %v", got, cached)
+	}
+}
+
+func TestProjectIDValidate(t *testing.T) {
+	tests := map[string]struct {
+		in  string
+		err error
+	}{
+		"valid":          {"ds-test-project-1", nil},
+		"toolong":        {"ds-test-project-that-is-way-too-long", ErrorProjectCreateTooLong},
+		"tooshort":       {"ds-te", ErrorProjectCreateTooShort},
+		"uppercase":      {"DS-test-project", ErrorProjectInvalidCharacters},
+		"badchars":       {"ds-test-project!", ErrorProjectInvalidCharacters},
+		"startswithnum":  {"1ds-test-project", ErrorProjectInvalidCharacters},
+		"trailinghyphen": {"ds-test-project-", ErrorProjectInvalidCharacters},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := ProjectIDValidate(tc.in)
+			if err != tc.err {
+				t.Fatalf("expected: %v got: %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestProjectIDAddSuffix(t *testing.T) {
+	tests := map[string]struct {
+		base string
+		want string
+	}{
+		"short":   {"ds-test", "ds-test-abcde"},
+		"long":    {"ds-test-project-that-is-way-too-long", "ds-test-project-that-is-abcde"},
+		"hyphens": {"ds-test-project-that-i---long", "ds-test-project-that-i-abcde"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := ProjectIDAddSuffix(tc.base, "abcde")
+			if got != tc.want {
+				t.Fatalf("expected: %s got: %s", tc.want, got)
+			}
+
+			if err := ProjectIDValidate(got); err != nil {
+				t.Fatalf("expected: no error got: %s", err)
+			}
+		})
+	}
+
+	random := ProjectIDAddSuffix("ds-test-project-that-is-way-too-long", ProjectIDSuffix())
+	if err := ProjectIDValidate(random); err != nil {
+		t.Fatalf("expected: no error got: %s", err)
+	}
+}
+
+func TestOrgPolicyAllows(t *testing.T) {
+	tests := map[string]struct {
+		allowed []string
+		denied  []string
+		value   string
+		want    bool
+	}{
+		"empty":         {nil, nil, "us-central1", true},
+		"region":        {[]string{"us-central1"}, nil, "us-central1", true},
+		"zoneinregion":  {[]string{"in:us-central1-locations"}, nil, "us-central1-a", true},
+		"group":         {[]string{"in:us-locations"}, nil, "us-east1", true},
+		"outsidegroup":  {[]string{"in:us-locations"}, nil, "europe-west1", false},
+		"eugroup":       {[]string{"in:eu-locations"}, nil, "europe-west1", true},
+		"euzone":        {[]string{"in:eu-locations"}, nil, "europe-west4-b", true},
+		"eunotlondon":   {[]string{"in:eu-locations"}, nil, "europe-west2", false},
+		"eunotus":       {[]string{"in:eu-locations"}, nil, "us-central1", false},
+		"europegroup":   {[]string{"in:europe-locations"}, nil, "europe-west2", true},
+		"northamerica":  {[]string{"in:northamerica-locations"}, nil, "northamerica-northeast1", true},
+		"northamericus": {[]string{"in:northamerica-locations"}, nil, "us-west1", true},
+		"unknowngroup":  {[]string{"in:gcp-somewhere-locations"}, nil, "us-west1", true},
+		"unknownmixed":  {[]string{"in:gcp-somewhere-locations", "in:eu-locations"}, nil, "us-west1", false},
+		"zoneprefix":    {[]string{"zones:us-central1-b"}, nil, "us-central1-a", false},
+		"imageproject":  {[]string{"projects/debian-cloud"}, nil, "debian-cloud", true},
+		"otherproject":  {[]string{"projects/debian-cloud"}, nil, "centos-cloud", false},
+		"similarregion": {[]string{"us-east1"}, nil, "us-east4", false},
+		"denied":        {nil, []string{"in:us-east1-locations"}, "us-east1", false},
+		"deniedzone":    {nil, []string{"in:us-east1-locations"}, "us-east1-b", false},
+		"notdenied":     {nil, []string{"in:us-east1-locations"}, "us-east4", true},
+		"deniedwins":    {[]string{"in:us-locations"}, []string{"zones:us-east1-b"}, "us-east1-b", false},
+		"deniedproject": {nil, []string{"projects/centos-cloud"}, "centos-cloud", false},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := OrgPolicyAllows(tc.allowed, tc.denied, tc.value)
+			if got != tc.want {
+				t.Fatalf("expected: %t got: %t", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestNewProjectDescription(t *testing.T) {
+	tests := map[string]struct {
+		parent  *cloudresourcemanager.ResourceId
+		billing *cloudbilling.ProjectBillingInfo
+		enabled int
+		want    ProjectDescription
+	}{
+		"full": {
+			parent: &cloudresourcemanager.ResourceId{Type: "organization", Id: "123456789"},
+			billing: &cloudbilling.ProjectBillingInfo{
+				BillingEnabled:     true,
+				BillingAccountName: "billingAccounts/000000-000000-000000",
+			},
+			enabled: 12,
+			want: ProjectDescription{
+				ID:              "ds-test-project",
+				Number:          "1234",
+				ParentType:      "organization",
+				ParentID:        "123456789",
+				BillingEnabled:  true,
+				BillingAccount:  "000000-000000-000000",
+				EnabledServices: 12,
+			},
+		},
+		"noParentNoBilling": {
+			billing: &cloudbilling.ProjectBillingInfo{},
+			want: ProjectDescription{
+				ID:     "ds-test-project",
+				Number: "1234",
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := newProjectDescription("ds-test-project", "1234", tc.parent, tc.billing, tc.enabled)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestOrganizationValues(t *testing.T) {
+	in := []*cloudresourcemanager.Organization{
+		{Name: "organizations/222", DisplayName: "zeta.example.com"},
+		{Name: "organizations/111", DisplayName: "alpha.example.com"},
+		{Name: "organizations/333"},
+	}
+
+	want := LabeledValues{
+		{Value: "333", Label: "333"},
+		{Value: "111", Label: "alpha.example.com"},
+		{Value: "222", Label: "zeta.example.com"},
+	}
+
+	assert.Equal(t, want, organizationValues(in))
+}
+
+func TestProjectListRateLimited(t *testing.T) {
+	oldDelay := retryBaseDelay
+	retryBaseDelay = time.Millisecond
+	defer func() { retryBaseDelay = oldDelay }()
+
+	var mu sync.Mutex
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/v1/projects":
+			mu.Lock()
+			calls++
+			first := calls == 1
+			mu.Unlock()
+			if first {
+				w.WriteHeader(http.StatusTooManyRequests)
+				fmt.Fprint(w, `{"error":{"code":429,"message":"Quota exceeded","status":"RESOURCE_EXHAUSTED"}}`)
+				return
+			}
+			fmt.Fprint(w, `{"projects":[
+				{"projectId":"ds-one","name":"ds-one","lifecycleState":"ACTIVE"},
+				{"projectId":"ds-two","name":"ds-two","lifecycleState":"ACTIVE"}
+			]}`)
+		case r.URL.Path == "/v1/billingAccounts":
+			fmt.Fprint(w, `{"billingAccounts":[]}`)
+		case strings.HasSuffix(r.URL.Path, "/billingInfo"):
+			fmt.Fprint(w, `{"billingEnabled":true}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	opts := []option.ClientOption{
+		option.WithEndpoint(srv.URL + "/"),
+		option.WithHTTPClient(srv.Client()),
+	}
+
+	c := NewClient(ctx, defaultUserAgent)
+	crm, err := cloudresourcemanager.NewService(ctx, opts...)
+	if err != nil {
+		t.Fatalf("could not create fake resource manager: %s", err)
+	}
+	billing, err := cloudbilling.NewService(ctx, opts...)
+	if err != nil {
+		t.Fatalf("could not create fake billing: %s", err)
+	}
+	c.services.resourceManager = crm
+	c.services.billing = billing
+
+	notified := 0
+	c.SetRetryNotify(func(attempt int, wait time.Duration) {
+		notified++
+	})
+
+	got, err := c.ProjectList()
+	if err != nil {
+		t.Fatalf("expected: no error, got: %s", err)
+	}
+
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, 1, notified)
+	assert.Equal(t, []ProjectWithBilling{
+		{Name: "ds-one", ID: "ds-one", BillingEnabled: true},
+		{Name: "ds-two", ID: "ds-two", BillingEnabled: true},
+	}, got)
+}
+
+func TestIsRateLimited(t *testing.T) {
+	assert.True(t, IsRateLimited(&googleapi.Error{Code: http.StatusTooManyRequests}))
+	assert.True(t, IsRateLimited(fmt.Errorf("googleapi: Error 403: rateLimitExceeded")))
+	assert.False(t, IsRateLimited(&googleapi.Error{Code: http.StatusForbidden}))
+	assert.False(t, IsRateLimited(nil))
+}
+
+func TestProjectListPage(t *testing.T) {
+	var mu sync.Mutex
+	billingCalls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/v1/projects":
+			if got := r.URL.Query().Get("pageSize"); got != "2" {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintf(w, `{"error":{"code":400,"message":"unexpected pageSize %s"}}`, got)
+				return
+			}
+			switch r.URL.Query().Get("pageToken") {
+			case "":
+				fmt.Fprint(w, `{"projects":[
+					{"projectId":"ds-two","name":"ds-two","lifecycleState":"ACTIVE"},
+					{"projectId":"ds-one","name":"ds-one","lifecycleState":"ACTIVE"}
+				],"nextPageToken":"page2"}`)
+			case "page2":
+				fmt.Fprint(w, `{"projects":[
+					{"projectId":"ds-three","name":"ds-three","lifecycleState":"ACTIVE"}
+				]}`)
+			default:
+				w.WriteHeader(http.StatusBadRequest)
+			}
+		case r.URL.Path == "/v1/billingAccounts":
+			fmt.Fprint(w, `{"billingAccounts":[]}`)
+		case strings.HasSuffix(r.URL.Path, "/billingInfo"):
+			mu.Lock()
+			billingCalls++
+			mu.Unlock()
+			fmt.Fprint(w, `{"billingEnabled":true}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	opts := []option.ClientOption{
+		option.WithEndpoint(srv.URL + "/"),
+		option.WithHTTPClient(srv.Client()),
+	}
+
+	tests := map[string]struct {
+		token     string
+		billing   bool
+		want      []ProjectWithBilling
+		wantToken string
+	}{
+		"firstPage": {
+			want: []ProjectWithBilling{
+				{Name: "ds-one", ID: "ds-one"},
+				{Name: "ds-two", ID: "ds-two"},
+			},
+			wantToken: "page2",
+		},
+		"lastPage": {
+			token: "page2",
+			want:  []ProjectWithBilling{{Name: "ds-three", ID: "ds-three"}},
+		},
+		"withBilling": {
+			billing: true,
+			want: []ProjectWithBilling{
+				{Name: "ds-one", ID: "ds-one", BillingEnabled: true},
+				{Name: "ds-two", ID: "ds-two", BillingEnabled: true},
+			},
+			wantToken: "page2",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			mu.Lock()
+			billingCalls = 0
+			mu.Unlock()
+
+			c := NewClient(ctx, defaultUserAgent)
+			crm, err := cloudresourcemanager.NewService(ctx, opts...)
+			if err != nil {
+				t.Fatalf("could not create fake resource manager: %s", err)
+			}
+			billing, err := cloudbilling.NewService(ctx, opts...)
+			if err != nil {
+				t.Fatalf("could not create fake billing: %s", err)
+			}
+			c.services.resourceManager = crm
+			c.services.billing = billing
+			c.SetProjectPageBilling(tc.billing)
+
+			got, token, err := c.ProjectListPage(tc.token, 2)
+			if err != nil {
+				t.Fatalf("expected: no error, got: %s", err)
+			}
+
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantToken, token)
+			if !tc.billing {
+				assert.Equal(t, 0, billingCalls)
+			}
+		})
+	}
+}
+
+func TestProjectCreateBilling(t *testing.T) {
+	tests := map[string]struct {
+		account     string
+		linkFails   bool
+		wantLinks   int
+		wantAccount string
+		err         error
+	}{
+		"NoAccount": {},
+		"Account": {
+			account:     "000000-000000-00000X",
+			wantLinks:   1,
+			wantAccount: "billingAccounts/000000-000000-00000X",
+		},
+		"PrefixedAccount": {
+			account:     "billingAccounts/000000-000000-00000X",
+			wantLinks:   1,
+			wantAccount: "billingAccounts/000000-000000-00000X",
+		},
+		"LinkFails": {
+			account:   "000000-000000-00000Y",
+			linkFails: true,
+			wantLinks: 10,
+			err:       ErrorProjectBillingLink,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var mu sync.Mutex
+			links := 0
+			account := ""
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				switch {
+				case r.URL.Path == "/v1/projects" && r.Method == http.MethodPost:
+					fmt.Fprint(w, `{"name":"operations/cp.1"}`)
+				case r.URL.Path == "/v1/operations/cp.1":
+					fmt.Fprint(w, `{"name":"operations/cp.1","done":true}`)
+				case r.URL.Path == "/v1/projects/ds-billing-test/billingInfo" && r.Method == http.MethodPut:
+					mu.Lock()
+					links++
+					mu.Unlock()
+					if tc.linkFails {
+						w.WriteHeader(http.StatusBadRequest)
+						fmt.Fprint(w, `{"error":{"code":400,"message":"Request contains an invalid argument."}}`)
+						return
+					}
+					info := cloudbilling.ProjectBillingInfo{}
+					json.NewDecoder(r.Body).Decode(&info)
+					account = info.BillingAccountName
+					fmt.Fprint(w, `{"billingEnabled":true}`)
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer srv.Close()
+
+			opts := []option.ClientOption{
+				option.WithEndpoint(srv.URL + "/"),
+				option.WithHTTPClient(srv.Client()),
+			}
+
+			c := NewClient(ctx, defaultUserAgent)
+			crm, err := cloudresourcemanager.NewService(ctx, opts...)
+			if err != nil {
+				t.Fatalf("could not create fake resource manager: %s", err)
+			}
+			billing, err := cloudbilling.NewService(ctx, opts...)
+			if err != nil {
+				t.Fatalf("could not create fake billing: %s", err)
+			}
+			c.services.resourceManager = crm
+			c.services.billing = billing
+
+			err = c.ProjectCreate("ds-billing-test", "", "", tc.account)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("expected: %v, got: %v", tc.err, err)
+			}
+			if tc.linkFails && !errors.Is(err, ErrorBillingInvalidAccount) {
+				t.Fatalf("expected the cause to be kept, got: %v", err)
+			}
+
+			assert.Equal(t, tc.wantLinks, links)
+			assert.Equal(t, tc.wantAccount, account)
+		})
+	}
+}
+
+func TestProjectCreateParent(t *testing.T) {
+	tests := map[string]struct {
+		parent     string
+		parentType string
+		want       *cloudresourcemanager.ResourceId
+		err        error
+	}{
+		"organization": {
+			parent:     "298490623289",
+			parentType: "organization",
+			want:       &cloudresourcemanager.ResourceId{Id: "298490623289", Type: "organization"},
+		},
+		"folder": {
+			parent:     "456",
+			parentType: "folder",
+			want:       &cloudresourcemanager.ResourceId{Id: "456", Type: "folder"},
+		},
+		"none": {},
+		"badType": {
+			parent:     "456",
+			parentType: "project",
+			err:        ErrorProjectParentType,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var got *cloudresourcemanager.ResourceId
+			creates := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				switch {
+				case r.URL.Path == "/v1/projects" && r.Method == http.MethodPost:
+					creates++
+					proj := cloudresourcemanager.Project{}
+					json.NewDecoder(r.Body).Decode(&proj)
+					got = proj.Parent
+					fmt.Fprint(w, `{"name":"operations/cp.1"}`)
+				case r.URL.Path == "/v1/operations/cp.1":
+					fmt.Fprint(w, `{"name":"operations/cp.1","done":true}`)
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer srv.Close()
+
+			c := NewClient(ctx, defaultUserAgent)
+			crm, err := cloudresourcemanager.NewService(ctx,
+				option.WithEndpoint(srv.URL+"/"),
+				option.WithHTTPClient(srv.Client()),
+			)
+			if err != nil {
+				t.Fatalf("could not create fake resource manager: %s", err)
+			}
+			c.services.resourceManager = crm
+
+			err = c.ProjectCreate("ds-parent-test", tc.parent, tc.parentType, "")
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("expected: %v, got: %v", tc.err, err)
+			}
+			if tc.err != nil {
+				assert.Equal(t, 0, creates)
+				return
+			}
+
+			assert.Equal(t, 1, creates)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestProjectLabelsSet(t *testing.T) {
+	var got map[string]string
+	updates := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/v1/projects/ds-labels" && r.Method == http.MethodGet:
+			fmt.Fprint(w, `{"projectId":"ds-labels","labels":{"owner":"alice","team":"web"}}`)
+		case r.URL.Path == "/v1/projects/ds-labels" && r.Method == http.MethodPut:
+			updates++
+			proj := cloudresourcemanager.Project{}
+			json.NewDecoder(r.Body).Decode(&proj)
+			got = proj.Labels
+			json.NewEncoder(w).Encode(proj)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(ctx, defaultUserAgent)
+	crm, err := cloudresourcemanager.NewService(ctx,
+		option.WithEndpoint(srv.URL+"/"),
+		option.WithHTTPClient(srv.Client()),
+	)
+	if err != nil {
+		t.Fatalf("could not create fake resource manager: %s", err)
+	}
+	c.services.resourceManager = crm
+
+	existing, err := c.ProjectLabelsGet("ds-labels")
+	if err != nil {
+		t.Fatalf("expected no error getting labels, got: %s", err)
+	}
+	assert.Equal(t, map[string]string{"owner": "alice", "team": "web"}, existing)
+
+	err = c.ProjectLabelsSet("ds-labels", map[string]string{"team": "data", "env": "prod"})
+	if err != nil {
+		t.Fatalf("expected no error setting labels, got: %s", err)
+	}
+	assert.Equal(t, 1, updates)
+	assert.Equal(t, map[string]string{"owner": "alice", "team": "data", "env": "prod"}, got)
+
+	err = c.ProjectLabelsSet("ds-labels", map[string]string{"Team": "data"})
+	if !errors.Is(err, ErrorLabelInvalid) {
+		t.Fatalf("expected: %v, got: %v", ErrorLabelInvalid, err)
+	}
+	assert.Equal(t, 1, updates)
+}
+
+func TestLabelValidate(t *testing.T) {
+	tests := map[string]struct {
+		key   string
+		value string
+		err   error
+	}{
+		"basic":             {key: "team", value: "data"},
+		"emptyValue":        {key: "team", value: ""},
+		"allowedChars":      {key: "cost_center-1", value: "a-b_2"},
+		"longest":           {key: "a" + strings.Repeat("b", 62), value: strings.Repeat("c", 63)},
+		"emptyKey":          {key: "", value: "data", err: ErrorLabelInvalid},
+		"upperKey":          {key: "Team", value: "data", err: ErrorLabelInvalid},
+		"upperValue":        {key: "team", value: "Data", err: ErrorLabelInvalid},
+		"keyStartsNumber":   {key: "1team", value: "data", err: ErrorLabelInvalid},
+		"keyTooLong":        {key: "a" + strings.Repeat("b", 63), value: "data", err: ErrorLabelInvalid},
+		"valueTooLong":      {key: "team", value: strings.Repeat("c", 64), err: ErrorLabelInvalid},
+		"valueHasSpace":     {key: "team", value: "big data", err: ErrorLabelInvalid},
+		"keyHasPunctuation": {key: "team.name", value: "data", err: ErrorLabelInvalid},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := LabelValidate(tc.key, tc.value)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("expected: %v, got: %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestProjectListPages(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/v1/projects":
+			calls++
+			switch r.URL.Query().Get("pageToken") {
+			case "":
+				fmt.Fprint(w, `{"projects":[
+					{"projectId":"ds-two","name":"ds-two","lifecycleState":"ACTIVE"},
+					{"projectId":"ds-one","name":"ds-one","lifecycleState":"ACTIVE"}
+				],"nextPageToken":"page2"}`)
+			case "page2":
+				fmt.Fprint(w, `{"projects":[
+					{"projectId":"ds-three","name":"ds-three","lifecycleState":"ACTIVE"}
+				]}`)
+			default:
+				w.WriteHeader(http.StatusBadRequest)
+			}
+		case r.URL.Path == "/v1/billingAccounts":
+			fmt.Fprint(w, `{"billingAccounts":[]}`)
+		case strings.HasSuffix(r.URL.Path, "/billingInfo"):
+			fmt.Fprint(w, `{"billingEnabled":true}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	opts := []option.ClientOption{
+		option.WithEndpoint(srv.URL + "/"),
+		option.WithHTTPClient(srv.Client()),
+	}
+
+	c := NewClient(ctx, defaultUserAgent)
+	crm, err := cloudresourcemanager.NewService(ctx, opts...)
+	if err != nil {
+		t.Fatalf("could not create fake resource manager: %s", err)
+	}
+	billing, err := cloudbilling.NewService(ctx, opts...)
+	if err != nil {
+		t.Fatalf("could not create fake billing: %s", err)
+	}
+	c.services.resourceManager = crm
+	c.services.billing = billing
+
+	got, err := c.ProjectList()
+	if err != nil {
+		t.Fatalf("expected: no error, got: %s", err)
+	}
+
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, []ProjectWithBilling{
+		{Name: "ds-one", ID: "ds-one", BillingEnabled: true},
+		{Name: "ds-three", ID: "ds-three", BillingEnabled: true},
+		{Name: "ds-two", ID: "ds-two", BillingEnabled: true},
+	}, got)
+}
+
+func TestFolderValues(t *testing.T) {
+	in := []*resourcemanagerfolders.Folder{
+		{Name: "folders/222", DisplayName: "Production", LifecycleState: "ACTIVE"},
+		{Name: "folders/111", DisplayName: "Development", LifecycleState: "ACTIVE"},
+		{Name: "folders/444", DisplayName: "Old", LifecycleState: "DELETE_REQUESTED"},
+		{Name: "folders/333"},
+	}
+
+	want := LabeledValues{
+		{Value: "333", Label: "333"},
+		{Value: "111", Label: "Development"},
+		{Value: "222", Label: "Production"},
+	}
+
+	assert.Equal(t, want, folderValues(in))
+}
@@ -44,25 +44,54 @@ func (c *Client) getRunService(project string) (*run.APIService, error) {
 	return svc, nil
 }
 
-// RunRegionList will return a list of regions for Cloud Run
+// RunRegionList will return a list of regions for Cloud Run, as reported by
+// RunLocationList
 func (c *Client) RunRegionList(project string) ([]string, error) {
 	resp := []string{}
 
-	svc, err := c.getRunService(project)
+	locations, err := c.RunLocationList(project)
 	if err != nil {
 		return resp, err
 	}
 
+	for _, v := range locations {
+		resp = append(resp, v.Value)
+	}
+
+	return resp, nil
+}
+
+// RunLocationList will return the locations Cloud Run is available in. These
+// come from the Cloud Run API rather than Compute Engine, as the two do not
+// always line up.
+func (c *Client) RunLocationList(project string) (LabeledValues, error) {
+	svc, err := c.getRunService(project)
+	if err != nil {
+		return LabeledValues{}, err
+	}
+
 	results, err := svc.Projects.Locations.List("projects/" + project).Do()
 	if err != nil {
-		return resp, err
+		return LabeledValues{}, err
 	}
 
-	for _, v := range results.Locations {
-		resp = append(resp, v.LocationId)
+	return runLocations(results.Locations), nil
+}
+
+func runLocations(locations []*run.Location) LabeledValues {
+	resp := LabeledValues{}
+
+	for _, v := range locations {
+		label := v.LocationId
+		if v.DisplayName != "" {
+			label = fmt.Sprintf("%s (%s)", v.LocationId, v.DisplayName)
+		}
+		resp = append(resp, LabeledValue{Value: v.LocationId, Label: label})
 	}
 
-	sort.Strings(resp)
+	sort.Slice(resp, func(i, j int) bool {
+		return resp[i].Value < resp[j].Value
+	})
 
-	return resp, nil
+	return resp
 }
@@ -23,6 +23,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/run/v1"
 )
 
 func TestGetRunRegions(t *testing.T) {
@@ -84,3 +85,39 @@ func TestCloudRunBadProject(t *testing.T) {
 		})
 	}
 }
+
+func TestRunLocations(t *testing.T) {
+	in := []*run.Location{
+		{LocationId: "us-central1", DisplayName: "Iowa"},
+		{LocationId: "asia-east1", DisplayName: "Taiwan"},
+		{LocationId: "me-central2"},
+	}
+
+	want := LabeledValues{
+		{Value: "asia-east1", Label: "asia-east1 (Taiwan)"},
+		{Value: "me-central2", Label: "me-central2"},
+		{Value: "us-central1", Label: "us-central1 (Iowa)"},
+	}
+
+	assert.Equal(t, want, runLocations(in))
+}
+
+func TestRegionListRun(t *testing.T) {
+	t.Parallel()
+	c := NewClient(ctx, defaultUserAgent)
+
+	locations, err := c.RunLocationList(projectID)
+	if err != nil {
+		t.Fatalf("expected: no error, got: %v", err)
+	}
+
+	got, err := c.RegionList(projectID, "run")
+	if err != nil {
+		t.Fatalf("expected: no error, got: %v", err)
+	}
+
+	assert.Len(t, got, len(locations))
+	for i, v := range locations {
+		assert.Equal(t, v.Value, got[i])
+	}
+}
@@ -16,12 +16,26 @@ package gcloud
 
 import (
 	"fmt"
+	"path"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"google.golang.org/api/compute/v1"
 )
 
+const (
+	// ArchitectureX86 is the architecture of x86-64 images and machines
+	ArchitectureX86 = "X86_64"
+	// ArchitectureArm is the architecture of arm64 images and machines
+	ArchitectureArm = "ARM64"
+)
+
+// ArmMachineFamilies are the machine families that run on arm64 processors
+var ArmMachineFamilies = []string{"t2a", "c4a"}
+
 // DiskProjects are the list of projects for disk images for Compute Engine
 var DiskProjects = LabeledValues{
 	LabeledValue{Label: "CentOS", Value: "centos-cloud"},
@@ -40,6 +54,141 @@ var DiskProjects = LabeledValues{
 	LabeledValue{Label: "Windows Server", Value: "windows-cloud"},
 }
 
+// DiskTypes are the boot disk types offered when the zone's disk types can't
+// be retrieved
+var DiskTypes = LabeledValues{
+	LabeledValue{Label: "Standard", Value: "pd-standard"},
+	LabeledValue{Label: "Balanced", Value: "pd-balanced"},
+	LabeledValue{Label: "SSD", Value: "pd-ssd"},
+}
+
+// diskTypeLabels labels the disk types Compute Engine offers, in the order
+// they should be listed. Unknown ones fall back to their API description.
+var diskTypeLabels = LabeledValues{
+	LabeledValue{Label: "Standard", Value: "pd-standard"},
+	LabeledValue{Label: "Balanced", Value: "pd-balanced"},
+	LabeledValue{Label: "SSD", Value: "pd-ssd"},
+	LabeledValue{Label: "Extreme", Value: "pd-extreme"},
+	LabeledValue{Label: "Hyperdisk Balanced", Value: "hyperdisk-balanced"},
+	LabeledValue{Label: "Hyperdisk Extreme", Value: "hyperdisk-extreme"},
+}
+
+// nonBootDiskTypes can't be used as a boot disk. Hyperdisk Throughput and ML
+// are only for data disks.
+var nonBootDiskTypes = []string{"local-ssd", "hyperdisk-throughput", "hyperdisk-ml"}
+
+// ConfidentialComputingFamilies are the machine type series that can run as
+// Confidential VMs
+var ConfidentialComputingFamilies = []string{"n2d", "c2d", "c3d"}
+
+// FreeTierLabel is appended to the label of resources that fall within the
+// Compute Engine free tier
+const FreeTierLabel = "(Free tier)"
+
+// Kinds of resources covered by the free tier rules
+const (
+	FreeTierMachineType = "machinetype"
+	FreeTierDiskType    = "disktype"
+)
+
+// FreeTierRule describes a resource that is eligible for the Compute Engine
+// free tier, and the regions in which it is eligible
+type FreeTierRule struct {
+	Kind    string
+	Value   string
+	Regions []string
+}
+
+var freeTierRegions = []string{"us-central1", "us-east1", "us-west1"}
+
+// FreeTierRules are the resources eligible for the Compute Engine free tier.
+// See https://cloud.google.com/free/docs/free-cloud-features#compute
+var FreeTierRules = []FreeTierRule{
+	{Kind: FreeTierMachineType, Value: "e2-micro", Regions: freeTierRegions},
+	{Kind: FreeTierDiskType, Value: "pd-standard", Regions: freeTierRegions},
+}
+
+// PremiumImageProjects are image projects that charge a license fee on top
+// of the instance, so disks built from them are never free
+var PremiumImageProjects = map[string]bool{
+	"rhel-cloud":        true,
+	"rhel-sap-cloud":    true,
+	"suse-cloud":        true,
+	"suse-sap-cloud":    true,
+	"windows-cloud":     true,
+	"windows-sql-cloud": true,
+}
+
+// ImageDiskTypeRule keeps images published by an image project, or built
+// on one of its licenses, off boot disk types they don't run well on
+type ImageDiskTypeRule struct {
+	ImageProject string
+	Excluded     []string
+	Reason       string
+}
+
+// ImageDiskTypeRules are the known image and boot disk type conflicts. Only
+// add restrictions Compute Engine documents, with a link to where in the
+// Reason, as a rule keeps the disk type from being picked at all.
+var ImageDiskTypeRules = []ImageDiskTypeRule{}
+
+// ImageDiskTypeCompatible checks a boot disk type against the image it will
+// be built from, going by the image project and the licenses on the image
+func ImageDiskTypeCompatible(imageproject string, img *compute.Image, diskType string) error {
+	for _, rule := range ImageDiskTypeRules {
+		applies := rule.ImageProject == imageproject
+		if img != nil {
+			for _, v := range img.Licenses {
+				if strings.Contains(v, "/projects/"+rule.ImageProject+"/") {
+					applies = true
+				}
+			}
+		}
+		if !applies {
+			continue
+		}
+
+		for _, v := range rule.Excluded {
+			if v == diskType {
+				return fmt.Errorf("%w: %s", ErrorDiskTypeIncompatible, rule.Reason)
+			}
+		}
+	}
+
+	return nil
+}
+
+// IsFreeTier reports whether a resource of the given kind is eligible for the
+// free tier in location, which can be either a region or a zone
+func IsFreeTier(kind, value, location string) bool {
+	region := location
+	if parts := strings.Split(location, "-"); len(parts) > 2 {
+		region = strings.Join(parts[:len(parts)-1], "-")
+	}
+
+	for _, rule := range FreeTierRules {
+		if rule.Kind != kind || rule.Value != value {
+			continue
+		}
+		for _, v := range rule.Regions {
+			if v == region {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// IsFreeTierDisk reports whether a boot disk of diskType, built from an
+// image in imageproject, is eligible for the free tier in location
+func IsFreeTierDisk(diskType, imageproject, location string) bool {
+	if PremiumImageProjects[imageproject] {
+		return false
+	}
+	return IsFreeTier(FreeTierDiskType, diskType, location)
+}
+
 func (c *Client) getComputeService(project string) (*compute.Service, error) {
 	var err error
 	svc := c.services.computeService
@@ -63,6 +212,63 @@ func (c *Client) getComputeService(project string) (*compute.Service, error) {
 	return svc, nil
 }
 
+// RegionLocations maps Google Cloud region ids to where the region is, so
+// regions can be shown by place rather than by id alone
+var RegionLocations = map[string]string{
+	"africa-south1":           "Johannesburg",
+	"asia-east1":              "Taiwan",
+	"asia-east2":              "Hong Kong",
+	"asia-northeast1":         "Tokyo",
+	"asia-northeast2":         "Osaka",
+	"asia-northeast3":         "Seoul",
+	"asia-south1":             "Mumbai",
+	"asia-south2":             "Delhi",
+	"asia-southeast1":         "Singapore",
+	"asia-southeast2":         "Jakarta",
+	"australia-southeast1":    "Sydney",
+	"australia-southeast2":    "Melbourne",
+	"europe-central2":         "Warsaw",
+	"europe-north1":           "Finland",
+	"europe-southwest1":       "Madrid",
+	"europe-west1":            "Belgium",
+	"europe-west2":            "London",
+	"europe-west3":            "Frankfurt",
+	"europe-west4":            "Netherlands",
+	"europe-west6":            "Zurich",
+	"europe-west8":            "Milan",
+	"europe-west9":            "Paris",
+	"europe-west10":           "Berlin",
+	"europe-west12":           "Turin",
+	"me-central1":             "Doha",
+	"me-central2":             "Dammam",
+	"me-west1":                "Tel Aviv",
+	"northamerica-northeast1": "Montréal",
+	"northamerica-northeast2": "Toronto",
+	"southamerica-east1":      "São Paulo",
+	"southamerica-west1":      "Santiago",
+	"us-central1":             "Iowa",
+	"us-east1":                "South Carolina",
+	"us-east4":                "Northern Virginia",
+	"us-east5":                "Columbus",
+	"us-south1":               "Dallas",
+	"us-west1":                "Oregon",
+	"us-west2":                "Los Angeles",
+	"us-west3":                "Salt Lake City",
+	"us-west4":                "Las Vegas",
+}
+
+// RegionLabel returns a label for a region that says where it is, like
+// "Iowa (us-central1)", or just the region id for a region RegionLocations
+// doesn't know
+func RegionLabel(region string) string {
+	location, ok := RegionLocations[region]
+	if !ok {
+		return region
+	}
+
+	return fmt.Sprintf("%s (%s)", location, region)
+}
+
 // ComputeRegionList will return a list of regions for Compute Engine
 func (c *Client) ComputeRegionList(project string) ([]string, error) {
 	resp := []string{}
@@ -72,7 +278,14 @@ func (c *Client) ComputeRegionList(project string) ([]string, error) {
 		return resp, err
 	}
 
-	results, err := svc.Regions.List(project).Do()
+	ctx, cancel := c.callContext()
+	defer cancel()
+
+	var results *compute.RegionList
+	err = c.retry(ctx, func() error {
+		results, err = svc.Regions.List(project).Context(ctx).Do()
+		return err
+	})
 	if err != nil {
 		return resp, err
 	}
@@ -97,7 +310,14 @@ func (c *Client) ZoneList(project, region string) ([]string, error) {
 
 	filter := fmt.Sprintf("name=%s*", region)
 
-	results, err := svc.Zones.List(project).Filter(filter).Do()
+	ctx, cancel := c.callContext()
+	defer cancel()
+
+	var results *compute.ZoneList
+	err = c.retry(ctx, func() error {
+		results, err = svc.Zones.List(project).Filter(filter).Context(ctx).Do()
+		return err
+	})
 	if err != nil {
 		return resp, err
 	}
@@ -111,30 +331,623 @@ func (c *Client) ZoneList(project, region string) ([]string, error) {
 	return resp, nil
 }
 
+// ZonesWithAccelerator retrieves the list of zones that offer a given
+// accelerator type
+func (c *Client) ZonesWithAccelerator(project, acceleratorType string) ([]string, error) {
+	resp := []string{}
+
+	svc, err := c.getComputeService(project)
+	if err != nil {
+		return resp, err
+	}
+
+	filter := fmt.Sprintf("name=%s", acceleratorType)
+
+	ctx, cancel := c.callContext()
+	defer cancel()
+
+	items := map[string]compute.AcceleratorTypesScopedList{}
+	if err := svc.AcceleratorTypes.AggregatedList(project).Filter(filter).Pages(ctx, func(page *compute.AcceleratorTypeAggregatedList) error {
+		for k, v := range page.Items {
+			items[k] = v
+		}
+		return nil
+	}); err != nil {
+		return resp, err
+	}
+
+	return acceleratorZones(items, acceleratorType), nil
+}
+
+// acceleratorZones pulls the zones that offer acceleratorType out of an
+// aggregated list of accelerator types
+func acceleratorZones(items map[string]compute.AcceleratorTypesScopedList, acceleratorType string) []string {
+	resp := []string{}
+
+	for _, scoped := range items {
+		for _, v := range scoped.AcceleratorTypes {
+			if v.Name != acceleratorType {
+				continue
+			}
+			resp = append(resp, path.Base(v.Zone))
+		}
+	}
+
+	sort.Strings(resp)
+
+	return resp
+}
+
+// DiskTypeList retrieves the boot disk types available in a zone
+func (c *Client) DiskTypeList(project, zone string) (LabeledValues, error) {
+	resp := LabeledValues{}
+
+	if zone == "" {
+		return resp, ErrorZoneRequired
+	}
+
+	svc, err := c.getComputeService(project)
+	if err != nil {
+		return resp, err
+	}
+
+	ctx, cancel := c.callContext()
+	defer cancel()
+
+	items := []*compute.DiskType{}
+	if err := svc.DiskTypes.List(project, zone).Pages(ctx, func(page *compute.DiskTypeList) error {
+		items = append(items, page.Items...)
+		return nil
+	}); err != nil {
+		return resp, err
+	}
+
+	return diskTypes(items), nil
+}
+
+// diskTypes turns the disk types that can boot an instance into choices,
+// known types first in the order of diskTypeLabels
+func diskTypes(items []*compute.DiskType) LabeledValues {
+	available := map[string]*compute.DiskType{}
+	for _, v := range items {
+		if v.Deprecated != nil && v.Deprecated.State != "" {
+			continue
+		}
+		available[v.Name] = v
+	}
+
+	for _, v := range nonBootDiskTypes {
+		delete(available, v)
+	}
+
+	resp := LabeledValues{}
+	for _, v := range diskTypeLabels {
+		if _, ok := available[v.Value]; ok {
+			resp = append(resp, LabeledValue{Label: v.Label, Value: v.Value})
+			delete(available, v.Value)
+		}
+	}
+
+	unknown := LabeledValues{}
+	for name, v := range available {
+		label := v.Description
+		if label == "" {
+			label = name
+		}
+		unknown = append(unknown, LabeledValue{Label: label, Value: name})
+	}
+	sort.Slice(unknown, func(i, j int) bool {
+		return unknown[i].Value < unknown[j].Value
+	})
+
+	return append(resp, unknown...)
+}
+
+// AcceleratorTypeList retrieves the GPUs and other accelerators that can be
+// attached to instances in a zone
+func (c *Client) AcceleratorTypeList(project, zone string) (LabeledValues, error) {
+	resp := LabeledValues{}
+
+	if zone == "" {
+		return resp, ErrorZoneRequired
+	}
+
+	svc, err := c.getComputeService(project)
+	if err != nil {
+		return resp, err
+	}
+
+	ctx, cancel := c.callContext()
+	defer cancel()
+
+	items := []*compute.AcceleratorType{}
+	if err := svc.AcceleratorTypes.List(project, zone).Pages(ctx, func(page *compute.AcceleratorTypeList) error {
+		items = append(items, page.Items...)
+		return nil
+	}); err != nil {
+		return resp, err
+	}
+
+	return acceleratorTypes(items), nil
+}
+
+// AcceleratorTypeRegionList retrieves the GPUs and other accelerators offered
+// in at least one zone of a region, so that one can be picked before the zone
+func (c *Client) AcceleratorTypeRegionList(project, region string) (LabeledValues, error) {
+	resp := LabeledValues{}
+
+	if region == "" {
+		return resp, ErrorRegionRequired
+	}
+
+	svc, err := c.getComputeService(project)
+	if err != nil {
+		return resp, err
+	}
+
+	ctx, cancel := c.callContext()
+	defer cancel()
+
+	items := map[string]compute.AcceleratorTypesScopedList{}
+	if err := svc.AcceleratorTypes.AggregatedList(project).Pages(ctx, func(page *compute.AcceleratorTypeAggregatedList) error {
+		for k, v := range page.Items {
+			items[k] = v
+		}
+		return nil
+	}); err != nil {
+		return resp, err
+	}
+
+	return regionAcceleratorTypes(items, region), nil
+}
+
+// regionAcceleratorTypes pulls the accelerator types offered in the zones of
+// region out of an aggregated list, listing each type once
+func regionAcceleratorTypes(items map[string]compute.AcceleratorTypesScopedList, region string) LabeledValues {
+	seen := map[string]bool{}
+	found := []*compute.AcceleratorType{}
+
+	for k, scoped := range items {
+		if !strings.HasPrefix(k, "zones/"+region+"-") {
+			continue
+		}
+		for _, v := range scoped.AcceleratorTypes {
+			if seen[v.Name] {
+				continue
+			}
+			seen[v.Name] = true
+			found = append(found, v)
+		}
+	}
+
+	return acceleratorTypes(found)
+}
+
+// acceleratorTypes turns the accelerator types that aren't deprecated into
+// choices labeled by their description, like NVIDIA T4
+func acceleratorTypes(items []*compute.AcceleratorType) LabeledValues {
+	resp := LabeledValues{}
+
+	for _, v := range items {
+		if v.Deprecated != nil && v.Deprecated.State != "" {
+			continue
+		}
+
+		label := v.Description
+		if label == "" {
+			label = v.Name
+		}
+
+		resp = append(resp, LabeledValue{Value: v.Name, Label: label})
+	}
+
+	resp.Sort()
+
+	return resp
+}
+
+// AddressList retrieves the static IP addresses reserved in a region that
+// aren't attached to anything yet, so they can be reused
+func (c *Client) AddressList(project, region string) (LabeledValues, error) {
+	resp := LabeledValues{}
+
+	svc, err := c.getComputeService(project)
+	if err != nil {
+		return resp, err
+	}
+
+	ctx, cancel := c.callContext()
+	defer cancel()
+
+	items := []*compute.Address{}
+	if err := svc.Addresses.List(project, region).Filter("status=RESERVED").Pages(ctx, func(page *compute.AddressList) error {
+		items = append(items, page.Items...)
+		return nil
+	}); err != nil {
+		return resp, err
+	}
+
+	return unattachedAddresses(items), nil
+}
+
+// unattachedAddresses turns the reserved addresses that nothing uses into
+// choices, valued by IP address
+func unattachedAddresses(items []*compute.Address) LabeledValues {
+	resp := LabeledValues{}
+
+	for _, v := range items {
+		if v.Status != "RESERVED" || len(v.Users) > 0 {
+			continue
+		}
+
+		resp = append(resp, LabeledValue{
+			Value: v.Address,
+			Label: fmt.Sprintf("%s (%s)", v.Name, v.Address),
+		})
+	}
+
+	resp.Sort()
+
+	return resp
+}
+
+// NodeAffinityKey is the node affinity label that pins an instance to sole
+// tenant nodes of a given node type
+const NodeAffinityKey = "compute.googleapis.com/node-type"
+
+const (
+	// ProvisioningStandard is the provisioning model of regular instances
+	ProvisioningStandard = "STANDARD"
+	// ProvisioningSpot is the provisioning model of Spot instances, which
+	// are cheaper but can be stopped by Compute Engine at any time
+	ProvisioningSpot = "SPOT"
+	// HostMaintenanceMigrate live migrates an instance to another host
+	// during host maintenance
+	HostMaintenanceMigrate = "MIGRATE"
+	// HostMaintenanceTerminate stops an instance during host maintenance
+	HostMaintenanceTerminate = "TERMINATE"
+)
+
+// SchedulingValidate checks that a provisioning model, host maintenance
+// behavior and automatic restart setting can be used together
+func SchedulingValidate(provisioningModel, onHostMaintenance string, automaticRestart bool) error {
+	if provisioningModel != ProvisioningSpot {
+		return nil
+	}
+
+	if onHostMaintenance == HostMaintenanceMigrate {
+		return fmt.Errorf("%w: Spot instances can't live migrate during host maintenance", ErrorScheduling)
+	}
+
+	if automaticRestart {
+		return fmt.Errorf("%w: Spot instances can't restart automatically", ErrorScheduling)
+	}
+
+	return nil
+}
+
+// AcceleratorSchedulingValidate checks that an instance with acceleratorType
+// attached, if any, can use the host maintenance behavior. Instances with
+// GPUs can't live migrate.
+func AcceleratorSchedulingValidate(acceleratorType, onHostMaintenance string) error {
+	if acceleratorType == "" || onHostMaintenance != HostMaintenanceMigrate {
+		return nil
+	}
+
+	return fmt.Errorf("%w: instances with accelerators can't live migrate during host maintenance", ErrorScheduling)
+}
+
+// NodeTypeList retrieves the sole-tenant node types offered in a zone. Zones
+// without sole-tenant nodes return an empty list.
+func (c *Client) NodeTypeList(project, zone string) (LabeledValues, error) {
+	resp := LabeledValues{}
+
+	svc, err := c.getComputeService(project)
+	if err != nil {
+		return resp, err
+	}
+
+	ctx, cancel := c.callContext()
+	defer cancel()
+
+	items := []*compute.NodeType{}
+	if err := svc.NodeTypes.List(project, zone).Pages(ctx, func(page *compute.NodeTypeList) error {
+		items = append(items, page.Items...)
+		return nil
+	}); err != nil {
+		return resp, err
+	}
+
+	return nodeTypes(items), nil
+}
+
+// nodeTypes turns the node types that aren't deprecated into choices, valued
+// by node type name
+func nodeTypes(items []*compute.NodeType) LabeledValues {
+	resp := LabeledValues{}
+
+	for _, v := range items {
+		if v.Deprecated != nil && v.Deprecated.State != "" {
+			continue
+		}
+
+		resp = append(resp, LabeledValue{
+			Value: v.Name,
+			Label: fmt.Sprintf("%s (%d vCPUs, %s)", v.Name, v.GuestCpus, formatMBToGB(v.MemoryMb)),
+		})
+	}
+
+	resp.Sort()
+
+	return resp
+}
+
+// NetworkList retrieves the VPC networks in a project
+func (c *Client) NetworkList(project string) (LabeledValues, error) {
+	resp := LabeledValues{}
+
+	svc, err := c.getComputeService(project)
+	if err != nil {
+		return resp, err
+	}
+
+	ctx, cancel := c.callContext()
+	defer cancel()
+
+	if err := svc.Networks.List(project).Pages(ctx, func(page *compute.NetworkList) error {
+		for _, v := range page.Items {
+			resp = append(resp, LabeledValue{Value: v.Name, Label: v.Name})
+		}
+		return nil
+	}); err != nil {
+		return resp, err
+	}
+
+	resp.Sort()
+
+	return resp, nil
+}
+
+// SubnetworkList retrieves the subnets in a region, on any network
+func (c *Client) SubnetworkList(project, region string) (LabeledValues, error) {
+	return c.NetworkSubnetworkList(project, region, "")
+}
+
+// NetworkSubnetworkList retrieves the subnets of a network in a region.
+// Leave network empty for subnets on any network.
+func (c *Client) NetworkSubnetworkList(project, region, network string) (LabeledValues, error) {
+	items, err := c.subnetworkItems(project)
+	if err != nil {
+		return LabeledValues{}, err
+	}
+
+	return subnetworks(items, region, network), nil
+}
+
+// NetworkRegions retrieves the regions a network has subnets in
+func (c *Client) NetworkRegions(project, network string) ([]string, error) {
+	items, err := c.subnetworkItems(project)
+	if err != nil {
+		return []string{}, err
+	}
+
+	return networkRegions(items, network), nil
+}
+
+func (c *Client) subnetworkItems(project string) ([]*compute.Subnetwork, error) {
+	items := []*compute.Subnetwork{}
+
+	svc, err := c.getComputeService(project)
+	if err != nil {
+		return items, err
+	}
+
+	ctx, cancel := c.callContext()
+	defer cancel()
+
+	if err := svc.Subnetworks.AggregatedList(project).Pages(ctx, func(page *compute.SubnetworkAggregatedList) error {
+		for _, scoped := range page.Items {
+			items = append(items, scoped.Subnetworks...)
+		}
+		return nil
+	}); err != nil {
+		return items, err
+	}
+
+	return items, nil
+}
+
+// subnetworks turns the subnets in region, and on network if it's set, into
+// choices valued by subnet name
+func subnetworks(items []*compute.Subnetwork, region, network string) LabeledValues {
+	resp := LabeledValues{}
+
+	for _, v := range items {
+		if path.Base(v.Region) != region {
+			continue
+		}
+		if network != "" && path.Base(v.Network) != network {
+			continue
+		}
+
+		resp = append(resp, LabeledValue{
+			Value: v.Name,
+			Label: fmt.Sprintf("%s (%s)", v.Name, v.IpCidrRange),
+		})
+	}
+
+	resp.Sort()
+
+	return resp
+}
+
+// networkRegions lists the regions network has subnets in
+func networkRegions(items []*compute.Subnetwork, network string) []string {
+	found := map[string]bool{}
+	for _, v := range items {
+		if path.Base(v.Network) == network {
+			found[path.Base(v.Region)] = true
+		}
+	}
+
+	resp := []string{}
+	for k := range found {
+		resp = append(resp, k)
+	}
+	sort.Strings(resp)
+
+	return resp
+}
+
+// SubnetSecondaryRanges retrieves the secondary IP ranges of a subnet, for
+// stacks like GKE that put pods and services on them. A subnet without
+// secondary ranges returns an empty list.
+func (c *Client) SubnetSecondaryRanges(project, region, subnet string) (LabeledValues, error) {
+	resp := LabeledValues{}
+
+	svc, err := c.getComputeService(project)
+	if err != nil {
+		return resp, err
+	}
+
+	ctx, cancel := c.callContext()
+	defer cancel()
+
+	sn, err := svc.Subnetworks.Get(project, region, subnet).Context(ctx).Do()
+	if err != nil {
+		return resp, err
+	}
+
+	return subnetSecondaryRanges(sn), nil
+}
+
+// subnetSecondaryRanges turns the secondary ranges of a subnet into choices,
+// labeled with their CIDR blocks
+func subnetSecondaryRanges(sn *compute.Subnetwork) LabeledValues {
+	resp := LabeledValues{}
+
+	for _, v := range sn.SecondaryIpRanges {
+		resp = append(resp, LabeledValue{
+			Value: v.RangeName,
+			Label: fmt.Sprintf("%s (%s)", v.RangeName, v.IpCidrRange),
+		})
+	}
+
+	resp.Sort()
+
+	return resp
+}
+
+// machineTypeCache holds the machine types already retrieved for each
+// project and zone, as several steps ask for the same list. The lock only
+// guards the maps, so a slow lookup for one zone doesn't hold up another.
+type machineTypeCache struct {
+	mu       sync.Mutex
+	lists    map[string]*compute.MachineTypeList
+	inflight map[string]*machineTypeCall
+}
+
+// machineTypeCall is a lookup in progress, which callers asking for the same
+// project and zone wait on rather than asking the API again
+type machineTypeCall struct {
+	done chan struct{}
+	list *compute.MachineTypeList
+	err  error
+}
+
+func machineTypeCacheKey(project, zone string) string {
+	return project + "/" + zone
+}
+
 // MachineTypeList retrieves the list of Machine Types available in a
-// given zone
+// given zone, gathered across every page of results. Results are cached per
+// project and zone until InvalidateMachineTypes is called.
 func (c *Client) MachineTypeList(project, zone string) (*compute.MachineTypeList, error) {
-	resp := &compute.MachineTypeList{}
+	cache := c.services.machineTypes
+	if cache == nil {
+		return c.machineTypeList(project, zone)
+	}
 
-	i := c.get("MachineTypeList" + zone)
-	switch val := i.(type) {
-	case *compute.MachineTypeList:
+	key := machineTypeCacheKey(project, zone)
+
+	cache.mu.Lock()
+	if val, ok := cache.lists[key]; ok {
+		cache.mu.Unlock()
 		return val, nil
 	}
+	if call, ok := cache.inflight[key]; ok {
+		cache.mu.Unlock()
+		<-call.done
+		return call.list, call.err
+	}
+	call := &machineTypeCall{done: make(chan struct{})}
+	cache.inflight[key] = call
+	cache.mu.Unlock()
+
+	call.list, call.err = c.machineTypeList(project, zone)
+
+	cache.mu.Lock()
+	// If the zone was invalidated while the call was out, the result is
+	// handed to those waiting on it but not kept
+	if cache.inflight[key] == call {
+		delete(cache.inflight, key)
+		if call.err == nil {
+			cache.lists[key] = call.list
+		}
+	}
+	cache.mu.Unlock()
+	close(call.done)
+
+	return call.list, call.err
+}
+
+func (c *Client) machineTypeList(project, zone string) (*compute.MachineTypeList, error) {
+	resp := &compute.MachineTypeList{}
 
 	svc, err := c.getComputeService(project)
 	if err != nil {
 		return resp, err
 	}
 
-	results, err := svc.MachineTypes.List(project, zone).Do()
-	if err != nil {
-		return resp, err
+	ctx, cancel := c.callContext()
+	defer cancel()
+
+	items := []*compute.MachineType{}
+	token := ""
+	for {
+		var results *compute.MachineTypeList
+		err = c.retry(ctx, func() error {
+			results, err = svc.MachineTypes.List(project, zone).PageToken(token).Context(ctx).Do()
+			return err
+		})
+		if err != nil {
+			return resp, err
+		}
+		items = append(items, results.Items...)
+
+		token = results.NextPageToken
+		if token == "" {
+			resp = results
+			break
+		}
 	}
+	resp.Items = items
 
-	c.save("MachineTypeList"+zone, results)
+	return resp, nil
+}
+
+// InvalidateMachineTypes drops the cached machine types for a project and
+// zone, so the next call to MachineTypeList asks the API again
+func (c *Client) InvalidateMachineTypes(project, zone string) {
+	cache := c.services.machineTypes
+	if cache == nil {
+		return
+	}
 
-	return results, nil
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	delete(cache.lists, machineTypeCacheKey(project, zone))
+	delete(cache.inflight, machineTypeCacheKey(project, zone))
 }
 
 func formatMBToGB(i int64) string {
@@ -150,25 +963,308 @@ func (c *Client) ImageList(project, imageproject string) (*compute.ImageList, er
 	if err != nil {
 		return resp, err
 	}
-	results, err := svc.Images.List(imageproject).Do()
-	if err != nil {
-		return resp, err
+
+	// Image projects like ubuntu-os-cloud run to several pages, and stopping
+	// at the first one drops recent families.
+	ctx, cancel := c.callContext()
+	defer cancel()
+
+	items := []*compute.Image{}
+	token := ""
+	for {
+		var results *compute.ImageList
+		err = c.retry(ctx, func() error {
+			results, err = svc.Images.List(imageproject).PageToken(token).Context(ctx).Do()
+			return err
+		})
+		if err != nil {
+			return resp, err
+		}
+		items = append(items, results.Items...)
+
+		token = results.NextPageToken
+		if token == "" {
+			resp = results
+			break
+		}
 	}
 
 	tmp := []*compute.Image{}
-	for _, v := range results.Items {
-		// fmt.Printf("%v", v.Name)
+	for _, v := range items {
 		if v.Deprecated == nil || v.Deprecated.State == "" {
-			// fmt.Printf("- not deprecated")
 			tmp = append(tmp, v)
 		}
+	}
+
+	resp.Items = tmp
+
+	return resp, nil
+}
+
+// ImageEOLWindow is how close to its end of life an image has to be before
+// users are warned about it
+var ImageEOLWindow = 90 * 24 * time.Hour
+
+// Support statuses returned by ImageSupportStatus
+const (
+	ImageSupportUnknown = "UNKNOWN"
+	ImageSupported      = "SUPPORTED"
+	ImageNearingEOL     = "NEARING_EOL"
+	ImageEOL            = "EOL"
+)
+
+// ImageSupportStatus reports whether an image is supported, nearing its end
+// of life, or past it, based on the dates the image publishes.
+func (c *Client) ImageSupportStatus(imageproject, name string) (string, error) {
+	// Public images can be read without turning on Compute Engine in the
+	// image project, which we couldn't do anyway.
+	svc := c.services.computeService
+	if svc == nil {
+		var err error
+		svc, err = compute.NewService(c.ctx, c.opts)
+		if err != nil {
+			return ImageSupportUnknown, err
+		}
+		svc.UserAgent = c.userAgent
+	}
+
+	ctx, cancel := c.callContext()
+	defer cancel()
+
+	img, err := svc.Images.Get(imageproject, name).Context(ctx).Do()
+	if err != nil {
+		return ImageSupportUnknown, err
+	}
+
+	return imageSupportStatus(img, time.Now()), nil
+}
+
+// ImageEndOfLife returns the earliest date an image stops being supported,
+// whether that is being deprecated, made obsolete or deleted. ok is false if
+// the image doesn't publish any of those dates.
+func ImageEndOfLife(img *compute.Image) (eol time.Time, ok bool) {
+	if img == nil || img.Deprecated == nil {
+		return eol, false
+	}
+
+	for _, v := range []string{img.Deprecated.Deprecated, img.Deprecated.Obsolete, img.Deprecated.Deleted} {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			continue
+		}
+		if !ok || t.Before(eol) {
+			eol = t
+			ok = true
+		}
+	}
+
+	return eol, ok
+}
+
+func imageSupportStatus(img *compute.Image, now time.Time) string {
+	if img != nil && img.Deprecated != nil && img.Deprecated.State != "" && img.Deprecated.State != "ACTIVE" {
+		return ImageEOL
+	}
+
+	eol, ok := ImageEndOfLife(img)
+	switch {
+	case !ok:
+		return ImageSupportUnknown
+	case !now.Before(eol):
+		return ImageEOL
+	case eol.Sub(now) <= ImageEOLWindow:
+		return ImageNearingEOL
+	}
+
+	return ImageSupported
+}
+
+// ImageEOLWarning returns a note to show next to an image that is nearing
+// its end of life, or nothing if it isn't
+func ImageEOLWarning(img *compute.Image, now time.Time) string {
+	if imageSupportStatus(img, now) != ImageNearingEOL {
+		return ""
+	}
+
+	eol, _ := ImageEndOfLife(img)
+	return fmt.Sprintf("(Warning: end of life %s)", eol.Format("2006-01-02"))
+}
+
+// ImageFamilyArchitectures retrieves the CPU architectures, like X86_64 or
+// ARM64, that images in a family are published for
+func (c *Client) ImageFamilyArchitectures(project, imageproject, family string) ([]string, error) {
+	imgs, err := c.ImageList(project, imageproject)
+	if err != nil {
+		return []string{}, err
+	}
+
+	return imageArchitectures(imgs, family), nil
+}
+
+func imageArchitectures(imgs *compute.ImageList, family string) []string {
+	seen := map[string]bool{}
+	resp := []string{}
+
+	for _, v := range imgs.Items {
+		if v.Family != family {
+			continue
+		}
+
+		arch := v.Architecture
+		if arch == "" {
+			arch = ArchitectureX86
+		}
+
+		if seen[arch] {
+			continue
+		}
+		seen[arch] = true
+		resp = append(resp, arch)
+	}
+
+	sort.Strings(resp)
+
+	return resp
+}
+
+// MachineTypeArchitecture returns the CPU architecture of a machine type,
+// working it out from the machine family as the API doesn't report it
+func MachineTypeArchitecture(machineType string) string {
+	family := strings.Split(machineType, "-")[0]
+
+	for _, v := range ArmMachineFamilies {
+		if family == v {
+			return ArchitectureArm
+		}
+	}
+
+	return ArchitectureX86
+}
+
+// MachineTypeFamily returns the family of a machine type, like n2-standard
+// for n2-standard-4
+func MachineTypeFamily(machineType string) string {
+	parts := strings.Split(machineType, "-")
+	if len(parts) < 2 {
+		return machineType
+	}
+	return fmt.Sprintf("%s-%s", parts[0], parts[1])
+}
+
+// Bounds on custom machine types, going by the N1 custom machine type rules
+const (
+	CustomMachineMaxCPUs      = 96
+	CustomMachineMemoryStepMB = 256
+	customMachineMinMBPerCPU  = 922  // 0.9 GB
+	customMachineMaxMBPerCPU  = 6656 // 6.5 GB
+)
+
+// CustomMachineType returns the name of the custom machine type with cpus
+// vCPUs and memMB megabytes of memory, like custom-4-8192, checking the
+// combination is one Compute Engine will create.
+func CustomMachineType(cpus, memMB int) (string, error) {
+	if cpus < 1 || cpus > CustomMachineMaxCPUs {
+		return "", fmt.Errorf("%w: vCPUs must be between 1 and %d", ErrorCustomMachineType, CustomMachineMaxCPUs)
+	}
+
+	if cpus > 1 && cpus%2 != 0 {
+		return "", fmt.Errorf("%w: vCPUs must be 1 or an even number", ErrorCustomMachineType)
+	}
+
+	if memMB%CustomMachineMemoryStepMB != 0 {
+		return "", fmt.Errorf("%w: memory must be a multiple of %d MB", ErrorCustomMachineType, CustomMachineMemoryStepMB)
+	}
+
+	if memMB < cpus*customMachineMinMBPerCPU || memMB > cpus*customMachineMaxMBPerCPU {
+		return "", fmt.Errorf("%w: memory must be between 0.9 GB and 6.5 GB per vCPU", ErrorCustomMachineType)
+	}
+
+	return fmt.Sprintf("custom-%d-%d", cpus, memMB), nil
+}
+
+// MachineTypeMatch returns the smallest predefined machine type in a zone with
+// at least minCPU vCPUs and minMemMB megabytes of memory. If none are big
+// enough, it suggests a custom machine type that is.
+func (c *Client) MachineTypeMatch(project, zone string, minCPU int64, minMemMB int64) (string, error) {
+	types, err := c.MachineTypeList(project, zone)
+	if err != nil {
+		return "", err
+	}
+
+	return MachineTypeMatchList(types, minCPU, minMemMB)
+}
+
+// MachineTypeMatchList does the matching for MachineTypeMatch against a list
+// of machine types already retrieved. Smallest means fewest vCPUs, then
+// least memory, with shared core types left out.
+func MachineTypeMatchList(types *compute.MachineTypeList, minCPU int64, minMemMB int64) (string, error) {
+	var best *compute.MachineType
+
+	for _, v := range types.Items {
+		if v.IsSharedCpu || v.Deprecated != nil {
+			continue
+		}
+		if v.GuestCpus < minCPU || v.MemoryMb < minMemMB {
+			continue
+		}
+
+		if best == nil ||
+			v.GuestCpus < best.GuestCpus ||
+			(v.GuestCpus == best.GuestCpus && v.MemoryMb < best.MemoryMb) ||
+			(v.GuestCpus == best.GuestCpus && v.MemoryMb == best.MemoryMb && v.Name < best.Name) {
+			best = v
+		}
+	}
+
+	if best != nil {
+		return best.Name, nil
+	}
+
+	return customMachineTypeFor(minCPU, minMemMB)
+}
 
-		// fmt.Printf("\n")
+// customMachineTypeFor works out the smallest custom machine type with at
+// least cpus vCPUs and memMB megabytes of memory, adding vCPUs if the memory
+// is more than the vCPUs can have
+func customMachineTypeFor(cpus, memMB int64) (string, error) {
+	if cpus < 1 {
+		cpus = 1
+	}
+	if perCPU := (memMB + customMachineMaxMBPerCPU - 1) / customMachineMaxMBPerCPU; perCPU > cpus {
+		cpus = perCPU
+	}
+	if cpus > 1 && cpus%2 != 0 {
+		cpus++
+	}
+
+	if floor := cpus * customMachineMinMBPerCPU; memMB < floor {
+		memMB = floor
+	}
+	memMB = (memMB + CustomMachineMemoryStepMB - 1) / CustomMachineMemoryStepMB * CustomMachineMemoryStepMB
+
+	return CustomMachineType(int(cpus), int(memMB))
+}
+
+// MachineTypeCPUs returns the vCPUs of machineType, reading them from the
+// name of a custom machine type, like custom-4-8192, or looking them up in
+// types otherwise
+func MachineTypeCPUs(types *compute.MachineTypeList, machineType string) (int64, bool) {
+	if _, custom, ok := strings.Cut(machineType, "custom-"); ok {
+		cpus, err := strconv.ParseInt(strings.Split(custom, "-")[0], 10, 64)
+		return cpus, err == nil
 	}
 
-	results.Items = tmp
+	if types == nil {
+		return 0, false
+	}
+
+	for _, v := range types.Items {
+		if v.Name == machineType {
+			return v.GuestCpus, true
+		}
+	}
 
-	return results, nil
+	return 0, false
 }
 
 // ImageLatestGet retrieves the latest image from a particular family
@@ -180,8 +1276,11 @@ func (c *Client) ImageLatestGet(project, imageproject, imagefamily string) (stri
 		return resp, fmt.Errorf("ImageLatestGet: could not get compute service: %s", err)
 	}
 
+	ctx, cancel := c.callContext()
+	defer cancel()
+
 	filter := fmt.Sprintf("(family=\"%s\")", imagefamily)
-	results, err := svc.Images.List(imageproject).Filter(filter).Do()
+	results, err := svc.Images.List(imageproject).Filter(filter).Context(ctx).Do()
 	if err != nil {
 		return resp, fmt.Errorf("ImageLatestGet: could not get filter list images: %s", err)
 	}
@@ -205,10 +1304,10 @@ func (c *Client) MachineTypeFamilyList(imgs *compute.MachineTypeList) LabeledVal
 	lb := LabeledValues{}
 
 	for _, v := range imgs.Items {
-		parts := strings.Split(v.Name, "-")
-
-		key := fmt.Sprintf("%s %s", parts[0], parts[1])
-		fam[key] = fmt.Sprintf("%s-%s", parts[0], parts[1])
+		// Names without a dash are their own family
+		value := MachineTypeFamily(v.Name)
+		key := strings.Replace(value, "-", " ", 1)
+		fam[key] = value
 	}
 
 	for key, value := range fam {
@@ -230,6 +1329,7 @@ func (c *Client) MachineTypeFamilyList(imgs *compute.MachineTypeList) LabeledVal
 // for each family
 func (c *Client) MachineTypeListByFamily(imgs *compute.MachineTypeList, family string) LabeledValues {
 	lb := LabeledValues{}
+	rates := c.prices.rates()
 
 	tempTypes := []compute.MachineType{}
 
@@ -247,6 +1347,9 @@ func (c *Client) MachineTypeListByFamily(imgs *compute.MachineTypeList, family s
 		if strings.Contains(v.Name, family) {
 			value := v.Name
 			label := fmt.Sprintf("%s %s", v.Name, v.Description)
+			if price, ok := machineTypePrice(v, rates); ok {
+				label = fmt.Sprintf("%s %s", label, formatHourlyPrice(price))
+			}
 			lb = append(lb, LabeledValue{
 				Value:     value,
 				Label:     label,
@@ -259,8 +1362,32 @@ func (c *Client) MachineTypeListByFamily(imgs *compute.MachineTypeList, family s
 	return lb
 }
 
+// ImageFamilyDefault returns the default family for an image project, or an
+// empty string if the project has no known default.
+func ImageFamilyDefault(project string) string {
+	return DefaultImageFamilies[project]
+}
+
 // ImageFamilyList gets a list of image families
 func (c *Client) ImageFamilyList(imgs *compute.ImageList) LabeledValues {
+	lb := imageFamilies(imgs)
+	lb.SetDefault(DefaultImageFamily)
+	return lb
+}
+
+// ImageFamilyListForProject gets a list of image families, defaulting to the
+// known default family for the image project or the first family if there
+// isn't one
+func (c *Client) ImageFamilyListForProject(imgs *compute.ImageList, project string) LabeledValues {
+	lb := imageFamilies(imgs)
+	lb.SetDefault(ImageFamilyDefault(project))
+	if len(lb) > 0 && lb.GetDefault().Value == "" {
+		lb[0].IsDefault = true
+	}
+	return lb
+}
+
+func imageFamilies(imgs *compute.ImageList) LabeledValues {
 	fam := make(map[string]bool)
 	lb := LabeledValues{}
 
@@ -278,7 +1405,6 @@ func (c *Client) ImageFamilyList(imgs *compute.ImageList) LabeledValues {
 			IsDefault: false,
 		})
 	}
-	lb.SetDefault(DefaultImageFamily)
 	lb.Sort()
 	return lb
 }
@@ -306,3 +1432,78 @@ func (c *Client) ImageTypeListByFamily(imgs *compute.ImageList, project, family
 
 	return lb
 }
+
+// InstanceGet retrieves a Compute Engine instance. It returns nil, and no
+// error, when the instance doesn't exist.
+func (c *Client) InstanceGet(project, zone, name string) (*compute.Instance, error) {
+	svc, err := c.getComputeService(project)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := c.callContext()
+	defer cancel()
+
+	inst, err := svc.Instances.Get(project, zone, name).Context(ctx).Do()
+	if err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not get instance (%s): %w", name, err)
+	}
+
+	return inst, nil
+}
+
+// zoneOperationWait waits on a zone operation, giving each wait its own
+// timeout, as Compute Engine may return before the operation is done
+func (c *Client) zoneOperationWait(svc *compute.Service, project, zone, name string) (*compute.Operation, error) {
+	ctx, cancel := c.callContext()
+	defer cancel()
+
+	return svc.ZoneOperations.Wait(project, zone, name).Context(ctx).Do()
+}
+
+// InstanceCreate creates a Compute Engine instance and waits for the
+// operation to finish
+func (c *Client) InstanceCreate(project, zone string, inst *compute.Instance) error {
+	if err := c.checkWritable("InstanceCreate"); err != nil {
+		return err
+	}
+
+	svc, err := c.getComputeService(project)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := c.callContext()
+	defer cancel()
+
+	op, err := svc.Instances.Insert(project, zone, inst).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("could not create instance (%s): %w", inst.Name, err)
+	}
+
+	for i := 0; i < 20 && op.Status != "DONE"; i++ {
+		op, err = c.zoneOperationWait(svc, project, zone, op.Name)
+		if err != nil {
+			return fmt.Errorf("could not poll for instance creation: %w", err)
+		}
+	}
+
+	if op.Status != "DONE" {
+		return fmt.Errorf("instance (%s) was not created in time", inst.Name)
+	}
+
+	if op.Error != nil && len(op.Error.Errors) > 0 {
+		return fmt.Errorf("instance creation was unsuccessful, reason: %s", op.Error.Errors[0].Message)
+	}
+
+	c.auditLog("InstanceCreate", map[string]any{
+		"project":  project,
+		"zone":     zone,
+		"instance": inst.Name,
+	})
+
+	return nil
+}
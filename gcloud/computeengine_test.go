@@ -17,19 +17,25 @@ package gcloud
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"math"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"reflect"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
 )
 
 func TestGetComputeRegions(t *testing.T) {
@@ -270,6 +276,38 @@ func TestGetListOfDiskFamilies(t *testing.T) {
 	}
 }
 
+func TestImageFamilyDefaultAgrees(t *testing.T) {
+	assert.Equal(t, DefaultImageFamily, ImageFamilyDefault(DefaultImageProject))
+}
+
+func TestImageFamilyListDefault(t *testing.T) {
+	t.Parallel()
+	c := NewClient(ctx, defaultUserAgent)
+	imgs := &compute.ImageList{
+		Items: []*compute.Image{
+			{Family: "debian-11"},
+			{Family: "debian-12"},
+			{Family: "ubuntu-2004-lts"},
+			{Family: "ubuntu-2204-lts"},
+		},
+	}
+	tests := map[string]struct {
+		project string
+		want    string
+	}{
+		"debian":  {project: "debian-cloud", want: "debian-12"},
+		"ubuntu":  {project: "ubuntu-os-cloud", want: "ubuntu-2204-lts"},
+		"unknown": {project: "some-custom-images", want: "debian-11"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := c.ImageFamilyListForProject(imgs, tc.project)
+			assert.Equal(t, tc.want, got.GetDefault().Value)
+		})
+	}
+}
+
 func TestGetListOfImageTypesByFamily(t *testing.T) {
 	t.Parallel()
 	c := NewClient(ctx, defaultUserAgent)
@@ -433,6 +471,26 @@ func TestGetListOfMachineTypeFamily(t *testing.T) {
 				},
 			},
 		},
+		"SingleSegment": {
+			input: &compute.MachineTypeList{
+				Items: []*compute.MachineType{
+					{Name: "n1-standard-1", Description: "1 Proc"},
+					{Name: "custom", Description: "Custom"},
+				},
+			},
+			want: LabeledValues{
+				LabeledValue{
+					Value:     "n1-standard",
+					Label:     "n1 standard",
+					IsDefault: false,
+				},
+				LabeledValue{
+					Value:     "custom",
+					Label:     "custom",
+					IsDefault: false,
+				},
+			},
+		},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -440,6 +498,10 @@ func TestGetListOfMachineTypeFamily(t *testing.T) {
 
 			tc.want.Sort()
 
+			if len(tc.want) != len(got) {
+				t.Fatalf("Length expected: %d, got: %d", len(tc.want), len(got))
+			}
+
 			for i, v := range got {
 				if !reflect.DeepEqual(tc.want[i].Value, v.Value) {
 					t.Fatalf("Value expected: %+v, got: %+v", tc.want[i].Value, v.Value)
@@ -635,3 +697,1088 @@ func TestComputeBadProject(t *testing.T) {
 		})
 	}
 }
+
+func TestAcceleratorZones(t *testing.T) {
+	zoneURL := "https://www.googleapis.com/compute/v1/projects/test/zones/"
+	tests := map[string]struct {
+		items       map[string]compute.AcceleratorTypesScopedList
+		accelerator string
+		want        []string
+	}{
+		"onezone": {
+			items: map[string]compute.AcceleratorTypesScopedList{
+				"zones/us-central1-a": {AcceleratorTypes: []*compute.AcceleratorType{
+					{Name: "nvidia-tesla-t4", Zone: zoneURL + "us-central1-a"},
+				}},
+				"zones/us-central1-b": {},
+				"zones/us-east1-b": {AcceleratorTypes: []*compute.AcceleratorType{
+					{Name: "nvidia-tesla-v100", Zone: zoneURL + "us-east1-b"},
+				}},
+			},
+			accelerator: "nvidia-tesla-t4",
+			want:        []string{"us-central1-a"},
+		},
+		"none": {
+			items: map[string]compute.AcceleratorTypesScopedList{
+				"zones/us-central1-b": {},
+			},
+			accelerator: "nvidia-tesla-t4",
+			want:        []string{},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := acceleratorZones(tc.items, tc.accelerator)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestRegionAcceleratorTypes(t *testing.T) {
+	items := map[string]compute.AcceleratorTypesScopedList{
+		"zones/us-central1-a": {AcceleratorTypes: []*compute.AcceleratorType{
+			{Name: "nvidia-tesla-t4", Description: "NVIDIA T4"},
+		}},
+		"zones/us-central1-b": {AcceleratorTypes: []*compute.AcceleratorType{
+			{Name: "nvidia-tesla-t4", Description: "NVIDIA T4"},
+			{Name: "nvidia-l4", Description: "NVIDIA L4"},
+		}},
+		"zones/us-central10-a": {AcceleratorTypes: []*compute.AcceleratorType{
+			{Name: "nvidia-tesla-v100", Description: "NVIDIA V100"},
+		}},
+		"zones/us-east1-b": {AcceleratorTypes: []*compute.AcceleratorType{
+			{Name: "nvidia-tesla-p4", Description: "NVIDIA P4"},
+		}},
+	}
+
+	tests := map[string]struct {
+		region string
+		want   LabeledValues
+	}{
+		"several_zones": {
+			region: "us-central1",
+			want: LabeledValues{
+				{Value: "nvidia-l4", Label: "NVIDIA L4"},
+				{Value: "nvidia-tesla-t4", Label: "NVIDIA T4"},
+			},
+		},
+		"none": {
+			region: "europe-west1",
+			want:   LabeledValues{},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := regionAcceleratorTypes(items, tc.region)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestUnattachedAddresses(t *testing.T) {
+	items := []*compute.Address{
+		{Name: "web-ip", Address: "34.1.2.3", Status: "RESERVED"},
+		{Name: "api-ip", Address: "34.1.2.4", Status: "RESERVED"},
+		{Name: "lb-ip", Address: "34.1.2.5", Status: "IN_USE", Users: []string{"forwardingRules/lb"}},
+		{Name: "stale-ip", Address: "34.1.2.6", Status: "RESERVED", Users: []string{"instances/old"}},
+	}
+
+	want := LabeledValues{
+		{Value: "34.1.2.4", Label: "api-ip (34.1.2.4)"},
+		{Value: "34.1.2.3", Label: "web-ip (34.1.2.3)"},
+	}
+
+	assert.Equal(t, want, unattachedAddresses(items))
+	assert.Equal(t, LabeledValues{}, unattachedAddresses(nil))
+}
+
+func TestImageDiskTypeCompatible(t *testing.T) {
+	byolLicense := "https://www.googleapis.com/compute/v1/projects/windows-cloud/global/licenses/windows-server-2022-dc"
+
+	// None of the shipped rules, just one to check how rules apply
+	defer func(rules []ImageDiskTypeRule) { ImageDiskTypeRules = rules }(ImageDiskTypeRules)
+	ImageDiskTypeRules = []ImageDiskTypeRule{
+		{ImageProject: "windows-cloud", Excluded: []string{"pd-standard"}, Reason: "test rule"},
+	}
+
+	tests := map[string]struct {
+		imageproject string
+		img          *compute.Image
+		diskType     string
+		err          error
+	}{
+		"windowsStandard": {
+			imageproject: "windows-cloud",
+			img:          &compute.Image{Name: "windows-server-2022-dc-v20230414"},
+			diskType:     "pd-standard",
+			err:          ErrorDiskTypeIncompatible,
+		},
+		"windowsBalanced": {
+			imageproject: "windows-cloud",
+			img:          &compute.Image{Name: "windows-server-2022-dc-v20230414"},
+			diskType:     "pd-balanced",
+		},
+		"customWindowsStandard": {
+			imageproject: "my-images",
+			img:          &compute.Image{Name: "golden-windows", Licenses: []string{byolLicense}},
+			diskType:     "pd-standard",
+			err:          ErrorDiskTypeIncompatible,
+		},
+		"debianStandard": {
+			imageproject: "debian-cloud",
+			img:          &compute.Image{Name: "debian-12-bookworm-v20240110"},
+			diskType:     "pd-standard",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := ImageDiskTypeCompatible(tc.imageproject, tc.img, tc.diskType)
+			if tc.err == nil {
+				assert.Nil(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tc.err)
+		})
+	}
+}
+
+func TestSubnetSecondaryRanges(t *testing.T) {
+	tests := map[string]struct {
+		subnet *compute.Subnetwork
+		want   LabeledValues
+	}{
+		"two": {
+			subnet: &compute.Subnetwork{
+				Name: "gke-subnet",
+				SecondaryIpRanges: []*compute.SubnetworkSecondaryRange{
+					{RangeName: "services", IpCidrRange: "10.8.0.0/20"},
+					{RangeName: "pods", IpCidrRange: "10.4.0.0/14"},
+				},
+			},
+			want: LabeledValues{
+				{Value: "pods", Label: "pods (10.4.0.0/14)"},
+				{Value: "services", Label: "services (10.8.0.0/20)"},
+			},
+		},
+		"none": {
+			subnet: &compute.Subnetwork{Name: "plain-subnet"},
+			want:   LabeledValues{},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := subnetSecondaryRanges(tc.subnet)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestImageArchitectures(t *testing.T) {
+	imgs := &compute.ImageList{
+		Items: []*compute.Image{
+			{Family: "ubuntu-2204-lts", Name: "ubuntu-2204-jammy-v20230302", Architecture: "X86_64"},
+			{Family: "ubuntu-2204-lts", Name: "ubuntu-2204-jammy-arm64-v20230302", Architecture: "ARM64"},
+			{Family: "ubuntu-2204-lts", Name: "ubuntu-2204-jammy-v20230214", Architecture: "X86_64"},
+			{Family: "debian-11", Name: "debian-11-bullseye-v20221206"},
+		},
+	}
+
+	tests := map[string]struct {
+		family string
+		want   []string
+	}{
+		"both":    {family: "ubuntu-2204-lts", want: []string{ArchitectureArm, ArchitectureX86}},
+		"unset":   {family: "debian-11", want: []string{ArchitectureX86}},
+		"missing": {family: "centos-7", want: []string{}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := imageArchitectures(imgs, tc.family)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestMachineTypeArchitecture(t *testing.T) {
+	tests := map[string]struct {
+		in   string
+		want string
+	}{
+		"x86": {in: "n2-standard-4", want: ArchitectureX86},
+		"arm": {in: "t2a-standard-1", want: ArchitectureArm},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, MachineTypeArchitecture(tc.in))
+		})
+	}
+}
+
+func TestIsFreeTier(t *testing.T) {
+	tests := map[string]struct {
+		kind     string
+		value    string
+		location string
+		want     bool
+	}{
+		"e2-micro eligible region": {kind: FreeTierMachineType, value: "e2-micro", location: "us-central1", want: true},
+		"e2-micro eligible zone":   {kind: FreeTierMachineType, value: "e2-micro", location: "us-west1-b", want: true},
+		"e2-micro elsewhere":       {kind: FreeTierMachineType, value: "e2-micro", location: "europe-west1-b", want: false},
+		"e2-micro lookalike":       {kind: FreeTierMachineType, value: "e2-micro", location: "us-central2-a", want: false},
+		"e2-small":                 {kind: FreeTierMachineType, value: "e2-small", location: "us-central1-a", want: false},
+		"pd-standard":              {kind: FreeTierDiskType, value: "pd-standard", location: "us-east1-c", want: true},
+		"pd-ssd":                   {kind: FreeTierDiskType, value: "pd-ssd", location: "us-east1-c", want: false},
+		"wrong kind":               {kind: FreeTierDiskType, value: "e2-micro", location: "us-east1-c", want: false},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, IsFreeTier(tc.kind, tc.value, tc.location))
+		})
+	}
+}
+
+func TestIsFreeTierDisk(t *testing.T) {
+	assert.True(t, IsFreeTierDisk("pd-standard", "debian-cloud", "us-central1-a"))
+	assert.False(t, IsFreeTierDisk("pd-standard", "windows-cloud", "us-central1-a"))
+	assert.False(t, IsFreeTierDisk("pd-balanced", "debian-cloud", "us-central1-a"))
+}
+
+func TestImageSupportStatus(t *testing.T) {
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		img     *compute.Image
+		want    string
+		warning string
+	}{
+		"noDates": {
+			img:  &compute.Image{Name: "debian-12"},
+			want: ImageSupportUnknown,
+		},
+		"farOff": {
+			img: &compute.Image{Name: "debian-12", Deprecated: &compute.DeprecationStatus{
+				Obsolete: "2028-06-30T00:00:00Z",
+			}},
+			want: ImageSupported,
+		},
+		"nearEOL": {
+			img: &compute.Image{Name: "centos-7", Deprecated: &compute.DeprecationStatus{
+				Deprecated: "2024-06-30T00:00:00Z",
+				Obsolete:   "2024-09-30T00:00:00Z",
+			}},
+			want:    ImageNearingEOL,
+			warning: "(Warning: end of life 2024-06-30)",
+		},
+		"pastEOL": {
+			img: &compute.Image{Name: "centos-6", Deprecated: &compute.DeprecationStatus{
+				Deleted: "2020-11-30T00:00:00Z",
+			}},
+			want: ImageEOL,
+		},
+		"deprecatedState": {
+			img:  &compute.Image{Name: "centos-6", Deprecated: &compute.DeprecationStatus{State: "DEPRECATED"}},
+			want: ImageEOL,
+		},
+		"badDate": {
+			img:  &compute.Image{Name: "odd", Deprecated: &compute.DeprecationStatus{Obsolete: "soon"}},
+			want: ImageSupportUnknown,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, imageSupportStatus(tc.img, now))
+			assert.Equal(t, tc.warning, ImageEOLWarning(tc.img, now))
+		})
+	}
+}
+
+func TestImageListPages(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Query().Get("pageToken") {
+		case "":
+			fmt.Fprint(w, `{"items":[
+				{"name":"ubuntu-2004-focal-v20230302","family":"ubuntu-2004-lts"},
+				{"name":"ubuntu-1804-bionic-v20230302","family":"ubuntu-1804-lts","deprecated":{"state":"DEPRECATED"}}
+			],"nextPageToken":"page2"}`)
+		case "page2":
+			fmt.Fprint(w, `{"items":[
+				{"name":"ubuntu-2404-noble-v20240423","family":"ubuntu-2404-lts"}
+			]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	svc, err := compute.NewService(ctx,
+		option.WithEndpoint(srv.URL+"/"),
+		option.WithHTTPClient(srv.Client()),
+	)
+	if err != nil {
+		t.Fatalf("could not create fake compute: %s", err)
+	}
+
+	c := NewClient(ctx, defaultUserAgent)
+	c.services.computeService = svc
+
+	got, err := c.ImageList("ds-test", "ubuntu-os-cloud")
+	if err != nil {
+		t.Fatalf("expected: no error, got: %s", err)
+	}
+
+	names := []string{}
+	for _, v := range got.Items {
+		names = append(names, v.Name)
+	}
+
+	assert.Equal(t, []string{"ubuntu-2004-focal-v20230302", "ubuntu-2404-noble-v20240423"}, names)
+}
+
+func TestMachineTypeListPages(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Query().Get("pageToken") {
+		case "":
+			fmt.Fprint(w, `{"items":[
+				{"name":"n1-standard-1"},
+				{"name":"n2-standard-2"}
+			],"nextPageToken":"page2"}`)
+		case "page2":
+			fmt.Fprint(w, `{"items":[
+				{"name":"n2d-standard-2"},
+				{"name":"c3-standard-4"}
+			]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	svc, err := compute.NewService(ctx,
+		option.WithEndpoint(srv.URL+"/"),
+		option.WithHTTPClient(srv.Client()),
+	)
+	if err != nil {
+		t.Fatalf("could not create fake compute: %s", err)
+	}
+
+	c := NewClient(ctx, defaultUserAgent)
+	c.services.computeService = svc
+
+	got, err := c.MachineTypeList("ds-test", "us-central1-a")
+	if err != nil {
+		t.Fatalf("expected: no error, got: %s", err)
+	}
+
+	assert.Len(t, got.Items, 4)
+	assert.Equal(t, 2, calls)
+
+	families := c.MachineTypeFamilyList(got)
+	assert.Len(t, families, 4)
+
+	// The combined list is cached for the zone
+	_, err = c.MachineTypeList("ds-test", "us-central1-a")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, calls)
+}
+
+func TestMachineTypeListCache(t *testing.T) {
+	calls := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls[r.URL.Path]++
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"items":[{"name":"n1-standard-1"}]}`)
+	}))
+	defer srv.Close()
+
+	svc, err := compute.NewService(ctx,
+		option.WithEndpoint(srv.URL+"/"),
+		option.WithHTTPClient(srv.Client()),
+	)
+	if err != nil {
+		t.Fatalf("could not create fake compute: %s", err)
+	}
+
+	c := NewClient(ctx, defaultUserAgent)
+	c.services.computeService = svc
+
+	zoneA := "/projects/ds-test/zones/us-central1-a/machineTypes"
+	zoneB := "/projects/ds-test/zones/us-central1-b/machineTypes"
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.MachineTypeList("ds-test", "us-central1-a"); err != nil {
+			t.Fatalf("expected: no error, got: %s", err)
+		}
+	}
+	assert.Equal(t, 1, calls[zoneA])
+
+	if _, err := c.MachineTypeList("ds-test", "us-central1-b"); err != nil {
+		t.Fatalf("expected: no error, got: %s", err)
+	}
+	assert.Equal(t, 1, calls[zoneB])
+
+	c.InvalidateMachineTypes("ds-test", "us-central1-a")
+
+	if _, err := c.MachineTypeList("ds-test", "us-central1-a"); err != nil {
+		t.Fatalf("expected: no error, got: %s", err)
+	}
+	if _, err := c.MachineTypeList("ds-test", "us-central1-b"); err != nil {
+		t.Fatalf("expected: no error, got: %s", err)
+	}
+	assert.Equal(t, 2, calls[zoneA])
+	assert.Equal(t, 1, calls[zoneB])
+}
+
+func TestMachineTypeListConcurrent(t *testing.T) {
+	var mu sync.Mutex
+	calls := map[string]int{}
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls[r.URL.Path]++
+		mu.Unlock()
+
+		// Zone a is slow until the test lets it go
+		if strings.Contains(r.URL.Path, "us-central1-a") {
+			<-release
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"items":[{"name":"n1-standard-1"}]}`)
+	}))
+	defer srv.Close()
+
+	svc, err := compute.NewService(ctx,
+		option.WithEndpoint(srv.URL+"/"),
+		option.WithHTTPClient(srv.Client()),
+	)
+	if err != nil {
+		t.Fatalf("could not create fake compute: %s", err)
+	}
+
+	c := NewClient(ctx, defaultUserAgent)
+	c.services.computeService = svc
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.MachineTypeList("ds-test", "us-central1-a"); err != nil {
+				t.Errorf("expected: no error, got: %s", err)
+			}
+		}()
+	}
+
+	// Another zone isn't held up by the slow one
+	done := make(chan struct{})
+	go func() {
+		c.MachineTypeList("ds-test", "us-central1-b")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected: zone b to answer while zone a is slow")
+	}
+
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, 1, calls["/projects/ds-test/zones/us-central1-a/machineTypes"])
+	assert.Equal(t, 1, calls["/projects/ds-test/zones/us-central1-b/machineTypes"])
+}
+
+func TestNodeTypeList(t *testing.T) {
+	tests := map[string]struct {
+		zone string
+		body string
+		want LabeledValues
+	}{
+		"soleTenant": {
+			zone: "us-central1-a",
+			body: `{"items":[
+				{"name":"n2-node-80-640","guestCpus":80,"memoryMb":655360},
+				{"name":"n1-node-96-624","guestCpus":96,"memoryMb":638976},
+				{"name":"n1-node-96-old","guestCpus":96,"memoryMb":638976,"deprecated":{"state":"DEPRECATED"}}
+			]}`,
+			want: LabeledValues{
+				{Label: "n1-node-96-624 (96 vCPUs, 624 GB)", Value: "n1-node-96-624"},
+				{Label: "n2-node-80-640 (80 vCPUs, 640 GB)", Value: "n2-node-80-640"},
+			},
+		},
+		"noSoleTenant": {
+			zone: "us-west4-c",
+			body: `{}`,
+			want: LabeledValues{},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var gotPath string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotPath = r.URL.Path
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprint(w, tc.body)
+			}))
+			defer srv.Close()
+
+			svc, err := compute.NewService(ctx,
+				option.WithEndpoint(srv.URL+"/"),
+				option.WithHTTPClient(srv.Client()),
+			)
+			if err != nil {
+				t.Fatalf("could not create fake compute: %s", err)
+			}
+
+			c := NewClient(ctx, defaultUserAgent)
+			c.services.computeService = svc
+
+			got, err := c.NodeTypeList("ds-test", tc.zone)
+			if err != nil {
+				t.Fatalf("expected: no error, got: %s", err)
+			}
+
+			assert.True(t, strings.HasSuffix(gotPath, "/zones/"+tc.zone+"/nodeTypes"), gotPath)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestCustomMachineType(t *testing.T) {
+	tests := map[string]struct {
+		cpus  int
+		memMB int
+		want  string
+		err   bool
+	}{
+		"basic":        {cpus: 4, memMB: 8192, want: "custom-4-8192"},
+		"single":       {cpus: 1, memMB: 1024, want: "custom-1-1024"},
+		"maxPerCPU":    {cpus: 2, memMB: 13312, want: "custom-2-13312"},
+		"oddCPUs":      {cpus: 3, memMB: 6144, err: true},
+		"noCPUs":       {cpus: 0, memMB: 1024, err: true},
+		"tooManyCPUs":  {cpus: 98, memMB: 98304, err: true},
+		"notStep":      {cpus: 2, memMB: 4000, err: true},
+		"tooLittleMem": {cpus: 8, memMB: 4096, err: true},
+		"tooMuchMem":   {cpus: 2, memMB: 16384, err: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := CustomMachineType(tc.cpus, tc.memMB)
+			if tc.err {
+				assert.ErrorIs(t, err, ErrorCustomMachineType)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestMachineTypeCPUs(t *testing.T) {
+	types := &compute.MachineTypeList{Items: []*compute.MachineType{
+		{Name: "n2-standard-8", GuestCpus: 8},
+	}}
+
+	tests := map[string]struct {
+		machineType string
+		want        int64
+		ok          bool
+	}{
+		"predefined":   {machineType: "n2-standard-8", want: 8, ok: true},
+		"custom":       {machineType: "custom-4-8192", want: 4, ok: true},
+		"familyCustom": {machineType: "n2-custom-6-12288-ext", want: 6, ok: true},
+		"unknown":      {machineType: "n2-standard-4"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, ok := MachineTypeCPUs(types, tc.machineType)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestSchedulingValidate(t *testing.T) {
+	tests := map[string]struct {
+		model       string
+		maintenance string
+		restart     bool
+		err         bool
+	}{
+		"standardMigrate":   {model: ProvisioningStandard, maintenance: HostMaintenanceMigrate, restart: true},
+		"standardTerminate": {model: ProvisioningStandard, maintenance: HostMaintenanceTerminate},
+		"spotTerminate":     {model: ProvisioningSpot, maintenance: HostMaintenanceTerminate},
+		"spotMigrate":       {model: ProvisioningSpot, maintenance: HostMaintenanceMigrate, err: true},
+		"spotRestart":       {model: ProvisioningSpot, maintenance: HostMaintenanceTerminate, restart: true, err: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := SchedulingValidate(tc.model, tc.maintenance, tc.restart)
+			if tc.err {
+				assert.ErrorIs(t, err, ErrorScheduling)
+				return
+			}
+
+			assert.Nil(t, err)
+		})
+	}
+}
+
+func TestAcceleratorSchedulingValidate(t *testing.T) {
+	tests := map[string]struct {
+		accelerator string
+		maintenance string
+		err         bool
+	}{
+		"noneMigrate":        {maintenance: HostMaintenanceMigrate},
+		"acceleratorMigrate": {accelerator: "nvidia-l4", maintenance: HostMaintenanceMigrate, err: true},
+		"acceleratorStop":    {accelerator: "nvidia-l4", maintenance: HostMaintenanceTerminate},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := AcceleratorSchedulingValidate(tc.accelerator, tc.maintenance)
+			if tc.err {
+				assert.ErrorIs(t, err, ErrorScheduling)
+				return
+			}
+
+			assert.Nil(t, err)
+		})
+	}
+}
+
+func TestAcceleratorTypeList(t *testing.T) {
+	tests := map[string]struct {
+		zone string
+		body string
+		want LabeledValues
+		err  error
+	}{
+		"gpus": {
+			zone: "us-central1-a",
+			body: `{"items":[
+				{"name":"nvidia-tesla-t4","description":"NVIDIA T4"},
+				{"name":"nvidia-l4","description":"NVIDIA L4"},
+				{"name":"nvidia-tesla-k80","description":"NVIDIA Tesla K80","deprecated":{"state":"OBSOLETE"}}
+			]}`,
+			want: LabeledValues{
+				{Label: "NVIDIA L4", Value: "nvidia-l4"},
+				{Label: "NVIDIA T4", Value: "nvidia-tesla-t4"},
+			},
+		},
+		"noGPUs": {
+			zone: "us-west4-c",
+			body: `{}`,
+			want: LabeledValues{},
+		},
+		"noZone": {
+			want: LabeledValues{},
+			err:  ErrorZoneRequired,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var gotPath string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotPath = r.URL.Path
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprint(w, tc.body)
+			}))
+			defer srv.Close()
+
+			svc, err := compute.NewService(ctx,
+				option.WithEndpoint(srv.URL+"/"),
+				option.WithHTTPClient(srv.Client()),
+			)
+			if err != nil {
+				t.Fatalf("could not create fake compute: %s", err)
+			}
+
+			c := NewClient(ctx, defaultUserAgent)
+			c.services.computeService = svc
+
+			got, err := c.AcceleratorTypeList("ds-test", tc.zone)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				assert.Equal(t, "", gotPath)
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected: no error, got: %s", err)
+			}
+
+			assert.True(t, strings.HasSuffix(gotPath, "/zones/"+tc.zone+"/acceleratorTypes"), gotPath)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestMachineTypeMatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"items":[
+			{"name":"e2-micro","guestCpus":2,"memoryMb":1024,"isSharedCpu":true},
+			{"name":"n1-standard-2","guestCpus":2,"memoryMb":7680},
+			{"name":"e2-standard-8","guestCpus":8,"memoryMb":32768},
+			{"name":"n2-standard-8","guestCpus":8,"memoryMb":32768},
+			{"name":"n2-highcpu-8","guestCpus":8,"memoryMb":8192},
+			{"name":"n2-highmem-8","guestCpus":8,"memoryMb":65536},
+			{"name":"n2-standard-16","guestCpus":16,"memoryMb":65536},
+			{"name":"n1-standard-8-old","guestCpus":8,"memoryMb":30720,"deprecated":{"state":"DEPRECATED"}}
+		]}`)
+	}))
+	defer srv.Close()
+
+	svc, err := compute.NewService(ctx,
+		option.WithEndpoint(srv.URL+"/"),
+		option.WithHTTPClient(srv.Client()),
+	)
+	if err != nil {
+		t.Fatalf("could not create fake compute: %s", err)
+	}
+
+	c := NewClient(ctx, defaultUserAgent)
+	c.services.computeService = svc
+
+	tests := map[string]struct {
+		cpu  int64
+		mem  int64
+		want string
+		err  error
+	}{
+		"smallest":       {cpu: 1, mem: 1024, want: "n1-standard-2"},
+		"cpuAndMemory":   {cpu: 8, mem: 32768, want: "e2-standard-8"},
+		"cpuOnly":        {cpu: 8, mem: 0, want: "n2-highcpu-8"},
+		"moreMemory":     {cpu: 8, mem: 40000, want: "n2-highmem-8"},
+		"moreCPU":        {cpu: 12, mem: 1024, want: "n2-standard-16"},
+		"customCPU":      {cpu: 32, mem: 65536, want: "custom-32-65536"},
+		"customMemory":   {cpu: 4, mem: 100000, want: "custom-16-100096"},
+		"customTooLarge": {cpu: 128, mem: 1024, err: ErrorCustomMachineType},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := c.MachineTypeMatch("ds-test", "us-central1-a", tc.cpu, tc.mem)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestDiskTypes(t *testing.T) {
+	tests := map[string]struct {
+		input []*compute.DiskType
+		want  LabeledValues
+	}{
+		"ordered": {
+			input: []*compute.DiskType{
+				{Name: "hyperdisk-balanced"},
+				{Name: "hyperdisk-throughput"},
+				{Name: "hyperdisk-ml"},
+				{Name: "local-ssd"},
+				{Name: "pd-ssd"},
+				{Name: "pd-extreme"},
+				{Name: "pd-standard"},
+				{Name: "pd-balanced"},
+			},
+			want: LabeledValues{
+				{Label: "Standard", Value: "pd-standard"},
+				{Label: "Balanced", Value: "pd-balanced"},
+				{Label: "SSD", Value: "pd-ssd"},
+				{Label: "Extreme", Value: "pd-extreme"},
+				{Label: "Hyperdisk Balanced", Value: "hyperdisk-balanced"},
+			},
+		},
+		"unknown": {
+			input: []*compute.DiskType{
+				{Name: "pd-standard"},
+				{Name: "pd-future", Description: "Future Persistent Disk"},
+				{Name: "pd-old", Deprecated: &compute.DeprecationStatus{State: "DEPRECATED"}},
+			},
+			want: LabeledValues{
+				{Label: "Standard", Value: "pd-standard"},
+				{Label: "Future Persistent Disk", Value: "pd-future"},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, diskTypes(tc.input))
+		})
+	}
+}
+
+func TestDiskTypesSpelling(t *testing.T) {
+	for _, v := range DiskTypes {
+		assert.NotEqual(t, "pd-sdd", v.Value)
+	}
+	assert.Contains(t, DiskTypes, LabeledValue{Label: "SSD", Value: "pd-ssd"})
+}
+
+func TestDiskTypeList(t *testing.T) {
+	var gotPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"items":[{"name":"pd-ssd"},{"name":"pd-standard"},{"name":"local-ssd"}]}`)
+	}))
+	defer srv.Close()
+
+	svc, err := compute.NewService(ctx,
+		option.WithEndpoint(srv.URL+"/"),
+		option.WithHTTPClient(srv.Client()),
+	)
+	if err != nil {
+		t.Fatalf("could not create fake compute: %s", err)
+	}
+
+	c := NewClient(ctx, defaultUserAgent)
+	c.services.computeService = svc
+
+	got, err := c.DiskTypeList("ds-test", "us-central1-a")
+	if err != nil {
+		t.Fatalf("expected: no error, got: %s", err)
+	}
+
+	assert.True(t, strings.HasSuffix(gotPath, "/zones/us-central1-a/diskTypes"), gotPath)
+	assert.Equal(t, LabeledValues{
+		{Label: "Standard", Value: "pd-standard"},
+		{Label: "SSD", Value: "pd-ssd"},
+	}, got)
+
+	_, err = c.DiskTypeList("ds-test", "")
+	assert.ErrorIs(t, err, ErrorZoneRequired)
+}
+
+func TestSubnetworks(t *testing.T) {
+	base := "https://www.googleapis.com/compute/v1/projects/ds-test"
+	items := []*compute.Subnetwork{
+		{Name: "web", Region: base + "/regions/us-central1", Network: base + "/global/networks/prod", IpCidrRange: "10.0.0.0/24"},
+		{Name: "db", Region: base + "/regions/us-central1", Network: base + "/global/networks/prod", IpCidrRange: "10.0.1.0/24"},
+		{Name: "default", Region: base + "/regions/us-central1", Network: base + "/global/networks/default", IpCidrRange: "10.128.0.0/20"},
+		{Name: "web-east", Region: base + "/regions/us-east1", Network: base + "/global/networks/prod", IpCidrRange: "10.1.0.0/24"},
+		{Name: "default", Region: base + "/regions/europe-west1", Network: base + "/global/networks/default", IpCidrRange: "10.132.0.0/20"},
+	}
+
+	tests := map[string]struct {
+		region  string
+		network string
+		want    LabeledValues
+	}{
+		"region": {
+			region: "us-central1",
+			want: LabeledValues{
+				{Label: "db (10.0.1.0/24)", Value: "db"},
+				{Label: "default (10.128.0.0/20)", Value: "default"},
+				{Label: "web (10.0.0.0/24)", Value: "web"},
+			},
+		},
+		"regionAndNetwork": {
+			region:  "us-central1",
+			network: "prod",
+			want: LabeledValues{
+				{Label: "db (10.0.1.0/24)", Value: "db"},
+				{Label: "web (10.0.0.0/24)", Value: "web"},
+			},
+		},
+		"otherRegion": {
+			region: "us-east1",
+			want: LabeledValues{
+				{Label: "web-east (10.1.0.0/24)", Value: "web-east"},
+			},
+		},
+		"noSubnets": {
+			region: "asia-east1",
+			want:   LabeledValues{},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, subnetworks(items, tc.region, tc.network))
+		})
+	}
+
+	assert.Equal(t, []string{"us-central1", "us-east1"}, networkRegions(items, "prod"))
+	assert.Equal(t, []string{"europe-west1", "us-central1"}, networkRegions(items, "default"))
+	assert.Equal(t, []string{}, networkRegions(items, "missing"))
+}
+
+func TestNetworkAndSubnetworkList(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/global/networks"):
+			fmt.Fprint(w, `{"items":[{"name":"prod"},{"name":"default"}]}`)
+		case strings.HasSuffix(r.URL.Path, "/aggregated/subnetworks"):
+			fmt.Fprint(w, `{"items":{
+				"regions/us-central1":{"subnetworks":[
+					{"name":"web","region":"regions/us-central1","network":"global/networks/prod","ipCidrRange":"10.0.0.0/24"}
+				]},
+				"regions/us-east1":{"subnetworks":[
+					{"name":"web-east","region":"regions/us-east1","network":"global/networks/prod","ipCidrRange":"10.1.0.0/24"}
+				]}
+			}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	svc, err := compute.NewService(ctx,
+		option.WithEndpoint(srv.URL+"/"),
+		option.WithHTTPClient(srv.Client()),
+	)
+	if err != nil {
+		t.Fatalf("could not create fake compute: %s", err)
+	}
+
+	c := NewClient(ctx, defaultUserAgent)
+	c.services.computeService = svc
+
+	networks, err := c.NetworkList("ds-test")
+	assert.Nil(t, err)
+	assert.Equal(t, LabeledValues{
+		{Label: "default", Value: "default"},
+		{Label: "prod", Value: "prod"},
+	}, networks)
+
+	subnets, err := c.SubnetworkList("ds-test", "us-east1")
+	assert.Nil(t, err)
+	assert.Equal(t, LabeledValues{{Label: "web-east (10.1.0.0/24)", Value: "web-east"}}, subnets)
+
+	regions, err := c.NetworkRegions("ds-test", "prod")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"us-central1", "us-east1"}, regions)
+}
+
+func TestComputeListContext(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	calls := map[string]func(c *Client) error{
+		"ComputeRegionList": func(c *Client) error {
+			_, err := c.ComputeRegionList("ds-test")
+			return err
+		},
+		"ZoneList": func(c *Client) error {
+			_, err := c.ZoneList("ds-test", "us-central1")
+			return err
+		},
+		"MachineTypeList": func(c *Client) error {
+			_, err := c.MachineTypeList("ds-test", "us-central1-a")
+			return err
+		},
+		"ImageList": func(c *Client) error {
+			_, err := c.ImageList("ds-test", "debian-cloud")
+			return err
+		},
+		"DiskTypeList": func(c *Client) error {
+			_, err := c.DiskTypeList("ds-test", "us-central1-a")
+			return err
+		},
+		"NetworkList": func(c *Client) error {
+			_, err := c.NetworkList("ds-test")
+			return err
+		},
+		"InstanceGet": func(c *Client) error {
+			_, err := c.InstanceGet("ds-test", "us-central1-a", "web")
+			return err
+		},
+	}
+
+	for name, call := range calls {
+		t.Run(name+"Cancelled", func(t *testing.T) {
+			cctx, cancel := context.WithCancel(ctx)
+			cancel()
+
+			c := NewClient(cctx, defaultUserAgent)
+			svc, err := compute.NewService(ctx,
+				option.WithEndpoint(srv.URL+"/"),
+				option.WithHTTPClient(srv.Client()),
+			)
+			if err != nil {
+				t.Fatalf("could not create fake compute: %s", err)
+			}
+			c.services.computeService = svc
+
+			start := time.Now()
+			err = call(&c)
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("expected: %s, got: %v", context.Canceled, err)
+			}
+			assert.Less(t, time.Since(start), time.Second)
+		})
+
+		t.Run(name+"Timeout", func(t *testing.T) {
+			c := NewClient(ctx, defaultUserAgent)
+			c.SetTimeout(50 * time.Millisecond)
+			svc, err := compute.NewService(ctx,
+				option.WithEndpoint(srv.URL+"/"),
+				option.WithHTTPClient(srv.Client()),
+			)
+			if err != nil {
+				t.Fatalf("could not create fake compute: %s", err)
+			}
+			c.services.computeService = svc
+
+			start := time.Now()
+			err = call(&c)
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("expected: %s, got: %v", context.DeadlineExceeded, err)
+			}
+			assert.Less(t, time.Since(start), time.Second)
+		})
+	}
+}
+
+func TestRegionLabel(t *testing.T) {
+	tests := map[string]struct {
+		in   string
+		want string
+	}{
+		"iowa":      {in: "us-central1", want: "Iowa (us-central1)"},
+		"belgium":   {in: "europe-west1", want: "Belgium (europe-west1)"},
+		"tokyo":     {in: "asia-northeast1", want: "Tokyo (asia-northeast1)"},
+		"saoPaulo":  {in: "southamerica-east1", want: "São Paulo (southamerica-east1)"},
+		"unknown":   {in: "mars-north1", want: "mars-north1"},
+		"emptyName": {in: "", want: ""},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, RegionLabel(tc.in))
+		})
+	}
+}
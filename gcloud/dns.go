@@ -0,0 +1,72 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcloud
+
+import (
+	"fmt"
+
+	"google.golang.org/api/dns/v1"
+)
+
+func (c *Client) getDNSService(project string) (*dns.Service, error) {
+	var err error
+	svc := c.services.dns
+
+	if svc != nil {
+		return svc, nil
+	}
+
+	if err := c.ServiceEnable(project, DNS); err != nil {
+		return nil, fmt.Errorf("error activating service for polling: %s", err)
+	}
+
+	svc, err = dns.NewService(c.ctx, c.opts)
+	if err != nil {
+		return nil, err
+	}
+
+	svc.UserAgent = c.userAgent
+	c.services.dns = svc
+
+	return svc, nil
+}
+
+// DNSZoneList retrieves the Cloud DNS managed zones in a project, valued by
+// zone name and labeled with the domain each serves
+func (c *Client) DNSZoneList(project string) (LabeledValues, error) {
+	svc, err := c.getDNSService(project)
+	if err != nil {
+		return nil, err
+	}
+
+	lb := LabeledValues{}
+
+	err = svc.ManagedZones.List(project).Pages(c.ctx, func(resp *dns.ManagedZonesListResponse) error {
+		for _, v := range resp.ManagedZones {
+			lb = append(lb, LabeledValue{
+				Value: v.Name,
+				Label: fmt.Sprintf("%s (%s)", v.Name, v.DnsName),
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not list dns zones in project (%s): %s", project, err)
+	}
+
+	lb.Sort()
+
+	return lb, nil
+}
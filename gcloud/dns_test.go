@@ -0,0 +1,76 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcloud
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/dns/v1"
+	"google.golang.org/api/option"
+)
+
+func TestDNSZoneList(t *testing.T) {
+	tests := map[string]struct {
+		body string
+		want LabeledValues
+	}{
+		"zones": {
+			body: `{"managedZones":[
+				{"name":"shop-zone","dnsName":"shop.example.com."},
+				{"name":"blog-zone","dnsName":"blog.example.com."}
+			]}`,
+			want: LabeledValues{
+				{Value: "blog-zone", Label: "blog-zone (blog.example.com.)"},
+				{Value: "shop-zone", Label: "shop-zone (shop.example.com.)"},
+			},
+		},
+		"none": {
+			body: `{}`,
+			want: LabeledValues{},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprint(w, tc.body)
+			}))
+			defer srv.Close()
+
+			svc, err := dns.NewService(ctx,
+				option.WithEndpoint(srv.URL+"/"),
+				option.WithHTTPClient(srv.Client()),
+			)
+			if err != nil {
+				t.Fatalf("could not create fake dns: %s", err)
+			}
+
+			c := NewClient(ctx, defaultUserAgent)
+			c.services.dns = svc
+
+			got, err := c.DNSZoneList("ds-test")
+			if err != nil {
+				t.Fatalf("expected: no error, got: %s", err)
+			}
+
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -19,9 +19,14 @@ package gcloud
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/rand"
+	"net/http"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	domains "cloud.google.com/go/domains/apiv1beta1"
 	scheduler "cloud.google.com/go/scheduler/apiv1beta1"
@@ -30,12 +35,17 @@ import (
 	"google.golang.org/api/cloudbuild/v1"
 	"google.golang.org/api/cloudfunctions/v1"
 	"google.golang.org/api/cloudresourcemanager/v1"
+	resourcemanagerfolders "google.golang.org/api/cloudresourcemanager/v2"
 	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/dns/v1"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/iam/v1"
 	"google.golang.org/api/option"
 	"google.golang.org/api/run/v1"
 	"google.golang.org/api/secretmanager/v1"
 	"google.golang.org/api/serviceusage/v1"
+	serviceusagebeta "google.golang.org/api/serviceusage/v1beta1"
+	"google.golang.org/api/siteverification/v1"
 )
 
 var (
@@ -48,11 +58,28 @@ var (
 	// DefaultImageProject is the default project for images used in compute calls.
 	DefaultImageProject = "debian-cloud"
 	// DefaultImageFamily is the default project for images used in compute calls.
-	DefaultImageFamily = "debian-11"
+	DefaultImageFamily = "debian-12"
+	// DefaultImageFamilies maps image projects to the family that should be
+	// offered as the default when that project is picked.
+	DefaultImageFamilies = map[string]string{
+		"centos-cloud":        "centos-stream-9",
+		"cos-cloud":           "cos-stable",
+		"debian-cloud":        DefaultImageFamily,
+		"fedora-coreos-cloud": "fedora-coreos-stable",
+		"opensuse-cloud":      "opensuse-leap",
+		"rhel-cloud":          "rhel-9",
+		"rocky-linux-cloud":   "rocky-linux-9",
+		"suse-cloud":          "sles-15",
+		"ubuntu-os-cloud":     "ubuntu-2204-lts",
+		"windows-cloud":       "windows-2022",
+	}
 	// DefaultDiskSize is the default size for making disks for Compute Engine
 	DefaultDiskSize = "200"
 	// DefaultDiskType is the default style of disk
 	DefaultDiskType = "pd-standard"
+	// DefaultDiskReplication is the default replication of disk, zonal or
+	// regional
+	DefaultDiskReplication = "zonal"
 	// DefaultInstanceType is the default machine type of compute engine
 	DefaultInstanceType = "n1-standard-1"
 	// HTTPServerTags are the instance tags to open up the instance to be a
@@ -67,6 +94,9 @@ var (
 	// ErrorBillingNoPermission is the error you get if the user lacks billing
 	// related permissions
 	ErrorBillingNoPermission = fmt.Errorf("user lacks permission")
+	// ErrorProjectBillingLink is the error you get if a project was created
+	// but couldn't be linked to the billing account asked for
+	ErrorProjectBillingLink = fmt.Errorf("project was created but could not be linked to billing")
 	// ErrorProjectCreateTooLong is an error when you try to create a project
 	// wuth more than 30 characters
 	ErrorProjectCreateTooLong = fmt.Errorf("project_id contains too many characters, limit 30")
@@ -79,10 +109,262 @@ var (
 	// ErrorProjectAlreadyExists is an error when you try and create a project
 	// That already exists
 	ErrorProjectAlreadyExists = fmt.Errorf("project_id already exists")
+	// ErrorProjectParentType is an error when a project is to be created in a
+	// parent that is neither a folder nor an organization
+	ErrorProjectParentType = fmt.Errorf("project parent must be a folder or an organization")
+	// ErrorLabelInvalid is an error when a label key or value breaks the
+	// Google Cloud label rules
+	ErrorLabelInvalid = fmt.Errorf("invalid label")
 	// ErrorProjectDidNotFinish is an error we cannot confirm that project completion actually occurred
 	ErrorProjectDidNotFinish = fmt.Errorf("project creation did not complete in a timely manner")
+	// ErrorAuthExpired is returned, wrapping the API's own error, when a call
+	// fails because the credentials have expired or been revoked. Retrying
+	// won't help, the user has to log in again.
+	ErrorAuthExpired = fmt.Errorf("google cloud credentials have expired or are invalid, run 'gcloud auth login' and 'gcloud auth application-default login' then try again")
+	// ErrorRegionRequired communicates that an empty region string has been passed
+	ErrorRegionRequired = fmt.Errorf("Region may not be an empty string")
+	// ErrorZoneRequired communicates that an empty zone string has been passed
+	ErrorZoneRequired = fmt.Errorf("Zone may not be an empty string")
+	// ErrorDiskTypeIncompatible is returned when an image can't boot from the
+	// chosen disk type
+	ErrorDiskTypeIncompatible = fmt.Errorf("image does not support disk type")
+	// ErrorScheduling is returned for a combination of scheduling options that
+	// Compute Engine won't accept
+	ErrorScheduling = fmt.Errorf("invalid scheduling")
+	// ErrorCustomMachineType is returned when a vCPU and memory combination can't
+	// make up a custom machine type
+	ErrorCustomMachineType = fmt.Errorf("invalid custom machine type")
+	// ErrReadOnly is returned by every method that would change something
+	// once the client has been made read only
+	ErrReadOnly = fmt.Errorf("client is read only")
 )
 
+// authErrorMarkers are fragments of the messages the auth libraries return
+// when credentials have expired or been revoked
+var authErrorMarkers = []string{
+	"invalid_grant",
+	"oauth2: token expired",
+	"code = Unauthenticated",
+	"Request had invalid authentication credentials",
+}
+
+// IsAuthError reports whether an error was caused by expired or invalid
+// credentials, rather than by the call itself
+func IsAuthError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, ErrorAuthExpired) {
+		return true
+	}
+
+	var gerr *googleapi.Error
+	if errors.As(err, &gerr) && gerr.Code == http.StatusUnauthorized {
+		return true
+	}
+
+	for _, v := range authErrorMarkers {
+		if strings.Contains(err.Error(), v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isNotFound reports whether an error was the API saying the resource
+// doesn't exist
+func isNotFound(err error) bool {
+	var gerr *googleapi.Error
+	return errors.As(err, &gerr) && gerr.Code == http.StatusNotFound
+}
+
+// IsRateLimited reports whether an error was the API telling us to slow down
+func IsRateLimited(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var gerr *googleapi.Error
+	if errors.As(err, &gerr) && gerr.Code == http.StatusTooManyRequests {
+		return true
+	}
+
+	return strings.Contains(err.Error(), "rateLimitExceeded")
+}
+
+// IsTransient reports whether an error is one that is worth retrying: the
+// API being rate limited or briefly unavailable
+func IsTransient(err error) bool {
+	if IsRateLimited(err) {
+		return true
+	}
+
+	var gerr *googleapi.Error
+	if errors.As(err, &gerr) {
+		switch gerr.Code {
+		case http.StatusInternalServerError, http.StatusServiceUnavailable:
+			return true
+		}
+	}
+
+	return false
+}
+
+// DefaultRetryAttempts is how many times a call is tried before a transient
+// error is given back to the caller
+const DefaultRetryAttempts = 6
+
+// retryBaseDelay is the wait before the first retry. It doubles with each
+// retry after that.
+var retryBaseDelay = 1 * time.Second
+
+// retryDelay is the wait before retry number attempt, with up to half of it
+// again added as jitter so that clients don't retry in lockstep
+func retryDelay(attempt int) time.Duration {
+	wait := retryBaseDelay << (attempt - 1)
+	if jitter := int64(wait / 2); jitter > 0 {
+		wait += time.Duration(rand.Int63n(jitter))
+	}
+	return wait
+}
+
+// retry runs f, retrying with exponential backoff for as long as it comes
+// back with a transient error and attempts remain. Any other error is
+// returned straight away, as is the context error if ctx ends while waiting.
+// Auth errors are never retried, and come back wrapped in ErrorAuthExpired
+// so callers can ask the user to log in again.
+func (c *Client) retry(ctx context.Context, f func() error) error {
+	attempts := c.retryAttempts
+	if attempts == 0 {
+		attempts = DefaultRetryAttempts
+	}
+
+	err := f()
+
+	for i := 1; i < attempts; i++ {
+		if IsAuthError(err) || !IsTransient(err) {
+			break
+		}
+
+		wait := retryDelay(i)
+		if notify := c.retryNotifyFor(ctx); notify != nil {
+			notify(i, wait)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+
+		err = f()
+	}
+
+	if IsAuthError(err) && !errors.Is(err, ErrorAuthExpired) {
+		return fmt.Errorf("%w: %w", ErrorAuthExpired, err)
+	}
+
+	return err
+}
+
+// retryNotifier holds the function set by SetRetryNotify. Copies of a Client
+// share it, and calls read it from several goroutines at once, so it is
+// guarded.
+type retryNotifier struct {
+	mu sync.RWMutex
+	f  func(attempt int, wait time.Duration)
+}
+
+type retryNotifyKey struct{}
+
+// WithRetryNotify returns a copy of ctx that has calls made with it report
+// their retries to f, in place of the function set by SetRetryNotify. It
+// lets one caller hear about its own retries without affecting anyone else
+// using the same client.
+func WithRetryNotify(ctx context.Context, f func(attempt int, wait time.Duration)) context.Context {
+	return context.WithValue(ctx, retryNotifyKey{}, f)
+}
+
+// retryNotifyFor returns the function to tell about a retry of a call made
+// with ctx: the one the context carries, or else the client wide one
+func (c *Client) retryNotifyFor(ctx context.Context) func(attempt int, wait time.Duration) {
+	if f, ok := ctx.Value(retryNotifyKey{}).(func(attempt int, wait time.Duration)); ok && f != nil {
+		return f
+	}
+
+	if c.retryNotify == nil {
+		return nil
+	}
+
+	c.retryNotify.mu.RLock()
+	defer c.retryNotify.mu.RUnlock()
+	return c.retryNotify.f
+}
+
+// SetRetryNotify sets a function to be called whenever a call is about to be
+// retried, so that users can be told why things are slow. It applies to
+// every call made with the client; use WithRetryNotify for a single call.
+func (c *Client) SetRetryNotify(f func(attempt int, wait time.Duration)) {
+	c.retryNotify.mu.Lock()
+	defer c.retryNotify.mu.Unlock()
+	c.retryNotify.f = f
+}
+
+// SetRetryAttempts sets how many times calls that fail with a transient error
+// are tried before giving up. One turns retries off.
+func (c *Client) SetRetryAttempts(n int) {
+	if n < 1 {
+		n = 1
+	}
+	c.retryAttempts = n
+}
+
+// DefaultTimeout is how long each Compute Engine call may take unless
+// SetTimeout says otherwise. It leaves room for operation waits, which
+// Compute Engine holds for up to two minutes.
+const DefaultTimeout = 5 * time.Minute
+
+// SetTimeout limits how long each Compute Engine call may take before it
+// gives up with a context error. Zero means no limit beyond the client's own
+// context.
+func (c *Client) SetTimeout(d time.Duration) {
+	c.timeout = d
+}
+
+// ReadOnly stops the client from changing anything. From then on the methods
+// that would create, change or delete something return ErrReadOnly instead,
+// while lookups work as before, so a stack can be evaluated without granting
+// write access.
+func (c *Client) ReadOnly() {
+	c.readOnly = true
+}
+
+// IsReadOnly reports whether ReadOnly has been called on the client
+func (c *Client) IsReadOnly() bool {
+	return c.readOnly
+}
+
+// checkWritable returns ErrReadOnly, naming action, if the client is read
+// only
+func (c *Client) checkWritable(action string) error {
+	if c.readOnly {
+		return fmt.Errorf("%w: %s", ErrReadOnly, action)
+	}
+
+	return nil
+}
+
+// callContext returns the context for a single API call, bounded by the
+// client timeout if one is set
+func (c *Client) callContext() (context.Context, context.CancelFunc) {
+	if c.timeout > 0 {
+		return context.WithTimeout(c.ctx, c.timeout)
+	}
+	return context.WithCancel(c.ctx)
+}
+
 // Client is the tool that will handle all of the communication between gcloud
 // and the various product areas
 type Client struct {
@@ -92,6 +374,20 @@ type Client struct {
 	opts            option.ClientOption
 	enabledServices map[string]bool
 	cache           map[string]interface{}
+	auditPath       string
+	retryNotify     *retryNotifier
+	retryAttempts   int
+	timeout         time.Duration
+
+	// prices are the prices MachineTypeListByFamily annotates machine
+	// types with, once MachineTypePricing has loaded them
+	prices *machineTypePrices
+
+	// projectPageBilling makes ProjectListPage look up billing too
+	projectPageBilling bool
+
+	// readOnly makes every mutating method return ErrReadOnly
+	readOnly bool
 }
 
 // NewClient initiates a new gcloud Client
@@ -102,6 +398,13 @@ func NewClient(ctx context.Context, ua string) Client {
 	c.opts = option.WithCredentialsFile("")
 	c.enabledServices = make(map[string]bool)
 	c.cache = map[string]interface{}{}
+	c.retryNotify = &retryNotifier{}
+	c.timeout = DefaultTimeout
+	c.prices = &machineTypePrices{byRegion: map[string]map[string]machineTypeRate{}}
+	c.services.machineTypes = &machineTypeCache{
+		lists:    map[string]*compute.MachineTypeList{},
+		inflight: map[string]*machineTypeCall{},
+	}
 	return c
 }
 
@@ -114,18 +417,24 @@ func (c *Client) get(key string) interface{} {
 }
 
 type services struct {
-	resourceManager *cloudresourcemanager.Service
-	billing         *cloudbilling.APIService
-	domains         *domains.Client
-	serviceUsage    *serviceusage.Service
-	computeService  *compute.Service
-	functions       *cloudfunctions.Service
-	run             *run.APIService
-	build           *cloudbuild.Service
-	iam             *iam.Service
-	scheduler       *scheduler.CloudSchedulerClient
-	secretManager   *secretmanager.Service
-	storage         *storage.Client
+	resourceManager  *cloudresourcemanager.Service
+	folders          *resourcemanagerfolders.Service
+	billing          *cloudbilling.APIService
+	domains          *domains.Client
+	dns              *dns.Service
+	serviceUsage     *serviceusage.Service
+	quotas           *serviceusagebeta.APIService
+	cloudQuotas      *cloudQuotasService
+	computeService   *compute.Service
+	machineTypes     *machineTypeCache
+	functions        *cloudfunctions.Service
+	run              *run.APIService
+	build            *cloudbuild.Service
+	iam              *iam.Service
+	scheduler        *scheduler.CloudSchedulerClient
+	secretManager    *secretmanager.Service
+	siteVerification *siteverification.Service
+	storage          *storage.Client
 }
 
 // RegionList will return a list of RegionsList depending on product type
@@ -207,6 +516,23 @@ func (l *LabeledValues) SetDefault(value string) {
 	}
 }
 
+// Unique returns the list with entries that repeat an earlier Value removed.
+// The first entry for each Value is kept, along with its label and default.
+func (l LabeledValues) Unique() LabeledValues {
+	seen := map[string]bool{}
+	result := LabeledValues{}
+
+	for _, v := range l {
+		if seen[v.Value] {
+			continue
+		}
+		seen[v.Value] = true
+		result = append(result, v)
+	}
+
+	return result
+}
+
 // NewLabeledValues takes a slice of strings and returns a list of LabeledValues
 func NewLabeledValues(sl []string, defaultValue string) LabeledValues {
 	r := LabeledValues{}
@@ -17,20 +17,26 @@ package gcloud
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
-	"math/rand"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"reflect"
 	"sort"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"cloud.google.com/go/scheduler/apiv1beta1/schedulerpb"
+	"github.com/GoogleCloudPlatform/deploystack/config"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/option"
 )
 
@@ -76,17 +82,6 @@ func readTestFile(file string) string {
 	return string(dat)
 }
 
-func randSeq(n int) string {
-	rand.Seed(time.Now().Unix())
-
-	letters := []rune("abcdefghijklmnopqrstuvwxyz")
-	b := make([]rune, n)
-	for i := range b {
-		b[i] = letters[rand.Intn(len(letters))]
-	}
-	return string(b)
-}
-
 func removeFromSlice(slice []string, s string) []string {
 	for i, v := range slice {
 		if v == s {
@@ -639,6 +634,48 @@ func TestLabeledValuesLongestLen(t *testing.T) {
 	}
 }
 
+func TestLabeledValuesUnique(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		in   LabeledValues
+		want LabeledValues
+	}{
+		"empty": {
+			in:   LabeledValues{},
+			want: LabeledValues{},
+		},
+		"noDuplicates": {
+			in: LabeledValues{
+				{Label: "n1 standard", Value: "n1-standard"},
+				{Label: "e2 medium", Value: "e2-medium"},
+			},
+			want: LabeledValues{
+				{Label: "n1 standard", Value: "n1-standard"},
+				{Label: "e2 medium", Value: "e2-medium"},
+			},
+		},
+		"duplicates": {
+			in: LabeledValues{
+				{Label: "n1 standard", Value: "n1-standard", IsDefault: true},
+				{Label: "e2 medium", Value: "e2-medium"},
+				{Label: "N1 Standard (us-east1-b)", Value: "n1-standard"},
+				{Label: "e2 medium (us-east1-c)", Value: "e2-medium", IsDefault: true},
+			},
+			want: LabeledValues{
+				{Label: "n1 standard", Value: "n1-standard", IsDefault: true},
+				{Label: "e2 medium", Value: "e2-medium"},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := tc.in.Unique()
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
 func TestLabeledValuesGetDefault(t *testing.T) {
 	t.Parallel()
 	tests := map[string]struct {
@@ -801,3 +838,348 @@ func getBadClient() *Client {
 
 	return &c
 }
+
+func TestIsAuthError(t *testing.T) {
+	tests := map[string]struct {
+		in   error
+		want bool
+	}{
+		"nil":          {in: nil, want: false},
+		"generic":      {in: fmt.Errorf("something broke"), want: false},
+		"googleapi403": {in: &googleapi.Error{Code: 403, Message: "forbidden"}, want: false},
+		"googleapi401": {in: &googleapi.Error{Code: 401, Message: "unauthorized"}, want: true},
+		"wrapped401":   {in: fmt.Errorf("could not list: %w", &googleapi.Error{Code: 401}), want: true},
+		"invalidGrant": {in: fmt.Errorf(`oauth2: cannot fetch token: {"error":"invalid_grant"}`), want: true},
+		"grpc":         {in: fmt.Errorf("rpc error: code = Unauthenticated desc = token expired"), want: true},
+		"expired":      {in: fmt.Errorf("could not list: %w", ErrorAuthExpired), want: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := IsAuthError(tc.in)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+// flakyTransport fails the first failures requests with status, then
+// answers with body
+type flakyTransport struct {
+	mu       sync.Mutex
+	failures int
+	status   int
+	body     string
+	calls    int
+}
+
+func (f *flakyTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls++
+	status, body := http.StatusOK, f.body
+	if f.calls <= f.failures {
+		status = f.status
+		body = fmt.Sprintf(`{"error":{"code":%d,"message":"%s"}}`, f.status, http.StatusText(f.status))
+	}
+
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+		Request:    r,
+	}, nil
+}
+
+func TestRetryTransient(t *testing.T) {
+	oldDelay := retryBaseDelay
+	retryBaseDelay = time.Millisecond
+	defer func() { retryBaseDelay = oldDelay }()
+
+	tests := map[string]struct {
+		failures  int
+		status    int
+		attempts  int
+		wantCalls int
+		wantErr   bool
+	}{
+		"503ThenOK":        {failures: 2, status: http.StatusServiceUnavailable, wantCalls: 3},
+		"429ThenOK":        {failures: 2, status: http.StatusTooManyRequests, wantCalls: 3},
+		"500ThenOK":        {failures: 1, status: http.StatusInternalServerError, wantCalls: 2},
+		"NotRetried":       {failures: 2, status: http.StatusNotFound, wantCalls: 1, wantErr: true},
+		"OutOfAttempts":    {failures: 2, status: http.StatusServiceUnavailable, attempts: 2, wantCalls: 2, wantErr: true},
+		"RetriesTurnedOff": {failures: 2, status: http.StatusServiceUnavailable, attempts: 1, wantCalls: 1, wantErr: true},
+		"DefaultIsEnough":  {failures: DefaultRetryAttempts - 1, status: http.StatusServiceUnavailable, wantCalls: DefaultRetryAttempts},
+		"DefaultRunsOut":   {failures: DefaultRetryAttempts, status: http.StatusServiceUnavailable, wantCalls: DefaultRetryAttempts, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			fake := &flakyTransport{
+				failures: tc.failures,
+				status:   tc.status,
+				body:     `{"items":[{"name":"us-east1"},{"name":"us-central1"}]}`,
+			}
+
+			svc, err := compute.NewService(ctx,
+				option.WithEndpoint("http://compute.fake/"),
+				option.WithHTTPClient(&http.Client{Transport: fake}),
+			)
+			if err != nil {
+				t.Fatalf("could not create fake compute: %s", err)
+			}
+
+			c := NewClient(ctx, defaultUserAgent)
+			c.services.computeService = svc
+			if tc.attempts > 0 {
+				c.SetRetryAttempts(tc.attempts)
+			}
+
+			got, err := c.ComputeRegionList("ds-test")
+			assert.Equal(t, tc.wantCalls, fake.calls)
+
+			if tc.wantErr {
+				var gerr *googleapi.Error
+				if !errors.As(err, &gerr) || gerr.Code != tc.status {
+					t.Fatalf("expected: error %d, got: %v", tc.status, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected: no error, got: %s", err)
+			}
+			assert.Equal(t, []string{"us-central1", "us-east1"}, got)
+		})
+	}
+}
+
+func TestRetryAuthError(t *testing.T) {
+	oldDelay := retryBaseDelay
+	retryBaseDelay = time.Millisecond
+	defer func() { retryBaseDelay = oldDelay }()
+
+	fake := &flakyTransport{
+		failures: 2,
+		status:   http.StatusUnauthorized,
+		body:     `{"items":[{"name":"us-central1"}]}`,
+	}
+
+	svc, err := compute.NewService(ctx,
+		option.WithEndpoint("http://compute.fake/"),
+		option.WithHTTPClient(&http.Client{Transport: fake}),
+	)
+	if err != nil {
+		t.Fatalf("could not create fake compute: %s", err)
+	}
+
+	c := NewClient(ctx, defaultUserAgent)
+	c.services.computeService = svc
+
+	_, err = c.ComputeRegionList("ds-test")
+	assert.Equal(t, 1, fake.calls)
+
+	if !errors.Is(err, ErrorAuthExpired) {
+		t.Fatalf("expected: %s, got: %v", ErrorAuthExpired, err)
+	}
+
+	var gerr *googleapi.Error
+	if !errors.As(err, &gerr) || gerr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected: the 401 to be kept, got: %v", err)
+	}
+	assert.True(t, IsAuthError(err))
+}
+
+func TestRetryContext(t *testing.T) {
+	oldDelay := retryBaseDelay
+	retryBaseDelay = time.Hour
+	defer func() { retryBaseDelay = oldDelay }()
+
+	cctx, cancel := context.WithCancel(ctx)
+	c := NewClient(cctx, defaultUserAgent)
+	c.SetRetryNotify(func(attempt int, wait time.Duration) {
+		cancel()
+	})
+
+	calls := 0
+	err := c.retry(cctx, func() error {
+		calls++
+		return &googleapi.Error{Code: http.StatusServiceUnavailable}
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected: %s, got: %v", context.Canceled, err)
+	}
+	assert.Equal(t, 1, calls)
+}
+
+func TestRetryNotifyContext(t *testing.T) {
+	oldDelay := retryBaseDelay
+	retryBaseDelay = time.Millisecond
+	defer func() { retryBaseDelay = oldDelay }()
+
+	c := NewClient(ctx, defaultUserAgent)
+	c.SetRetryNotify(func(attempt int, wait time.Duration) {
+		t.Fatalf("expected the context notify to be used, got the client one")
+	})
+
+	notified := []int{}
+	cctx := WithRetryNotify(ctx, func(attempt int, wait time.Duration) {
+		notified = append(notified, attempt)
+	})
+
+	calls := 0
+	err := c.retry(cctx, func() error {
+		calls++
+		if calls < 3 {
+			return &googleapi.Error{Code: http.StatusServiceUnavailable}
+		}
+		return nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, []int{1, 2}, notified)
+}
+
+func TestRetryDelay(t *testing.T) {
+	for attempt := 1; attempt <= 5; attempt++ {
+		base := retryBaseDelay << (attempt - 1)
+		got := retryDelay(attempt)
+		assert.GreaterOrEqual(t, got, base)
+		assert.Less(t, got, base+base/2)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := map[string]struct {
+		err  error
+		want bool
+	}{
+		"429":         {err: &googleapi.Error{Code: http.StatusTooManyRequests}, want: true},
+		"500":         {err: &googleapi.Error{Code: http.StatusInternalServerError}, want: true},
+		"503":         {err: &googleapi.Error{Code: http.StatusServiceUnavailable}, want: true},
+		"RateLimited": {err: fmt.Errorf("googleapi: Error 403: rateLimitExceeded"), want: true},
+		"Wrapped":     {err: fmt.Errorf("listing: %w", &googleapi.Error{Code: http.StatusServiceUnavailable}), want: true},
+		"403":         {err: &googleapi.Error{Code: http.StatusForbidden}},
+		"404":         {err: &googleapi.Error{Code: http.StatusNotFound}},
+		"Other":       {err: fmt.Errorf("something else")},
+		"nil":         {},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, IsTransient(tc.err))
+		})
+	}
+}
+
+// toServer sends every request to srv, whichever API it was meant for
+type toServer struct {
+	srv *httptest.Server
+}
+
+func (t toServer) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.URL.Scheme = "http"
+	r.URL.Host = strings.TrimPrefix(t.srv.URL, "http://")
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestReadOnly(t *testing.T) {
+	project := "ds-readonly"
+
+	enables := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, ":enable"):
+			enables++
+			fmt.Fprint(w, `{"done":true}`)
+		case strings.HasSuffix(r.URL.Path, "/services/compute.googleapis.com"):
+			fmt.Fprint(w, `{"state":"ENABLED"}`)
+		case strings.Contains(r.URL.Path, "/services/"):
+			fmt.Fprint(w, `{"state":"DISABLED"}`)
+		case strings.HasSuffix(r.URL.Path, "/projects/ds-readonly/regions"):
+			fmt.Fprint(w, `{"items":[{"name":"us-east1"},{"name":"us-central1"}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(context.Background(), "testing")
+	c.opts = option.WithHTTPClient(&http.Client{Transport: toServer{srv}})
+	assert.False(t, c.IsReadOnly())
+
+	c.ReadOnly()
+	assert.True(t, c.IsReadOnly())
+
+	stack := config.NewStack()
+	stack.AddSetting("project_id", project)
+
+	tests := map[string]func() error{
+		"ProjectCreate": func() error {
+			return c.ProjectCreate(project, "", "", "")
+		},
+		"ProjectLabelsSet": func() error {
+			return c.ProjectLabelsSet(project, map[string]string{"team": "data"})
+		},
+		"ProjectDelete": func() error {
+			return c.ProjectDelete(project)
+		},
+		"ProjectIDSet": func() error {
+			return c.ProjectIDSet(project)
+		},
+		"BillingAccountAttach": func() error {
+			return c.BillingAccountAttach(project, "000000-000000-000000")
+		},
+		"ServiceEnable": func() error {
+			return c.ServiceEnable(project, Run)
+		},
+		"ServiceDisable": func() error {
+			return c.ServiceDisable(project, Compute)
+		},
+		"InstanceCreate": func() error {
+			return c.InstanceCreate(project, DefaultZone, &compute.Instance{Name: "readonly"})
+		},
+		"JobSchedule": func() error {
+			return c.JobSchedule(project, DefaultRegion, schedulerpb.Job{})
+		},
+		"SecretCreate": func() error {
+			return c.SecretCreate(project, "readonly", "payload")
+		},
+		"StorageBucketCreate": func() error {
+			return c.StorageBucketCreate(project, "readonly")
+		},
+		"StorageObjectCreate": func() error {
+			_, err := c.StorageObjectCreate(project, "readonly", "file.txt")
+			return err
+		},
+		"ServiceAccountCreate": func() error {
+			_, err := c.ServiceAccountCreate(project, "readonly", "Read Only")
+			return err
+		},
+		"Apply": func() error {
+			_, err := c.Apply(&stack)
+			return err
+		},
+	}
+
+	for name, f := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := f()
+			if !errors.Is(err, ErrReadOnly) {
+				t.Fatalf("expected: %v got: %v", ErrReadOnly, err)
+			}
+			assert.ErrorContains(t, err, name)
+		})
+	}
+
+	// Lookups still work, enabling the services they need only when those
+	// are already on
+	regions, err := c.ComputeRegionList(project)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"us-central1", "us-east1"}, regions)
+	assert.Equal(t, 0, enables)
+}
@@ -0,0 +1,118 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcloud
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ConfigDefaults are the project, region and zone set in the active gcloud
+// configuration, with `gcloud config set`
+type ConfigDefaults struct {
+	Project string
+	Region  string
+	Zone    string
+}
+
+// ConfigDefaultsGet reads the defaults from the active gcloud configuration.
+// If gcloud has never been configured the defaults are empty, rather than
+// an error.
+func (c *Client) ConfigDefaultsGet() (ConfigDefaults, error) {
+	dir := os.Getenv("CLOUDSDK_CONFIG")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ConfigDefaults{}, nil
+		}
+		dir = filepath.Join(home, ".config", "gcloud")
+	}
+
+	return configDefaultsRead(dir)
+}
+
+// configDefaultsRead reads the defaults of the active configuration in a
+// gcloud config directory
+func configDefaultsRead(dir string) (ConfigDefaults, error) {
+	name := os.Getenv("CLOUDSDK_ACTIVE_CONFIG_NAME")
+	if name == "" {
+		active, err := os.ReadFile(filepath.Join(dir, "active_config"))
+		if err == nil {
+			name = strings.TrimSpace(string(active))
+		}
+	}
+	if name == "" {
+		name = "default"
+	}
+
+	f, err := os.Open(filepath.Join(dir, "configurations", "config_"+name))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ConfigDefaults{}, nil
+		}
+		return ConfigDefaults{}, fmt.Errorf("could not read gcloud configuration (%s): %w", name, err)
+	}
+	defer f.Close()
+
+	return parseConfigDefaults(f)
+}
+
+// parseConfigDefaults reads the properties DeployStack cares about out of a
+// gcloud configuration file, which is in INI format
+func parseConfigDefaults(r io.Reader) (ConfigDefaults, error) {
+	result := ConfigDefaults{}
+	section := ""
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		switch section + "/" + key {
+		case "core/project":
+			result.Project = value
+		case "compute/region":
+			result.Region = value
+		case "compute/zone":
+			result.Zone = value
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return result, fmt.Errorf("could not parse gcloud configuration: %w", err)
+	}
+
+	return result, nil
+}
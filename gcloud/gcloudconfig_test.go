@@ -0,0 +1,110 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcloud
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const sampleGcloudConfig = `[core]
+account = someone@example.com
+project = ds-test-project
+disable_usage_reporting = True
+
+; region and zone for compute
+[compute]
+region = europe-west1
+zone = europe-west1-b
+
+[run]
+region = us-central1
+`
+
+func TestParseConfigDefaults(t *testing.T) {
+	tests := map[string]struct {
+		in   string
+		want ConfigDefaults
+	}{
+		"full": {
+			in: sampleGcloudConfig,
+			want: ConfigDefaults{
+				Project: "ds-test-project",
+				Region:  "europe-west1",
+				Zone:    "europe-west1-b",
+			},
+		},
+		"projectOnly": {
+			in:   "[core]\nproject = ds-test-project\n",
+			want: ConfigDefaults{Project: "ds-test-project"},
+		},
+		"otherSections": {
+			in:   "[run]\nregion = us-central1\n[core]\naccount = someone@example.com\n",
+			want: ConfigDefaults{},
+		},
+		"empty": {},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := parseConfigDefaults(strings.NewReader(tc.in))
+			if err != nil {
+				t.Fatalf("expected: no error, got: %s", err)
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestConfigDefaultsRead(t *testing.T) {
+	t.Setenv("CLOUDSDK_ACTIVE_CONFIG_NAME", "")
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "configurations"), 0o755); err != nil {
+		t.Fatalf("could not create configurations: %s", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "configurations", "config_default"), []byte("[core]\nproject = ds-default\n"), 0o644); err != nil {
+		t.Fatalf("could not write config: %s", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "configurations", "config_work"), []byte(sampleGcloudConfig), 0o644); err != nil {
+		t.Fatalf("could not write config: %s", err)
+	}
+
+	got, err := configDefaultsRead(dir)
+	if err != nil {
+		t.Fatalf("expected: no error, got: %s", err)
+	}
+	assert.Equal(t, ConfigDefaults{Project: "ds-default"}, got)
+
+	if err := os.WriteFile(filepath.Join(dir, "active_config"), []byte("work\n"), 0o644); err != nil {
+		t.Fatalf("could not write active config: %s", err)
+	}
+
+	got, err = configDefaultsRead(dir)
+	if err != nil {
+		t.Fatalf("expected: no error, got: %s", err)
+	}
+	assert.Equal(t, "europe-west1-b", got.Zone)
+
+	got, err = configDefaultsRead(filepath.Join(dir, "missing"))
+	if err != nil {
+		t.Fatalf("expected: no error for a missing config, got: %s", err)
+	}
+	assert.Equal(t, ConfigDefaults{}, got)
+}
@@ -45,6 +45,10 @@ func (c *Client) getIAMService(project string) (*iam.Service, error) {
 
 // ServiceAccountCreate creates a service account. A little on the nose
 func (c *Client) ServiceAccountCreate(project, username, displayName string) (string, error) {
+	if err := c.checkWritable("ServiceAccountCreate"); err != nil {
+		return "", err
+	}
+
 	svc, err := c.getIAMService(project)
 	if err != nil {
 		return "", err
@@ -62,18 +66,34 @@ func (c *Client) ServiceAccountCreate(project, username, displayName string) (st
 		return "", err
 	}
 
+	c.auditLog("ServiceAccountCreate", map[string]any{
+		"project": project,
+		"email":   servicaccount.Email,
+	})
+
 	return servicaccount.Email, nil
 }
 
 // ServiceAccountDelete deletes a service account. A little on the nose
 func (c *Client) ServiceAccountDelete(project, email string) error {
+	if err := c.checkWritable("ServiceAccountDelete"); err != nil {
+		return err
+	}
+
 	svc, err := c.getIAMService(project)
 	if err != nil {
 		return err
 	}
 
 	name := fmt.Sprintf("projects/%s/serviceAccounts/%s", project, email)
-	_, err = svc.Projects.ServiceAccounts.Delete(name).Do()
+	if _, err = svc.Projects.ServiceAccounts.Delete(name).Do(); err != nil {
+		return err
+	}
+
+	c.auditLog("ServiceAccountDelete", map[string]any{
+		"project": project,
+		"email":   email,
+	})
 
-	return err
+	return nil
 }
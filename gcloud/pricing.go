@@ -0,0 +1,189 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcloud
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"google.golang.org/api/cloudbilling/v1"
+	"google.golang.org/api/compute/v1"
+)
+
+// ComputeBillingService is the Cloud Billing Catalog name of Compute Engine
+const ComputeBillingService = "services/6F81-5844-456A"
+
+// machineTypeRate is the on-demand hourly price of one vCPU and one GB of
+// memory for a machine type family
+type machineTypeRate struct {
+	core float64
+	ram  float64
+}
+
+// machineTypePrices holds the prices loaded for each region, and the ones in
+// use. Prices load while the machine types are being listed, so the lock
+// guards both.
+type machineTypePrices struct {
+	mu       sync.Mutex
+	byRegion map[string]map[string]machineTypeRate
+	current  map[string]machineTypeRate
+}
+
+// use makes the prices loaded for region, if any, the ones in use, and
+// reports whether there were any
+func (p *machineTypePrices) use(region string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	rates, ok := p.byRegion[region]
+	p.current = rates
+	return ok
+}
+
+// set keeps rates for region and puts them in use
+func (p *machineTypePrices) set(region string, rates map[string]machineTypeRate) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.byRegion[region] = rates
+	p.current = rates
+}
+
+// rates returns the prices in use
+func (p *machineTypePrices) rates() map[string]machineTypeRate {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.current
+}
+
+// machineTypeSku matches the descriptions of the predefined vCPU and memory
+// SKUs, like "N1 Predefined Instance Core running in Americas" or
+// "E2 Instance Ram running in Americas"
+var machineTypeSku = regexp.MustCompile(`^(\S+) (?:Predefined )?Instance (Core|Ram) running in`)
+
+// MachineTypePricing loads the on-demand prices of machine types in region
+// from the Cloud Billing Catalog. Once loaded, MachineTypeListByFamily adds
+// an approximate hourly price to the machine types it lists. Prices are in
+// USD and leave out discounts.
+func (c *Client) MachineTypePricing(region string) error {
+	// Until loaded, prices from an earlier region would be wrong here
+	if c.prices.use(region) {
+		return nil
+	}
+
+	svc, err := c.getCloudbillingService()
+	if err != nil {
+		return err
+	}
+
+	skus := []*cloudbilling.Sku{}
+	if err := svc.Services.Skus.List(ComputeBillingService).CurrencyCode("USD").Pages(c.ctx, func(page *cloudbilling.ListSkusResponse) error {
+		skus = append(skus, page.Skus...)
+		return nil
+	}); err != nil {
+		return fmt.Errorf("could not get machine type prices: %w", err)
+	}
+
+	c.prices.set(region, machineTypeRates(skus, region))
+
+	return nil
+}
+
+// machineTypeRates picks out the on-demand vCPU and memory prices for each
+// machine type family in region
+func machineTypeRates(skus []*cloudbilling.Sku, region string) map[string]machineTypeRate {
+	rates := map[string]machineTypeRate{}
+
+	for _, v := range skus {
+		if v.Category == nil || v.Category.UsageType != "OnDemand" {
+			continue
+		}
+
+		inRegion := false
+		for _, r := range v.ServiceRegions {
+			if r == region {
+				inRegion = true
+				break
+			}
+		}
+		if !inRegion {
+			continue
+		}
+
+		m := machineTypeSku.FindStringSubmatch(v.Description)
+		if m == nil {
+			continue
+		}
+
+		price, ok := skuUnitPrice(v)
+		if !ok {
+			continue
+		}
+
+		family := strings.ToLower(m[1])
+		rate := rates[family]
+		if m[2] == "Core" {
+			rate.core = price
+		} else {
+			rate.ram = price
+		}
+		rates[family] = rate
+	}
+
+	return rates
+}
+
+// skuUnitPrice returns the price of a single unit of a SKU, going by its
+// last pricing tier
+func skuUnitPrice(sku *cloudbilling.Sku) (float64, bool) {
+	if len(sku.PricingInfo) == 0 || sku.PricingInfo[0].PricingExpression == nil {
+		return 0, false
+	}
+
+	tiers := sku.PricingInfo[0].PricingExpression.TieredRates
+	if len(tiers) == 0 || tiers[len(tiers)-1].UnitPrice == nil {
+		return 0, false
+	}
+
+	price := tiers[len(tiers)-1].UnitPrice
+	return float64(price.Units) + float64(price.Nanos)/1e9, true
+}
+
+// machineTypePrice works out the hourly price of a machine type from the
+// rates of its series, if both its vCPU and memory rates are known. Shared
+// core types, like e2-micro, only get a fraction of their vCPUs and are
+// billed differently, so they aren't priced.
+func machineTypePrice(mt compute.MachineType, rates map[string]machineTypeRate) (float64, bool) {
+	if mt.IsSharedCpu {
+		return 0, false
+	}
+
+	series := strings.Split(mt.Name, "-")[0]
+
+	rate, ok := rates[series]
+	if !ok || rate.core == 0 || rate.ram == 0 {
+		return 0, false
+	}
+
+	return float64(mt.GuestCpus)*rate.core + float64(mt.MemoryMb)/1024*rate.ram, true
+}
+
+// formatHourlyPrice renders a price as an approximate hourly cost
+func formatHourlyPrice(price float64) string {
+	return fmt.Sprintf("(~$%.3f/hr)", price)
+}
@@ -0,0 +1,162 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcloud
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/cloudbilling/v1"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+)
+
+func testSku(description, usage string, units int64, nanos int64, regions ...string) *cloudbilling.Sku {
+	return &cloudbilling.Sku{
+		Description:    description,
+		Category:       &cloudbilling.Category{UsageType: usage},
+		ServiceRegions: regions,
+		PricingInfo: []*cloudbilling.PricingInfo{{
+			PricingExpression: &cloudbilling.PricingExpression{
+				TieredRates: []*cloudbilling.TierRate{{
+					UnitPrice: &cloudbilling.Money{Units: units, Nanos: nanos},
+				}},
+			},
+		}},
+	}
+}
+
+func TestMachineTypeRates(t *testing.T) {
+	tests := map[string]struct {
+		skus []*cloudbilling.Sku
+		want map[string]machineTypeRate
+	}{
+		"basic": {
+			skus: []*cloudbilling.Sku{
+				testSku("N1 Predefined Instance Core running in Americas", "OnDemand", 0, 31611000, "us-central1"),
+				testSku("N1 Predefined Instance Ram running in Americas", "OnDemand", 0, 4237000, "us-central1"),
+				testSku("E2 Instance Core running in Americas", "OnDemand", 0, 21811000, "us-central1"),
+			},
+			want: map[string]machineTypeRate{
+				"n1": {core: 0.031611, ram: 0.004237},
+				"e2": {core: 0.021811},
+			},
+		},
+		"WrongRegion": {
+			skus: []*cloudbilling.Sku{
+				testSku("N1 Predefined Instance Core running in EMEA", "OnDemand", 0, 34773000, "europe-west1"),
+			},
+			want: map[string]machineTypeRate{},
+		},
+		"Commitment": {
+			skus: []*cloudbilling.Sku{
+				testSku("Commitment v1: Cpu in Americas for 1 Year", "Commit1Yr", 0, 19915000, "us-central1"),
+				testSku("N1 Predefined Instance Core running in Americas", "Preemptible", 0, 6655000, "us-central1"),
+			},
+			want: map[string]machineTypeRate{},
+		},
+		"NotMachineType": {
+			skus: []*cloudbilling.Sku{
+				testSku("Storage PD Capacity", "OnDemand", 0, 40000000, "us-central1"),
+			},
+			want: map[string]machineTypeRate{},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := machineTypeRates(tc.skus, "us-central1")
+			assert.InDeltaMapValues(t, ratesToMap(tc.want), ratesToMap(got), 1e-9)
+		})
+	}
+}
+
+// ratesToMap flattens rates so that they can be compared with a tolerance
+func ratesToMap(rates map[string]machineTypeRate) map[string]float64 {
+	out := map[string]float64{}
+	for k, v := range rates {
+		out[k+"/core"] = v.core
+		out[k+"/ram"] = v.ram
+	}
+	return out
+}
+
+func TestMachineTypeListByFamilyPricing(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		if calls > 1 {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"error":{"code":403,"message":"denied"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"skus":[
+			{"description":"N1 Predefined Instance Core running in Americas","category":{"usageType":"OnDemand"},"serviceRegions":["us-central1"],"pricingInfo":[{"pricingExpression":{"tieredRates":[{"unitPrice":{"nanos":30000000}}]}}]},
+			{"description":"N1 Predefined Instance Ram running in Americas","category":{"usageType":"OnDemand"},"serviceRegions":["us-central1"],"pricingInfo":[{"pricingExpression":{"tieredRates":[{"unitPrice":{"nanos":4000000}}]}}]},
+			{"description":"E2 Instance Core running in Americas","category":{"usageType":"OnDemand"},"serviceRegions":["us-central1"],"pricingInfo":[{"pricingExpression":{"tieredRates":[{"unitPrice":{"nanos":20000000}}]}}]},
+			{"description":"E2 Instance Ram running in Americas","category":{"usageType":"OnDemand"},"serviceRegions":["us-central1"],"pricingInfo":[{"pricingExpression":{"tieredRates":[{"unitPrice":{"nanos":3000000}}]}}]}
+		]}`)
+	}))
+	defer srv.Close()
+
+	svc, err := cloudbilling.NewService(ctx,
+		option.WithEndpoint(srv.URL+"/"),
+		option.WithHTTPClient(srv.Client()),
+	)
+	if err != nil {
+		t.Fatalf("could not create fake billing: %s", err)
+	}
+
+	types := &compute.MachineTypeList{Items: []*compute.MachineType{
+		{Name: "n1-standard-1", Description: "1 vCPU, 3.75 GB RAM", GuestCpus: 1, MemoryMb: 3840},
+	}}
+
+	c := NewClient(ctx, defaultUserAgent)
+	c.services.billing = svc
+
+	got := c.MachineTypeListByFamily(types, "n1")
+	assert.False(t, strings.Contains(got[0].Label, "/hr"), "expected no price, got: %s", got[0].Label)
+
+	for i := 0; i < 2; i++ {
+		if err := c.MachineTypePricing("us-central1"); err != nil {
+			t.Fatalf("expected: no error, got: %s", err)
+		}
+	}
+	assert.Equal(t, 1, calls)
+
+	// 1 * 0.03 + 3.75 * 0.004
+	got = c.MachineTypeListByFamily(types, "n1")
+	assert.Equal(t, "n1-standard-1 1 vCPU, 3.75 GB RAM (~$0.045/hr)", got[0].Label)
+
+	// Shared core types only get part of their vCPUs, so they aren't priced
+	e2 := &compute.MachineTypeList{Items: []*compute.MachineType{
+		{Name: "e2-micro", Description: "2 vCPU, 1 GB RAM", GuestCpus: 2, MemoryMb: 1024, IsSharedCpu: true},
+	}}
+	got = c.MachineTypeListByFamily(e2, "e2")
+	assert.Equal(t, "e2-micro 2 vCPU, 1 GB RAM", got[0].Label)
+
+	// A failed lookup for another region must not leave the last region's
+	// prices in place
+	if err := c.MachineTypePricing("europe-west1"); err == nil {
+		t.Fatalf("expected: an error, got none")
+	}
+	got = c.MachineTypeListByFamily(types, "n1")
+	assert.Equal(t, "n1-standard-1 1 vCPU, 3.75 GB RAM", got[0].Label)
+}
@@ -0,0 +1,403 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcloud
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	serviceusagebeta "google.golang.org/api/serviceusage/v1beta1"
+	htransport "google.golang.org/api/transport/http"
+)
+
+var (
+	// ErrorQuotaUnsupported occurs when a quota metric has no limit that
+	// can be changed for the requested location.
+	ErrorQuotaUnsupported = fmt.Errorf("quota cannot be changed for this metric and location")
+
+	// ErrorQuotaNoPermission occurs when the user running this code isn't
+	// allowed to change quotas in the project.
+	ErrorQuotaNoPermission = fmt.Errorf("user lacks permission to change quotas")
+)
+
+// quotaJustification is the reason given on the quota increase requests
+// this package files
+const quotaJustification = "Requested by DeployStack to create the resources of a stack"
+
+func (c *Client) getQuotaService() (*serviceusagebeta.APIService, error) {
+	var err error
+	svc := c.services.quotas
+
+	if svc != nil {
+		return svc, nil
+	}
+
+	svc, err = serviceusagebeta.NewService(c.ctx, c.opts)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve service: %w", err)
+	}
+
+	svc.UserAgent = c.userAgent
+	c.services.quotas = svc
+
+	return svc, nil
+}
+
+// QuotaOverrideSet sets the consumer override of the quota metric, like
+// `compute.googleapis.com/cpus`, to value in region. Leave region empty for
+// metrics that aren't regional. An override only caps usage below the limit
+// Google has granted the project. It can't raise a quota past that limit, so
+// this is not a quota increase request. Increases still have to be asked for
+// through QuotaIncreaseRequest.
+func (c *Client) QuotaOverrideSet(project, region, metric string, value int64) error {
+	if err := c.checkWritable("QuotaOverrideSet"); err != nil {
+		return err
+	}
+
+	if project == "" {
+		return ErrorProjectRequired
+	}
+
+	svc, err := c.getQuotaService()
+	if err != nil {
+		return err
+	}
+
+	service, _, ok := strings.Cut(metric, "/")
+	if !ok {
+		return fmt.Errorf("%w: metric (%s) is not of the form service/metric", ErrorQuotaUnsupported, metric)
+	}
+
+	name := fmt.Sprintf("projects/%s/services/%s/consumerQuotaMetrics/%s", project, service, url.PathEscape(metric))
+
+	qm, err := svc.Services.ConsumerQuotaMetrics.Get(name).View("BASIC").Do()
+	if err != nil {
+		return quotaError(err)
+	}
+
+	limit := quotaLimit(qm.ConsumerQuotaLimits, region)
+	if limit == nil {
+		return fmt.Errorf("%w: metric (%s) region (%s)", ErrorQuotaUnsupported, metric, region)
+	}
+
+	if _, err := svc.Services.ConsumerQuotaMetrics.Limits.ConsumerOverrides.Create(limit.Name, quotaOverride(metric, region, value)).Do(); err != nil {
+		return quotaError(err)
+	}
+
+	c.auditLog("QuotaOverrideSet", map[string]any{
+		"project": project,
+		"region":  region,
+		"metric":  metric,
+		"value":   value,
+	})
+
+	return nil
+}
+
+// cloudQuotasService talks to the Cloud Quotas API, which files quota
+// increase requests. There is no generated client for it in the api module
+// this package uses, so requests are made by hand.
+type cloudQuotasService struct {
+	client    *http.Client
+	basePath  string
+	userAgent string
+}
+
+const cloudQuotasBasePath = "https://cloudquotas.googleapis.com/"
+
+func (c *Client) getCloudQuotasService(project string) (*cloudQuotasService, error) {
+	svc := c.services.cloudQuotas
+
+	if svc != nil {
+		return svc, nil
+	}
+
+	if err := c.ServiceEnable(project, CloudQuotas); err != nil {
+		return nil, fmt.Errorf("error activating service for polling: %s", err)
+	}
+
+	hc, _, err := htransport.NewClient(c.ctx, option.WithScopes("https://www.googleapis.com/auth/cloud-platform"), c.opts)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve service: %w", err)
+	}
+
+	svc = &cloudQuotasService{client: hc, basePath: cloudQuotasBasePath, userAgent: c.userAgent}
+	c.services.cloudQuotas = svc
+
+	return svc, nil
+}
+
+// do sends in, if there is one, as the body of a request to path and decodes
+// the response into out
+func (s *cloudQuotasService) do(ctx context.Context, method, path string, in, out any) error {
+	var body bytes.Buffer
+	if in != nil {
+		if err := json.NewEncoder(&body).Encode(in); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, s.basePath+path, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", s.userAgent)
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if err := googleapi.CheckResponse(res); err != nil {
+		return err
+	}
+
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+// quotaInfo is the part of a Cloud Quotas QuotaInfo needed to find the quota
+// a metric is limited by
+type quotaInfo struct {
+	QuotaID    string   `json:"quotaId"`
+	Metric     string   `json:"metric"`
+	Dimensions []string `json:"dimensions"`
+}
+
+type quotaInfoList struct {
+	QuotaInfos    []quotaInfo `json:"quotaInfos"`
+	NextPageToken string      `json:"nextPageToken"`
+}
+
+// quotaPreference is a Cloud Quotas QuotaPreference, the request for a new
+// value of a quota
+type quotaPreference struct {
+	Service       string            `json:"service"`
+	QuotaID       string            `json:"quotaId"`
+	QuotaConfig   quotaConfig       `json:"quotaConfig"`
+	Dimensions    map[string]string `json:"dimensions,omitempty"`
+	Justification string            `json:"justification,omitempty"`
+	ContactEmail  string            `json:"contactEmail,omitempty"`
+}
+
+type quotaConfig struct {
+	PreferredValue int64 `json:"preferredValue,string"`
+}
+
+// QuotaIncreaseRequest asks Google to raise the quota of metric, like
+// `compute.googleapis.com/cpus`, in region to requested. Leave region empty
+// for metrics that aren't regional. The request is reviewed, so the new
+// limit may take a while to apply, or be turned down.
+func (c *Client) QuotaIncreaseRequest(project, region, metric string, requested int64) error {
+	if err := c.checkWritable("QuotaIncreaseRequest"); err != nil {
+		return err
+	}
+
+	if project == "" {
+		return ErrorProjectRequired
+	}
+
+	service, _, ok := strings.Cut(metric, "/")
+	if !ok {
+		return fmt.Errorf("%w: metric (%s) is not of the form service/metric", ErrorQuotaUnsupported, metric)
+	}
+
+	svc, err := c.getCloudQuotasService(project)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := c.callContext()
+	defer cancel()
+
+	parent := fmt.Sprintf("v1/projects/%s/locations/global", project)
+
+	quotaID := ""
+	token := ""
+	for quotaID == "" {
+		path := fmt.Sprintf("%s/services/%s/quotaInfos", parent, service)
+		if token != "" {
+			path = fmt.Sprintf("%s?pageToken=%s", path, url.QueryEscape(token))
+		}
+
+		infos := quotaInfoList{}
+		if err := c.retry(ctx, func() error {
+			return svc.do(ctx, http.MethodGet, path, nil, &infos)
+		}); err != nil {
+			return quotaError(err)
+		}
+
+		quotaID = quotaInfoFor(infos.QuotaInfos, metric, region)
+
+		token = infos.NextPageToken
+		if token == "" {
+			break
+		}
+	}
+
+	if quotaID == "" {
+		return fmt.Errorf("%w: metric (%s) region (%s)", ErrorQuotaUnsupported, metric, region)
+	}
+
+	pref := quotaPreference{
+		Service:       service,
+		QuotaID:       quotaID,
+		QuotaConfig:   quotaConfig{PreferredValue: requested},
+		Justification: quotaJustification,
+		ContactEmail:  c.accountGet(),
+	}
+
+	if region != "" {
+		pref.Dimensions = map[string]string{"region": region}
+	}
+
+	if err := svc.do(ctx, http.MethodPost, parent+"/quotaPreferences", pref, &quotaPreference{}); err != nil {
+		return quotaError(err)
+	}
+
+	c.auditLog("QuotaIncreaseRequest", map[string]any{
+		"project":   project,
+		"region":    region,
+		"metric":    metric,
+		"requested": requested,
+	})
+
+	return nil
+}
+
+// quotaInfoFor returns the id of the quota metric is limited by per region,
+// or per project if there is no region
+func quotaInfoFor(infos []quotaInfo, metric, region string) string {
+	for _, v := range infos {
+		if v.Metric != metric {
+			continue
+		}
+
+		regional := false
+		for _, d := range v.Dimensions {
+			if d == "region" {
+				regional = true
+			}
+		}
+
+		if regional == (region != "") {
+			return v.QuotaID
+		}
+	}
+
+	return ""
+}
+
+// RegionQuota returns the Compute Engine quota of metric, like `CPUS`, in
+// region, with how much of it the project is using
+func (c *Client) RegionQuota(project, region, metric string) (*compute.Quota, error) {
+	svc, err := c.getComputeService(project)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := c.callContext()
+	defer cancel()
+
+	var r *compute.Region
+	if err := c.retry(ctx, func() error {
+		var err error
+		r, err = svc.Regions.Get(project, region).Context(ctx).Do()
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	for _, v := range r.Quotas {
+		if v.Metric == metric {
+			return v, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: metric (%s) region (%s)", ErrorQuotaUnsupported, metric, region)
+}
+
+// ComputeQuotaMetric turns a Compute Engine quota metric, like `CPUS`, into
+// the name quota requests know it by, like `compute.googleapis.com/cpus`
+func ComputeQuotaMetric(metric string) string {
+	return fmt.Sprintf("%s/%s", Compute, strings.ToLower(metric))
+}
+
+// cpuQuotaFamilies are the machine type families whose vCPUs count against
+// a quota of their own instead of the shared CPUS one
+var cpuQuotaFamilies = map[string]bool{
+	"a2": true, "c2": true, "c2d": true, "c3": true, "c3d": true,
+	"m1": true, "m2": true, "m3": true, "n2": true, "n2d": true, "t2d": true,
+}
+
+// CPUQuotaMetric returns the Compute Engine quota metric the vCPUs of a
+// machine type family count against
+func CPUQuotaMetric(family string) string {
+	if cpuQuotaFamilies[family] {
+		return strings.ToUpper(family) + "_CPUS"
+	}
+
+	return "CPUS"
+}
+
+// quotaLimit picks the limit of a metric that applies per region, or per
+// project if there is no region
+func quotaLimit(limits []*serviceusagebeta.ConsumerQuotaLimit, region string) *serviceusagebeta.ConsumerQuotaLimit {
+	for _, v := range limits {
+		regional := strings.Contains(v.Unit, "{region}")
+		if regional == (region != "") {
+			return v
+		}
+	}
+
+	return nil
+}
+
+func quotaOverride(metric, region string, value int64) *serviceusagebeta.QuotaOverride {
+	o := &serviceusagebeta.QuotaOverride{
+		Metric:        metric,
+		OverrideValue: value,
+	}
+
+	if region != "" {
+		o.Dimensions = map[string]string{"region": region}
+	}
+
+	return o
+}
+
+func quotaError(err error) error {
+	var gerr *googleapi.Error
+	if errors.As(err, &gerr) {
+		switch gerr.Code {
+		case http.StatusForbidden:
+			return fmt.Errorf("%w: %s", ErrorQuotaNoPermission, gerr.Message)
+		case http.StatusNotFound, http.StatusBadRequest:
+			return fmt.Errorf("%w: %s", ErrorQuotaUnsupported, gerr.Message)
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,260 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcloud
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/option"
+	serviceusagebeta "google.golang.org/api/serviceusage/v1beta1"
+)
+
+func TestQuotaOverrideSet(t *testing.T) {
+	limits := `{"consumerQuotaLimits":[
+		{"name":"projects/ds-test/services/compute.googleapis.com/consumerQuotaMetrics/compute.googleapis.com%2Fcpus/limits/%2Fproject","unit":"1/{project}"},
+		{"name":"projects/ds-test/services/compute.googleapis.com/consumerQuotaMetrics/compute.googleapis.com%2Fcpus/limits/%2Fproject%2Fregion","unit":"1/{project}/{region}"}
+	]}`
+
+	tests := map[string]struct {
+		region    string
+		status    int
+		wantLimit string
+		want      *serviceusagebeta.QuotaOverride
+		err       error
+	}{
+		"regional": {
+			region:    "us-central1",
+			wantLimit: "/limits/%2Fproject%2Fregion/consumerOverrides",
+			want: &serviceusagebeta.QuotaOverride{
+				Metric:        "compute.googleapis.com/cpus",
+				OverrideValue: 48,
+				Dimensions:    map[string]string{"region": "us-central1"},
+			},
+		},
+		"project": {
+			wantLimit: "/limits/%2Fproject/consumerOverrides",
+			want: &serviceusagebeta.QuotaOverride{
+				Metric:        "compute.googleapis.com/cpus",
+				OverrideValue: 48,
+			},
+		},
+		"noPermission": {
+			region: "us-central1",
+			status: http.StatusForbidden,
+			err:    ErrorQuotaNoPermission,
+		},
+		"notSupported": {
+			region: "us-central1",
+			status: http.StatusNotFound,
+			err:    ErrorQuotaUnsupported,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var gotLimit string
+			got := &serviceusagebeta.QuotaOverride{}
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				if tc.status != 0 {
+					w.WriteHeader(tc.status)
+					fmt.Fprintf(w, `{"error":{"code":%d,"message":"nope"}}`, tc.status)
+					return
+				}
+
+				if r.Method == http.MethodGet {
+					fmt.Fprint(w, limits)
+					return
+				}
+
+				gotLimit = r.URL.Path
+				if err := json.NewDecoder(r.Body).Decode(got); err != nil {
+					t.Fatalf("could not decode override: %s", err)
+				}
+				fmt.Fprint(w, `{"name":"operations/quota"}`)
+			}))
+			defer srv.Close()
+
+			svc, err := serviceusagebeta.NewService(ctx,
+				option.WithEndpoint(srv.URL+"/"),
+				option.WithHTTPClient(srv.Client()),
+			)
+			if err != nil {
+				t.Fatalf("could not create fake service usage: %s", err)
+			}
+
+			c := NewClient(ctx, defaultUserAgent)
+			c.services.quotas = svc
+
+			err = c.QuotaOverrideSet("ds-test", tc.region, "compute.googleapis.com/cpus", 48)
+
+			if tc.err != nil {
+				if !errors.Is(err, tc.err) {
+					t.Fatalf("expected: %v, got: %v", tc.err, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected: no error, got: %s", err)
+			}
+
+			assert.True(t, strings.HasSuffix(gotLimit, tc.wantLimit), gotLimit)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestQuotaIncreaseRequest(t *testing.T) {
+	infos := `{"quotaInfos":[
+		{"quotaId":"CPUS-per-project","metric":"compute.googleapis.com/cpus","dimensions":[]},
+		{"quotaId":"CPUS-per-project-region","metric":"compute.googleapis.com/cpus","dimensions":["region"]},
+		{"quotaId":"N2-CPUS-per-project-region","metric":"compute.googleapis.com/n2_cpus","dimensions":["region"]}
+	]}`
+
+	tests := map[string]struct {
+		region string
+		metric string
+		status int
+		want   map[string]any
+		err    error
+	}{
+		"regional": {
+			region: "us-central1",
+			metric: "compute.googleapis.com/cpus",
+			want: map[string]any{
+				"service":       "compute.googleapis.com",
+				"quotaId":       "CPUS-per-project-region",
+				"quotaConfig":   map[string]any{"preferredValue": "48"},
+				"dimensions":    map[string]any{"region": "us-central1"},
+				"justification": quotaJustification,
+				"contactEmail":  "user@example.com",
+			},
+		},
+		"project": {
+			metric: "compute.googleapis.com/cpus",
+			want: map[string]any{
+				"service":       "compute.googleapis.com",
+				"quotaId":       "CPUS-per-project",
+				"quotaConfig":   map[string]any{"preferredValue": "48"},
+				"justification": quotaJustification,
+				"contactEmail":  "user@example.com",
+			},
+		},
+		"unknownMetric": {
+			region: "us-central1",
+			metric: "compute.googleapis.com/nothing",
+			err:    ErrorQuotaUnsupported,
+		},
+		"noPermission": {
+			region: "us-central1",
+			metric: "compute.googleapis.com/cpus",
+			status: http.StatusForbidden,
+			err:    ErrorQuotaNoPermission,
+		},
+		"notSupported": {
+			region: "us-central1",
+			metric: "compute.googleapis.com/cpus",
+			status: http.StatusBadRequest,
+			err:    ErrorQuotaUnsupported,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var got map[string]any
+			var gotPath string
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				if r.Method == http.MethodGet {
+					fmt.Fprint(w, infos)
+					return
+				}
+
+				if tc.status != 0 {
+					w.WriteHeader(tc.status)
+					fmt.Fprintf(w, `{"error":{"code":%d,"message":"nope"}}`, tc.status)
+					return
+				}
+
+				gotPath = r.URL.Path
+				if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+					t.Fatalf("could not decode preference: %s", err)
+				}
+				fmt.Fprint(w, `{"name":"projects/ds-test/locations/global/quotaPreferences/1"}`)
+			}))
+			defer srv.Close()
+
+			c := NewClient(ctx, defaultUserAgent)
+			c.save("account", "user@example.com")
+			c.services.cloudQuotas = &cloudQuotasService{client: srv.Client(), basePath: srv.URL + "/"}
+
+			err := c.QuotaIncreaseRequest("ds-test", tc.region, tc.metric, 48)
+
+			if tc.err != nil {
+				if !errors.Is(err, tc.err) {
+					t.Fatalf("expected: %v, got: %v", tc.err, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected: no error, got: %s", err)
+			}
+
+			assert.Equal(t, "/v1/projects/ds-test/locations/global/quotaPreferences", gotPath)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestQuotaIncreaseRequestReadOnly(t *testing.T) {
+	c := NewClient(ctx, defaultUserAgent)
+	c.ReadOnly()
+
+	err := c.QuotaIncreaseRequest("ds-test", "us-central1", "compute.googleapis.com/cpus", 48)
+	if !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("expected: %v, got: %v", ErrReadOnly, err)
+	}
+}
+
+func TestCPUQuotaMetric(t *testing.T) {
+	tests := map[string]struct {
+		family string
+		want   string
+		metric string
+	}{
+		"shared": {"e2", "CPUS", "compute.googleapis.com/cpus"},
+		"custom": {"custom", "CPUS", "compute.googleapis.com/cpus"},
+		"own":    {"n2", "N2_CPUS", "compute.googleapis.com/n2_cpus"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := CPUQuotaMetric(tc.family)
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.metric, ComputeQuotaMetric(got))
+		})
+	}
+}
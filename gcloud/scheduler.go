@@ -46,6 +46,10 @@ func (c *Client) getSchedulerService(project string) (*scheduler.CloudSchedulerC
 
 // JobSchedule creates a Cloud Scheduler Job
 func (c *Client) JobSchedule(project, region string, job schedulerpb.Job) error {
+	if err := c.checkWritable("JobSchedule"); err != nil {
+		return err
+	}
+
 	ctx := context.Background()
 	svc, err := c.getSchedulerService(project)
 	if err != nil {
@@ -62,11 +66,21 @@ func (c *Client) JobSchedule(project, region string, job schedulerpb.Job) error
 		return err
 	}
 
+	c.auditLog("JobSchedule", map[string]any{
+		"project": project,
+		"region":  region,
+		"job":     job.Name,
+	})
+
 	return nil
 }
 
 // JobDelete deletes a Cloud Scheduler Job
 func (c *Client) JobDelete(project, region, job string) error {
+	if err := c.checkWritable("JobDelete"); err != nil {
+		return err
+	}
+
 	ctx := context.Background()
 	svc, err := c.getSchedulerService(project)
 	if err != nil {
@@ -82,5 +96,11 @@ func (c *Client) JobDelete(project, region, job string) error {
 		return err
 	}
 
+	c.auditLog("JobDelete", map[string]any{
+		"project": project,
+		"region":  region,
+		"job":     job,
+	})
+
 	return nil
 }
@@ -17,6 +17,7 @@ package gcloud
 import (
 	b64 "encoding/base64"
 	"fmt"
+	"path"
 
 	"google.golang.org/api/secretmanager/v1"
 )
@@ -46,6 +47,10 @@ func (c *Client) getSecretManagerService(project string) (*secretmanager.Service
 
 // SecretCreate creates a secret and populates the lastest version with a payload.
 func (c *Client) SecretCreate(project, name, payload string) error {
+	if err := c.checkWritable("SecretCreate"); err != nil {
+		return err
+	}
+
 	svc, err := c.getSecretManagerService(project)
 	if err != nil {
 		return err
@@ -78,11 +83,50 @@ func (c *Client) SecretCreate(project, name, payload string) error {
 		return fmt.Errorf("failed to create secret versiopn: %s", err)
 	}
 
+	// Only the name is recorded, never the payload
+	c.auditLog("SecretCreate", map[string]any{
+		"project": project,
+		"name":    name,
+	})
+
 	return nil
 }
 
+// SecretList returns the secrets in a project, labeled by their short name
+// with the full resource name as the value.
+func (c *Client) SecretList(project string) (LabeledValues, error) {
+	svc, err := c.getSecretManagerService(project)
+	if err != nil {
+		return nil, err
+	}
+
+	lb := LabeledValues{}
+	parent := fmt.Sprintf("projects/%s", project)
+
+	err = svc.Projects.Secrets.List(parent).Pages(c.ctx, func(resp *secretmanager.ListSecretsResponse) error {
+		for _, v := range resp.Secrets {
+			lb = append(lb, LabeledValue{
+				Value: v.Name,
+				Label: path.Base(v.Name),
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not list secrets in project (%s): %s", project, err)
+	}
+
+	lb.Sort()
+
+	return lb, nil
+}
+
 // SecretDelete deletes a secret
 func (c *Client) SecretDelete(project, name string) error {
+	if err := c.checkWritable("SecretDelete"); err != nil {
+		return err
+	}
+
 	svc, err := c.getSecretManagerService(project)
 	if err != nil {
 		return err
@@ -93,5 +137,10 @@ func (c *Client) SecretDelete(project, name string) error {
 		return fmt.Errorf("could not delete secret (%s) in project (%s)", name, project)
 	}
 
+	c.auditLog("SecretDelete", map[string]any{
+		"project": project,
+		"name":    name,
+	})
+
 	return nil
 }
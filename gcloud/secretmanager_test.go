@@ -49,6 +49,28 @@ func TestSecretCreate(t *testing.T) {
 	}
 }
 
+func TestSecretList(t *testing.T) {
+	c := NewClient(ctx, defaultUserAgent)
+	name := "testsecretlist"
+
+	if err := c.SecretCreate(projectID, name, "secretshhhhhhhhhh"); err != nil {
+		t.Fatalf("expected: no error got: %+v", err)
+	}
+	defer c.SecretDelete(projectID, name)
+
+	got, err := c.SecretList(projectID)
+	if err != nil {
+		t.Fatalf("expected: no error got: %+v", err)
+	}
+
+	want := LabeledValue{
+		Label: name,
+		Value: fmt.Sprintf("projects/%s/secrets/%s", projectID, name),
+	}
+
+	assert.Contains(t, got, want)
+}
+
 func TestSecretsBadProject(t *testing.T) {
 	t.Parallel()
 	bad := "notavalidprojectnameanditshouldfaildasdas"
@@ -63,6 +85,14 @@ func TestSecretsBadProject(t *testing.T) {
 			},
 			err: fmt.Errorf("error activating service for polling"),
 		},
+		"SecretList": {
+			servicefunc: func() error {
+				c := NewClient(context.Background(), "testing")
+				_, err := c.SecretList(bad)
+				return err
+			},
+			err: fmt.Errorf("error activating service for polling"),
+		},
 		"SecretDelete": {
 			servicefunc: func() error {
 				c := NewClient(context.Background(), "testing")
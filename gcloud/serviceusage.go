@@ -41,6 +41,8 @@ const (
 	CloudScheduler
 	// Domains is the service name for enabling Cloud Domains
 	Domains
+	// DNS is the service name for enabling Cloud DNS
+	DNS
 	// IAM is the service name for enabling Cloud IAM
 	IAM
 	// Run is the service name for enabling Cloud Run
@@ -53,6 +55,12 @@ const (
 	Storage
 	// Vault is the service name for enabling Cloud Vault
 	Vault
+	// CloudQuotas is the service name for enabling Cloud Quotas
+	CloudQuotas
+
+	// serviceCount marks the end of the services. Keep it last, so
+	// ParseService sees every service added above it.
+	serviceCount
 )
 
 func (s Service) String() string {
@@ -73,6 +81,8 @@ func (s Service) String() string {
 		svc = "compute"
 	case Domains:
 		svc = "domains"
+	case DNS:
+		svc = "dns"
 	case IAM:
 		svc = "iam"
 	case Run:
@@ -85,12 +95,35 @@ func (s Service) String() string {
 		svc = "storage"
 	case Vault:
 		svc = "vault"
+	case CloudQuotas:
+		svc = "cloudquotas"
 	default:
 		svc = "unknown"
 	}
 	return fmt.Sprintf("%s.%s", svc, apistring)
 }
 
+// ErrorServiceUnknown occurs when a service name doesn't match any of the
+// services this package knows how to enable.
+var ErrorServiceUnknown = fmt.Errorf("unknown service")
+
+// ParseService converts a service name like `compute` or
+// `compute.googleapis.com` to a Service.
+func ParseService(name string) (Service, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if !strings.Contains(name, ".") {
+		name = fmt.Sprintf("%s.googleapis.com", name)
+	}
+
+	for s := Compute; s < serviceCount; s++ {
+		if s.String() == name {
+			return s, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%w: %s", ErrorServiceUnknown, name)
+}
+
 // ErrorServiceNotExistOrNotAllowed occurs when the user running this code doesn't have
 // permission to enable the service in the project or it's a nonexistent service name.
 var ErrorServiceNotExistOrNotAllowed = fmt.Errorf("Not found or permission denied for service")
@@ -118,7 +151,8 @@ func (c *Client) getServiceUsageService() (*serviceusage.Service, error) {
 }
 
 // ServiceEnable enable a service in the selected project so that query calls
-// to various lists will work.
+// to various lists will work. A read only client can still call it for a
+// service that is already enabled, it only refuses when the service is off.
 func (c *Client) ServiceEnable(project string, service Service) error {
 	if _, ok := c.enabledServices[service.String()]; ok {
 		return nil
@@ -139,6 +173,10 @@ func (c *Client) ServiceEnable(project string, service Service) error {
 		return nil
 	}
 
+	if err := c.checkWritable("ServiceEnable"); err != nil {
+		return err
+	}
+
 	s := fmt.Sprintf("projects/%s/services/%s", project, service)
 	op, err := svc.Services.Enable(s, &serviceusage.EnableServiceRequest{}).Do()
 	if err != nil {
@@ -153,6 +191,10 @@ func (c *Client) ServiceEnable(project string, service Service) error {
 			}
 			if enabled {
 				c.enabledServices[service.String()] = true
+				c.auditLog("ServiceEnable", map[string]any{
+					"project": project,
+					"service": service.String(),
+				})
 				return nil
 			}
 			time.Sleep(1 * time.Second)
@@ -160,6 +202,10 @@ func (c *Client) ServiceEnable(project string, service Service) error {
 	}
 
 	c.enabledServices[service.String()] = true
+	c.auditLog("ServiceEnable", map[string]any{
+		"project": project,
+		"service": service.String(),
+	})
 	return nil
 }
 
@@ -189,8 +235,36 @@ func (c *Client) ServiceIsEnabled(project string, service Service) (bool, error)
 	return false, nil
 }
 
+// ServiceEnabledCount returns the number of services enabled in a project
+func (c *Client) ServiceEnabledCount(project string) (int, error) {
+	count := 0
+
+	if project == "" {
+		return count, ErrorProjectRequired
+	}
+
+	svc, err := c.getServiceUsageService()
+	if err != nil {
+		return count, err
+	}
+
+	parent := fmt.Sprintf("projects/%s", project)
+	if err := svc.Services.List(parent).Filter("state:ENABLED").Pages(c.ctx, func(resp *serviceusage.ListServicesResponse) error {
+		count += len(resp.Services)
+		return nil
+	}); err != nil {
+		return count, fmt.Errorf("cannot list services for project (%s): %w", project, err)
+	}
+
+	return count, nil
+}
+
 // ServiceDisable disables a service in the selected project
 func (c *Client) ServiceDisable(project string, service Service) error {
+	if err := c.checkWritable("ServiceDisable"); err != nil {
+		return err
+	}
+
 	svc, err := c.getServiceUsageService()
 	if err != nil {
 		return err
@@ -203,5 +277,10 @@ func (c *Client) ServiceDisable(project string, service Service) error {
 		return fmt.Errorf("could not disable service: %s", err)
 	}
 
+	c.auditLog("ServiceDisable", map[string]any{
+		"project": project,
+		"service": service.String(),
+	})
+
 	return nil
 }
@@ -99,3 +99,30 @@ func TestServiceDisable(t *testing.T) {
 		})
 	}
 }
+
+func TestParseService(t *testing.T) {
+	tests := map[string]struct {
+		in   string
+		want Service
+		err  error
+	}{
+		"short":   {"compute", Compute, nil},
+		"full":    {"run.googleapis.com", Run, nil},
+		"spaces":  {" SecretManager ", SecretManager, nil},
+		"last":    {"cloudquotas", CloudQuotas, nil},
+		"unknown": {"notreal.googleapis.com", 0, ErrorServiceUnknown},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseService(tc.in)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("expected: %v got: %v", tc.err, err)
+			}
+
+			if !reflect.DeepEqual(tc.want, got) {
+				t.Fatalf("expected: %+v, got: %+v", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcloud
+
+import (
+	"errors"
+	"fmt"
+
+	"google.golang.org/api/compute/v1"
+)
+
+// The keys of a project config snapshot. Region, zone and network match the
+// names of the settings they make defaults for.
+const (
+	SnapshotRegion  = "region"
+	SnapshotZone    = "zone"
+	SnapshotNetwork = "instance-network"
+)
+
+// ProjectConfigSnapshot reads the parts of a project's setup worth copying to
+// a new one: its default region and zone, and its default network. It is best effort, a part
+// that can't be read is left out of the snapshot and the reasons are joined
+// into the error returned with whatever could be read.
+func (c *Client) ProjectConfigSnapshot(projectID string) (map[string]string, error) {
+	snapshot := map[string]string{}
+
+	if projectID == "" {
+		return snapshot, ErrorProjectRequired
+	}
+
+	var errs []error
+
+	svc, err := c.getComputeService(projectID)
+	if err != nil {
+		return snapshot, err
+	}
+
+	proj, err := svc.Projects.Get(projectID).Do()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("could not read region defaults of project (%s): %w", projectID, err))
+	} else {
+		for k, v := range snapshotRegionDefaults(proj) {
+			snapshot[k] = v
+		}
+	}
+
+	networks, err := c.NetworkList(projectID)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("could not read networks of project (%s): %w", projectID, err))
+	} else if network := snapshotNetwork(networks); network != "" {
+		snapshot[SnapshotNetwork] = network
+	}
+
+	return snapshot, errors.Join(errs...)
+}
+
+// snapshotRegionDefaults reads the default region and zone a project sets in
+// its common instance metadata
+func snapshotRegionDefaults(proj *compute.Project) map[string]string {
+	result := map[string]string{}
+	if proj.CommonInstanceMetadata == nil {
+		return result
+	}
+
+	for _, v := range proj.CommonInstanceMetadata.Items {
+		if v.Value == nil || *v.Value == "" {
+			continue
+		}
+
+		switch v.Key {
+		case "google-compute-default-region":
+			result[SnapshotRegion] = *v.Value
+		case "google-compute-default-zone":
+			result[SnapshotZone] = *v.Value
+		}
+	}
+
+	return result
+}
+
+// snapshotNetwork picks the network a project uses by default: the one
+// called default, or the only one there is
+func snapshotNetwork(networks LabeledValues) string {
+	for _, v := range networks {
+		if v.Value == "default" {
+			return v.Value
+		}
+	}
+
+	if len(networks) == 1 {
+		return networks[0].Value
+	}
+
+	return ""
+}
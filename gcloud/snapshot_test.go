@@ -0,0 +1,102 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcloud
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+)
+
+func TestProjectConfigSnapshot(t *testing.T) {
+	tests := map[string]struct {
+		networks string
+		want     map[string]string
+		err      bool
+	}{
+		"complete": {
+			networks: `{"items":[{"name":"prod"},{"name":"default"}]}`,
+			want: map[string]string{
+				SnapshotRegion:  "europe-west1",
+				SnapshotZone:    "europe-west1-b",
+				SnapshotNetwork: "default",
+			},
+		},
+		"onlyNetwork": {
+			networks: `{"items":[{"name":"prod"}]}`,
+			want: map[string]string{
+				SnapshotRegion:  "europe-west1",
+				SnapshotZone:    "europe-west1-b",
+				SnapshotNetwork: "prod",
+			},
+		},
+		"networksFail": {
+			want: map[string]string{
+				SnapshotRegion: "europe-west1",
+				SnapshotZone:   "europe-west1-b",
+			},
+			err: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				switch r.URL.Path {
+				case "/projects/ds-src":
+					fmt.Fprint(w, `{"name":"ds-src","commonInstanceMetadata":{"items":[
+						{"key":"google-compute-default-region","value":"europe-west1"},
+						{"key":"google-compute-default-zone","value":"europe-west1-b"},
+						{"key":"enable-oslogin","value":"TRUE"}
+					]}}`)
+				case "/projects/ds-src/global/networks":
+					if tc.networks == "" {
+						w.WriteHeader(http.StatusInternalServerError)
+						return
+					}
+					fmt.Fprint(w, tc.networks)
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer srv.Close()
+
+			opts := []option.ClientOption{
+				option.WithEndpoint(srv.URL + "/"),
+				option.WithHTTPClient(srv.Client()),
+			}
+
+			c := NewClient(ctx, defaultUserAgent)
+			var err error
+			if c.services.computeService, err = compute.NewService(ctx, opts...); err != nil {
+				t.Fatalf("could not create fake compute: %s", err)
+			}
+
+			got, err := c.ProjectConfigSnapshot("ds-src")
+			if tc.err {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -47,6 +47,10 @@ func (c *Client) getStorageService(project string) (*storage.Client, error) {
 
 // StorageBucketCreate creates a storage bucket in Cloud Storage
 func (c *Client) StorageBucketCreate(project, bucket string) error {
+	if err := c.checkWritable("StorageBucketCreate"); err != nil {
+		return err
+	}
+
 	svc, err := c.getStorageService(project)
 	if err != nil {
 		return err
@@ -56,11 +60,20 @@ func (c *Client) StorageBucketCreate(project, bucket string) error {
 		return fmt.Errorf("could not create bucket (%s): %s", bucket, err)
 	}
 
+	c.auditLog("StorageBucketCreate", map[string]any{
+		"project": project,
+		"bucket":  bucket,
+	})
+
 	return nil
 }
 
 // StorageBucketDelete deletes a storage bucket in Cloud Storage
 func (c *Client) StorageBucketDelete(project, bucket string) error {
+	if err := c.checkWritable("StorageBucketDelete"); err != nil {
+		return err
+	}
+
 	svc, err := c.getStorageService(project)
 	if err != nil {
 		return err
@@ -70,11 +83,20 @@ func (c *Client) StorageBucketDelete(project, bucket string) error {
 		return fmt.Errorf("could not delete bucket (%s): %s", bucket, err)
 	}
 
+	c.auditLog("StorageBucketDelete", map[string]any{
+		"project": project,
+		"bucket":  bucket,
+	})
+
 	return nil
 }
 
 // StorageObjectCreate creates an object in a particular bucket in Cloud Storage
 func (c *Client) StorageObjectCreate(project, bucket, path string) (string, error) {
+	if err := c.checkWritable("StorageObjectCreate"); err != nil {
+		return "", err
+	}
+
 	svc, err := c.getStorageService(project)
 	if err != nil {
 		return "", err
@@ -97,16 +119,34 @@ func (c *Client) StorageObjectCreate(project, bucket, path string) (string, erro
 
 	result := fmt.Sprintf("gs://%s/%s", obj.BucketName(), obj.ObjectName())
 
+	c.auditLog("StorageObjectCreate", map[string]any{
+		"project": project,
+		"object":  result,
+	})
+
 	return result, nil
 }
 
 // StorageObjectDelete deletes an object in a particular bucket in Cloud Storage
 func (c *Client) StorageObjectDelete(project, bucket, gspath string) error {
+	if err := c.checkWritable("StorageObjectDelete"); err != nil {
+		return err
+	}
+
 	svc, err := c.getStorageService(project)
 	if err != nil {
 		return err
 	}
 	name := filepath.Base(gspath)
 
-	return svc.Bucket(bucket).Object(name).Delete(c.ctx)
+	if err := svc.Bucket(bucket).Object(name).Delete(c.ctx); err != nil {
+		return err
+	}
+
+	c.auditLog("StorageObjectDelete", map[string]any{
+		"project": project,
+		"object":  gspath,
+	})
+
+	return nil
 }
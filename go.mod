@@ -6,17 +6,20 @@ require (
 	cloud.google.com/go/domains v0.8.0
 	cloud.google.com/go/scheduler v1.8.0
 	cloud.google.com/go/storage v1.29.0
+	github.com/BurntSushi/toml v1.3.2
 	github.com/charmbracelet/bubbles v0.15.0
 	github.com/charmbracelet/bubbletea v0.23.2
 	github.com/charmbracelet/lipgloss v0.7.1
 	github.com/go-test/deep v1.1.0
 	github.com/google/go-github v17.0.0+incompatible
+	github.com/hashicorp/hcl/v2 v2.16.2
 	github.com/hashicorp/terraform-config-inspect v0.0.0-20230308124657-d7dec65d5f3a
 	github.com/kylelemons/godebug v1.1.0
 	github.com/muesli/termenv v0.15.1
 	github.com/nyaruka/phonenumbers v1.1.6
 	github.com/otiai10/copy v1.9.0
 	github.com/stretchr/testify v1.8.2
+	github.com/zclconf/go-cty v1.13.0
 	golang.org/x/term v0.6.0
 	golang.org/x/text v0.8.0
 	google.golang.org/api v0.112.0
@@ -47,7 +50,6 @@ require (
 	github.com/googleapis/enterprise-certificate-proxy v0.2.3 // indirect
 	github.com/googleapis/gax-go/v2 v2.7.1 // indirect
 	github.com/hashicorp/hcl v1.0.0 // indirect
-	github.com/hashicorp/hcl/v2 v2.16.2 // indirect
 	github.com/jbenet/go-context v0.0.0-20150711004518-d14ea06fba99 // indirect
 	github.com/kevinburke/ssh_config v1.2.0 // indirect
 	github.com/kr/pretty v0.2.1 // indirect
@@ -68,7 +70,6 @@ require (
 	github.com/sergi/go-diff v1.3.1 // indirect
 	github.com/src-d/gcfg v1.4.0 // indirect
 	github.com/xanzy/ssh-agent v0.3.3 // indirect
-	github.com/zclconf/go-cty v1.13.0 // indirect
 	go.opencensus.io v0.24.0 // indirect
 	golang.org/x/crypto v0.7.0 // indirect
 	golang.org/x/mod v0.9.0 // indirect
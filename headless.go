@@ -0,0 +1,92 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploystack
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/deploystack/config"
+	"github.com/GoogleCloudPlatform/deploystack/tui"
+)
+
+// ErrSettingsMissing is returned by RunHeadless when settings the stack asks
+// for have no value
+var ErrSettingsMissing = fmt.Errorf("required settings are missing")
+
+// gceSettings are the settings a stack that configures a Compute Engine
+// instance can't do without
+var gceSettings = []string{
+	"instance-name",
+	"instance-machine-type",
+	"instance-image",
+	"instance-disksize",
+}
+
+// RunHeadless does what the tui does with the answers it collects, without
+// asking any questions, for pipelines that can't drive a terminal. Every
+// setting the stack asks for has to already be on the stack, from the
+// environment or a file of answers. Custom settings left out take their
+// defaults. The values are checked with the same validations the tui uses,
+// and then written to the tfvars file.
+func RunHeadless(s *config.Stack) error {
+	project := s.GetSetting("project_id")
+
+	for _, v := range s.Config.CustomSettings {
+		if s.GetSetting(v.Name) != "" || v.Default == "" {
+			continue
+		}
+
+		value := v.Default
+		if v.PrependProject {
+			value = fmt.Sprintf("%s-%s", project, value)
+		}
+		s.AddSetting(v.Name, value)
+	}
+
+	required := s.Config.RequiredSettings()
+	if s.Config.ConfigureGCEInstance {
+		required = append(required, gceSettings...)
+	}
+
+	missing := []string{}
+	for _, v := range required {
+		if s.GetSetting(v) == "" {
+			missing = append(missing, v)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s", ErrSettingsMissing, strings.Join(missing, ", "))
+	}
+
+	var errs []error
+	for _, v := range s.Config.CustomSettings {
+		if err := v.ValidateAnswer(s.GetSetting(v.Name)); err != nil {
+			errs = append(errs, fmt.Errorf("setting %s: %w", v.Name, err))
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	if err := s.TerraformFile(tui.AnswersFile); err != nil {
+		return fmt.Errorf("could not write settings to %s: %w", tui.AnswersFile, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,142 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploystack
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/deploystack/config"
+	"github.com/GoogleCloudPlatform/deploystack/tui"
+)
+
+func TestRunHeadless(t *testing.T) {
+	conf := config.Config{
+		Project: true,
+		Region:  true,
+		Zone:    true,
+		CustomSettings: config.Customs{
+			{Name: "nodes", Description: "How many nodes?", Default: "3", Validation: "integer"},
+			{Name: "public", Description: "Make it public?", Validation: "yesorno"},
+			{Name: "bucket", Description: "Bucket name", Default: "files", PrependProject: true},
+		},
+	}
+
+	tests := map[string]struct {
+		config   config.Config
+		settings map[string]string
+		want     map[string]string
+		err      error
+		contains []string
+	}{
+		"complete": {
+			config: conf,
+			settings: map[string]string{
+				"project_id": "ds-one",
+				"region":     "us-central1",
+				"zone":       "us-central1-a",
+				"public":     "no",
+			},
+			want: map[string]string{
+				"project_id": "ds-one",
+				"nodes":      "3",
+				"public":     "no",
+				"bucket":     "ds-one-files",
+			},
+		},
+		"incomplete": {
+			config: conf,
+			settings: map[string]string{
+				"project_id": "ds-one",
+			},
+			err:      ErrSettingsMissing,
+			contains: []string{"region", "zone", "public"},
+		},
+		"invalid": {
+			config: conf,
+			settings: map[string]string{
+				"project_id": "ds-one",
+				"region":     "us-central1",
+				"zone":       "us-central1-a",
+				"nodes":      "lots",
+				"public":     "perhaps",
+			},
+			contains: []string{"nodes", "public"},
+		},
+		"gce": {
+			config: config.Config{ConfigureGCEInstance: true},
+			settings: map[string]string{
+				"instance-name": "ds-instance",
+			},
+			err:      ErrSettingsMissing,
+			contains: []string{"instance-machine-type", "instance-image", "instance-disksize"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := config.NewStack()
+			s.Config = tc.config
+			s.Config.Setwd(t.TempDir())
+			for k, v := range tc.settings {
+				s.AddSetting(k, v)
+			}
+
+			err := RunHeadless(&s)
+
+			if len(tc.contains) > 0 {
+				if err == nil {
+					t.Fatalf("expected: error got: nil")
+				}
+				if tc.err != nil && !errors.Is(err, tc.err) {
+					t.Fatalf("expected: %v got: %v", tc.err, err)
+				}
+				for _, v := range tc.contains {
+					if !strings.Contains(err.Error(), v) {
+						t.Fatalf("expected: error to mention %s got: %v", v, err)
+					}
+				}
+				if _, err := os.Stat(s.OutputPath(tui.AnswersFile)); !os.IsNotExist(err) {
+					t.Fatalf("expected: no %s got: %v", tui.AnswersFile, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected: no error got: %v", err)
+			}
+
+			for k, v := range tc.want {
+				if got := s.GetSetting(k); got != v {
+					t.Fatalf("%s - expected: %s got: %s", k, v, got)
+				}
+			}
+
+			answers, err := config.ReadTFVars(filepath.Join(s.Config.Getwd(), tui.AnswersFile))
+			if err != nil {
+				t.Fatalf("could not read %s: %v", tui.AnswersFile, err)
+			}
+
+			for k, v := range tc.want {
+				if got := answers.Find(k); got == nil || got.Value != v {
+					t.Fatalf("%s - expected in %s: %s got: %+v", k, tui.AnswersFile, v, got)
+				}
+			}
+		})
+	}
+}
@@ -15,16 +15,27 @@
 package main
 
 import (
+	"flag"
+
 	"github.com/GoogleCloudPlatform/deploystack"
 	"github.com/GoogleCloudPlatform/deploystack/tui"
 )
 
 func main() {
+	from := flag.String("from", "", "The key of the step to start at, skipping the ones before it")
+	flag.Parse()
 
 	s, err := deploystack.Init(".")
 	if err != nil {
 		tui.Fatal(err)
 	}
 
+	if *from != "" {
+		if err := tui.RunFrom(s, tui.GetMock(1), *from); err != nil {
+			tui.Fatal(err)
+		}
+		return
+	}
+
 	tui.Run(s, true)
 }
@@ -16,10 +16,12 @@ package tui
 
 import (
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
 
 	"github.com/GoogleCloudPlatform/deploystack/config"
+	"github.com/GoogleCloudPlatform/deploystack/gcloud"
 	"github.com/charmbracelet/bubbles/table"
 	"github.com/charmbracelet/lipgloss"
 	"golang.org/x/text/cases"
@@ -30,6 +32,23 @@ type component interface {
 	render() string
 }
 
+// countingWriter tracks the bytes written to w, and holds on to the first
+// error so callers can write freely and check once at the end.
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) WriteString(s string) {
+	if c.err != nil {
+		return
+	}
+	n, err := io.WriteString(c.w, s)
+	c.n += int64(n)
+	c.err = err
+}
+
 type productList []struct {
 	item    string
 	product string
@@ -78,7 +97,14 @@ func (d *description) parse() (productList, []string) {
 }
 
 func (d description) render() string {
-	doc := strings.Builder{}
+	sb := strings.Builder{}
+	d.WriteTo(&sb)
+	return sb.String()
+}
+
+// WriteTo streams the rendered description to w
+func (d description) WriteTo(w io.Writer) (int64, error) {
+	doc := &countingWriter{w: w}
 
 	list, additionalText := d.parse()
 
@@ -133,9 +159,12 @@ func (d description) render() string {
 		doc.WriteString("\n\n")
 	}
 
-	return doc.String()
+	return doc.n, doc.err
 }
 
+const credentialsMsg = "Your Google Cloud credentials have expired or are invalid. \n" +
+	"Run 'gcloud auth login' and 'gcloud auth application-default login' then try again."
+
 type errorAlert struct {
 	err errMsg
 }
@@ -150,8 +179,13 @@ func (e errorAlert) Render() string {
 	sb.WriteString("\n")
 	sb.WriteString(boldAlert.Render("There was an error!"))
 	sb.WriteString("\n")
-	if e.err.usermsg != "" {
-		sb.WriteString(e.err.usermsg)
+	usermsg := e.err.usermsg
+	if gcloud.IsAuthError(e.err.err) {
+		usermsg = credentialsMsg
+	}
+
+	if usermsg != "" {
+		sb.WriteString(usermsg)
 		sb.WriteString("\n")
 	}
 	sb.WriteString("\n")
@@ -240,7 +274,14 @@ func newSettingsTable(s *config.Stack) settingsTable {
 }
 
 func (s settingsTable) render() string {
-	doc := strings.Builder{}
+	sb := strings.Builder{}
+	s.WriteTo(&sb)
+	return sb.String()
+}
+
+// WriteTo streams the rendered settings table to w
+func (s settingsTable) WriteTo(w io.Writer) (int64, error) {
+	doc := &countingWriter{w: w}
 	wSetting := 0
 	wValue := 0
 
@@ -329,6 +370,135 @@ func (s settingsTable) render() string {
 	doc.WriteString(t.View())
 	doc.WriteString("\n")
 
+	return doc.n, doc.err
+}
+
+const (
+	serviceStatusEnabled   = "enabled"
+	serviceStatusAlreadyOn = "already on"
+	serviceStatusFailed    = "failed"
+)
+
+type serviceResult struct {
+	service string
+	status  string
+	err     error
+}
+
+type serviceResults struct {
+	queue *Queue
+}
+
+func newServiceResults(q *Queue) serviceResults {
+	return serviceResults{queue: q}
+}
+
+func (s serviceResults) render() string {
+	results, ok := s.queue.Get("serviceResults").([]serviceResult)
+	if !ok || len(results) == 0 {
+		return ""
+	}
+
+	longest := 0
+	for _, v := range results {
+		if len(v.service) > longest {
+			longest = len(v.service)
+		}
+	}
+
+	doc := strings.Builder{}
+	for _, v := range results {
+		status := strong.Render(v.status)
+		if v.status == serviceStatusFailed {
+			status = boldAlert.Render(v.status)
+		}
+
+		doc.WriteString(fmt.Sprintf("%-*s  %s\n", longest, v.service, status))
+		if v.err != nil {
+			doc.WriteString(fmt.Sprintf("  %s\n", v.err))
+		}
+	}
+
+	return doc.String()
+}
+
+// settingsDiff shows which settings differ from a previous run, if the queue
+// was given one
+type settingsDiff struct {
+	queue *Queue
+}
+
+func newSettingsDiff(q *Queue) settingsDiff {
+	return settingsDiff{queue: q}
+}
+
+func (d settingsDiff) render() string {
+	previous, ok := d.queue.Get("previousSettings").(config.Settings)
+	if !ok {
+		return ""
+	}
+
+	doc := strings.Builder{}
+	doc.WriteString(titleStyle.Render("Changes since the last run"))
+	doc.WriteString("\n")
+
+	changes := d.queue.stack.Diff(previous)
+	if len(changes) == 0 {
+		doc.WriteString(normal.Render("No settings changed"))
+		doc.WriteString("\n\n")
+		return doc.String()
+	}
+
+	for _, v := range changes {
+		before := strings.Trim(v.Old, "\"")
+		after := strings.Trim(v.New, "\"")
+
+		switch v.Kind {
+		case config.SettingAdded:
+			doc.WriteString(fmt.Sprintf("+ %s %s\n", v.Name, strong.Render(after)))
+		case config.SettingChanged:
+			doc.WriteString(fmt.Sprintf("~ %s %s -> %s\n", v.Name, before, strong.Render(after)))
+		case config.SettingRemoved:
+			doc.WriteString(fmt.Sprintf("- %s %s\n", v.Name, boldAlert.Render(before)))
+		}
+	}
+	doc.WriteString("\n")
+
+	return doc.String()
+}
+
+type projectSummary struct {
+	queue *Queue
+}
+
+func newProjectSummary(q *Queue) projectSummary {
+	return projectSummary{queue: q}
+}
+
+func (p projectSummary) render() string {
+	d, ok := p.queue.Get("projectDescription").(gcloud.ProjectDescription)
+	if !ok {
+		return ""
+	}
+
+	billing := boldAlert.Render("disabled")
+	if d.BillingEnabled {
+		billing = strong.Render(fmt.Sprintf("enabled (%s)", d.BillingAccount))
+	}
+
+	parent := "none"
+	if d.ParentID != "" {
+		parent = fmt.Sprintf("%s/%s", d.ParentType, d.ParentID)
+	}
+
+	doc := strings.Builder{}
+	doc.WriteString(titleStyle.Render(fmt.Sprintf("Project %s", d.ID)))
+	doc.WriteString("\n")
+	doc.WriteString(fmt.Sprintf("%-14s %s\n", "Number", strong.Render(d.Number)))
+	doc.WriteString(fmt.Sprintf("%-14s %s\n", "Parent", strong.Render(parent)))
+	doc.WriteString(fmt.Sprintf("%-14s %s\n", "Billing", billing))
+	doc.WriteString(fmt.Sprintf("%-14s %s\n", "Enabled APIs", strong.Render(strconv.Itoa(d.EnabledServices))))
+
 	return doc.String()
 }
 
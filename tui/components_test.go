@@ -15,13 +15,17 @@
 package tui
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/GoogleCloudPlatform/deploystack/config"
+	"github.com/GoogleCloudPlatform/deploystack/gcloud"
 	"github.com/kylelemons/godebug/diff"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestDrawProgress(t *testing.T) {
@@ -68,6 +72,56 @@ func TestDrawProgress(t *testing.T) {
 
 }
 
+func TestSetTheme(t *testing.T) {
+	t.Cleanup(func() {
+		setColors(nil)
+		buildStyles()
+	})
+
+	tests := map[string]struct {
+		theme                Theme
+		text, complete, pend string
+	}{
+		"light": {
+			theme:    ThemeLight,
+			text:     "black",
+			complete: "cyan",
+			pend:     "dark grey",
+		},
+		"dark": {
+			theme:    ThemeDark,
+			text:     "light grey",
+			complete: "bright cyan",
+			pend:     "white",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			SetTheme(tc.theme)
+
+			got := drawProgress(50)
+			got = strings.ReplaceAll(got, "\x1b[1;m", "")
+			got = strings.ReplaceAll(got, clear, "")
+
+			want := fmt.Sprintf("%s   Progress %s%s%s%s",
+				textColors.code(tc.text),
+				textColors.code(tc.complete),
+				strings.Repeat("█", 44),
+				textColors.code(tc.pend),
+				strings.Repeat("░", 44),
+			)
+
+			if want != got {
+				t.Fatalf("want \n%q\n got\n%q\n", want, got)
+			}
+
+			// The table and borders are drawn by lipgloss, by color number
+			assert.Equal(t, fmt.Sprint(textColors.color(tc.text).id), lgbasicText.Dark)
+		})
+	}
+}
+
 func TestProductListLongest(t *testing.T) {
 	tests := map[string]struct {
 		configPath  string
@@ -189,6 +243,14 @@ func TestErrorAlertRender(t *testing.T) {
 			outputFile: "error_alert_user_message.txt",
 		},
 
+		"AuthError": {
+			errMsg: errMsg{
+				err:     fmt.Errorf(`oauth2: cannot fetch token: 400 Bad Request Response: {"error":"invalid_grant"}`),
+				usermsg: "It was probably something you said",
+			},
+			outputFile: "error_alert_auth_error.txt",
+		},
+
 		"TargetQuit": {
 			errMsg: errMsg{
 				err:    fmt.Errorf("Everything broke"),
@@ -223,6 +285,14 @@ func TestErrorAlertRender(t *testing.T) {
 	}
 }
 
+func TestErrorAlertRenderAuthExpired(t *testing.T) {
+	err := fmt.Errorf("could not get regions: %w", fmt.Errorf("%w: %s", gcloud.ErrorAuthExpired, "googleapi: Error 401"))
+	got := errorAlert{errMsg{err: err, usermsg: "It was probably something you said"}}.Render()
+
+	assert.Contains(t, got, credentialsMsg)
+	assert.NotContains(t, got, "It was probably something you said")
+}
+
 func TestSettingsTableRender(t *testing.T) {
 	tests := map[string]struct {
 		settings   map[string]string
@@ -280,3 +350,111 @@ func TestSettingsTableRender(t *testing.T) {
 		})
 	}
 }
+
+func TestProjectSummaryRender(t *testing.T) {
+	tests := map[string]struct {
+		desc     interface{}
+		contains []string
+	}{
+		"none": {
+			desc: nil,
+		},
+		"billingEnabled": {
+			desc: gcloud.ProjectDescription{
+				ID:              "ds-test-project",
+				Number:          "123456789",
+				ParentType:      "folder",
+				ParentID:        "42",
+				BillingEnabled:  true,
+				BillingAccount:  "000000-000000-000000",
+				EnabledServices: 12,
+			},
+			contains: []string{"ds-test-project", "123456789", "folder/42", "enabled (000000-000000-000000)", "12"},
+		},
+		"billingDisabled": {
+			desc: gcloud.ProjectDescription{
+				ID:     "ds-test-project",
+				Number: "123456789",
+			},
+			contains: []string{"none", "disabled"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+			if tc.desc != nil {
+				q.Save("projectDescription", tc.desc)
+			}
+
+			got := newProjectSummary(&q).render()
+
+			if tc.contains == nil {
+				assert.Empty(t, got)
+			}
+			for _, v := range tc.contains {
+				assert.Contains(t, got, v)
+			}
+		})
+	}
+}
+
+type errWriter struct{}
+
+func (errWriter) Write(p []byte) (int, error) {
+	return 0, fmt.Errorf("write failed")
+}
+
+func TestSettingsDiffRender(t *testing.T) {
+	q := getTestQueue(appTitle, "test")
+	assert.Empty(t, newSettingsDiff(&q).render())
+
+	q.stack.AddSetting("region", "us-east1")
+	q.stack.AddSetting("nodes", "3")
+
+	previous := config.Settings{}
+	previous.Add("region", "us-central1")
+	previous.Add("bucket", "files")
+	q.SetPreviousSettings(previous)
+
+	got := newSettingsDiff(&q).render()
+
+	for _, v := range []string{"+ nodes", "~ region us-central1 -> ", "- bucket"} {
+		assert.Contains(t, got, v)
+	}
+}
+
+func TestComponentsWriteTo(t *testing.T) {
+	stack := config.NewStack()
+	config, err := config.NewConfigYAML([]byte(readTestFile(filepath.Join(testFilesDir, "tui/testdata", "config_basic.yaml"))))
+	if err != nil {
+		t.Fatalf("could not read in config %s:", err)
+	}
+	stack.Config = config
+	stack.AddSetting("testkey", "testvalue")
+
+	tests := map[string]struct {
+		component interface {
+			component
+			io.WriterTo
+		}
+	}{
+		"description":   {component: newDescription(&stack)},
+		"settingsTable": {component: newSettingsTable(&stack)},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			want := tc.component.render()
+
+			buf := bytes.Buffer{}
+			n, err := tc.component.WriteTo(&buf)
+			assert.Nil(t, err)
+			assert.Equal(t, int64(len(want)), n)
+			assert.Equal(t, want, buf.String())
+
+			_, err = tc.component.WriteTo(errWriter{})
+			assert.NotNil(t, err)
+		})
+	}
+}
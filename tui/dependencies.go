@@ -0,0 +1,116 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tui
+
+import "strings"
+
+// settingDependents maps a setting to the settings that were picked based on
+// it, and so no longer hold once it changes. Going back and picking another
+// region, say, leaves a zone from the old region behind otherwise.
+var settingDependents = map[string][]string{
+	"region":                       {"zone", "instance-subnet", "instance-disk-replica-zones"},
+	"zone":                         {"instance-machine-type-family", "instance-machine-type", "instance-accelerator-type", "instance-node-type", "instance-disk-replica-zones", "instance-disktype"},
+	"instance-network":             {"instance-subnet"},
+	"instance-subnet":              {"instance-subnet-pods-range", "instance-subnet-services-range"},
+	"instance-machine-type-family": {"instance-machine-type"},
+	"instance-machine-type":        {"instance-image-architecture"},
+	"instance-node-type":           {"instance-node-affinity", "instance-node-affinity-values"},
+	"instance-image-project":       {"instance-image-family", "instance-image"},
+	"instance-image-family":        {"instance-image"},
+}
+
+// overrideSteps maps a setting to the steps that only help pick it, which
+// have nothing left to ask once the setting is overridden
+var overrideSteps = map[string][]string{
+	"instance-machine-type": {
+		"instance-machine-type-search",
+		"instance-min-cpus",
+		"instance-min-memory",
+		"instance-machine-type-family",
+		"instance-custom-cpus",
+		"instance-custom-memory",
+	},
+	"instance-image": {
+		"instance-image-project",
+		"instance-image-search",
+		"instance-image-family",
+		"instance-image-architecture",
+	},
+}
+
+// finishingSteps wrap up the settings of a flow, so they stay in the queue
+// when their own setting is overridden, and answer themselves with it
+var finishingSteps = map[string]bool{
+	"instance-webserver": true,
+}
+
+// overridden reports whether the step with key has nothing to ask, because
+// its setting, or the setting it helps pick, was overridden
+func (q *Queue) overridden(key string) bool {
+	if finishingSteps[key] {
+		return false
+	}
+
+	if q.stack.IsOverridden(key) {
+		return true
+	}
+
+	// The steps that create a project are named after it
+	for _, suffix := range []string{projNewSuffix, parentNewSuffix, folderNewSuffix, billNewSuffix} {
+		if name, ok := strings.CutSuffix(key, suffix); ok && q.stack.IsOverridden(name) {
+			return true
+		}
+	}
+
+	for setting, steps := range overrideSteps {
+		if !q.stack.IsOverridden(setting) {
+			continue
+		}
+		for _, v := range steps {
+			if v == key {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// clearDependents removes every setting that depends on key, directly or
+// through another setting, from the stack
+func (q *Queue) clearDependents(key string) {
+	seen := map[string]bool{key: true}
+	pending := settingDependents[key]
+
+	for len(pending) > 0 {
+		dependent := pending[0]
+		pending = pending[1:]
+		if seen[dependent] {
+			continue
+		}
+		seen[dependent] = true
+
+		// An override holds whatever it was picked with
+		if q.stack.IsOverridden(dependent) {
+			continue
+		}
+
+		// Steps that keep their answer out of the settings, like the
+		// image architecture, hold it in the store
+		q.stack.DeleteSetting(dependent)
+		delete(q.store, dependent)
+		pending = append(pending, settingDependents[dependent]...)
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tui
+
+// EventType is the kind of progress an Event reports
+type EventType string
+
+const (
+	// EventStepEntered is sent when the flow moves to a step
+	EventStepEntered EventType = "StepEntered"
+	// EventSettingSet is sent when a step stores the answer it collected
+	EventSettingSet EventType = "SettingSet"
+	// EventErrorOccurred is sent when a step runs into an error
+	EventErrorOccurred EventType = "ErrorOccurred"
+	// EventCompleted is sent when the flow moves past its last step
+	EventCompleted EventType = "Completed"
+)
+
+// eventBuffer is how many events the channel returned by Events holds. Once
+// it is full, the oldest events are dropped to make room.
+const eventBuffer = 64
+
+// Event is a machine readable record of the flow's progress, for driving a
+// UI other than the terminal from the same logic
+type Event struct {
+	Type  EventType `json:"type"`
+	Key   string    `json:"key,omitempty"`
+	Value string    `json:"value,omitempty"`
+	Err   error     `json:"-"`
+}
+
+// Events returns the channel the queue sends an Event on as the flow
+// progresses. Every call returns the same channel. The flow never waits on
+// receivers: a receiver that falls more than the buffer behind misses the
+// oldest events. The channel is closed once the flow completes or quits.
+func (q *Queue) Events() <-chan Event {
+	if q.events == nil {
+		q.events = make(chan Event, eventBuffer)
+	}
+
+	return q.events
+}
+
+// emit sends e to whoever asked for Events, dropping the oldest event if the
+// buffer is full. Without a receiver, or once the channel is closed, it does
+// nothing.
+func (q *Queue) emit(e Event) {
+	if q.events == nil || q.eventsClosed {
+		return
+	}
+
+	for {
+		select {
+		case q.events <- e:
+			return
+		default:
+		}
+
+		select {
+		case <-q.events:
+		default:
+		}
+	}
+}
+
+// closeEvents closes the channel returned by Events, once the flow is over,
+// so receivers ranging over it stop
+func (q *Queue) closeEvents() {
+	if q.events == nil || q.eventsClosed {
+		return
+	}
+
+	close(q.events)
+	q.eventsClosed = true
+}
+
+// setSetting stores the answer a step collected on the stack, letting
+// receivers of Events know. Changing an answer clears the settings that
+// depend on it.
+func (q *Queue) setSetting(key, value string) {
+	if old := q.stack.Settings.Find(key); old != nil && old.Value != value {
+		q.clearDependents(key)
+	}
+
+	q.stack.AddSetting(key, value)
+	q.emit(Event{Type: EventSettingSet, Key: key, Value: value})
+}
+
+// reportError lets receivers of Events know that the step with key ran into
+// err
+func (q *Queue) reportError(key string, err error) {
+	q.emit(Event{Type: EventErrorOccurred, Key: key, Err: err})
+}
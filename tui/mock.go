@@ -40,9 +40,29 @@ func GetMock(delay int) mock {
 //revive:enable:unexported-return
 
 type mock struct {
-	d        int
-	forceErr bool
-	cache    map[string]interface{}
+	d                int
+	forceErr         bool
+	cache            map[string]interface{}
+	disabledServices map[string]bool
+	failedServices   map[string]bool
+	orgPolicy        map[string][]string
+	projectLabels    map[string]map[string]string
+	noRegions        bool
+	configDefaults   gcloud.ConfigDefaults
+	readOnly         bool
+	quotaErr         error
+	pricingErr       error
+}
+
+func (m mock) IsReadOnly() bool {
+	return m.readOnly
+}
+
+func (m mock) checkWritable(action string) error {
+	if m.readOnly {
+		return fmt.Errorf("%w: %s", gcloud.ErrReadOnly, action)
+	}
+	return nil
 }
 
 func (m mock) delay() {
@@ -57,14 +77,38 @@ func (m mock) ProjectIDGet() (string, error) {
 	return "ds-tester-singlevm", nil
 }
 
+func (m mock) ConfigDefaultsGet() (gcloud.ConfigDefaults, error) {
+	return m.configDefaults, nil
+}
+
+func (m mock) ProjectConfigSnapshot(projectID string) (map[string]string, error) {
+	m.delay()
+	if m.forceErr {
+		return map[string]string{}, errForced
+	}
+
+	return map[string]string{
+		gcloud.SnapshotRegion:  "us-east1",
+		gcloud.SnapshotZone:    "us-east1-c",
+		gcloud.SnapshotNetwork: "default",
+	}, nil
+}
+
 func (m mock) ProjectIDSet(id string) error {
 	m.delay()
+	if err := m.checkWritable("ProjectIDSet"); err != nil {
+		return err
+	}
 	if m.forceErr {
 		return errForced
 	}
 	return nil
 }
 
+func (m mock) ProjectListNotify(notify func(attempt int, wait time.Duration)) ([]gcloud.ProjectWithBilling, error) {
+	return m.ProjectList()
+}
+
 func (m mock) ProjectList() ([]gcloud.ProjectWithBilling, error) {
 	m.delay()
 	if m.forceErr {
@@ -171,6 +215,9 @@ func (m mock) RegionList(project, product string) ([]string, error) {
 	if m.forceErr {
 		return nil, errForced
 	}
+	if m.noRegions {
+		return []string{}, nil
+	}
 	r := []string{
 		"asia-east1",
 		"asia-east2",
@@ -212,6 +259,148 @@ func (m mock) RegionList(project, product string) ([]string, error) {
 	return r, nil
 }
 
+func (m mock) NetworkList(project string) (gcloud.LabeledValues, error) {
+	m.delay()
+	if m.forceErr {
+		return nil, errForced
+	}
+	r := gcloud.LabeledValues{
+		{Label: "default", Value: "default"},
+		{Label: "prod", Value: "prod"},
+	}
+	return r, nil
+}
+
+// mockSubnets are the subnets the mock knows about, as network, region, name
+// and range
+var mockSubnets = [][]string{
+	{"default", "us-central1", "default", "10.128.0.0/20"},
+	{"default", "us-east1", "default", "10.142.0.0/20"},
+	{"prod", "us-central1", "web", "10.0.0.0/24"},
+	{"prod", "us-central1", "db", "10.0.1.0/24"},
+	{"prod", "europe-west1", "web-eu", "10.2.0.0/24"},
+}
+
+func (m mock) NetworkSubnetworkList(project, region, network string) (gcloud.LabeledValues, error) {
+	m.delay()
+	if m.forceErr {
+		return nil, errForced
+	}
+	r := gcloud.LabeledValues{}
+	for _, v := range mockSubnets {
+		if v[1] != region || (network != "" && v[0] != network) {
+			continue
+		}
+		r = append(r, gcloud.LabeledValue{Label: fmt.Sprintf("%s (%s)", v[2], v[3]), Value: v[2]})
+	}
+	r.Sort()
+	return r, nil
+}
+
+// mockSecondaryRanges are the secondary ranges of the mock subnets:
+// subnet, range name, CIDR
+var mockSecondaryRanges = [][]string{
+	{"db", "services", "10.4.0.0/20"},
+	{"db", "pods", "10.8.0.0/14"},
+}
+
+func (m mock) SubnetSecondaryRanges(project, region, subnet string) (gcloud.LabeledValues, error) {
+	m.delay()
+	if m.forceErr {
+		return nil, errForced
+	}
+	r := gcloud.LabeledValues{}
+	for _, v := range mockSecondaryRanges {
+		if v[0] != subnet {
+			continue
+		}
+		r = append(r, gcloud.LabeledValue{Label: fmt.Sprintf("%s (%s)", v[1], v[2]), Value: v[1]})
+	}
+	r.Sort()
+	return r, nil
+}
+
+func (m mock) NetworkRegions(project, network string) ([]string, error) {
+	m.delay()
+	if m.forceErr {
+		return nil, errForced
+	}
+	found := map[string]bool{}
+	r := []string{}
+	for _, v := range mockSubnets {
+		if v[0] == network && !found[v[1]] {
+			found[v[1]] = true
+			r = append(r, v[1])
+		}
+	}
+	sort.Strings(r)
+	return r, nil
+}
+
+func (m mock) DiskTypeList(project, zone string) (gcloud.LabeledValues, error) {
+	m.delay()
+	if m.forceErr {
+		return nil, errForced
+	}
+	r := gcloud.LabeledValues{
+		{Label: "Standard", Value: "pd-standard"},
+		{Label: "Balanced", Value: "pd-balanced"},
+		{Label: "SSD", Value: "pd-ssd"},
+		{Label: "Extreme", Value: "pd-extreme"},
+		{Label: "Hyperdisk Balanced", Value: "hyperdisk-balanced"},
+	}
+	return r, nil
+}
+
+func (m mock) AcceleratorTypeList(project, zone string) (gcloud.LabeledValues, error) {
+	m.delay()
+	if m.forceErr {
+		return nil, errForced
+	}
+	if zone == "" {
+		return nil, gcloud.ErrorZoneRequired
+	}
+	if !strings.HasPrefix(zone, "us-central1") {
+		return gcloud.LabeledValues{}, nil
+	}
+	r := gcloud.LabeledValues{
+		{Label: "NVIDIA L4", Value: "nvidia-l4"},
+		{Label: "NVIDIA T4", Value: "nvidia-tesla-t4"},
+	}
+	return r, nil
+}
+
+func (m mock) AcceleratorTypeRegionList(project, region string) (gcloud.LabeledValues, error) {
+	m.delay()
+	if m.forceErr {
+		return nil, errForced
+	}
+	if region == "" {
+		return nil, gcloud.ErrorRegionRequired
+	}
+	if region != "us-central1" {
+		return gcloud.LabeledValues{}, nil
+	}
+	r := gcloud.LabeledValues{
+		{Label: "NVIDIA L4", Value: "nvidia-l4"},
+		{Label: "NVIDIA T4", Value: "nvidia-tesla-t4"},
+	}
+	return r, nil
+}
+
+func (m mock) ZonesWithAccelerator(project, acceleratorType string) ([]string, error) {
+	m.delay()
+	if m.forceErr {
+		return nil, errForced
+	}
+
+	if acceleratorType != "nvidia-tesla-t4" {
+		return []string{}, nil
+	}
+
+	return []string{"asia-east1-a", "us-central1-a", "us-central1-b"}, nil
+}
+
 func (m mock) ZoneList(project, region string) ([]string, error) {
 	m.delay()
 	if m.forceErr {
@@ -351,8 +540,64 @@ func (m mock) ProjectParentGet(project string) (*cloudresourcemanager.ResourceId
 	return r, nil
 }
 
-func (m mock) ProjectCreate(project, parent, parentType string) error {
+func (m mock) OrganizationList() (gcloud.LabeledValues, error) {
 	m.delay()
+	if m.forceErr {
+		return nil, errForced
+	}
+	return gcloud.LabeledValues{
+		{Value: "298490623289", Label: "example.com"},
+		{Value: "123456789012", Label: "other.example.com"},
+	}, nil
+}
+
+func (m mock) FolderList(parent string) (gcloud.LabeledValues, error) {
+	m.delay()
+	if m.forceErr {
+		return nil, errForced
+	}
+	if parent != "organizations/298490623289" {
+		return gcloud.LabeledValues{}, nil
+	}
+	return gcloud.LabeledValues{
+		{Value: "111", Label: "Development"},
+		{Value: "222", Label: "Production"},
+	}, nil
+}
+
+func (m mock) OrgPolicyConstraints(project string) (map[string][]string, error) {
+	m.delay()
+	if m.forceErr {
+		return nil, errForced
+	}
+	if m.orgPolicy == nil {
+		return map[string][]string{}, nil
+	}
+	return m.orgPolicy, nil
+}
+
+func (m mock) ProjectDescribe(projectID string) (gcloud.ProjectDescription, error) {
+	m.delay()
+	if m.forceErr {
+		return gcloud.ProjectDescription{}, errForced
+	}
+
+	return gcloud.ProjectDescription{
+		ID:              projectID,
+		Number:          "123456789",
+		ParentType:      "organization",
+		ParentID:        "1234567890",
+		BillingEnabled:  true,
+		BillingAccount:  "000000-000000-000000",
+		EnabledServices: 12,
+	}, nil
+}
+
+func (m mock) ProjectCreate(project, parent, parentType, billingAccount string) error {
+	m.delay()
+	if err := m.checkWritable("ProjectCreate"); err != nil {
+		return err
+	}
 	if m.forceErr {
 		return errForced
 	}
@@ -382,6 +627,18 @@ func (m mock) ProjectCreate(project, parent, parentType string) error {
 	return nil
 }
 
+func (m mock) ProjectCreateWithLabels(project, parent, parentType, billingAccount string, labels map[string]string) error {
+	if err := m.ProjectCreate(project, parent, parentType, billingAccount); err != nil {
+		return err
+	}
+
+	if m.projectLabels != nil {
+		m.projectLabels[project] = labels
+	}
+
+	return nil
+}
+
 func (m mock) DomainIsAvailable(project, domain string) (*domainspb.RegisterParameters, error) {
 	m.delay()
 	if m.forceErr {
@@ -394,7 +651,7 @@ func (m mock) DomainIsAvailable(project, domain string) (*domainspb.RegisterPara
 		return r, nil
 	}
 
-	if domain == "example2.com" {
+	if domain == "example2.com" || domain == "example6.com" {
 		r.Availability = domainspb.RegisterParameters_UNAVAILABLE
 		return r, nil
 	}
@@ -414,7 +671,7 @@ func (m mock) DomainIsVerified(project, domain string) (bool, error) {
 	if m.forceErr {
 		return false, errForced
 	}
-	if domain == "example2.com" {
+	if domain == "example2.com" || domain == "example6.com" {
 		return false, nil
 	}
 	if domain == "example.com" {
@@ -424,8 +681,20 @@ func (m mock) DomainIsVerified(project, domain string) (bool, error) {
 	return true, nil
 }
 
+func (m mock) DomainVerified(domain string) (bool, error) {
+	m.delay()
+	if m.forceErr {
+		return false, errForced
+	}
+
+	return domain == "example6.com", nil
+}
+
 func (m mock) DomainRegister(project string, domaininfo *domainspb.RegisterParameters, contact gcloud.ContactData) error {
 	m.delay()
+	if err := m.checkWritable("DomainRegister"); err != nil {
+		return err
+	}
 	if m.forceErr {
 		return errForced
 	}
@@ -450,6 +719,20 @@ func (m mock) ImageLatestGet(project, imageproject, imagefamily string) (string,
 	return "debian-cloud/debian-11-bullseye-v20230202", nil
 }
 
+func (m mock) MachineTypeMatch(project, zone string, minCPU int64, minMemMB int64) (string, error) {
+	types, err := m.MachineTypeList(project, zone)
+	if err != nil {
+		return "", err
+	}
+	return gcloud.MachineTypeMatchList(types, minCPU, minMemMB)
+}
+
+func (m mock) InvalidateMachineTypes(project, zone string) {}
+
+func (m mock) MachineTypePricing(region string) error {
+	return m.pricingErr
+}
+
 func (m mock) MachineTypeList(project, zone string) (*compute.MachineTypeList, error) {
 	m.delay()
 	if m.forceErr {
@@ -809,6 +1092,22 @@ func (m mock) ProjectNumberGet(id string) (string, error) {
 
 func (m mock) ImageFamilyList(imgs *compute.ImageList) gcloud.LabeledValues {
 	m.delay()
+	lb := mockImageFamilies(imgs)
+	lb.SetDefault(gcloud.DefaultImageFamily)
+	return lb
+}
+
+func (m mock) ImageFamilyListForProject(imgs *compute.ImageList, project string) gcloud.LabeledValues {
+	m.delay()
+	lb := mockImageFamilies(imgs)
+	lb.SetDefault(gcloud.ImageFamilyDefault(project))
+	if len(lb) > 0 && lb.GetDefault().Value == "" {
+		lb[0].IsDefault = true
+	}
+	return lb
+}
+
+func mockImageFamilies(imgs *compute.ImageList) gcloud.LabeledValues {
 	fam := make(map[string]bool)
 	lb := gcloud.LabeledValues{}
 
@@ -826,11 +1125,26 @@ func (m mock) ImageFamilyList(imgs *compute.ImageList) gcloud.LabeledValues {
 			IsDefault: false,
 		})
 	}
-	lb.SetDefault(gcloud.DefaultImageFamily)
 	lb.Sort()
 	return lb
 }
 
+func (m mock) ImageFamilyArchitectures(project, imageproject, family string) ([]string, error) {
+	m.delay()
+	if m.forceErr {
+		return nil, errForced
+	}
+
+	switch {
+	case family == "ubuntu-2204-lts":
+		return []string{gcloud.ArchitectureArm, gcloud.ArchitectureX86}, nil
+	case strings.HasSuffix(family, "-arm64"):
+		return []string{gcloud.ArchitectureArm}, nil
+	}
+
+	return []string{gcloud.ArchitectureX86}, nil
+}
+
 func (m *mock) save(key string, value interface{}) {
 	if m.cache == nil {
 		m.cache = make(map[string]interface{})
@@ -873,17 +1187,96 @@ var errForced = fmt.Errorf("this is a forced error for mocking")
 
 func (m mock) BillingAccountAttach(project, account string) error {
 	m.delay()
+	if err := m.checkWritable("BillingAccountAttach"); err != nil {
+		return err
+	}
 	if m.forceErr {
 		return errForced
 	}
 	return nil
 }
 
-func (m mock) ServiceEnable(project string, service gcloud.Service) error {
+func (m mock) SecretList(project string) (gcloud.LabeledValues, error) {
+	m.delay()
+	if m.forceErr {
+		return nil, errForced
+	}
+	r := gcloud.LabeledValues{
+		{Label: "api-key", Value: fmt.Sprintf("projects/%s/secrets/api-key", project)},
+		{Label: "db-password", Value: fmt.Sprintf("projects/%s/secrets/db-password", project)},
+	}
+	return r, nil
+}
+
+func (m mock) AddressList(project, region string) (gcloud.LabeledValues, error) {
+	m.delay()
+	if m.forceErr {
+		return nil, errForced
+	}
+	r := gcloud.LabeledValues{
+		{Label: "api-ip (34.1.2.4)", Value: "34.1.2.4"},
+		{Label: "web-ip (34.1.2.3)", Value: "34.1.2.3"},
+	}
+	return r, nil
+}
+
+func (m mock) NodeTypeList(project, zone string) (gcloud.LabeledValues, error) {
+	m.delay()
+	if m.forceErr {
+		return nil, errForced
+	}
+	if !strings.HasPrefix(zone, "us-central1") {
+		return gcloud.LabeledValues{}, nil
+	}
+	r := gcloud.LabeledValues{
+		{Label: "n1-node-96-624 (96 vCPUs, 624 GB)", Value: "n1-node-96-624"},
+		{Label: "n2-node-80-640 (80 vCPUs, 640 GB)", Value: "n2-node-80-640"},
+	}
+	return r, nil
+}
+
+func (m mock) DNSZoneList(project string) (gcloud.LabeledValues, error) {
+	m.delay()
+	if m.forceErr {
+		return nil, errForced
+	}
+	r := gcloud.LabeledValues{
+		{Label: "blog-zone (blog.example.com.)", Value: "blog-zone"},
+		{Label: "shop-zone (shop.example.com.)", Value: "shop-zone"},
+	}
+	return r, nil
+}
+
+func (m mock) RegionQuota(project, region, metric string) (*compute.Quota, error) {
 	m.delay()
+	if m.forceErr {
+		return nil, errForced
+	}
+	if metric != "CPUS" {
+		return nil, gcloud.ErrorQuotaUnsupported
+	}
+	return &compute.Quota{Metric: metric, Limit: 24, Usage: 20}, nil
+}
+
+func (m mock) QuotaIncreaseRequest(project, region, metric string, requested int64) error {
+	m.delay()
+	if err := m.checkWritable("QuotaIncreaseRequest"); err != nil {
+		return err
+	}
 	if m.forceErr {
 		return errForced
 	}
+	return m.quotaErr
+}
+
+func (m mock) ServiceEnable(project string, service gcloud.Service) error {
+	m.delay()
+	if err := m.checkWritable("ServiceEnable"); err != nil {
+		return err
+	}
+	if m.forceErr || m.failedServices[service.String()] {
+		return errForced
+	}
 	return nil
 }
 
@@ -892,5 +1285,8 @@ func (m mock) ServiceIsEnabled(project string, service gcloud.Service) (bool, er
 	if m.forceErr {
 		return false, errForced
 	}
+	if m.disabledServices[service.String()] || m.failedServices[service.String()] {
+		return false, nil
+	}
 	return true, nil
 }
@@ -18,6 +18,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
 )
@@ -124,13 +125,16 @@ func (p page) View() string {
 // TODO: a test for this is pretty straight forward
 func (p page) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg.(type) {
+	case tea.WindowSizeMsg:
+		p.queue.windowHeight = msg.(tea.WindowSizeMsg).Height
 	case successMsg:
 		return p.queue.next()
 	case tea.KeyMsg:
-		switch msg.(tea.KeyMsg).String() {
+		if key.Matches(msg.(tea.KeyMsg), p.queue.keyMap.Back) {
+			return p.queue.previous()
+		}
 
-		case "alt+b", "ctrl+b":
-			return p.queue.prev()
+		switch msg.(tea.KeyMsg).String() {
 		case "ctrl+c", "q":
 			if p.queue.Get("halted") != nil {
 				os.Exit(1)
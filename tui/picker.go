@@ -19,6 +19,7 @@ import (
 	"io"
 	"strings"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
@@ -62,12 +63,86 @@ type picker struct {
 	list         list.Model
 	target       string
 	defaultValue string
+
+	// hideable, when set, marks items that can be hidden by pressing the
+	// hideKey. allItems holds the full list while they are.
+	hideable func(*Queue, item) bool
+	hideText string
+	hiding   bool
+	allItems []list.Item
+
+	// picked is set once the user picks an item, telling a step the user
+	// answered from one whose pre processor answered it for them
+	picked bool
+}
+
+// addQueue attaches the picker to its queue, picking up the queue's key
+// bindings for the list
+func (p *picker) addQueue(q *Queue) {
+	p.queue = q
+	q.keyMap.applyTo(&p.list)
+}
+
+// visibleItems returns the items that should be shown, leaving out hideable
+// ones if they are being hidden
+func (p picker) visibleItems() []list.Item {
+	if !p.hiding || p.hideable == nil {
+		return p.allItems
+	}
+
+	items := []list.Item{}
+	for _, v := range p.allItems {
+		if p.hideable(p.queue, v.(item)) {
+			continue
+		}
+		items = append(items, v)
+	}
+
+	return items
+}
+
+const (
+	// defaultListHeight is used until the terminal reports its size
+	defaultListHeight    = 19
+	defaultListHeightMin = 8
+	defaultListHeightMax = 40
+)
+
+// fitHeight sizes the list to the rows the terminal has left once the header,
+// progress bar and page content are drawn
+func (p *picker) fitHeight() {
+	q := p.queue
+	if q == nil || q.windowHeight == 0 {
+		return
+	}
+
+	used := lipgloss.Height(drawProgress(0)) + 2
+	if q.header != nil {
+		used += lipgloss.Height(q.header.render())
+	}
+	if len(p.content) > 0 {
+		inst := strings.Builder{}
+		for _, v := range p.content {
+			inst.WriteString(v.render())
+		}
+		used += lipgloss.Height(instructionStyle.Width(width).Render(inst.String())) + 2
+	}
+
+	height := q.windowHeight - used
+	if height < q.listHeightMin {
+		height = q.listHeightMin
+	}
+	if height > q.listHeightMax {
+		height = q.listHeightMax
+	}
+
+	p.list.SetHeight(height)
 }
 
 func newPicker(listLabel, spinnerLabel, key, defaultValue string, preProcessor tea.Cmd) picker {
 	p := picker{}
 
-	l := list.New([]list.Item{}, itemDelegate{}, 0, 19)
+	l := list.New([]list.Item{}, itemDelegate{}, 0, defaultListHeight)
 	l.Title = listLabel
 	l.Styles.Title = titleStyle.style
 	l.Styles.PaginationStyle = paginationStyle
@@ -154,28 +229,51 @@ func (p picker) Init() tea.Cmd {
 
 func (p picker) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		p.queue.windowHeight = msg.Height
+		p.fitHeight()
+		return p, nil
 	case []list.Item:
 		p.state = "displaying"
 		items := []list.Item(msg)
+		p.fitHeight()
 
-		offset := len(p.list.Items())
-
-		for i, v := range items {
-			p.list.InsertItem(i+offset, v)
-		}
+		// Inserting one at a time re-paginates the whole list on every insert,
+		// which crawls for lists as long as a full image project. Set them all
+		// at once instead; the list sizes its pages from the pagination it
+		// already has, so it takes a second SetItems below to settle.
+		p.list.SetItems(append(append([]list.Item{}, p.list.Items()...), items...))
 
 		tmp, selectedIndex := positionDefault(p.list.Items(), p.defaultValue)
 		p.list.SetItems(tmp)
 
 		p.list.Select(selectedIndex)
 
+		if p.hideable != nil {
+			p.allItems = tmp
+			if p.hiding {
+				p.list.SetItems(p.visibleItems())
+				p.list.Select(0)
+			}
+		}
+
 		return p, p.spinner.Tick
 	case errMsg:
 		p.state = "idle"
 		p.err = msg
 		p.target = msg.target
+		p.queue.reportError(p.key, msg)
+		return p, nil
+	case slowMsg:
+		if msg.key == p.key {
+			p.querySlowText = msg.text
+		}
 		return p, nil
 	case successMsg:
+		if msg.msg == "retry" {
+			return p.queue.goToModel(p.key)
+		}
+
 		p.state = "idle"
 		newValue := p.value
 		if msg.msg == "prependProject" {
@@ -184,7 +282,15 @@ func (p picker) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 		if !msg.unset && !p.omitFromSettings {
-			p.queue.stack.AddSetting(p.key, newValue)
+			p.queue.setSetting(p.key, newValue)
+		}
+
+		if msg.target != "" {
+			return p.queue.goToModel(msg.target)
+		}
+
+		if !p.picked {
+			return p.queue.skip()
 		}
 
 		return p.queue.next()
@@ -192,19 +298,29 @@ func (p picker) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if p.list.FilterState() == list.Filtering {
 			break
 		}
-		switch keypress := msg.String(); keypress {
-		case "alt+b", "ctrl+b":
-			return p.queue.prev()
-		case "ctrl+c":
+		keys := p.queue.keyMap
+		switch {
+		case key.Matches(msg, keys.Back) && p.list.FilterState() == list.Unfiltered:
+			// With a filter applied, esc clears the filter instead
+			return p.queue.previous()
+		case key.Matches(msg, keys.Quit):
 			return p.queue.exitPage()
-		case "enter":
+		case key.Matches(msg, keys.Hide):
+			if p.hideable != nil && p.state == "displaying" {
+				p.hiding = !p.hiding
+				p.list.SetItems(p.visibleItems())
+				p.list.Select(0)
+				return p, nil
+			}
+		case key.Matches(msg, keys.Select):
 			if p.state == "displaying" {
 				i, ok := p.list.SelectedItem().(item)
 				if ok {
 					p.value = string(i.value)
 				}
+				p.picked = true
 				if !p.omitFromSettings {
-					p.queue.stack.AddSetting(p.key, p.value)
+					p.queue.setSetting(p.key, p.value)
 				}
 
 				if p.postProcessor != nil {
@@ -282,6 +398,15 @@ func (p picker) View() string {
 	if p.state != "waiting" && p.state != "idle" && p.state != "querying" {
 		selectedItemStyle.Width(hardWidthLimit)
 		doc.WriteString(componentStyle.Render(p.list.View()))
+
+		if p.hideable != nil {
+			verb := "hide"
+			if p.hiding {
+				verb = "show"
+			}
+			doc.WriteString("\n")
+			doc.WriteString(helpStyle.Render(fmt.Sprintf("Press %s to %s %s", p.queue.keyMap.Hide.Help().Key, verb, p.hideText)))
+		}
 	}
 
 	if p.state == "querying" {
@@ -19,6 +19,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/stretchr/testify/assert"
@@ -420,3 +421,179 @@ func TestPositionDefault(t *testing.T) {
 		})
 	}
 }
+
+func TestPickerHideBillingDisabled(t *testing.T) {
+	tests := map[string]struct {
+		hiding     bool
+		toggle     bool
+		count      int
+		outputFile string
+	}{
+		"unfiltered": {
+			hiding:     false,
+			count:      4,
+			outputFile: "picker_billing_unfiltered.txt",
+		},
+		"filtered": {
+			hiding:     true,
+			count:      3,
+			outputFile: "picker_billing_filtered.txt",
+		},
+		"toggled": {
+			hiding:     false,
+			toggle:     true,
+			count:      3,
+			outputFile: "picker_billing_filtered.txt",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+			q.Save("billingDisabledProjects", map[string]bool{"no-billing": true})
+
+			ptmp := newProjectSelector("project_id", "Choose a project", "", nil)
+			ptmp.hiding = tc.hiding
+			q.add(&ptmp)
+			p := q.models[0].(*picker)
+			p.state = "displaying"
+
+			raw, _ := p.Update([]list.Item{
+				item{label: "has-billing", value: "has-billing"},
+				item{label: "no-billing (Billing Diabled)", value: "no-billing"},
+				item{label: "also-billing", value: "also-billing"},
+			})
+			newP := raw.(picker)
+
+			if tc.toggle {
+				raw, _ = newP.Update(tea.KeyMsg{Type: tea.KeyTab})
+				newP = raw.(picker)
+			}
+
+			assert.Len(t, newP.list.Items(), tc.count)
+
+			content := newP.View()
+			testdata := filepath.Join(testFilesDir, "tui/testdata", tc.outputFile)
+			tcOutput := readTestFile(testdata)
+			if content != tcOutput {
+				writeDebugFile(content, testdata)
+				t.Fatalf("text wasn't the same. Look in testdata for expected and debug/testdata for got")
+			}
+		})
+	}
+}
+
+func TestPickerKeyMap(t *testing.T) {
+	custom := DefaultKeyMap()
+	custom.Down = key.NewBinding(key.WithKeys("n"))
+	custom.Up = key.NewBinding(key.WithKeys("p"))
+
+	tests := map[string]struct {
+		keyMap *KeyMap
+		filter bool
+		keys   []string
+		want   int
+	}{
+		"j moves down": {
+			keys: []string{"j"},
+			want: 1,
+		},
+		"j then k": {
+			keys: []string{"j", "j", "k"},
+			want: 1,
+		},
+		"arrows still work": {
+			keys: []string{"down", "down", "up"},
+			want: 1,
+		},
+		"custom": {
+			keyMap: &custom,
+			keys:   []string{"n", "n", "p", "j"},
+			want:   1,
+		},
+		"filtering": {
+			filter: true,
+			keys:   []string{"j", "j"},
+			want:   0,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+			if tc.keyMap != nil {
+				q.SetKeyMap(*tc.keyMap)
+			}
+
+			ptmp := newPicker("test", "test", "test", "", nil)
+			q.add(&ptmp)
+			p := q.models[0].(*picker)
+			p.state = "displaying"
+
+			raw, _ := p.Update([]list.Item{
+				item{label: "one", value: "one"},
+				item{label: "two", value: "two"},
+				item{label: "three", value: "three"},
+			})
+			newP := raw.(picker)
+
+			if tc.filter {
+				raw, _ = newP.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("/")})
+				newP = raw.(picker)
+			}
+
+			for _, k := range tc.keys {
+				msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+				switch k {
+				case "up":
+					msg = tea.KeyMsg{Type: tea.KeyUp}
+				case "down":
+					msg = tea.KeyMsg{Type: tea.KeyDown}
+				}
+				raw, _ = newP.Update(msg)
+				newP = raw.(picker)
+			}
+
+			assert.Equal(t, tc.want, newP.list.Index())
+		})
+	}
+}
+
+func TestPickerWindowSize(t *testing.T) {
+	q := getTestQueue(appTitle, "test")
+	q.SetListHeightRange(5, 30)
+
+	ptmp := newPicker("test", "test", "test", "", nil)
+	q.add(&ptmp)
+	p := *q.models[0].(*picker)
+	assert.Equal(t, defaultListHeight, p.list.Height())
+
+	resize := func(height int) int {
+		raw, _ := p.Update(tea.WindowSizeMsg{Width: 80, Height: height})
+		p = raw.(picker)
+		return p.list.Height()
+	}
+
+	assert.Equal(t, 30, resize(200), "tall terminals stop at the max")
+	assert.Equal(t, 5, resize(3), "short terminals stop at the min")
+
+	before := resize(30)
+	assert.Equal(t, before+5, resize(35), "resizing gives the list the extra rows")
+	assert.Equal(t, 35, q.windowHeight)
+}
+
+func TestPickerSlowMsg(t *testing.T) {
+	q := getTestQueue(appTitle, "test")
+
+	ptmp := newPicker("test", "test", "test", "", nil)
+	q.add(&ptmp)
+	p := *q.models[0].(*picker)
+
+	raw, _ := p.Update(slowMsg{key: "other", text: "not for this step"})
+	p = raw.(picker)
+	assert.Equal(t, "", p.querySlowText)
+
+	raw, _ = p.Update(slowMsg{key: "test", text: "retrying"})
+	p = raw.(picker)
+	assert.Equal(t, "retrying", p.querySlowText)
+}
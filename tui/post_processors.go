@@ -15,14 +15,20 @@
 package tui
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
+	"time"
 
 	"cloud.google.com/go/domains/apiv1beta1/domainspb"
+	"github.com/GoogleCloudPlatform/deploystack/config"
 	"github.com/GoogleCloudPlatform/deploystack/gcloud"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/nyaruka/phonenumbers"
+	"google.golang.org/api/cloudresourcemanager/v1"
+	"google.golang.org/api/compute/v1"
 )
 
 func processProjectSelection(projectID string, q *Queue) tea.Cmd {
@@ -33,17 +39,21 @@ func processProjectSelection(projectID string, q *Queue) tea.Cmd {
 				return errMsg
 			}
 
-			if err := q.client.ProjectIDSet(projectID); err != nil {
-				return errMsg{err: err}
+			// A read only client leaves the gcloud config alone
+			if !q.client.IsReadOnly() {
+				if err := q.client.ProjectIDSet(projectID); err != nil {
+					return errMsg{err: err}
+				}
 			}
 
 			q.Save("currentProject", projectID)
 
+			parent := q.currentKey() + parentNewSuffix
+			folder := q.currentKey() + folderNewSuffix
 			creator := q.currentKey() + projNewSuffix
 			billing := q.currentKey() + billNewSuffix
 
-			q.removeModel(creator)
-			q.removeModel(billing)
+			q.skipSteps(parent, folder, creator, billing)
 
 			return successMsg{}
 		}
@@ -63,25 +73,100 @@ func handleProjectNumber(projectID string, q *Queue) tea.Msg {
 	return nil
 }
 
-func createProject(projectID string, q *Queue) tea.Cmd {
+// currentProjectParent works out the parent of the project the user is
+// currently working in, falling back to the first project they can see.
+func currentProjectParent(q *Queue) (*cloudresourcemanager.ResourceId, error) {
+	currentProjectID, _ := q.Get("currentProject").(string)
+
+	if currentProjectID == "" {
+		tmp, err := q.client.ProjectList()
+		if err != nil || len(tmp) == 0 || tmp[0].ID == "" {
+			return nil, fmt.Errorf("could not determine an alternate project for parent detection: %w ", err)
+		}
+		currentProjectID = tmp[0].ID
+	}
+
+	parent, err := q.client.ProjectParentGet(currentProjectID)
+	if err != nil {
+		return nil, fmt.Errorf("could not determine proper parent for project: %w ", err)
+	}
+
+	return parent, nil
+}
+
+// parseProjectParent turns a project parent picker value, like
+// organization/123 or folder/456, into the parent to create the project in
+func parseProjectParent(value string) (*cloudresourcemanager.ResourceId, error) {
+	parent := &cloudresourcemanager.ResourceId{}
+
+	if value == noParentValue {
+		return parent, nil
+	}
+
+	parts := strings.SplitN(value, "/", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid parent (%s)", value)
+	}
+	parent.Type = parts[0]
+	parent.Id = parts[1]
+
+	return parent, nil
+}
+
+func processProjectParent(value string, q *Queue) tea.Cmd {
 	return func() tea.Msg {
+		parent, err := parseProjectParent(value)
+		if err != nil {
+			return errMsg{err: fmt.Errorf("processProjectParent: %w", err)}
+		}
 
-		currentProjectID := q.Get("currentProject").(string)
+		q.Save(q.currentKey(), parent)
 
-		if currentProjectID == "" {
-			tmp, err := q.client.ProjectList()
-			if err != nil || len(tmp) == 0 || tmp[0].ID == "" {
-				return errMsg{err: fmt.Errorf("createProject: could not determine an alternate project for parent detection: %w ", err)}
-			}
-			currentProjectID = tmp[0].ID
+		// Only an organization has folders to offer
+		if parent.Type != "organization" {
+			q.skipSteps(strings.ReplaceAll(q.currentKey(), parentNewSuffix, folderNewSuffix))
 		}
 
-		parent, err := q.client.ProjectParentGet(currentProjectID)
+		return successMsg{}
+	}
+}
+
+// processProjectFolder swaps the organization picked as the project parent
+// for the folder in it the user picked, if any
+func processProjectFolder(value string, q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		parent, err := parseProjectParent(value)
 		if err != nil {
-			return errMsg{err: fmt.Errorf("createProject: could not determine proper parent for project: %w ", err)}
+			return errMsg{err: fmt.Errorf("processProjectFolder: %w", err)}
+		}
+
+		q.Save(strings.ReplaceAll(q.currentKey(), folderNewSuffix, parentNewSuffix), parent)
+
+		return successMsg{}
+	}
+}
+
+func createProject(projectID string, q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		// Use the parent the user picked if there was a step for it,
+		// otherwise put the project alongside the current one.
+		parentKey := strings.ReplaceAll(q.currentKey(), projNewSuffix, parentNewSuffix)
+		parent, ok := q.Get(parentKey).(*cloudresourcemanager.ResourceId)
+		if !ok {
+			var err error
+			parent, err = currentProjectParent(q)
+			if err != nil {
+				return errMsg{err: fmt.Errorf("createProject: %w", err)}
+			}
 		}
 
-		if err := q.client.ProjectCreate(projectID, parent.Id, parent.Type); err != nil {
+		// The cost labels are asked for before the projects, so the new
+		// project can carry them, and the labels the stack declares, from
+		// the start
+		labels := projectLabels(q)
+
+		// Billing is linked by the billing picker that follows
+		if err := q.client.ProjectCreateWithLabels(projectID, parent.Id, parent.Type, "", labels); err != nil {
 			return errMsg{err: fmt.Errorf("createProject: could not create project: %w", err)}
 		}
 
@@ -114,6 +199,56 @@ func createProject(projectID string, q *Queue) tea.Cmd {
 	}
 }
 
+// processZone drops the machine types cached for the previously picked zone
+// when the user goes back and picks a different one. When configuring a
+// Compute Engine instance, it starts fetching the machine types and images
+// the next steps need.
+func processZone(zone string, q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		project := q.stack.GetSetting("project_id")
+
+		previous, _ := q.Get("currentZone").(string)
+		if previous != "" && previous != zone {
+			q.client.InvalidateMachineTypes(project, previous)
+			q.prefetch.drop(machineTypesKey(project, previous))
+		}
+
+		q.Save("currentZone", zone)
+
+		if q.stack.Config.ConfigureGCEInstance {
+			q.prefetchGCE(project, zone)
+		}
+
+		return successMsg{}
+	}
+}
+
+// Settings that record an image was pinned rather than the latest picked
+const (
+	imagePinnedKey     = "instance-image-pinned"
+	imageResolvedAtKey = "instance-image-resolved-at"
+)
+
+// processImagePin records that an image other than the latest in its family
+// was picked, and when, so the summary of the stack shows the image was
+// pinned on purpose rather than left to go stale
+func processImagePin(image string, q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		latest, _ := q.Get("instance-image-latest").(string)
+
+		if latest == "" || image == latest {
+			q.stack.DeleteSetting(imagePinnedKey)
+			q.stack.DeleteSetting(imageResolvedAtKey)
+			return successMsg{}
+		}
+
+		q.stack.AddSetting(imagePinnedKey, "true")
+		q.stack.AddSetting(imageResolvedAtKey, time.Now().UTC().Format(time.RFC3339))
+
+		return successMsg{}
+	}
+}
+
 func attachBilling(ba string, q *Queue) tea.Cmd {
 	return func() tea.Msg {
 		baclean := strings.ReplaceAll(ba, "billingAccounts/", "")
@@ -154,9 +289,20 @@ func validateDomain(domain string, q *Queue) tea.Cmd {
 					err:     fmt.Errorf("validateDomain: error verifying domain: %s", err),
 				}
 			}
+			if !isVerified {
+				// Domains bought outside of Cloud Domains can still be used
+				// if the user has verified they own them.
+				isVerified, err = q.client.DomainVerified(domain)
+				if err != nil {
+					return errMsg{
+						usermsg: "Trying to validate that you own this domain failed due to an error",
+						err:     fmt.Errorf("validateDomain: error checking domain verification: %s", err),
+					}
+				}
+			}
 			if !isVerified {
 				return errMsg{
-					usermsg: "Domain is owned by someone other than the requestor",
+					usermsg: fmt.Sprintf("Domain is owned by someone other than the requestor. If it's yours, verify it at %s and try again", gcloud.DomainVerificationURL),
 					err:     fmt.Errorf("validateDomain: not owned by requestor: %w", err),
 				}
 			}
@@ -222,13 +368,17 @@ func registerDomain(consent string, q *Queue) tea.Cmd {
 
 		projectID := q.Get("currentProject").(string)
 
-		err := q.client.DomainRegister(projectID, domainInfo, d)
-		if err != nil {
-			q.stack.AddSetting("domain_consent", "")
-			return errMsg{
-				usermsg: userMsg,
-				err:     fmt.Errorf("registerDomain: error registering domain: %w", err),
-				target:  "domain",
+		// A read only client can't register the domain, but the answers are
+		// still collected
+		if !q.client.IsReadOnly() {
+			err := q.client.DomainRegister(projectID, domainInfo, d)
+			if err != nil {
+				q.stack.AddSetting("domain_consent", "")
+				return errMsg{
+					usermsg: userMsg,
+					err:     fmt.Errorf("registerDomain: error registering domain: %w", err),
+					target:  "domain",
+				}
 			}
 		}
 
@@ -243,44 +393,87 @@ func registerDomain(consent string, q *Queue) tea.Cmd {
 	}
 }
 
-func validateInteger(input string, q *Queue) tea.Cmd {
+// answerCheck is the post processor result of checking an answer, err being
+// what the user is told when it doesn't pass
+func answerCheck(err error) tea.Cmd {
 	return func() tea.Msg {
-		_, err := strconv.Atoi(input)
 		if err != nil {
-			return errMsg{err: fmt.Errorf("Your answer '%s' not a valid integer", input)}
+			return errMsg{err: err}
 		}
+
 		return successMsg{}
 	}
 }
 
-func checkYesOrNo(input string) bool {
-	text := strings.TrimSpace(strings.ToLower(input))
-	yesList := " yes y "
-	noList := " no n "
+func validateInteger(input string, q *Queue) tea.Cmd {
+	return answerCheck(config.ValidateAnswer(validationInteger, input))
+}
 
-	return strings.Contains(yesList+noList, text)
+func checkYesOrNo(input string) bool {
+	return config.ValidYesOrNo(input)
 }
 
 func validateYesOrNo(input string, q *Queue) tea.Cmd {
-	return func() tea.Msg {
-		text := strings.TrimSpace(strings.ToLower(input))
+	return answerCheck(config.ValidateAnswer(validationYesOrNo, input))
+}
 
-		if !checkYesOrNo(text) {
-			return errMsg{err: fmt.Errorf("Your answer '%s' is neither 'yes' nor 'no'", input)}
+func validatePhoneNumber(input string, q *Queue) tea.Cmd {
+	return answerCheck(config.ValidateAnswer(validationPhoneNumber, input))
+}
+
+func validateEmail(input string, q *Queue) tea.Cmd {
+	return answerCheck(config.ValidateAnswer(validationEmail, input))
+}
+
+func validateURL(input string, q *Queue) tea.Cmd {
+	return answerCheck(config.ValidateAnswer(validationURL, input))
+}
+
+// ValidateCustom checks input against the validation named by a custom
+// setting, returning the same error the user would see in the tui. It is
+// config.ValidateAnswer, kept for existing callers.
+func ValidateCustom(validation, input string) error {
+	return config.ValidateAnswer(validation, input)
+}
+
+// ValidateOverrides checks the overridden custom settings of a stack the way
+// answers to them are checked, since they are never asked for
+func ValidateOverrides(s *config.Stack) error {
+	for _, v := range s.Config.CustomSettings {
+		if !s.IsOverridden(v.Name) {
+			continue
 		}
 
-		return successMsg{}
+		if err := v.ValidateAnswer(s.GetSetting(v.Name)); err != nil {
+			return fmt.Errorf("%s: %w", v.Name, err)
+		}
 	}
+
+	return nil
 }
 
-func validatePhoneNumber(input string, q *Queue) tea.Cmd {
-	return func() tea.Msg {
-		_, err := massagePhoneNumber(input)
-		if err != nil {
-			return errMsg{err: fmt.Errorf("Your answer '%s' is not a valid phone number. Please try again", input)}
+// ValidateCustomSetting checks input against everything custom setting c
+// asks of its answer, as ValidateCustom does for the validation alone, so
+// that the bounds of an integer and the pattern are checked too. It is
+// config.Custom.ValidateAnswer, kept for existing callers.
+func ValidateCustomSetting(c config.Custom, input string) error {
+	return c.ValidateAnswer(input)
+}
+
+// customValidator is the post processor that checks the answers to c: its
+// validation, and its pattern if it has one. It is nil when there is
+// nothing to check.
+func customValidator(c config.Custom) func(string, *Queue) tea.Cmd {
+	switch c.Validation {
+	case validationPhoneNumber, validationYesOrNo, validationInteger, validationEmail, validationURL:
+	default:
+		if c.ValidationRegex == "" {
+			return nil
 		}
+	}
 
-		return successMsg{}
+	return func(input string, q *Queue) tea.Cmd {
+		return answerCheck(c.ValidateAnswer(input))
 	}
 }
 
@@ -327,32 +520,371 @@ func validateGCEDefault(input string, q *Queue) tea.Cmd {
 		}
 
 		defaultConfig := map[string]string{
-			"instance-image":        defaultImage,
-			"instance-disksize":     gcloud.DefaultDiskSize,
-			"instance-disktype":     gcloud.DefaultDiskType,
-			"instance-tags":         gcloud.HTTPServerTags,
-			"instance-name":         fmt.Sprintf("%s-instance", basename),
-			"region":                gcloud.DefaultRegion,
-			"zone":                  gcloud.DefaultZone,
-			"instance-machine-type": gcloud.DefaultInstanceType,
+			"instance-image":            defaultImage,
+			"instance-disksize":         gcloud.DefaultDiskSize,
+			"instance-disktype":         gcloud.DefaultDiskType,
+			"instance-tags":             gcloud.HTTPServerTags,
+			"instance-name":             fmt.Sprintf("%s-instance", basename),
+			"region":                    gcloud.DefaultRegion,
+			"zone":                      gcloud.DefaultZone,
+			"instance-machine-type":     gcloud.DefaultInstanceType,
+			"instance-shielded-vm":      "y",
+			"instance-disk-replication": gcloud.DefaultDiskReplication,
+		}
+
+		// The stack's recommended machine type beats the generic default
+		if q.stack.Config.DefaultMachineType != "" {
+			defaultConfig["instance-machine-type"] = q.stack.Config.DefaultMachineType
+		}
+
+		if q.stack.Config.InstanceNetwork {
+			defaultConfig["instance-network"] = "default"
+			defaultConfig["instance-subnet"] = "default"
 		}
 
 		for i, v := range defaultConfig {
+			if q.stack.IsOverridden(i) {
+				continue
+			}
 			q.stack.AddSetting(i, v)
 		}
-		q.removeModel("instance-webserver")
-		q.removeModel("instance-image-project")
-		q.removeModel("instance-machine-type-family")
-		q.removeModel("instance-image")
-		q.removeModel("instance-image-type")
-		q.removeModel("instance-disksize")
-		q.removeModel("instance-disktype")
-		q.removeModel("instance-tags")
-		q.removeModel("instance-name")
-		q.removeModel("instance-machine-type")
-		q.removeModel("region")
-		q.removeModel("zone")
-		q.removeModel("instance-image-family")
+		gceSecurityConfig(q)
+		gceCostLabels(q)
+		q.skipSteps(
+			"instance-webserver",
+			"instance-image-project",
+			"instance-image-search",
+			"instance-machine-type-search",
+			"instance-machine-type-family",
+			"instance-custom-cpus",
+			"instance-custom-memory",
+			"instance-min-cpus",
+			"instance-min-memory",
+			"instance-image",
+			"instance-image-type",
+			"instance-disksize",
+			"instance-disktype",
+			"instance-tags",
+			"instance-name",
+			"instance-machine-type",
+			"region",
+			"zone",
+			"instance-network",
+			"instance-subnet",
+			"instance-subnet-pods-range",
+			"instance-subnet-services-range",
+			"instance-image-family",
+			"instance-image-architecture",
+			"instance-disk-replication",
+			"instance-disk-replica-zones",
+			"instance-shielded-vm",
+			"instance-confidential-vm",
+			"instance-sole-tenant",
+			"instance-node-type",
+			"instance-provisioning-model",
+			"instance-on-host-maintenance",
+			"instance-automatic-restart",
+		)
+
+		return successMsg{}
+	}
+}
+
+// enforceShieldedVM turns on Shielded VM for the instance when the org policy
+// requires it, as instances without it would be rejected.
+func enforceShieldedVM(q *Queue) {
+	if _, ok := orgPolicyConstraints(q)[gcloud.OrgPolicyRequireShieldedVM]; ok {
+		q.stack.AddSetting("instance-shielded-vm", "y")
+	}
+}
+
+func supportsConfidentialComputing(family string) bool {
+	series := strings.Split(family, "-")[0]
+	for _, v := range gcloud.ConfidentialComputingFamilies {
+		if v == series {
+			return true
+		}
+	}
+
+	return false
+}
+
+// gceSecurityConfig turns the Shielded VM and Confidential Computing answers
+// into the shielded_instance_config and confidential_instance_config blocks
+// the instance expects.
+func gceSecurityConfig(q *Queue) {
+	enforceShieldedVM(q)
+
+	if strings.HasPrefix(q.stack.GetSetting("instance-shielded-vm"), "y") {
+		q.stack.AddSettingComplete(config.Setting{
+			Name: "instance-shielded-instance-config",
+			Type: "map",
+			Map: map[string]string{
+				"enable_secure_boot":          "true",
+				"enable_vtpm":                 "true",
+				"enable_integrity_monitoring": "true",
+			},
+		})
+	}
+
+	if strings.HasPrefix(q.stack.GetSetting("instance-confidential-vm"), "y") {
+		q.stack.AddSettingComplete(config.Setting{
+			Name: "instance-confidential-instance-config",
+			Type: "map",
+			Map: map[string]string{
+				"enable_confidential_compute": "true",
+			},
+		})
+	}
+
+	q.stack.DeleteSetting("instance-shielded-vm")
+	q.stack.DeleteSetting("instance-confidential-vm")
+}
+
+// costLabelsKey is the setting holding the labels every resource the stack
+// creates should carry, for cost attribution
+const costLabelsKey = "cost_labels"
+
+// parseLabels reads labels written as key=value pairs separated by commas,
+// holding them to the Google Cloud label rules
+func parseLabels(input string) (map[string]string, error) {
+	labels := map[string]string{}
+
+	for _, pair := range strings.Split(input, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		key, value, _ := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		if err := gcloud.LabelValidate(key, value); err != nil {
+			return nil, err
+		}
+
+		labels[key] = value
+	}
+
+	return labels, nil
+}
+
+func processCostLabels(input string, q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		if strings.ToLower(strings.TrimSpace(input)) == "none" {
+			q.stack.DeleteSetting(costLabelsKey)
+			return successMsg{unset: true}
+		}
+
+		labels, err := parseLabels(input)
+		if err != nil {
+			return errMsg{
+				usermsg: err.Error(),
+				err:     fmt.Errorf("processCostLabels: %w", err),
+				target:  costLabelsKey,
+			}
+		}
+
+		q.stack.AddSettingComplete(config.Setting{
+			Name:  costLabelsKey,
+			Value: input,
+			Type:  "map",
+			Map:   labels,
+		})
+
+		return successMsg{unset: true}
+	}
+}
+
+// projectLabels returns the labels a new project gets: the ones the stack
+// declares, with the cost labels the user gave winning over them
+func projectLabels(q *Queue) map[string]string {
+	cost := q.stack.Settings.Find(costLabelsKey)
+	if cost == nil || len(cost.Map) == 0 {
+		return q.stack.Config.ProjectLabels
+	}
+	if len(q.stack.Config.ProjectLabels) == 0 {
+		return cost.Map
+	}
+
+	labels := map[string]string{}
+	for k, v := range q.stack.Config.ProjectLabels {
+		labels[k] = v
+	}
+	for k, v := range cost.Map {
+		labels[k] = v
+	}
+
+	return labels
+}
+
+// gceCostLabels puts the cost labels on the instance and its boot disk, so
+// the instance terraform doesn't have to merge them in itself
+func gceCostLabels(q *Queue) {
+	labels := q.stack.Settings.Find(costLabelsKey)
+	if labels == nil || len(labels.Map) == 0 {
+		return
+	}
+
+	for _, v := range []string{"instance-labels", "instance-disk-labels"} {
+		q.stack.AddSettingComplete(config.Setting{Name: v, Type: "map", Map: labels.Map})
+	}
+}
+
+func processShieldedVM(input string, q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		family := q.stack.GetSetting("instance-machine-type-family")
+		if !supportsConfidentialComputing(family) {
+			q.skipSteps("instance-confidential-vm")
+			q.stack.DeleteSetting("instance-confidential-vm")
+		}
+
+		return successMsg{}
+	}
+}
+
+func processSoleTenant(input string, q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		if input != "y" {
+			q.skipSteps("instance-node-type")
+			q.stack.DeleteSetting("instance-node-affinity")
+			q.stack.DeleteSetting("instance-node-affinity-values")
+			return successMsg{}
+		}
+
+		project := q.stack.GetSetting("project_id")
+		zone := q.stack.GetSetting("zone")
+
+		types, err := q.client.NodeTypeList(project, zone)
+		if err != nil {
+			return errMsg{err: fmt.Errorf("processSoleTenant: could not get node types: %w", err)}
+		}
+
+		if len(types) == 0 {
+			return errMsg{
+				usermsg: fmt.Sprintf("Zone %s has no sole-tenant nodes, pick No or go back and pick another zone", zone),
+				err:     fmt.Errorf("processSoleTenant: zone (%s) has no node types", zone),
+				target:  "instance-sole-tenant",
+			}
+		}
+
+		return successMsg{}
+	}
+}
+
+// processNodeType pins the instance to nodes of the chosen type with a node
+// affinity. Terraform takes the affinity values as a list, and a map setting
+// can only hold strings, so they are stored in a list setting of their own.
+func processNodeType(input string, q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		q.stack.AddSettingComplete(config.Setting{
+			Name: "instance-node-affinity",
+			Type: "map",
+			Map: map[string]string{
+				"key":      gcloud.NodeAffinityKey,
+				"operator": "IN",
+			},
+		})
+		q.stack.AddListSetting("instance-node-affinity-values", []string{input})
+
+		return successMsg{}
+	}
+}
+
+// processProvisioningModel steers the following scheduling questions towards
+// the only answers Spot instances accept. Instances with an accelerator can
+// only be stopped during host maintenance, so that isn't asked.
+func processProvisioningModel(input string, q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		q.Save("instance-provisioning-model", input)
+
+		maintenance := gcloud.HostMaintenanceMigrate
+		restart := ""
+		if input == gcloud.ProvisioningSpot {
+			maintenance = gcloud.HostMaintenanceTerminate
+			restart = "n"
+		}
+
+		if q.stack.GetSetting("instance-accelerator-type") != "" {
+			maintenance = gcloud.HostMaintenanceTerminate
+			q.Save("instance-on-host-maintenance", maintenance)
+			q.skipSteps("instance-on-host-maintenance")
+		}
+
+		if p, ok := q.Model("instance-on-host-maintenance").(*picker); ok {
+			p.defaultValue = maintenance
+		}
+		if p, ok := q.Model("instance-automatic-restart").(*picker); ok {
+			p.defaultValue = restart
+		}
+
+		return successMsg{}
+	}
+}
+
+func validateHostMaintenance(input string, q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		model, _ := q.Get("instance-provisioning-model").(string)
+
+		if err := gcloud.SchedulingValidate(model, input, false); err != nil {
+			return errMsg{
+				usermsg: "Spot instances can't be migrated, pick Stop the instance or go back and pick Standard",
+				err:     fmt.Errorf("validateHostMaintenance: %w", err),
+				target:  "instance-on-host-maintenance",
+			}
+		}
+
+		accelerator := q.stack.GetSetting("instance-accelerator-type")
+		if err := gcloud.AcceleratorSchedulingValidate(accelerator, input); err != nil {
+			return errMsg{
+				usermsg: "Instances with an accelerator can't be migrated, pick Stop the instance",
+				err:     fmt.Errorf("validateHostMaintenance: %w", err),
+				target:  "instance-on-host-maintenance",
+			}
+		}
+
+		q.Save("instance-on-host-maintenance", input)
+
+		return successMsg{}
+	}
+}
+
+// processScheduling checks the scheduling answers go together and records
+// them in the instance-scheduling setting
+func processScheduling(input string, q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		model, _ := q.Get("instance-provisioning-model").(string)
+		maintenance, _ := q.Get("instance-on-host-maintenance").(string)
+		restart := input == "y"
+
+		if err := gcloud.SchedulingValidate(model, maintenance, restart); err != nil {
+			return errMsg{
+				usermsg: "Spot instances can't restart automatically, pick No or go back and pick Standard",
+				err:     fmt.Errorf("processScheduling: %w", err),
+				target:  "instance-automatic-restart",
+			}
+		}
+
+		accelerator := q.stack.GetSetting("instance-accelerator-type")
+		if err := gcloud.AcceleratorSchedulingValidate(accelerator, maintenance); err != nil {
+			return errMsg{
+				usermsg: "Instances with an accelerator can't be migrated, go back and pick Stop the instance",
+				err:     fmt.Errorf("processScheduling: %w", err),
+				target:  "instance-on-host-maintenance",
+			}
+		}
+
+		q.stack.AddSettingComplete(config.Setting{
+			Name: "instance-scheduling",
+			Type: "map",
+			Map: map[string]string{
+				"provisioning_model":  model,
+				"preemptible":         strconv.FormatBool(model == gcloud.ProvisioningSpot),
+				"on_host_maintenance": maintenance,
+				"automatic_restart":   strconv.FormatBool(restart),
+			},
+		})
 
 		return successMsg{}
 	}
@@ -371,6 +903,532 @@ func validateGCEConfiguration(input string, q *Queue) tea.Cmd {
 		q.stack.DeleteSetting("instance-image-project")
 		q.stack.DeleteSetting("instance-machine-type-family")
 		q.stack.DeleteSetting("instance-image-family")
+		gceSecurityConfig(q)
+		gceCostLabels(q)
+		return successMsg{unset: true}
+	}
+}
+
+// machineTypeByRequirements is the machine type search choice that picks the
+// machine type from the vCPUs and memory the user needs
+const machineTypeByRequirements = "requirements"
+
+func skipMachineTypeRequirements(q *Queue) {
+	q.skipSteps("instance-min-cpus", "instance-min-memory")
+}
+
+func processMachineTypeSearch(input string, q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		if input == machineTypeByRequirements {
+			q.skipSteps("instance-machine-type-family", "instance-custom-cpus", "instance-custom-memory", "instance-machine-type")
+			return successMsg{unset: true}
+		}
+
+		skipMachineTypeRequirements(q)
+
+		if input == "browse" {
+			if err := applyMachineTypeDefaults(q); err != nil {
+				return errMsg{err: fmt.Errorf("processMachineTypeSearch: could not get machine types: %w", err)}
+			}
+			return successMsg{unset: true}
+		}
+
+		// Shared core types like e2-micro are their own family, so only
+		// a name without a dash can't be a machine type
+		if !strings.Contains(input, "-") {
+			return errMsg{
+				err:    fmt.Errorf("processMachineTypeSearch: could not determine family of machine type (%s)", input),
+				target: "instance-machine-type-search",
+			}
+		}
+
+		q.stack.AddSetting("instance-machine-type-family", gcloud.MachineTypeFamily(input))
+		q.stack.AddSetting("instance-machine-type", input)
+		q.skipSteps("instance-machine-type-family", "instance-custom-cpus", "instance-custom-memory", "instance-machine-type")
+
+		return successMsg{unset: true}
+	}
+}
+
+// customMachineFamily is the machine type family choice that asks for vCPUs
+// and memory instead of a predefined machine type
+const customMachineFamily = "custom"
+
+// machineTypeFamily returns the family choice machineType belongs to, which
+// for a custom machine type like custom-8-32768 is the custom one
+func machineTypeFamily(machineType string) string {
+	if strings.HasPrefix(machineType, customMachineFamily+"-") {
+		return customMachineFamily
+	}
+	return gcloud.MachineTypeFamily(machineType)
+}
+
+func processMachineTypeFamily(input string, q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		if input == customMachineFamily {
+			q.skipSteps("instance-machine-type")
+			return successMsg{}
+		}
+
+		q.skipSteps("instance-custom-cpus", "instance-custom-memory")
+
+		return successMsg{}
+	}
+}
+
+func processCustomCPUs(input string, q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		cpus, err := strconv.Atoi(input)
+		if err != nil {
+			return errMsg{
+				usermsg: fmt.Sprintf("Your answer '%s' not a valid integer", input),
+				err:     fmt.Errorf("processCustomCPUs: %w", err),
+				target:  "instance-custom-cpus",
+			}
+		}
+
+		q.Save("customCPUs", cpus)
+
+		return successMsg{}
+	}
+}
+
+func processCustomMachineType(input string, q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		cpus, _ := q.Get("customCPUs").(int)
+
+		mem, err := strconv.Atoi(input)
+		if err != nil {
+			return errMsg{
+				usermsg: fmt.Sprintf("Your answer '%s' not a valid integer", input),
+				err:     fmt.Errorf("processCustomMachineType: %w", err),
+				target:  "instance-custom-memory",
+			}
+		}
+
+		machineType, err := gcloud.CustomMachineType(cpus, mem)
+		if err != nil {
+			return errMsg{
+				usermsg: err.Error(),
+				err:     fmt.Errorf("processCustomMachineType: %w", err),
+				target:  "instance-custom-memory",
+			}
+		}
+
+		q.stack.AddSetting("instance-machine-type", machineType)
+
+		return successMsg{}
+	}
+}
+
+func processMinCPUs(input string, q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		cpus, err := strconv.ParseInt(input, 10, 64)
+		if err != nil || cpus < 1 {
+			return errMsg{
+				usermsg: fmt.Sprintf("Your answer '%s' is not a positive whole number", input),
+				err:     fmt.Errorf("processMinCPUs: invalid vCPUs (%s)", input),
+				target:  "instance-min-cpus",
+			}
+		}
+
+		q.Save("minCPUs", cpus)
+
+		return successMsg{}
+	}
+}
+
+func processMachineTypeRequirements(input string, q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		cpus, _ := q.Get("minCPUs").(int64)
+
+		gb, err := strconv.ParseFloat(input, 64)
+		if err != nil || gb <= 0 {
+			return errMsg{
+				usermsg: fmt.Sprintf("Your answer '%s' is not a positive number", input),
+				err:     fmt.Errorf("processMachineTypeRequirements: invalid memory (%s)", input),
+				target:  "instance-min-memory",
+			}
+		}
+
+		project := q.stack.GetSetting("project_id")
+		zone := q.stack.GetSetting("zone")
+
+		machineType, err := q.client.MachineTypeMatch(project, zone, cpus, int64(math.Ceil(gb*1024)))
+		if err != nil {
+			return errMsg{
+				usermsg: fmt.Sprintf("No machine type fits %d vCPUs and %s GB", cpus, input),
+				err:     fmt.Errorf("processMachineTypeRequirements: %w", err),
+				target:  "instance-min-memory",
+			}
+		}
+
+		q.stack.AddSetting("instance-machine-type-family", machineTypeFamily(machineType))
+		q.stack.AddSetting("instance-machine-type", machineType)
+
+		return successMsg{}
+	}
+}
+
+// The choices offered when the machine type needs more quota than is left
+const (
+	quotaRequestAction  = "request"
+	quotaContinueAction = "continue"
+)
+
+// processQuota files the quota increase getQuotaActions worked out, if the
+// user asked for it. When the quota can't be increased from here, the user
+// is told why and can go on without it.
+func processQuota(input string, q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		if input != quotaRequestAction {
+			return successMsg{}
+		}
+
+		r, _ := q.Get("quotaRequest").(quotaRequest)
+		project := q.stack.GetSetting("project_id")
+
+		err := q.client.QuotaIncreaseRequest(project, r.region, r.metric, r.requested)
+		switch {
+		case err == nil:
+			return successMsg{}
+		case errors.Is(err, gcloud.ErrorQuotaNoPermission):
+			return errMsg{
+				usermsg: "You don't have permission to request quota increases in this project. Ask a project owner to request it, or continue without it.",
+				err:     fmt.Errorf("processQuota: %w", err),
+				target:  "instance-quota",
+			}
+		case errors.Is(err, gcloud.ErrorQuotaUnsupported):
+			return errMsg{
+				usermsg: "This quota can't be increased from here. Request it from the Quotas page of the Cloud Console, or continue without it.",
+				err:     fmt.Errorf("processQuota: %w", err),
+				target:  "instance-quota",
+			}
+		}
+
+		return errMsg{err: fmt.Errorf("processQuota: %w", err), target: "instance-quota"}
+	}
+}
+
+func processImageSearch(input string, q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		if input == "browse" {
+			return successMsg{unset: true}
+		}
+
+		project := q.stack.GetSetting("project_id")
+		imageProject := q.stack.GetSetting("instance-image-project")
+		name := strings.TrimPrefix(input, imageProject+"/")
+
+		images, err := q.imageList(project, imageProject)
+		if err != nil {
+			return errMsg{err: fmt.Errorf("processImageSearch: could not get images: %w", err)}
+		}
+
+		var picked *compute.Image
+		for _, v := range images.Items {
+			if strings.TrimSpace(v.Name) == name {
+				picked = v
+				break
+			}
+		}
+
+		if picked == nil || picked.Family == "" {
+			return errMsg{
+				err:    fmt.Errorf("processImageSearch: could not determine family of image (%s)", input),
+				target: "instance-image-search",
+			}
+		}
+
+		// The latest image to compare with is the one of the same
+		// architecture in the family
+		family := picked.Family
+		arch := imageArchitecture(picked)
+		same := &compute.ImageList{}
+		for _, v := range images.Items {
+			if v.Family == family && imageArchitecture(v) == arch {
+				same.Items = append(same.Items, v)
+			}
+		}
+		latest := q.client.ImageTypeListByFamily(same, imageProject, family)
+		q.Save("instance-image-latest", strings.TrimSpace(latest.GetDefault().Value))
+
+		q.stack.AddSetting("instance-image-family", family)
+		q.stack.AddSetting("instance-image", input)
+		q.skipSteps("instance-image-family", "instance-image-architecture", "instance-image")
+		processImagePin(input, q)()
+
+		return successMsg{unset: true}
+	}
+}
+
+// applyMachineTypeDefaults preselects the machine type the stack recommends
+// in the family and type pickers, if the stack sets one
+func applyMachineTypeDefaults(q *Queue) error {
+	conf := q.stack.Config
+	if conf.DefaultMachineType == "" && conf.DefaultMachineFamily == "" {
+		return nil
+	}
+
+	project := q.stack.GetSetting("project_id")
+	zone := q.stack.GetSetting("zone")
+
+	types, err := q.machineTypeList(project, zone)
+	if err != nil {
+		return err
+	}
+
+	family, machineType := machineTypeDefaults(conf, types)
+
+	if qmod := q.Model("instance-machine-type-family"); qmod != nil {
+		qmod.(*picker).defaultValue = family
+	}
+	if qmod := q.Model("instance-machine-type"); qmod != nil && machineType != "" {
+		qmod.(*picker).defaultValue = machineType
+	}
+
+	return nil
+}
+
+// machineTypeDefaults works out the family and machine type to preselect
+// from the ones a stack recommends, falling back to the DeployStack default
+// when the recommendation isn't offered in the zone
+func machineTypeDefaults(conf config.Config, types *compute.MachineTypeList) (family, machineType string) {
+	available := map[string]bool{}
+	families := map[string]bool{}
+	for _, v := range types.Items {
+		available[v.Name] = true
+		families[gcloud.MachineTypeFamily(v.Name)] = true
+	}
+
+	machineType = conf.DefaultMachineType
+	if machineType != "" && !available[machineType] {
+		machineType = gcloud.DefaultInstanceType
+	}
+
+	// The machine type, when there is one, decides the family so the two
+	// pickers agree
+	family = conf.DefaultMachineFamily
+	if machineType != "" {
+		family = gcloud.MachineTypeFamily(machineType)
+	}
+	if !families[family] {
+		family = gcloud.MachineTypeFamily(gcloud.DefaultInstanceType)
+	}
+
+	return family, machineType
+}
+
+func processImageProject(project string, q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		qmod := q.Model("instance-image-family")
+		if qmod != nil {
+			f := qmod.(*picker)
+			f.defaultValue = gcloud.ImageFamilyDefault(project)
+		}
+
+		return successMsg{}
+	}
+}
+
+func processImageFamily(family string, q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		// An architecture picked for another family doesn't hold for this one,
+		// and the step that would replace it may be skipped below
+		delete(q.store, "instance-image-architecture")
+
+		project := q.stack.GetSetting("project_id")
+		imageProject := q.stack.GetSetting("instance-image-project")
+
+		archs, err := q.client.ImageFamilyArchitectures(project, imageProject, family)
+		if err != nil {
+			return errMsg{err: fmt.Errorf("processImageFamily: could not get architectures: %w", err)}
+		}
+
+		machineArch := gcloud.MachineTypeArchitecture(q.stack.GetSetting("instance-machine-type"))
+
+		supported := false
+		for _, v := range archs {
+			if v == machineArch {
+				supported = true
+			}
+		}
+
+		if !supported {
+			return errMsg{
+				usermsg: fmt.Sprintf("The %s family has no images for %s machine types, pick another family", family, machineArch),
+				err:     fmt.Errorf("processImageFamily: family (%s) does not support architecture (%s)", family, machineArch),
+				target:  "instance-image-family",
+			}
+		}
+
+		if len(archs) < 2 {
+			q.skipSteps("instance-image-architecture")
+			return successMsg{}
+		}
+
+		qmod := q.Model("instance-image-architecture")
+		if qmod != nil {
+			a := qmod.(*picker)
+			a.defaultValue = machineArch
+		}
+
+		return successMsg{}
+	}
+}
+
+func validateImageArchitecture(arch string, q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		machineType := q.stack.GetSetting("instance-machine-type")
+		machineArch := gcloud.MachineTypeArchitecture(machineType)
+
+		if arch != machineArch {
+			return errMsg{
+				usermsg: fmt.Sprintf("The machine type %s is %s, pick a matching architecture", machineType, machineArch),
+				err:     fmt.Errorf("validateImageArchitecture: architecture (%s) does not match machine type (%s)", arch, machineType),
+				target:  "instance-image-architecture",
+			}
+		}
+
+		q.Save("instance-image-architecture", arch)
+
+		return successMsg{}
+	}
+}
+
+func validateDiskType(diskType string, q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		project := q.stack.GetSetting("project_id")
+		imageProject := q.stack.GetSetting("instance-image-project")
+		name := strings.TrimPrefix(q.stack.GetSetting("instance-image"), imageProject+"/")
+
+		images, err := q.imageList(project, imageProject)
+		if err != nil {
+			return errMsg{err: fmt.Errorf("validateDiskType: could not get images: %w", err)}
+		}
+
+		var img *compute.Image
+		for _, v := range images.Items {
+			if strings.TrimSpace(v.Name) == name {
+				img = v
+			}
+		}
+
+		if err := gcloud.ImageDiskTypeCompatible(imageProject, img, diskType); err != nil {
+			return errMsg{
+				usermsg: fmt.Sprintf("The %s disk type won't work with the image you picked, pick another disk type", diskType),
+				err:     fmt.Errorf("validateDiskType: %w", err),
+				target:  "instance-disktype",
+			}
+		}
+
+		return successMsg{}
+	}
+}
+
+func processDiskReplication(input string, q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		if input != "regional" {
+			q.skipSteps("instance-disk-replica-zones")
+			q.stack.DeleteSetting("instance-disk-replica-zones")
+		}
+
+		return successMsg{}
+	}
+}
+
+func validateReplicaZone(replica string, q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		region := q.stack.GetSetting("region")
+		zone := q.stack.GetSetting("zone")
+
+		if !strings.HasPrefix(replica, region+"-") {
+			return errMsg{
+				usermsg: "The replica zone must be in the same region as the instance",
+				err:     fmt.Errorf("validateReplicaZone: zone (%s) is not in region (%s)", replica, region),
+				target:  "instance-disk-replica-zones",
+			}
+		}
+
+		if replica == zone {
+			return errMsg{
+				usermsg: "The replica zone must be different from the instance zone",
+				err:     fmt.Errorf("validateReplicaZone: zone (%s) is already the instance zone", replica),
+				target:  "instance-disk-replica-zones",
+			}
+		}
+
+		q.stack.AddListSetting("instance-disk-replica-zones", []string{zone, replica})
+
+		return successMsg{}
+	}
+}
+
+func handleServiceResults(input string, q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		switch input {
+		case "retry":
+			q.Save("serviceResults", nil)
+			return successMsg{msg: "retry", unset: true}
+		case "abort":
+			return errMsg{
+				err:     fmt.Errorf("handleServiceResults: required services could not be enabled"),
+				usermsg: "DeployStack cannot continue without the required APIs enabled.",
+				quit:    true,
+				target:  "quit",
+			}
+		}
+
+		return successMsg{unset: true}
+	}
+}
+
+// processReview moves past the list of settings to edit on Confirm, which is
+// what allows the settings to be written out
+func processReview(input string, q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		if input == "confirm" {
+			q.Save(reviewConfirmedKey, true)
+			return successMsg{unset: true, target: "endpage"}
+		}
+
+		q.Save(reviewConfirmedKey, nil)
+		return successMsg{unset: true}
+	}
+}
+
+// processReviewEdit clears the setting picked to edit and goes back to the
+// step that asked for it. The flow comes back to the review from there.
+func processReviewEdit(key string, q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		q.clearDependents(key)
+		q.stack.DeleteSetting(key)
+
+		return successMsg{unset: true, target: key}
+	}
+}
+
+func storeSecret(value string, q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		q.stack.AddSettingComplete(config.Setting{
+			Name:  q.currentKey(),
+			Value: value,
+			Type:  "secret",
+		})
+
+		return successMsg{unset: true}
+	}
+}
+
+// processReuseOrNew leaves the setting empty when the user wants a new
+// resource, so the stack's terraform creates one
+func processReuseOrNew(value string, q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		if value == newResourceValue {
+			value = ""
+		}
+		q.stack.AddSetting(q.currentKey(), value)
+
 		return successMsg{unset: true}
 	}
 }
@@ -18,11 +18,14 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	"cloud.google.com/go/domains/apiv1beta1/domainspb"
+	"github.com/GoogleCloudPlatform/deploystack/config"
 	"github.com/GoogleCloudPlatform/deploystack/gcloud"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/cloudresourcemanager/v1"
 )
 
 func TestProcessProjectSelection(t *testing.T) {
@@ -235,6 +238,206 @@ func TestValidateInteger(t *testing.T) {
 	}
 }
 
+func TestValidateIntegerRange(t *testing.T) {
+	one, ten := 1, 10
+
+	tests := map[string]struct {
+		custom config.Custom
+		in     string
+		err    string
+	}{
+		"inRange":    {custom: config.Custom{Min: &one, Max: &ten}, in: "5"},
+		"atMin":      {custom: config.Custom{Min: &one, Max: &ten}, in: "1"},
+		"atMax":      {custom: config.Custom{Min: &one, Max: &ten}, in: "10"},
+		"belowMin":   {custom: config.Custom{Min: &one, Max: &ten}, in: "0", err: "Your answer '0' must be between 1 and 10"},
+		"aboveMax":   {custom: config.Custom{Min: &one, Max: &ten}, in: "11", err: "Your answer '11' must be between 1 and 10"},
+		"onlyMin":    {custom: config.Custom{Min: &one}, in: "-3", err: "Your answer '-3' must be at least 1"},
+		"onlyMax":    {custom: config.Custom{Max: &ten}, in: "300", err: "Your answer '300' must be at most 10"},
+		"notInteger": {custom: config.Custom{Min: &one, Max: &ten}, in: "5.5", err: "Your answer '5.5' not a valid integer"},
+		"unbounded":  {custom: config.Custom{}, in: "300"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+			tc.custom.Validation = validationInteger
+
+			got := customValidator(tc.custom)(tc.in, &q)()
+
+			if tc.err == "" {
+				assert.Equal(t, successMsg{}, got)
+				assert.Nil(t, ValidateCustomSetting(tc.custom, tc.in))
+				return
+			}
+
+			gotE, ok := got.(errMsg)
+			if !ok {
+				t.Fatalf("%s - want: an error got: \n'%+v'", tc.in, got)
+			}
+			assert.EqualError(t, gotE.err, tc.err)
+			assert.EqualError(t, ValidateCustomSetting(tc.custom, tc.in), tc.err)
+		})
+	}
+}
+
+func TestValidatePattern(t *testing.T) {
+	tests := map[string]struct {
+		custom config.Custom
+		in     string
+		err    string
+	}{
+		"matches": {
+			custom: config.Custom{ValidationRegex: "bucket-[0-9]+"},
+			in:     "bucket-12",
+		},
+		"noMatch": {
+			custom: config.Custom{ValidationRegex: "bucket-[0-9]+", ValidationMessage: "Bucket names look like bucket-1"},
+			in:     "bucket-x",
+			err:    "Bucket names look like bucket-1",
+		},
+		"partialMatch": {
+			custom: config.Custom{ValidationRegex: "bucket-[0-9]+"},
+			in:     "my-bucket-12",
+			err:    "Your answer 'my-bucket-12' does not match the pattern bucket-[0-9]+",
+		},
+		"validationFirst": {
+			custom: config.Custom{Validation: validationInteger, ValidationRegex: "[0-9]{2}"},
+			in:     "ab",
+			err:    "Your answer 'ab' not a valid integer",
+		},
+		"validationThenPattern": {
+			custom: config.Custom{Validation: validationInteger, ValidationRegex: "[0-9]{2}", ValidationMessage: "Use two digits"},
+			in:     "5",
+			err:    "Use two digits",
+		},
+		"invalidRegex": {
+			custom: config.Custom{ValidationRegex: "[a-"},
+			in:     "a",
+			err:    "validation_regex ([a-) does not compile",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+
+			got := customValidator(tc.custom)(tc.in, &q)()
+
+			if tc.err == "" {
+				assert.Equal(t, successMsg{}, got)
+				assert.Nil(t, ValidateCustomSetting(tc.custom, tc.in))
+				return
+			}
+
+			gotE, ok := got.(errMsg)
+			if !ok {
+				t.Fatalf("%s - want: an error got: \n'%+v'", tc.in, got)
+			}
+			assert.Contains(t, gotE.err.Error(), tc.err)
+			assert.Contains(t, ValidateCustomSetting(tc.custom, tc.in).Error(), tc.err)
+		})
+	}
+}
+
+func TestValidateEmail(t *testing.T) {
+	invalid := func(in string) errMsg {
+		return errMsg{err: fmt.Errorf("Your answer '%s' is not a valid email address", in)}
+	}
+
+	tests := map[string]struct {
+		in  string
+		msg tea.Msg
+	}{
+		"simple":        {in: "person@example.com", msg: successMsg{}},
+		"dotsAndPlus":   {in: "first.last+deploy@mail.example.co.uk", msg: successMsg{}},
+		"spaces":        {in: " person@example.com ", msg: successMsg{}},
+		"missingAt":     {in: "person.example.com", msg: invalid("person.example.com")},
+		"twoAts":        {in: "person@home@example.com", msg: invalid("person@home@example.com")},
+		"noDomainDot":   {in: "person@localhost", msg: invalid("person@localhost")},
+		"trailingDot":   {in: "person@example.com.", msg: invalid("person@example.com.")},
+		"localTrailing": {in: "person.@example.com", msg: invalid("person.@example.com")},
+		"doubleDot":     {in: "person@example..com", msg: invalid("person@example..com")},
+		"empty":         {in: "", msg: invalid("")},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+			cmd := validateEmail(tc.in, &q)
+
+			got := cmd()
+
+			switch tc.msg.(type) {
+			case successMsg:
+				if tc.msg != got {
+					t.Fatalf("%s - want: \n'%+v' \ngot: \n'%+v'", tc.in, tc.msg, got)
+				}
+			case errMsg:
+				gotE, ok := got.(errMsg)
+				if !ok {
+					t.Fatalf("%s - want: an error got: \n'%+v'", tc.in, got)
+				}
+				tcmsgE := tc.msg.(errMsg)
+
+				if tcmsgE.err.Error() != gotE.err.Error() {
+					t.Fatalf("want: \n'%+v' \ngot: \n'%+v'", tcmsgE.err.Error(), gotE.err.Error())
+				}
+
+				// ValidateCustom hands other front ends the same error
+				assert.EqualError(t, ValidateCustom(validationEmail, tc.in), tcmsgE.err.Error())
+			}
+		})
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	noScheme := func(in string) errMsg {
+		return errMsg{err: fmt.Errorf("Your answer '%s' needs to start with http:// or https://", in)}
+	}
+
+	tests := map[string]struct {
+		in  string
+		msg tea.Msg
+	}{
+		"https":     {in: "https://example.com/hooks/deploy", msg: successMsg{}},
+		"http":      {in: "http://example.com:8080", msg: successMsg{}},
+		"spaces":    {in: " https://example.com ", msg: successMsg{}},
+		"noScheme":  {in: "example.com/hooks", msg: noScheme("example.com/hooks")},
+		"ftp":       {in: "ftp://example.com", msg: noScheme("ftp://example.com")},
+		"noHost":    {in: "https:///hooks", msg: errMsg{err: fmt.Errorf("Your answer 'https:///hooks' has no host")}},
+		"badEscape": {in: "https://example.com/%zz", msg: errMsg{err: fmt.Errorf("Your answer 'https://example.com/%zz' is not a valid URL")}},
+		"empty":     {in: "", msg: errMsg{err: fmt.Errorf("You must enter a URL, like https://example.com")}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+			cmd := validateURL(tc.in, &q)
+
+			got := cmd()
+
+			switch tc.msg.(type) {
+			case successMsg:
+				if tc.msg != got {
+					t.Fatalf("%s - want: \n'%+v' \ngot: \n'%+v'", tc.in, tc.msg, got)
+				}
+			case errMsg:
+				gotE, ok := got.(errMsg)
+				if !ok {
+					t.Fatalf("%s - want: an error got: \n'%+v'", tc.in, got)
+				}
+				tcmsgE := tc.msg.(errMsg)
+
+				if tcmsgE.err.Error() != gotE.err.Error() {
+					t.Fatalf("want: \n'%+v' \ngot: \n'%+v'", tcmsgE.err.Error(), gotE.err.Error())
+				}
+
+				assert.EqualError(t, ValidateCustom(validationURL, tc.in), tcmsgE.err.Error())
+			}
+		})
+	}
+}
+
 func TestValidateDomain(t *testing.T) {
 	tests := map[string]struct {
 		in  string
@@ -244,6 +447,7 @@ func TestValidateDomain(t *testing.T) {
 		"example2.com": {in: "example2.com", msg: errMsg{err: fmt.Errorf("validateDomain: not owned by requestor: %%!w(<nil>)")}},
 		"example3.com": {in: "example3.com", msg: successMsg{}},
 		"example4.com": {in: "example4.com", msg: successMsg{}},
+		"example6.com": {in: "example6.com", msg: successMsg{}},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -422,12 +626,13 @@ func TestCreateProject(t *testing.T) {
 
 func TestValidateGCEDefault(t *testing.T) {
 	tests := map[string]struct {
-		in       string
-		msg      tea.Msg
-		lenItems int
+		in          string
+		msg         tea.Msg
+		lenItems    int
+		replication string
 	}{
-		"donotdefault": {in: "n", msg: successMsg{}, lenItems: 12},
-		"default":      {in: "y", msg: successMsg{}, lenItems: 1},
+		"donotdefault": {in: "n", msg: successMsg{}, lenItems: 26},
+		"default":      {in: "y", msg: successMsg{}, lenItems: 2, replication: gcloud.DefaultDiskReplication},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -452,13 +657,42 @@ func TestValidateGCEDefault(t *testing.T) {
 				}
 			}
 
-			if tc.lenItems != len(q.models) {
+			asked := 0
+			for _, v := range q.models {
+				if !q.skipped(v.getKey()) {
+					asked++
+				}
+			}
+
+			if tc.lenItems != asked {
 				for _, v := range q.models {
-					t.Logf("%s %s", v.getKey(), "")
+					t.Logf("%s %t", v.getKey(), q.skipped(v.getKey()))
 				}
 
-				t.Fatalf("number of models want: '%d' got: '%d'", tc.lenItems, len(q.models))
+				t.Fatalf("number of models want: '%d' got: '%d'", tc.lenItems, asked)
 			}
+
+			assert.Equal(t, tc.replication, q.stack.GetSetting("instance-disk-replication"))
+		})
+	}
+}
+
+func TestValidateGCEDefaultMachineType(t *testing.T) {
+	tests := map[string]struct {
+		configured string
+		want       string
+	}{
+		"unset":      {want: gcloud.DefaultInstanceType},
+		"configured": {configured: "n2-standard-4", want: "n2-standard-4"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+			q.stack.Config.DefaultMachineType = tc.configured
+			newGCEInstance(&q)
+
+			assert.Equal(t, successMsg{}, validateGCEDefault("y", &q)())
+			assert.Equal(t, tc.want, q.stack.GetSetting("instance-machine-type"))
 		})
 	}
 }
@@ -512,6 +746,289 @@ func TestValidateGCEConfiguration(t *testing.T) {
 	}
 }
 
+func TestProcessImageProject(t *testing.T) {
+	tests := map[string]struct {
+		in   string
+		want string
+	}{
+		"ubuntu":  {in: "ubuntu-os-cloud", want: "ubuntu-2204-lts"},
+		"debian":  {in: "debian-cloud", want: "debian-12"},
+		"unknown": {in: "some-custom-images", want: ""},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+			newDiskImageManager(&q)
+
+			cmd := processImageProject(tc.in, &q)
+			got := cmd()
+
+			assert.Equal(t, successMsg{}, got)
+			assert.Equal(t, tc.want, q.Model("instance-image-family").(*picker).defaultValue)
+		})
+	}
+}
+
+func TestProcessImageSearch(t *testing.T) {
+	tests := map[string]struct {
+		in         string
+		wantFamily string
+		wantModels bool
+		wantErr    bool
+	}{
+		"byName": {
+			in:         "centos-cloud/centos-7-v20230203",
+			wantFamily: "centos-7",
+		},
+		"browse": {
+			in:         "browse",
+			wantModels: true,
+		},
+		"unknown": {
+			in:         "centos-cloud/centos-1-v19990101",
+			wantModels: true,
+			wantErr:    true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+			newDiskImageManager(&q)
+			q.stack.AddSetting("instance-image-project", "centos-cloud")
+			// Left over from pinning an older image before
+			q.stack.AddSetting(imagePinnedKey, "true")
+
+			got := processImageSearch(tc.in, &q)()
+
+			if tc.wantErr {
+				assert.Equal(t, "instance-image-search", got.(errMsg).target)
+			} else {
+				assert.Equal(t, successMsg{unset: true}, got)
+			}
+
+			assert.Equal(t, tc.wantFamily, q.stack.GetSetting("instance-image-family"))
+			if tc.wantFamily != "" {
+				assert.Equal(t, tc.in, q.stack.GetSetting("instance-image"))
+				// The latest image of the family was found, so it isn't pinned
+				assert.Equal(t, tc.in, q.Get("instance-image-latest"))
+				assert.Equal(t, "", q.stack.GetSetting(imagePinnedKey))
+			}
+
+			for _, key := range []string{"instance-image-family", "instance-image-architecture", "instance-image"} {
+				assert.Equal(t, tc.wantModels, !q.skipped(key), key)
+			}
+		})
+	}
+}
+
+func TestProcessImageFamily(t *testing.T) {
+	tests := map[string]struct {
+		family      string
+		machineType string
+		wantModel   bool
+		wantDefault string
+		wantErr     bool
+	}{
+		"bothX86": {
+			family:      "ubuntu-2204-lts",
+			machineType: "n2-standard-4",
+			wantModel:   true,
+			wantDefault: gcloud.ArchitectureX86,
+		},
+		"bothArm": {
+			family:      "ubuntu-2204-lts",
+			machineType: "t2a-standard-1",
+			wantModel:   true,
+			wantDefault: gcloud.ArchitectureArm,
+		},
+		"single": {
+			family:      "debian-11",
+			machineType: "n2-standard-4",
+			wantModel:   false,
+		},
+		"mismatch": {
+			family:      "debian-11-arm64",
+			machineType: "n2-standard-4",
+			wantModel:   true,
+			wantErr:     true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+			newDiskImageManager(&q)
+			q.stack.AddSetting("instance-machine-type", tc.machineType)
+			q.Save("instance-image-architecture", gcloud.ArchitectureArm)
+
+			got := processImageFamily(tc.family, &q)()
+			assert.Nil(t, q.Get("instance-image-architecture"))
+
+			if tc.wantErr {
+				assert.Equal(t, "instance-image-family", got.(errMsg).target)
+			} else {
+				assert.Equal(t, successMsg{}, got)
+			}
+
+			m := q.Model("instance-image-architecture")
+			assert.Equal(t, tc.wantModel, !q.skipped("instance-image-architecture"))
+			if tc.wantDefault != "" {
+				assert.Equal(t, tc.wantDefault, m.(*picker).defaultValue)
+			}
+		})
+	}
+}
+
+func TestValidateDiskType(t *testing.T) {
+	defer func(rules []gcloud.ImageDiskTypeRule) { gcloud.ImageDiskTypeRules = rules }(gcloud.ImageDiskTypeRules)
+	gcloud.ImageDiskTypeRules = []gcloud.ImageDiskTypeRule{
+		{ImageProject: "windows-cloud", Excluded: []string{"pd-standard"}, Reason: "test rule"},
+	}
+
+	tests := map[string]struct {
+		imageProject string
+		image        string
+		diskType     string
+		wantErr      bool
+	}{
+		"windowsStandard": {
+			imageProject: "windows-cloud",
+			image:        "windows-cloud/windows-server-2012-r2-dc-v20230112",
+			diskType:     "pd-standard",
+			wantErr:      true,
+		},
+		"windowsBalanced": {
+			imageProject: "windows-cloud",
+			image:        "windows-cloud/windows-server-2012-r2-dc-v20230112",
+			diskType:     "pd-balanced",
+		},
+		"centosStandard": {
+			imageProject: "centos-cloud",
+			image:        "centos-cloud/centos-7-v20230203",
+			diskType:     "pd-standard",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+			q.stack.AddSetting("instance-image-project", tc.imageProject)
+			q.stack.AddSetting("instance-image", tc.image)
+
+			got := validateDiskType(tc.diskType, &q)()
+
+			if tc.wantErr {
+				assert.Equal(t, "instance-disktype", got.(errMsg).target)
+				return
+			}
+			assert.Equal(t, successMsg{}, got)
+		})
+	}
+}
+
+func TestValidateImageArchitecture(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		wantErr bool
+	}{
+		"match":    {in: gcloud.ArchitectureX86},
+		"mismatch": {in: gcloud.ArchitectureArm, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+			q.stack.AddSetting("instance-machine-type", "n2-standard-4")
+
+			got := validateImageArchitecture(tc.in, &q)()
+
+			if tc.wantErr {
+				assert.Equal(t, "instance-image-architecture", got.(errMsg).target)
+				assert.Nil(t, q.Get("instance-image-architecture"))
+				return
+			}
+			assert.Equal(t, successMsg{}, got)
+			assert.Equal(t, tc.in, q.Get("instance-image-architecture"))
+		})
+	}
+}
+
+func TestProcessDiskReplication(t *testing.T) {
+	tests := map[string]struct {
+		in        string
+		wantModel bool
+	}{
+		"zonal":    {in: "zonal", wantModel: false},
+		"regional": {in: "regional", wantModel: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+			newDiskReplicationManager(&q)
+			q.stack.AddSettingComplete(config.Setting{
+				Name: "instance-disk-replica-zones",
+				Type: "list",
+				List: []string{"us-central1-a", "us-central1-b"},
+			})
+
+			cmd := processDiskReplication(tc.in, &q)
+			got := cmd()
+
+			assert.Equal(t, successMsg{}, got)
+			assert.Equal(t, tc.wantModel, !q.skipped("instance-disk-replica-zones"))
+			// Switching back to zonal must not leave the replica zones behind
+			assert.Equal(t, tc.wantModel, q.stack.Settings.Find("instance-disk-replica-zones") != nil)
+			assert.Nil(t, q.stack.Settings.Find("instance-disk-replica-zone"))
+		})
+	}
+}
+
+func TestValidateReplicaZone(t *testing.T) {
+	tests := map[string]struct {
+		in   string
+		msg  tea.Msg
+		want []string
+	}{
+		"valid": {
+			in:   "us-central1-b",
+			msg:  successMsg{},
+			want: []string{"us-central1-a", "us-central1-b"},
+		},
+		"otherregion": {
+			in: "us-east1-b",
+			msg: errMsg{
+				usermsg: "The replica zone must be in the same region as the instance",
+				err:     fmt.Errorf("validateReplicaZone: zone (us-east1-b) is not in region (us-central1)"),
+				target:  "instance-disk-replica-zones",
+			},
+		},
+		"samezone": {
+			in: "us-central1-a",
+			msg: errMsg{
+				usermsg: "The replica zone must be different from the instance zone",
+				err:     fmt.Errorf("validateReplicaZone: zone (us-central1-a) is already the instance zone"),
+				target:  "instance-disk-replica-zones",
+			},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+			q.stack.AddSetting("region", "us-central1")
+			q.stack.AddSetting("zone", "us-central1-a")
+
+			cmd := validateReplicaZone(tc.in, &q)
+			got := cmd()
+
+			assert.Equal(t, tc.msg, got)
+
+			set := q.stack.Settings.Find("instance-disk-replica-zones")
+			if tc.want == nil {
+				assert.Nil(t, set)
+				return
+			}
+			assert.Equal(t, tc.want, set.List)
+		})
+	}
+}
+
 func TestStackSelection(t *testing.T) {
 	tests := map[string]struct {
 		input string
@@ -564,3 +1081,797 @@ func TestHandleProjectNumbe(t *testing.T) {
 		})
 	}
 }
+
+func TestHandleServiceResults(t *testing.T) {
+	tests := map[string]struct {
+		in   string
+		want tea.Msg
+	}{
+		"continue": {in: "continue", want: successMsg{unset: true}},
+		"retry":    {in: "retry", want: successMsg{msg: "retry", unset: true}},
+		"abort": {in: "abort", want: errMsg{
+			err:     fmt.Errorf("handleServiceResults: required services could not be enabled"),
+			usermsg: "DeployStack cannot continue without the required APIs enabled.",
+			quit:    true,
+			target:  "quit",
+		}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+			q.Save("serviceResults", []serviceResult{})
+
+			got := handleServiceResults(tc.in, &q)()
+
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestProcessMachineTypeSearch(t *testing.T) {
+	tests := map[string]struct {
+		in               string
+		wantFamily       string
+		wantType         string
+		wantModels       bool
+		wantRequirements bool
+	}{
+		"n2-standard-4": {
+			in:         "n2-standard-4",
+			wantFamily: "n2-standard",
+			wantType:   "n2-standard-4",
+			wantModels: false,
+		},
+		"e2-micro": {
+			in:         "e2-micro",
+			wantFamily: "e2-micro",
+			wantType:   "e2-micro",
+		},
+		"e2-small": {
+			in:         "e2-small",
+			wantFamily: "e2-small",
+			wantType:   "e2-small",
+		},
+		"f1-micro": {
+			in:         "f1-micro",
+			wantFamily: "f1-micro",
+			wantType:   "f1-micro",
+		},
+		"g1-small": {
+			in:         "g1-small",
+			wantFamily: "g1-small",
+			wantType:   "g1-small",
+		},
+		"browse": {
+			in:         "browse",
+			wantModels: true,
+		},
+		"requirements": {
+			in:               machineTypeByRequirements,
+			wantRequirements: true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+			newMachineTypeManager(&q)
+
+			got := processMachineTypeSearch(tc.in, &q)()
+
+			assert.Equal(t, successMsg{unset: true}, got)
+			assert.Equal(t, tc.wantFamily, q.stack.GetSetting("instance-machine-type-family"))
+			assert.Equal(t, tc.wantType, q.stack.GetSetting("instance-machine-type"))
+			assert.Equal(t, tc.wantModels, !q.skipped("instance-machine-type-family"))
+			assert.Equal(t, tc.wantModels, !q.skipped("instance-machine-type"))
+			assert.Equal(t, tc.wantRequirements, !q.skipped("instance-min-cpus"))
+			assert.Equal(t, tc.wantRequirements, !q.skipped("instance-min-memory"))
+		})
+	}
+}
+
+func TestProcessMachineTypeSearchInvalid(t *testing.T) {
+	q := getTestQueue(appTitle, "test")
+	newMachineTypeManager(&q)
+
+	got := processMachineTypeSearch("standard", &q)()
+
+	assert.IsType(t, errMsg{}, got)
+	assert.Equal(t, "instance-machine-type-search", got.(errMsg).target)
+	assert.Equal(t, "", q.stack.GetSetting("instance-machine-type"))
+}
+
+func TestProcessMachineTypeRequirements(t *testing.T) {
+	tests := map[string]struct {
+		cpus       string
+		mem        string
+		wantFamily string
+		wantType   string
+		wantErr    bool
+	}{
+		"match":      {cpus: "8", mem: "32", wantFamily: "c2-standard", wantType: "c2-standard-8"},
+		"noFit":      {cpus: "1000", mem: "32", wantErr: true},
+		"badMemory":  {cpus: "2", mem: "lots", wantErr: true},
+		"zeroMemory": {cpus: "2", mem: "0", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+
+			assert.Equal(t, successMsg{}, processMinCPUs(tc.cpus, &q)())
+			got := processMachineTypeRequirements(tc.mem, &q)()
+
+			if tc.wantErr {
+				assert.IsType(t, errMsg{}, got)
+				assert.Equal(t, "", q.stack.GetSetting("instance-machine-type"))
+				return
+			}
+
+			assert.Equal(t, successMsg{}, got)
+			assert.Equal(t, tc.wantFamily, q.stack.GetSetting("instance-machine-type-family"))
+			assert.Equal(t, tc.wantType, q.stack.GetSetting("instance-machine-type"))
+		})
+	}
+}
+
+func TestMachineTypeFamily(t *testing.T) {
+	assert.Equal(t, "n2-standard", machineTypeFamily("n2-standard-4"))
+	assert.Equal(t, "e2-micro", machineTypeFamily("e2-micro"))
+	assert.Equal(t, customMachineFamily, machineTypeFamily("custom-8-32768"))
+}
+
+func TestProcessMinCPUs(t *testing.T) {
+	q := getTestQueue(appTitle, "test")
+
+	assert.IsType(t, errMsg{}, processMinCPUs("0", &q)())
+	assert.IsType(t, errMsg{}, processMinCPUs("many", &q)())
+	assert.Equal(t, successMsg{}, processMinCPUs("4", &q)())
+	assert.Equal(t, int64(4), q.Get("minCPUs"))
+}
+
+func TestMachineTypeDefaults(t *testing.T) {
+	tests := map[string]struct {
+		machineType string
+		family      string
+		wantFamily  string
+		wantType    string
+	}{
+		"available": {
+			machineType: "n2-standard-4",
+			wantFamily:  "n2-standard",
+			wantType:    "n2-standard-4",
+		},
+		"unavailable": {
+			machineType: "n9-standard-4",
+			wantFamily:  "n1-standard",
+			wantType:    gcloud.DefaultInstanceType,
+		},
+		"familyOnly": {
+			family:     "e2-medium",
+			wantFamily: "e2-medium",
+		},
+		"familyUnavailable": {
+			family:     "n9-standard",
+			wantFamily: "n1-standard",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+			q.stack.Config.DefaultMachineType = tc.machineType
+			q.stack.Config.DefaultMachineFamily = tc.family
+			newMachineTypeManager(&q)
+
+			got := processMachineTypeSearch("browse", &q)()
+
+			assert.Equal(t, successMsg{unset: true}, got)
+			assert.Equal(t, tc.wantFamily, q.Model("instance-machine-type-family").(*picker).defaultValue)
+			if tc.wantType != "" {
+				assert.Equal(t, tc.wantType, q.Model("instance-machine-type").(*picker).defaultValue)
+			}
+		})
+	}
+}
+
+func TestEnforceShieldedVM(t *testing.T) {
+	tests := map[string]struct {
+		policy map[string][]string
+		want   string
+	}{
+		"required":    {policy: map[string][]string{gcloud.OrgPolicyRequireShieldedVM: {"true"}}, want: "y"},
+		"notrequired": {policy: nil, want: ""},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+			m := GetMock(0)
+			m.orgPolicy = tc.policy
+			q.client = m
+
+			enforceShieldedVM(&q)
+
+			assert.Equal(t, tc.want, q.stack.GetSetting("instance-shielded-vm"))
+		})
+	}
+}
+
+func TestProcessShieldedVM(t *testing.T) {
+	tests := map[string]struct {
+		family    string
+		wantModel bool
+	}{
+		"supported":   {family: "n2d-standard", wantModel: true},
+		"unsupported": {family: "e2-standard", wantModel: false},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+			newShieldedVMManager(&q)
+			q.stack.AddSetting("instance-machine-type-family", tc.family)
+
+			got := processShieldedVM("y", &q)()
+
+			assert.Equal(t, successMsg{}, got)
+			assert.Equal(t, tc.wantModel, !q.skipped("instance-confidential-vm"))
+		})
+	}
+}
+
+func TestGCESecurityConfig(t *testing.T) {
+	tests := map[string]struct {
+		shielded     string
+		confidential string
+		want         config.Settings
+	}{
+		"shielded": {
+			shielded:     "y",
+			confidential: "n",
+			want: config.Settings{
+				{Name: "instance-shielded-instance-config", Type: "map", Map: map[string]string{
+					"enable_secure_boot":          "true",
+					"enable_vtpm":                 "true",
+					"enable_integrity_monitoring": "true",
+				}},
+			},
+		},
+		"confidential": {
+			shielded:     "n",
+			confidential: "y",
+			want: config.Settings{
+				{Name: "instance-confidential-instance-config", Type: "map", Map: map[string]string{
+					"enable_confidential_compute": "true",
+				}},
+			},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+			q.stack.Settings = config.Settings{}
+			q.stack.AddSetting("instance-shielded-vm", tc.shielded)
+			q.stack.AddSetting("instance-confidential-vm", tc.confidential)
+
+			gceSecurityConfig(&q)
+
+			assert.Equal(t, tc.want, q.stack.Settings)
+		})
+	}
+}
+
+func TestStoreSecret(t *testing.T) {
+	q := getTestQueue(appTitle, "test")
+	p := newPicker("Pick a secret", "", "db_password", "", getSecrets(&q))
+	q.add(&p)
+
+	got := storeSecret("projects/ds-test-secrets/secrets/db-password", &q)()
+
+	assert.Equal(t, successMsg{unset: true}, got)
+
+	setting := q.stack.Settings.Find("db_password")
+	assert.Equal(t, "secret", setting.Type)
+	assert.Equal(t, "projects/ds-test-secrets/secrets/db-password", setting.Value)
+	assert.Contains(t, q.stack.Terraform(), `db_password="projects/ds-test-secrets/secrets/db-password"`)
+}
+
+func TestProcessReuseOrNew(t *testing.T) {
+	tests := map[string]struct {
+		in   string
+		want string
+	}{
+		"existing": {in: "34.1.2.3", want: "34.1.2.3"},
+		"new":      {in: newResourceValue, want: ""},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+			p := newPicker("Pick a static IP", "", "web_ip", "", getAddresses(&q))
+			q.add(&p)
+
+			got := processReuseOrNew(tc.in, &q)()
+
+			assert.Equal(t, successMsg{unset: true}, got)
+			assert.Equal(t, tc.want, q.stack.GetSetting("web_ip"))
+		})
+	}
+}
+
+func TestValidateCustom(t *testing.T) {
+	tests := map[string]struct {
+		validation string
+		input      string
+		wantErr    bool
+	}{
+		"integer":        {validation: validationInteger, input: "12"},
+		"integerBad":     {validation: validationInteger, input: "twelve", wantErr: true},
+		"yesorno":        {validation: validationYesOrNo, input: "Y"},
+		"yesornoBad":     {validation: validationYesOrNo, input: "maybe", wantErr: true},
+		"phonenumber":    {validation: validationPhoneNumber, input: "800 555 1234"},
+		"phonenumberBad": {validation: validationPhoneNumber, input: "not a number", wantErr: true},
+		"none":           {validation: "", input: "anything"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := ValidateCustom(tc.validation, tc.input)
+			if tc.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+		})
+	}
+}
+
+func TestValidateOverrides(t *testing.T) {
+	tests := map[string]struct {
+		nodes   string
+		wantErr bool
+	}{
+		"valid":      {nodes: "3"},
+		"notInteger": {nodes: "three", wantErr: true},
+		"tooMany":    {nodes: "12", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("DSTEST_NODES", tc.nodes)
+
+			limit := 5
+			s := config.NewStack()
+			s.Config.CustomSettings = config.Customs{
+				{Name: "nodes", Default: "3", Validation: validationInteger, Max: &limit},
+				{Name: "email", Validation: validationEmail},
+			}
+			s.ApplyEnvOverrides("DSTEST_")
+
+			err := ValidateOverrides(&s)
+			if tc.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+		})
+	}
+}
+
+func TestProcessCostLabels(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		want    map[string]string
+		wantErr bool
+	}{
+		"labels":     {in: "team=data, env=prod", want: map[string]string{"team": "data", "env": "prod"}},
+		"emptyValue": {in: "team=", want: map[string]string{"team": ""}},
+		"none":       {in: "none"},
+		"badKey":     {in: "Team=data", wantErr: true},
+		"badValue":   {in: "team=Data Science", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+
+			got := processCostLabels(tc.in, &q)()
+
+			if tc.wantErr {
+				assert.IsType(t, errMsg{}, got)
+				assert.Nil(t, q.stack.Settings.Find(costLabelsKey))
+				return
+			}
+
+			assert.Equal(t, successMsg{unset: true}, got)
+
+			s := q.stack.Settings.Find(costLabelsKey)
+			if tc.want == nil {
+				assert.Nil(t, s)
+				return
+			}
+			assert.Equal(t, tc.want, s.Map)
+		})
+	}
+}
+
+func TestGCECostLabels(t *testing.T) {
+	q := getTestQueue(appTitle, "test")
+
+	processCostLabels("team=data,env=prod", &q)()
+	gceCostLabels(&q)
+
+	tf := q.stack.Terraform()
+	for _, v := range []string{"cost_labels", "instance-labels", "instance-disk-labels"} {
+		assert.Contains(t, tf, fmt.Sprintf("%s=%s\n", v, `{env="prod",team="data"}`))
+	}
+}
+
+func TestCostLabelsProject(t *testing.T) {
+	key := "project_id"
+
+	q := getTestQueue(appTitle, "test")
+	m := GetMock(0)
+	m.projectLabels = map[string]map[string]string{}
+	q.client = m
+
+	processCostLabels("team=data,env=prod", &q)()
+
+	c := newProjectCreator(key + projNewSuffix)
+	q.add(&c)
+	q.Save(key+parentNewSuffix, &cloudresourcemanager.ResourceId{})
+	q.goToModel(key + projNewSuffix)
+
+	got := createProject("ds-labeled", &q)()
+	assert.Equal(t, successMsg{}, got)
+	assert.Equal(t, map[string]string{"team": "data", "env": "prod"}, m.projectLabels["ds-labeled"])
+}
+
+func TestProjectLabelsFromConfig(t *testing.T) {
+	key := "project_id"
+
+	q := getTestQueue(appTitle, "test")
+	q.stack.Config.ProjectLabels = map[string]string{"stack": "gce", "team": "web"}
+	m := GetMock(0)
+	m.projectLabels = map[string]map[string]string{}
+	q.client = m
+
+	processCostLabels("team=data", &q)()
+
+	c := newProjectCreator(key + projNewSuffix)
+	q.add(&c)
+	q.Save(key+parentNewSuffix, &cloudresourcemanager.ResourceId{})
+	q.goToModel(key + projNewSuffix)
+
+	got := createProject("ds-labeled", &q)()
+	assert.Equal(t, successMsg{}, got)
+	assert.Equal(t, map[string]string{"stack": "gce", "team": "data"}, m.projectLabels["ds-labeled"])
+	assert.Equal(t, map[string]string{"stack": "gce", "team": "web"}, q.stack.Config.ProjectLabels)
+}
+
+func TestProcessZone(t *testing.T) {
+	q := getTestQueue(appTitle, "test")
+
+	for _, zone := range []string{"us-central1-a", "us-central1-b"} {
+		got := processZone(zone, &q)()
+		assert.Equal(t, successMsg{}, got)
+		assert.Equal(t, zone, q.Get("currentZone"))
+	}
+}
+
+func TestProcessZonePrefetch(t *testing.T) {
+	project := "ds-prefetch"
+	zone := "us-central1-a"
+
+	q := getTestQueue(appTitle, "test")
+	q.stack.Config.ConfigureGCEInstance = true
+	imageProject := diskProjects(q.stack).GetDefault().Value
+	q.stack.AddSetting("project_id", project)
+
+	got := processZone(zone, &q)()
+	assert.Equal(t, successMsg{}, got)
+
+	q.prefetch.wait()
+	assert.True(t, q.prefetch.cached(machineTypesKey(project, zone)))
+	assert.True(t, q.prefetch.cached(imagesKey(project, imageProject)))
+
+	types, err := q.machineTypeList(project, zone)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, types.Items)
+
+	images, err := q.imageList(project, imageProject)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, images.Items)
+
+	processZone("us-central1-b", &q)()
+	q.prefetch.wait()
+	assert.False(t, q.prefetch.cached(machineTypesKey(project, zone)))
+	assert.True(t, q.prefetch.cached(machineTypesKey(project, "us-central1-b")))
+}
+
+func TestProcessImagePin(t *testing.T) {
+	latest := "centos-cloud/centos-7-v20230203"
+	tests := map[string]struct {
+		in         string
+		prepinned  bool
+		wantPinned bool
+	}{
+		"latest":       {in: latest},
+		"older":        {in: "centos-cloud/centos-7-v20221206", wantPinned: true},
+		"backToLatest": {in: latest, prepinned: true},
+		"olderAgain":   {in: "centos-cloud/centos-7-v20221206", prepinned: true, wantPinned: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+			q.stack.AddSetting("instance-image-project", "centos-cloud")
+			q.stack.AddSetting("instance-image-family", "centos-7")
+			if tc.prepinned {
+				q.stack.AddSetting(imagePinnedKey, "true")
+				q.stack.AddSetting(imageResolvedAtKey, "2023-01-01T00:00:00Z")
+			}
+
+			getImageDisks(&q)()
+			assert.Equal(t, latest, q.Get("instance-image-latest"))
+
+			before := time.Now().UTC().Truncate(time.Second)
+			got := processImagePin(tc.in, &q)()
+			assert.Equal(t, successMsg{}, got)
+
+			if !tc.wantPinned {
+				assert.Equal(t, "", q.stack.GetSetting(imagePinnedKey))
+				assert.Equal(t, "", q.stack.GetSetting(imageResolvedAtKey))
+				return
+			}
+
+			assert.Equal(t, "true", q.stack.GetSetting(imagePinnedKey))
+			resolved, err := time.Parse(time.RFC3339, q.stack.GetSetting(imageResolvedAtKey))
+			if err != nil {
+				t.Fatalf("expected: RFC3339 timestamp, got: %s", err)
+			}
+			assert.False(t, resolved.Before(before), "resolved at %s is before %s", resolved, before)
+		})
+	}
+}
+
+func TestProcessSoleTenant(t *testing.T) {
+	tests := map[string]struct {
+		in        string
+		zone      string
+		msg       tea.Msg
+		wantModel bool
+	}{
+		"no":  {in: "n", zone: "us-central1-a", msg: successMsg{}, wantModel: false},
+		"yes": {in: "y", zone: "us-central1-a", msg: successMsg{}, wantModel: true},
+		"noNodeTypes": {
+			in:   "y",
+			zone: "us-west4-c",
+			msg: errMsg{
+				usermsg: "Zone us-west4-c has no sole-tenant nodes, pick No or go back and pick another zone",
+				err:     fmt.Errorf("processSoleTenant: zone (us-west4-c) has no node types"),
+				target:  "instance-sole-tenant",
+			},
+			wantModel: true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+			q.stack.AddSetting("zone", tc.zone)
+			newSoleTenantManager(&q)
+
+			got := processSoleTenant(tc.in, &q)()
+
+			assert.Equal(t, tc.msg, got)
+			assert.Equal(t, tc.wantModel, !q.skipped("instance-node-type"))
+		})
+	}
+}
+
+func TestProcessNodeType(t *testing.T) {
+	q := getTestQueue(appTitle, "test")
+
+	got := processNodeType("n1-node-96-624", &q)()
+	assert.Equal(t, successMsg{}, got)
+
+	s := q.stack.Settings.Find("instance-node-affinity")
+	if assert.NotNil(t, s) {
+		assert.Equal(t, map[string]string{
+			"key":      gcloud.NodeAffinityKey,
+			"operator": "IN",
+		}, s.Map)
+	}
+
+	values := q.stack.Settings.Find("instance-node-affinity-values")
+	if assert.NotNil(t, values) {
+		assert.Equal(t, []string{"n1-node-96-624"}, values.List)
+	}
+
+	tf := q.stack.Terraform()
+	assert.Contains(t, tf, `instance-node-affinity={key="compute.googleapis.com/node-type",operator="IN"}`)
+	assert.Contains(t, tf, `instance-node-affinity-values=["n1-node-96-624"]`)
+}
+
+func TestProcessMachineTypeFamily(t *testing.T) {
+	tests := map[string]struct {
+		in     string
+		custom bool
+	}{
+		"predefined": {in: "n1-standard", custom: false},
+		"custom":     {in: customMachineFamily, custom: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+			newMachineTypeManager(&q)
+
+			got := processMachineTypeFamily(tc.in, &q)()
+
+			assert.Equal(t, successMsg{}, got)
+			assert.Equal(t, tc.custom, !q.skipped("instance-custom-cpus"))
+			assert.Equal(t, tc.custom, !q.skipped("instance-custom-memory"))
+			assert.Equal(t, !tc.custom, !q.skipped("instance-machine-type"))
+		})
+	}
+}
+
+func TestProcessCustomMachineType(t *testing.T) {
+	tests := map[string]struct {
+		cpus    string
+		mem     string
+		want    string
+		wantErr bool
+	}{
+		"valid":      {cpus: "4", mem: "8192", want: "custom-4-8192"},
+		"oddCPUs":    {cpus: "3", mem: "6144", wantErr: true},
+		"tooMuchMem": {cpus: "2", mem: "16384", wantErr: true},
+		"notANumber": {cpus: "2", mem: "lots", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+
+			assert.Equal(t, successMsg{}, processCustomCPUs(tc.cpus, &q)())
+			got := processCustomMachineType(tc.mem, &q)()
+
+			if tc.wantErr {
+				if assert.IsType(t, errMsg{}, got) {
+					assert.Equal(t, "instance-custom-memory", got.(errMsg).target)
+				}
+				assert.Equal(t, "", q.stack.GetSetting("instance-machine-type"))
+				return
+			}
+
+			assert.Equal(t, successMsg{}, got)
+			assert.Equal(t, tc.want, q.stack.GetSetting("instance-machine-type"))
+		})
+	}
+}
+
+func TestProcessQuota(t *testing.T) {
+	tests := map[string]struct {
+		input    string
+		quotaErr error
+		readOnly bool
+		want     string
+		err      error
+	}{
+		"continue":     {input: quotaContinueAction},
+		"request":      {input: quotaRequestAction},
+		"noPermission": {input: quotaRequestAction, quotaErr: gcloud.ErrorQuotaNoPermission, want: "You don't have permission to request quota increases in this project. Ask a project owner to request it, or continue without it."},
+		"unsupported":  {input: quotaRequestAction, quotaErr: gcloud.ErrorQuotaUnsupported, want: "This quota can't be increased from here. Request it from the Quotas page of the Cloud Console, or continue without it."},
+		"readOnly":     {input: quotaRequestAction, readOnly: true, err: gcloud.ErrReadOnly},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+			q.client = mock{quotaErr: tc.quotaErr, readOnly: tc.readOnly}
+			q.Save("quotaRequest", quotaRequest{region: "us-central1", metric: "compute.googleapis.com/cpus", requested: 28})
+
+			got := processQuota(tc.input, &q)()
+
+			if tc.quotaErr == nil && tc.err == nil {
+				assert.Equal(t, successMsg{}, got)
+				return
+			}
+
+			if assert.IsType(t, errMsg{}, got) {
+				e := got.(errMsg)
+				assert.Equal(t, "instance-quota", e.target)
+				assert.Equal(t, tc.want, e.usermsg)
+				if tc.err != nil {
+					assert.ErrorIs(t, e.err, tc.err)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessScheduling(t *testing.T) {
+	tests := map[string]struct {
+		model       string
+		maintenance string
+		restart     string
+		target      string
+		want        string
+	}{
+		"standard": {
+			model:       gcloud.ProvisioningStandard,
+			maintenance: gcloud.HostMaintenanceMigrate,
+			restart:     "y",
+			want:        `instance-scheduling={automatic_restart="true",on_host_maintenance="MIGRATE",preemptible="false",provisioning_model="STANDARD"}`,
+		},
+		"spot": {
+			model:       gcloud.ProvisioningSpot,
+			maintenance: gcloud.HostMaintenanceTerminate,
+			restart:     "n",
+			want:        `instance-scheduling={automatic_restart="false",on_host_maintenance="TERMINATE",preemptible="true",provisioning_model="SPOT"}`,
+		},
+		"spotMigrate": {
+			model:       gcloud.ProvisioningSpot,
+			maintenance: gcloud.HostMaintenanceMigrate,
+			target:      "instance-on-host-maintenance",
+		},
+		"spotRestart": {
+			model:       gcloud.ProvisioningSpot,
+			maintenance: gcloud.HostMaintenanceTerminate,
+			restart:     "y",
+			target:      "instance-automatic-restart",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+			newSchedulingManager(&q)
+
+			assert.Equal(t, successMsg{}, processProvisioningModel(tc.model, &q)())
+
+			got := validateHostMaintenance(tc.maintenance, &q)()
+			if tc.target == "instance-on-host-maintenance" {
+				assert.Equal(t, tc.target, got.(errMsg).target)
+				assert.ErrorIs(t, got.(errMsg).err, gcloud.ErrorScheduling)
+				return
+			}
+			assert.Equal(t, successMsg{}, got)
+
+			got = processScheduling(tc.restart, &q)()
+			if tc.target != "" {
+				assert.Equal(t, tc.target, got.(errMsg).target)
+				assert.ErrorIs(t, got.(errMsg).err, gcloud.ErrorScheduling)
+				assert.Equal(t, "", q.stack.GetSetting("instance-scheduling"))
+				return
+			}
+			assert.Equal(t, successMsg{}, got)
+
+			assert.Contains(t, q.stack.Terraform(), tc.want+"\n")
+		})
+	}
+}
+
+func TestSchedulingAccelerator(t *testing.T) {
+	q := getTestQueue(appTitle, "test")
+	newSchedulingManager(&q)
+	q.stack.AddSetting("instance-accelerator-type", "nvidia-l4")
+
+	// Migrating isn't asked about with an accelerator attached
+	assert.Equal(t, successMsg{}, processProvisioningModel(gcloud.ProvisioningStandard, &q)())
+	assert.True(t, q.skipped("instance-on-host-maintenance"))
+	assert.Equal(t, gcloud.HostMaintenanceTerminate, q.Get("instance-on-host-maintenance"))
+
+	got := validateHostMaintenance(gcloud.HostMaintenanceMigrate, &q)()
+	assert.Equal(t, "instance-on-host-maintenance", got.(errMsg).target)
+	assert.ErrorIs(t, got.(errMsg).err, gcloud.ErrorScheduling)
+
+	assert.Equal(t, successMsg{}, processScheduling("y", &q)())
+	assert.Contains(t, q.stack.Terraform(), "on_host_maintenance=\"TERMINATE\"")
+}
+
+func TestProcessProvisioningModelDefaults(t *testing.T) {
+	q := getTestQueue(appTitle, "test")
+	newSchedulingManager(&q)
+
+	processProvisioningModel(gcloud.ProvisioningSpot, &q)()
+	assert.Equal(t, gcloud.HostMaintenanceTerminate, q.Model("instance-on-host-maintenance").(*picker).defaultValue)
+	assert.Equal(t, "n", q.Model("instance-automatic-restart").(*picker).defaultValue)
+
+	processProvisioningModel(gcloud.ProvisioningStandard, &q)()
+	assert.Equal(t, gcloud.HostMaintenanceMigrate, q.Model("instance-on-host-maintenance").(*picker).defaultValue)
+	assert.Equal(t, "", q.Model("instance-automatic-restart").(*picker).defaultValue)
+}
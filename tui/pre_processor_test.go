@@ -16,9 +16,11 @@ package tui
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/GoogleCloudPlatform/deploystack/config"
+	"github.com/GoogleCloudPlatform/deploystack/gcloud"
 	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/stretchr/testify/assert"
@@ -38,10 +40,163 @@ func TestPreprocessors(t *testing.T) {
 	}{
 		"getDiskTypes": {
 			f:        getDiskTypes,
+			count:    5,
+			label1st: "Standard",
+			value1st: "pd-standard",
+			settings: map[string]string{"zone": "asia-east1-a"},
+		},
+		"getDiskTypesFallback": {
+			f:        getDiskTypes,
+			throw:    true,
 			count:    3,
 			label1st: "Standard",
 			value1st: "pd-standard",
 		},
+		"getDiskReplicationTypes": {
+			f:        getDiskReplicationTypes,
+			count:    2,
+			label1st: "Zonal",
+			value1st: "zonal",
+		},
+		"getReplicaZones": {
+			f:        getReplicaZones,
+			count:    2,
+			label1st: "asia-east1-b",
+			value1st: "asia-east1-b",
+			settings: map[string]string{"region": "asia-east1", "zone": "asia-east1-a"},
+		},
+		"getReplicaZonesError": {
+			f:      getReplicaZones,
+			throw:  true,
+			errmsg: errMsg{err: errForced},
+		},
+		"getAllMachineTypes": {
+			f:        getAllMachineTypes,
+			count:    166,
+			label1st: "Browse machine types by family",
+			value1st: "browse",
+		},
+		"getSecrets": {
+			f:        getSecrets,
+			count:    2,
+			label1st: "api-key",
+			value1st: "projects/ds-test-secrets/secrets/api-key",
+			settings: map[string]string{"project_id": "ds-test-secrets"},
+		},
+		"getSecretsError": {
+			f:      getSecrets,
+			throw:  true,
+			errmsg: errMsg{err: errForced},
+		},
+		"getAddresses": {
+			f:        getAddresses,
+			count:    3,
+			label1st: "Reserve a new static IP address",
+			value1st: newResourceValue,
+			settings: map[string]string{"project_id": "ds-test", "region": "us-central1"},
+		},
+		"getAddressesError": {
+			f:      getAddresses,
+			throw:  true,
+			errmsg: errMsg{err: errForced},
+		},
+		"getNetworks": {
+			f:        getNetworks,
+			count:    2,
+			label1st: "default",
+			value1st: "default",
+		},
+		"getNetworksError": {
+			f:      getNetworks,
+			throw:  true,
+			errmsg: errMsg{err: errForced},
+		},
+		"getSubnets": {
+			f:        getSubnets,
+			count:    2,
+			label1st: "db (10.0.1.0/24)",
+			value1st: "db",
+			settings: map[string]string{"region": "us-central1", "instance-network": "prod"},
+		},
+		"getSubnetsOtherRegion": {
+			f:        getSubnets,
+			count:    1,
+			label1st: "web-eu (10.2.0.0/24)",
+			value1st: "web-eu",
+			settings: map[string]string{"region": "europe-west1", "instance-network": "prod"},
+		},
+		"getSubnetsNone": {
+			f:        getSubnets,
+			settings: map[string]string{"region": "us-east1", "instance-network": "prod"},
+			errmsg: errMsg{
+				usermsg: "Network prod has no subnets in region us-east1, go back and pick another network or region",
+				err:     fmt.Errorf("getSubnets: network (prod) has no subnets in region (us-east1)"),
+			},
+		},
+		"getSecondaryRanges": {
+			f:        getSecondaryRanges,
+			count:    2,
+			label1st: "pods (10.8.0.0/14)",
+			value1st: "pods",
+			settings: map[string]string{"region": "us-central1", "instance-subnet": "db"},
+		},
+		"getSecondaryRangesError": {
+			f:      getSecondaryRanges,
+			throw:  true,
+			errmsg: errMsg{err: errForced},
+		},
+		"getAccelerators": {
+			f:        getAccelerators,
+			count:    2,
+			label1st: "NVIDIA L4",
+			value1st: "nvidia-l4",
+			settings: map[string]string{"project_id": "ds-test", "zone": "us-central1-a"},
+		},
+		"getAcceleratorsNoZone": {
+			f:        getAccelerators,
+			settings: map[string]string{"project_id": "ds-test"},
+			errmsg:   errMsg{err: gcloud.ErrorZoneRequired},
+		},
+		"getAcceleratorsNone": {
+			f:        getAccelerators,
+			settings: map[string]string{"project_id": "ds-test", "zone": "us-west4-c"},
+			errmsg: errMsg{
+				usermsg: "There are no accelerators in zone us-west4-c, go back and pick another zone",
+				err:     fmt.Errorf("getAccelerators: zone (us-west4-c) has no accelerator types"),
+			},
+		},
+		"getAcceleratorsRegion": {
+			f:        getAccelerators,
+			count:    2,
+			label1st: "NVIDIA L4",
+			value1st: "nvidia-l4",
+			settings: map[string]string{"project_id": "ds-test", "region": "us-central1"},
+		},
+		"getAcceleratorsRegionNone": {
+			f:        getAccelerators,
+			settings: map[string]string{"project_id": "ds-test", "region": "us-west4"},
+			errmsg: errMsg{
+				usermsg: "There are no accelerators in region us-west4, go back and pick another region",
+				err:     fmt.Errorf("getAccelerators: region (us-west4) has no accelerator types"),
+			},
+		},
+		"getAcceleratorsError": {
+			f:      getAccelerators,
+			throw:  true,
+			errmsg: errMsg{err: errForced},
+		},
+		"getDNSZones": {
+			f:        getDNSZones,
+			count:    3,
+			label1st: "Create a new managed zone",
+			value1st: newResourceValue,
+			settings: map[string]string{"project_id": "ds-test"},
+		},
+		"getDNSZonesError": {
+			f:      getDNSZones,
+			throw:  true,
+			errmsg: errMsg{err: errForced},
+		},
 		"getYesOrNo": {
 			f:        getYesOrNo,
 			count:    2,
@@ -87,13 +242,13 @@ func TestPreprocessors(t *testing.T) {
 		"getRegions": {
 			f:        getRegions,
 			count:    35,
-			label1st: "asia-east1",
+			label1st: "Taiwan (asia-east1)",
 			value1st: "asia-east1",
 		},
 		"getRegionsError": {
 			f:        getRegions,
 			count:    35,
-			label1st: "asia-east1",
+			label1st: "Taiwan (asia-east1)",
 			value1st: "asia-east1",
 			throw:    true,
 			errmsg:   errMsg{err: errForced},
@@ -106,6 +261,23 @@ func TestPreprocessors(t *testing.T) {
 			value1st: "asia-east1-a",
 			settings: map[string]string{"region": "asia-east1"},
 		},
+		"getZonesAccelerator": {
+			f:        getZones,
+			count:    1,
+			label1st: "asia-east1-a",
+			value1st: "asia-east1-a",
+			settings: map[string]string{
+				"region":                    "asia-east1",
+				"instance-accelerator-type": "nvidia-tesla-t4",
+			},
+		},
+		"getImageArchitectures": {
+			f:        getImageArchitectures,
+			count:    2,
+			label1st: "ARM64",
+			value1st: "ARM64",
+			settings: map[string]string{"instance-image-family": "ubuntu-2204-lts"},
+		},
 		"getZonesError": {
 			f:        getZones,
 			count:    3,
@@ -117,17 +289,17 @@ func TestPreprocessors(t *testing.T) {
 
 		"getMachineTypeFamilies": {
 			f:        getMachineTypeFamilies,
-			count:    34,
-			label1st: "a2 highgpu",
-			value1st: "a2-highgpu",
+			count:    35,
+			label1st: "Custom machine type",
+			value1st: customMachineFamily,
 			settings: map[string]string{"zone": "asia-east1-b"},
 		},
 
 		"getMachineTypeFamiliesError": {
 			f:        getMachineTypeFamilies,
-			count:    34,
-			label1st: "a2 highgpu",
-			value1st: "a2-highgpu",
+			count:    35,
+			label1st: "Custom machine type",
+			value1st: customMachineFamily,
 			throw:    true,
 			errmsg:   errMsg{err: errForced},
 		},
@@ -196,6 +368,20 @@ func TestPreprocessors(t *testing.T) {
 			errmsg:   errMsg{err: errForced},
 		},
 
+		"getStacks": {
+			f:        getStacks,
+			count:    2,
+			settings: map[string]string{},
+			label1st: "Minimal JSON",
+			value1st: "/minimaljson",
+			cache: map[string]interface{}{
+				"stacks": []config.StackInfo{
+					{Name: "minimaljson", Title: "Minimal JSON", Path: fmt.Sprintf("%s/minimaljson", testdata)},
+					{Name: "minimal-yaml", Path: fmt.Sprintf("%s/minimalyaml", testdata)},
+				},
+			},
+		},
+
 		"handleReports": {
 			f:        handleReports,
 			count:    2,
@@ -262,6 +448,32 @@ func TestPreprocessors(t *testing.T) {
 	}
 }
 
+func TestGetMachineTypesWithoutPrices(t *testing.T) {
+	q := getTestQueue(appTitle, "test")
+	q.client = mock{pricingErr: errForced}
+	q.stack.AddSetting("zone", "us-central1-a")
+	q.stack.AddSetting("instance-machine-type-family", "n2-standard")
+	events := q.Events()
+
+	// The machine types are still listed, without prices
+	items := getMachineTypes(&q)().([]list.Item)
+	assert.NotEmpty(t, items)
+
+	e := <-events
+	assert.Equal(t, EventErrorOccurred, e.Type)
+	assert.Equal(t, "instance-machine-type", e.Key)
+	assert.ErrorIs(t, e.Err, errForced)
+}
+
+func TestGetSecondaryRangesNone(t *testing.T) {
+	q := getTestQueue(appTitle, "test")
+	q.stack.AddSetting("region", "us-central1")
+	q.stack.AddSetting("instance-subnet", "default")
+
+	// Nothing to pick, so the step skips itself without a setting
+	assert.Equal(t, successMsg{unset: true}, getSecondaryRanges(&q)())
+}
+
 func TestCleanUp(t *testing.T) {
 
 	tests := map[string]struct {
@@ -293,3 +505,374 @@ func TestCleanUp(t *testing.T) {
 		})
 	}
 }
+
+func TestEnableServices(t *testing.T) {
+	tests := map[string]struct {
+		services []string
+		disabled map[string]bool
+		failed   map[string]bool
+		want     []serviceResult
+		items    []list.Item
+	}{
+		"allgood": {
+			services: []string{"compute", "run"},
+			disabled: map[string]bool{"run.googleapis.com": true},
+			want: []serviceResult{
+				{service: "compute.googleapis.com", status: serviceStatusAlreadyOn},
+				{service: "run.googleapis.com", status: serviceStatusEnabled},
+			},
+			items: []list.Item{item{"Continue", "continue"}},
+		},
+		"mixed": {
+			services: []string{"compute", "run", "vault", "notreal"},
+			disabled: map[string]bool{"run.googleapis.com": true},
+			failed:   map[string]bool{"vault.googleapis.com": true},
+			want: []serviceResult{
+				{service: "compute.googleapis.com", status: serviceStatusAlreadyOn},
+				{
+					service: "notreal.googleapis.com",
+					status:  serviceStatusFailed,
+					err:     fmt.Errorf("%w: %s", gcloud.ErrorServiceUnknown, "notreal.googleapis.com"),
+				},
+				{service: "run.googleapis.com", status: serviceStatusEnabled},
+				{service: "vault.googleapis.com", status: serviceStatusFailed, err: errForced},
+			},
+			items: []list.Item{item{"Retry", "retry"}, item{"Abort", "abort"}},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+			m := GetMock(0)
+			m.disabledServices = tc.disabled
+			m.failedServices = tc.failed
+			q.client = m
+			q.stack.Config.Services = tc.services
+
+			got := enableServices(&q)()
+
+			assert.Equal(t, tc.items, got)
+			assert.Equal(t, tc.want, q.Get("serviceResults"))
+
+			rendered := newServiceResults(&q).render()
+			for _, v := range tc.want {
+				assert.Contains(t, rendered, v.service)
+			}
+		})
+	}
+}
+
+func TestGetRegionsOrgPolicy(t *testing.T) {
+	tests := map[string]struct {
+		policy map[string][]string
+		want   []string
+	}{
+		"restricted": {
+			policy: map[string][]string{
+				gcloud.OrgPolicyResourceLocations: {"in:europe-west1-locations", "us-east4"},
+			},
+			want: []string{"europe-west1", "us-east4"},
+		},
+		"group": {
+			policy: map[string][]string{
+				gcloud.OrgPolicyResourceLocations: {"in:australia-locations"},
+			},
+			want: []string{"australia-southeast1", "australia-southeast2"},
+		},
+		"denied": {
+			policy: map[string][]string{
+				gcloud.OrgPolicyResourceLocations:                         {"in:australia-locations"},
+				gcloud.OrgPolicyDenied(gcloud.OrgPolicyResourceLocations): {"in:australia-southeast2-locations"},
+			},
+			want: []string{"australia-southeast1"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+			m := GetMock(0)
+			m.orgPolicy = tc.policy
+			q.client = m
+
+			raw := getRegions(&q)()
+
+			got := []string{}
+			for _, v := range raw.([]list.Item) {
+				got = append(got, v.(item).value)
+			}
+
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestGetDiskProjectsConfig(t *testing.T) {
+	private := config.ImageProject{Value: "my-private-images", Label: "Private Images", Default: true}
+	tests := map[string]struct {
+		projects    config.ImageProjects
+		append      bool
+		count       int
+		label1st    string
+		wantDefault string
+	}{
+		"none": {
+			count:       len(gcloud.DiskProjects),
+			label1st:    "CentOS",
+			wantDefault: "debian-cloud",
+		},
+		"replace": {
+			projects: config.ImageProjects{
+				{Value: "ubuntu-os-cloud", Label: "Ubuntu"},
+				private,
+			},
+			count:       2,
+			label1st:    "Ubuntu",
+			wantDefault: "my-private-images",
+		},
+		"append": {
+			projects:    config.ImageProjects{private},
+			append:      true,
+			count:       len(gcloud.DiskProjects) + 1,
+			label1st:    "CentOS",
+			wantDefault: "my-private-images",
+		},
+		"appendNoDefault": {
+			projects:    config.ImageProjects{{Value: "my-private-images"}},
+			append:      true,
+			count:       len(gcloud.DiskProjects) + 1,
+			label1st:    "CentOS",
+			wantDefault: "debian-cloud",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+			q.stack.Config.ImageProjects = tc.projects
+			q.stack.Config.ImageProjectsAppend = tc.append
+
+			raw := getDiskProjects(&q)()
+			got := raw.([]list.Item)
+
+			assert.Len(t, got, tc.count)
+			assert.Equal(t, tc.label1st, got[0].(item).label)
+
+			newDiskImageManager(&q)
+			p := q.Model("instance-image-project").(*picker)
+			assert.Equal(t, tc.wantDefault, p.defaultValue)
+		})
+	}
+}
+
+func TestGetRegionsNoRegions(t *testing.T) {
+	q := getTestQueue(appTitle, "test")
+	m := GetMock(0)
+	m.noRegions = true
+	q.client = m
+	q.stack.AddSetting("project_id", "ds-test-project")
+	q.stack.Config.RegionType = "compute"
+
+	raw := getRegions(&q)()
+
+	got, ok := raw.(errMsg)
+	if !ok {
+		t.Fatalf("expected: errMsg, got: %T", raw)
+	}
+
+	assert.Equal(t, "project_id", got.target)
+	assert.Contains(t, got.usermsg, "No regions were returned for project ds-test-project")
+	assert.Contains(t, got.usermsg, "may not have access to compute")
+}
+
+func TestDescribeProject(t *testing.T) {
+	tests := map[string]struct {
+		project string
+		throw   bool
+		want    interface{}
+	}{
+		"described": {
+			project: "ds-test-project",
+			want: gcloud.ProjectDescription{
+				ID:              "ds-test-project",
+				Number:          "123456789",
+				ParentType:      "organization",
+				ParentID:        "1234567890",
+				BillingEnabled:  true,
+				BillingAccount:  "000000-000000-000000",
+				EnabledServices: 12,
+			},
+		},
+		"noProject": {
+			want: nil,
+		},
+		"error": {
+			project: "ds-test-project",
+			throw:   true,
+			want:    nil,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+			if tc.throw {
+				m := GetMock(0)
+				m.forceErr = true
+				q.client = m
+			}
+			if tc.project != "" {
+				q.stack.AddSetting("project_id", tc.project)
+			}
+
+			describeProject(&q)()
+
+			assert.Equal(t, tc.want, q.Get("projectDescription"))
+		})
+	}
+}
+
+func TestPreprocessorsFreeTier(t *testing.T) {
+	tests := map[string]struct {
+		f         func(q *Queue) tea.Cmd
+		zone      string
+		first     bool
+		label1st  string
+		freeLabel string
+	}{
+		"machineTypesEligible": {
+			f:         getMachineTypes,
+			zone:      "us-central1-a",
+			freeLabel: "e2-micro (Free tier)",
+		},
+		"machineTypesEligibleFirst": {
+			f:         getMachineTypes,
+			zone:      "us-central1-a",
+			first:     true,
+			label1st:  "e2-micro (Free tier)",
+			freeLabel: "e2-micro (Free tier)",
+		},
+		"machineTypesIneligible": {
+			f:    getMachineTypes,
+			zone: "europe-west1-b",
+		},
+		"diskTypesEligible": {
+			f:         getDiskTypes,
+			zone:      "us-east1-b",
+			label1st:  "Standard (Free tier)",
+			freeLabel: "Standard (Free tier)",
+		},
+		"diskTypesIneligible": {
+			f:        getDiskTypes,
+			zone:     "asia-east1-a",
+			label1st: "Standard",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+			q.stack.Config.FreeTierFirst = tc.first
+			q.stack.AddSetting("zone", tc.zone)
+			q.stack.AddSetting("instance-machine-type-family", "e2")
+
+			items := tc.f(&q)().([]list.Item)
+
+			if tc.label1st != "" {
+				assert.Equal(t, tc.label1st, items[0].(item).label)
+			}
+
+			free := []string{}
+			for _, v := range items {
+				if strings.HasSuffix(v.(item).label, "(Free tier)") {
+					free = append(free, v.(item).label)
+				}
+			}
+
+			if tc.freeLabel == "" {
+				assert.Empty(t, free)
+				return
+			}
+			assert.Equal(t, []string{tc.freeLabel}, free)
+		})
+	}
+}
+
+func TestGetRegionsNetworkFirst(t *testing.T) {
+	tests := map[string]struct {
+		networkFirst bool
+		want         int
+	}{
+		"networkFirst": {networkFirst: true, want: 2},
+		"regionFirst":  {networkFirst: false, want: 35},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+			q.stack.Config.NetworkBeforeRegion = tc.networkFirst
+			q.stack.AddSetting("instance-network", "prod")
+
+			items := getRegions(&q)().([]list.Item)
+
+			assert.Equal(t, tc.want, len(items))
+			if tc.networkFirst {
+				assert.Equal(t, "europe-west1", items[0].(item).value)
+				assert.Equal(t, "us-central1", items[1].(item).value)
+			}
+		})
+	}
+}
+
+func TestGetQuotaActions(t *testing.T) {
+	tests := map[string]struct {
+		machineType string
+		readOnly    bool
+		want        []string
+		request     quotaRequest
+	}{
+		"fits": {machineType: "e2-medium"},
+		"short": {
+			machineType: "e2-highcpu-8",
+			want:        []string{quotaRequestAction, quotaContinueAction},
+			request:     quotaRequest{region: "us-central1", metric: "compute.googleapis.com/cpus", requested: 28},
+		},
+		"shortCustom": {
+			machineType: "custom-6-8192",
+			want:        []string{quotaRequestAction, quotaContinueAction},
+			request:     quotaRequest{region: "us-central1", metric: "compute.googleapis.com/cpus", requested: 26},
+		},
+		"shortReadOnly": {
+			machineType: "e2-highcpu-8",
+			readOnly:    true,
+			want:        []string{quotaContinueAction},
+			request:     quotaRequest{region: "us-central1", metric: "compute.googleapis.com/cpus", requested: 28},
+		},
+		"quotaUnreadable": {machineType: "n2-standard-8"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+			q.client = mock{readOnly: tc.readOnly}
+			q.stack.AddSetting("region", "us-central1")
+			q.stack.AddSetting("zone", "us-central1-a")
+			q.stack.AddSetting("instance-machine-type", tc.machineType)
+
+			got := getQuotaActions(&q)()
+
+			if tc.want == nil {
+				assert.Equal(t, successMsg{unset: true}, got)
+				return
+			}
+
+			values := []string{}
+			for _, v := range got.([]list.Item) {
+				values = append(values, v.(item).value)
+			}
+			assert.Equal(t, tc.want, values)
+			assert.Equal(t, tc.request, q.Get("quotaRequest"))
+		})
+	}
+}
@@ -16,24 +16,40 @@ package tui
 
 import (
 	"fmt"
+	"math"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/GoogleCloudPlatform/deploystack/config"
 	"github.com/GoogleCloudPlatform/deploystack/gcloud"
 	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
+	"google.golang.org/api/cloudresourcemanager/v1"
+	"google.golang.org/api/compute/v1"
 )
 
 func getProjects(q *Queue) tea.Cmd {
 	return func() tea.Msg {
-		p, err := q.client.ProjectList()
+		// Users with a lot of projects can get rate limited, or the API can
+		// be briefly unavailable, let them know why the list is taking a
+		// while rather than just spinning.
+		key := q.currentKey()
+		p, err := q.client.ProjectListNotify(func(attempt int, wait time.Duration) {
+			q.send(slowMsg{
+				key:  key,
+				text: fmt.Sprintf("Google Cloud is busy listing projects, retrying in %s (attempt %d)", wait, attempt),
+			})
+		})
 		if err != nil {
 			return errMsg{err: err}
 		}
 
+		disabled := map[string]bool{}
 		items := []list.Item{}
 		for _, v := range p {
 			if !v.BillingEnabled {
+				disabled[v.ID] = true
 				label := fmt.Sprintf("%s (Billing Diabled)", v.Name)
 				items = append(items, item{value: v.ID, label: billingDisabledStyle.Render(label)})
 				continue
@@ -43,6 +59,88 @@ func getProjects(q *Queue) tea.Cmd {
 				label: strings.TrimSpace(v.Name),
 			})
 		}
+		q.Save("billingDisabledProjects", disabled)
+
+		return items
+	}
+}
+
+// billingDisabled reports whether a project in the project picker has billing
+// turned off, so it can be hidden
+func billingDisabled(q *Queue, i item) bool {
+	disabled, ok := q.Get("billingDisabledProjects").(map[string]bool)
+	if !ok {
+		return false
+	}
+
+	return disabled[i.value]
+}
+
+func getProjectParents(q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		orgs, err := q.client.OrganizationList()
+		if err != nil {
+			return errMsg{err: err}
+		}
+
+		items := []list.Item{}
+		seen := map[string]bool{}
+
+		// Put the parent of the current project first, as that is where new
+		// projects went before users could choose.
+		if parent, err := currentProjectParent(q); err == nil && parent != nil && parent.Id != "" {
+			value := fmt.Sprintf("%s/%s", parent.Type, parent.Id)
+			label := fmt.Sprintf("Folder %s", parent.Id)
+			if parent.Type == "organization" {
+				label = fmt.Sprintf("Organization %s", parent.Id)
+			}
+			for _, v := range orgs {
+				if parent.Type == "organization" && v.Value == parent.Id {
+					label = fmt.Sprintf("Organization %s", v.Label)
+				}
+			}
+			if current, _ := q.Get("currentProject").(string); current != "" {
+				label = fmt.Sprintf("%s (same as %s)", label, current)
+			}
+			items = append(items, item{value: value, label: label})
+			seen[value] = true
+		}
+
+		for _, v := range orgs {
+			value := fmt.Sprintf("organization/%s", v.Value)
+			if seen[value] {
+				continue
+			}
+			items = append(items, item{value: value, label: fmt.Sprintf("Organization %s", v.Label)})
+		}
+
+		items = append(items, item{value: noParentValue, label: "No parent organization or folder"})
+
+		return items
+	}
+}
+
+// getProjectFolders lists the folders in the organization picked as the new
+// project's parent, after the choice of the organization itself
+func getProjectFolders(q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		parentKey := strings.ReplaceAll(q.currentKey(), folderNewSuffix, parentNewSuffix)
+		parent, ok := q.Get(parentKey).(*cloudresourcemanager.ResourceId)
+		if !ok || parent.Type != "organization" {
+			return errMsg{err: fmt.Errorf("getProjectFolders: no organization was picked")}
+		}
+
+		folders, err := q.client.FolderList(fmt.Sprintf("organizations/%s", parent.Id))
+		if err != nil {
+			return errMsg{err: err}
+		}
+
+		items := []list.Item{
+			item{value: fmt.Sprintf("organization/%s", parent.Id), label: "Directly in the organization, not in a folder"},
+		}
+		for _, v := range folders {
+			items = append(items, item{value: fmt.Sprintf("folder/%s", v.Value), label: fmt.Sprintf("Folder %s", v.Label)})
+		}
 
 		return items
 	}
@@ -65,8 +163,8 @@ func getBillingAccounts(q *Queue) tea.Cmd {
 		}
 
 		// If there is only 1 billing account, don't bother the user with
-		// setting it up.
-		if len(items) == 1 {
+		// setting it up. A read only client can't attach it though.
+		if len(items) == 1 && !q.client.IsReadOnly() {
 			ba := strings.ReplaceAll(p[0].Name, "billingAccounts/", "")
 
 			key := strings.ReplaceAll(q.currentKey(), billNewSuffix, "")
@@ -81,6 +179,26 @@ func getBillingAccounts(q *Queue) tea.Cmd {
 	}
 }
 
+// orgPolicyConstraints returns the org policy constraints for the selected
+// project. If the policy can't be read, DeployStack carries on as if there
+// were no constraints and lets the API reject anything disallowed.
+func orgPolicyConstraints(q *Queue) map[string][]string {
+	project := q.stack.GetSetting("project_id")
+
+	constraints, err := q.client.OrgPolicyConstraints(project)
+	if err != nil {
+		return map[string][]string{}
+	}
+
+	return constraints
+}
+
+// orgPolicyAllows reports whether the allowed and denied values of the list
+// constraint in constraints permit value
+func orgPolicyAllows(constraints map[string][]string, constraint, value string) bool {
+	return gcloud.OrgPolicyAllows(constraints[constraint], constraints[gcloud.OrgPolicyDenied(constraint)], value)
+}
+
 func getRegions(q *Queue) tea.Cmd {
 	return func() tea.Msg {
 		s := q.stack
@@ -92,11 +210,42 @@ func getRegions(q *Queue) tea.Cmd {
 			return errMsg{err: err}
 		}
 
+		if len(p) == 0 {
+			return errMsg{
+				usermsg: fmt.Sprintf("No regions were returned for project %s. The project may not have access to %s, or the API may be having trouble. Pick the project again, or choose another one.", project, product),
+				err:     fmt.Errorf("getRegions: no regions returned for product (%s) in project (%s)", product, project),
+				target:  "project_id",
+			}
+		}
+
+		constraints := orgPolicyConstraints(q)
+
+		// When the network was picked first, only offer regions it has
+		// subnets in
+		var networked map[string]bool
+		if network := s.GetSetting("instance-network"); network != "" && s.Config.NetworkBeforeRegion {
+			regions, err := q.client.NetworkRegions(project, network)
+			if err != nil {
+				return errMsg{err: err}
+			}
+
+			networked = map[string]bool{}
+			for _, v := range regions {
+				networked[v] = true
+			}
+		}
+
 		items := []list.Item{}
 		for _, v := range p {
+			if !orgPolicyAllows(constraints, gcloud.OrgPolicyResourceLocations, v) {
+				continue
+			}
+			if networked != nil && !networked[v] {
+				continue
+			}
 			items = append(items, item{
 				value: strings.TrimSpace(v),
-				label: strings.TrimSpace(v),
+				label: gcloud.RegionLabel(strings.TrimSpace(v)),
 			})
 		}
 
@@ -121,6 +270,29 @@ func handleReports(q *Queue) tea.Cmd {
 	}
 }
 
+// getStacks lists the stacks found by config.DiscoverStacks, by title when
+// they have one
+func getStacks(q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		stacks := q.Get("stacks").([]config.StackInfo)
+
+		items := []list.Item{}
+		for _, v := range stacks {
+			label := v.Title
+			if label == "" {
+				label = v.Name
+			}
+
+			items = append(items, item{
+				value: strings.TrimSpace(v.Path),
+				label: strings.TrimSpace(label),
+			})
+		}
+
+		return items
+	}
+}
+
 func getZones(q *Queue) tea.Cmd {
 	return func() tea.Msg {
 		s := q.stack
@@ -132,8 +304,30 @@ func getZones(q *Queue) tea.Cmd {
 			return errMsg{err: err}
 		}
 
+		constraints := orgPolicyConstraints(q)
+
+		// Only offer zones that actually have the chosen accelerator
+		var accelerated map[string]bool
+		if accelerator := s.GetSetting("instance-accelerator-type"); accelerator != "" {
+			zones, err := q.client.ZonesWithAccelerator(project, accelerator)
+			if err != nil {
+				return errMsg{err: err}
+			}
+
+			accelerated = map[string]bool{}
+			for _, v := range zones {
+				accelerated[v] = true
+			}
+		}
+
 		items := []list.Item{}
 		for _, v := range p {
+			if !orgPolicyAllows(constraints, gcloud.OrgPolicyResourceLocations, v) {
+				continue
+			}
+			if accelerated != nil && !accelerated[v] {
+				continue
+			}
 			items = append(items, item{
 				value: strings.TrimSpace(v),
 				label: strings.TrimSpace(v),
@@ -150,14 +344,16 @@ func getMachineTypeFamilies(q *Queue) tea.Cmd {
 		project := s.GetSetting("project_id")
 		zone := s.GetSetting("zone")
 
-		types, err := q.client.MachineTypeList(project, zone)
+		types, err := q.machineTypeList(project, zone)
 		if err != nil {
 			return errMsg{err: err}
 		}
 
 		typefamilies := q.client.MachineTypeFamilyList(types)
 
-		items := []list.Item{}
+		items := []list.Item{
+			item{label: "Custom machine type", value: customMachineFamily},
+		}
 		for _, v := range typefamilies {
 			items = append(items, item{
 				value: strings.TrimSpace(v.Value),
@@ -169,6 +365,67 @@ func getMachineTypeFamilies(q *Queue) tea.Cmd {
 	}
 }
 
+func getAllMachineTypes(q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		s := q.stack
+		project := s.GetSetting("project_id")
+		zone := s.GetSetting("zone")
+
+		types, err := q.machineTypeList(project, zone)
+		if err != nil {
+			return errMsg{err: err}
+		}
+
+		names := []string{}
+		labels := map[string]string{}
+		for _, v := range types.Items {
+			names = append(names, v.Name)
+			labels[v.Name] = strings.TrimSpace(fmt.Sprintf("%s %s", v.Name, v.Description))
+		}
+		sort.Strings(names)
+
+		found := []list.Item{}
+		for _, v := range names {
+			found = append(found, freeTierItem(v, labels[v], gcloud.IsFreeTier(gcloud.FreeTierMachineType, v, zone)))
+		}
+
+		items := []list.Item{
+			item{label: "Browse machine types by family", value: "browse"},
+			item{label: "Auto-select by vCPU and memory requirements", value: machineTypeByRequirements},
+		}
+
+		return append(items, freeTierSort(s, found)...)
+	}
+}
+
+// freeTierItem builds a list item, flagging it if it falls in the free tier
+func freeTierItem(value, label string, free bool) item {
+	if free {
+		label = fmt.Sprintf("%s %s", label, gcloud.FreeTierLabel)
+	}
+	return item{value: value, label: label}
+}
+
+// freeTierSort moves free tier items to the top of the list, if the stack
+// asks for it
+func freeTierSort(s *config.Stack, items []list.Item) []list.Item {
+	if !s.Config.FreeTierFirst {
+		return items
+	}
+
+	free := []list.Item{}
+	rest := []list.Item{}
+	for _, v := range items {
+		if strings.HasSuffix(v.(item).label, gcloud.FreeTierLabel) {
+			free = append(free, v)
+			continue
+		}
+		rest = append(rest, v)
+	}
+
+	return append(free, rest...)
+}
+
 func getMachineTypes(q *Queue) tea.Cmd {
 	return func() tea.Msg {
 		s := q.stack
@@ -176,31 +433,143 @@ func getMachineTypes(q *Queue) tea.Cmd {
 		zone := s.GetSetting("zone")
 		family := s.GetSetting("instance-machine-type-family")
 
-		types, err := q.client.MachineTypeList(project, zone)
+		types, err := q.machineTypeList(project, zone)
 		if err != nil {
 			return errMsg{err: err}
 		}
 
+		// Prices are a nicety; without them the machine types are listed
+		// without a price, and receivers of Events hear why
+		if err := q.client.MachineTypePricing(s.GetSetting("region")); err != nil {
+			q.reportError("instance-machine-type", fmt.Errorf("getMachineTypes: could not get prices: %w", err))
+		}
+
 		filteredtypes := q.client.MachineTypeListByFamily(types, family)
 
 		items := []list.Item{}
 		for _, v := range filteredtypes {
+			free := gcloud.IsFreeTier(gcloud.FreeTierMachineType, v.Value, zone)
+			items = append(items, freeTierItem(strings.TrimSpace(v.Value), strings.TrimSpace(v.Label), free))
+		}
+
+		return freeTierSort(s, items)
+	}
+}
+
+// quotaRequest is the quota increase the chosen machine type needs
+type quotaRequest struct {
+	region    string
+	metric    string
+	requested int64
+}
+
+// getQuotaActions checks the chosen machine type fits in what is left of the
+// region's vCPU quota. When it does, or the quota can't be read, the step is
+// skipped. Otherwise it offers to request an increase.
+func getQuotaActions(q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		s := q.stack
+		project := s.GetSetting("project_id")
+		region := s.GetSetting("region")
+		zone := s.GetSetting("zone")
+		machineType := s.GetSetting("instance-machine-type")
+
+		types, err := q.machineTypeList(project, zone)
+		if err != nil {
+			return errMsg{err: err}
+		}
+
+		cpus, ok := gcloud.MachineTypeCPUs(types, machineType)
+		if !ok {
+			return successMsg{unset: true}
+		}
+
+		metric := gcloud.CPUQuotaMetric(strings.Split(machineType, "-")[0])
+		quota, err := q.client.RegionQuota(project, region, metric)
+		if err != nil {
+			return successMsg{unset: true}
+		}
+
+		needed := int64(math.Ceil(quota.Usage)) + cpus
+		if needed <= int64(quota.Limit) {
+			return successMsg{unset: true}
+		}
+
+		q.Save("quotaRequest", quotaRequest{
+			region:    region,
+			metric:    gcloud.ComputeQuotaMetric(metric),
+			requested: needed,
+		})
+
+		items := []list.Item{}
+		if !q.client.IsReadOnly() {
 			items = append(items, item{
-				value: strings.TrimSpace(v.Value),
-				label: strings.TrimSpace(v.Label),
+				fmt.Sprintf("Request an increase of the %s quota in %s to %d", metric, region, needed),
+				quotaRequestAction,
 			})
 		}
+		items = append(items, item{"Continue without an increase", quotaContinueAction})
 
 		return items
 	}
 }
 
+// diskProjects returns the image projects to offer, taking into account any
+// the stack config declares
+func diskProjects(s *config.Stack) gcloud.LabeledValues {
+	custom := s.Config.ImageProjects
+	if len(custom) == 0 {
+		return gcloud.DiskProjects
+	}
+
+	customDefault := custom.GetDefault()
+	result := gcloud.LabeledValues{}
+
+	if s.Config.ImageProjectsAppend {
+		for _, v := range gcloud.DiskProjects {
+			if customDefault != "" {
+				v.IsDefault = false
+			}
+			result = append(result, v)
+		}
+	}
+
+	for _, v := range custom {
+		label := v.Label
+		if label == "" {
+			label = v.Value
+		}
+		lv := gcloud.LabeledValue{Value: v.Value, Label: label, IsDefault: v.Default}
+
+		replaced := false
+		for i, existing := range result {
+			if existing.Value == v.Value {
+				if customDefault == "" {
+					lv.IsDefault = existing.IsDefault
+				}
+				result[i] = lv
+				replaced = true
+			}
+		}
+
+		if !replaced {
+			result = append(result, lv)
+		}
+	}
+
+	return result
+}
+
 func getDiskProjects(q *Queue) tea.Cmd {
 	return func() tea.Msg {
-		diskImages := gcloud.DiskProjects
+		diskImages := diskProjects(q.stack)
+		constraints := orgPolicyConstraints(q)
 
 		items := []list.Item{}
 		for _, v := range diskImages {
+			if !orgPolicyAllows(constraints, gcloud.OrgPolicyTrustedImageProjects, v.Value) {
+				continue
+			}
 			items = append(items, item{
 				value: strings.TrimSpace(v.Value),
 				label: strings.TrimSpace(v.Label),
@@ -217,12 +586,12 @@ func getImageFamilies(q *Queue) tea.Cmd {
 		instanceImageProject := s.GetSetting("instance-image-project")
 		project := s.GetSetting("project_id")
 
-		images, err := q.client.ImageList(project, instanceImageProject)
+		images, err := q.imageList(project, instanceImageProject)
 		if err != nil {
 			return errMsg{err: err}
 		}
 
-		families := q.client.ImageFamilyList(images)
+		families := q.client.ImageFamilyListForProject(images, instanceImageProject)
 
 		items := []list.Item{}
 		for _, v := range families {
@@ -236,6 +605,27 @@ func getImageFamilies(q *Queue) tea.Cmd {
 	}
 }
 
+func getImageArchitectures(q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		s := q.stack
+		project := s.GetSetting("project_id")
+		imageProject := s.GetSetting("instance-image-project")
+		family := s.GetSetting("instance-image-family")
+
+		archs, err := q.client.ImageFamilyArchitectures(project, imageProject, family)
+		if err != nil {
+			return errMsg{err: err}
+		}
+
+		items := []list.Item{}
+		for _, v := range archs {
+			items = append(items, item{value: v, label: v})
+		}
+
+		return items
+	}
+}
+
 func getImageDisks(q *Queue) tea.Cmd {
 	return func() tea.Msg {
 		s := q.stack
@@ -243,18 +633,40 @@ func getImageDisks(q *Queue) tea.Cmd {
 		instanceImageFamily := s.GetSetting("instance-image-family")
 		project := s.GetSetting("project_id")
 
-		images, err := q.client.ImageList(project, instanceImageProject)
+		images, err := q.imageList(project, instanceImageProject)
 		if err != nil {
 			return errMsg{err: err}
 		}
 
+		if arch, ok := q.Get("instance-image-architecture").(string); ok && arch != "" {
+			filtered := &compute.ImageList{}
+			for _, v := range images.Items {
+				if v.Family != instanceImageFamily || imageArchitecture(v) == arch {
+					filtered.Items = append(filtered.Items, v)
+				}
+			}
+			images = filtered
+		}
+
 		imagesByFam := q.client.ImageTypeListByFamily(images, instanceImageProject, instanceImageFamily)
+		q.Save("instance-image-latest", strings.TrimSpace(imagesByFam.GetDefault().Value))
 
+		byName := map[string]*compute.Image{}
+		for _, v := range images.Items {
+			byName[strings.TrimSpace(v.Name)] = v
+		}
+
+		now := time.Now()
 		items := []list.Item{}
 		for _, v := range imagesByFam {
+			label := strings.TrimSpace(v.Label)
+			name := strings.TrimPrefix(strings.TrimSpace(v.Value), instanceImageProject+"/")
+			if warning := gcloud.ImageEOLWarning(byName[name], now); warning != "" {
+				label = fmt.Sprintf("%s %s", label, warning)
+			}
 			items = append(items, item{
 				value: strings.TrimSpace(v.Value),
-				label: strings.TrimSpace(v.Label),
+				label: label,
 			})
 		}
 
@@ -262,12 +674,420 @@ func getImageDisks(q *Queue) tea.Cmd {
 	}
 }
 
+// getAllImages lists every image in the image project that runs on the
+// chosen machine type, for users who know the image name but not its family
+func getAllImages(q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		s := q.stack
+		project := s.GetSetting("project_id")
+		imageProject := s.GetSetting("instance-image-project")
+		machineArch := gcloud.MachineTypeArchitecture(s.GetSetting("instance-machine-type"))
+
+		images, err := q.imageList(project, imageProject)
+		if err != nil {
+			return errMsg{err: err}
+		}
+
+		byName := map[string]*compute.Image{}
+		names := []string{}
+		for _, v := range images.Items {
+			if imageArchitecture(v) != machineArch {
+				continue
+			}
+			name := strings.TrimSpace(v.Name)
+			byName[name] = v
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		now := time.Now()
+		found := []list.Item{}
+		for _, v := range names {
+			label := v
+			if warning := gcloud.ImageEOLWarning(byName[v], now); warning != "" {
+				label = fmt.Sprintf("%s %s", label, warning)
+			}
+			found = append(found, item{value: fmt.Sprintf("%s/%s", imageProject, v), label: label})
+		}
+
+		items := []list.Item{
+			item{label: "Browse images by family", value: "browse"},
+		}
+
+		return append(items, found...)
+	}
+}
+
+// imageArchitecture returns the architecture of an image, images that predate
+// the field being x86-64
+func imageArchitecture(img *compute.Image) string {
+	if img.Architecture == "" {
+		return gcloud.ArchitectureX86
+	}
+	return img.Architecture
+}
+
 func getDiskTypes(q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		s := q.stack
+		project := s.GetSetting("project_id")
+		zone := s.GetSetting("zone")
+		imageProject := s.GetSetting("instance-image-project")
+
+		// Offering the common types beats stopping the flow if the zone's
+		// disk types can't be retrieved
+		types, err := q.client.DiskTypeList(project, zone)
+		if err != nil || len(types) == 0 {
+			types = gcloud.DiskTypes
+		}
+
+		items := []list.Item{}
+		for _, v := range types {
+			free := gcloud.IsFreeTierDisk(v.Value, imageProject, zone)
+			items = append(items, freeTierItem(v.Value, v.Label, free))
+		}
+
+		return freeTierSort(s, items)
+	}
+}
+
+func getDiskReplicationTypes(q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		items := []list.Item{
+			item{"Zonal", "zonal"},
+			item{"Regional", "regional"},
+		}
+
+		return items
+	}
+}
+
+func getProvisioningModels(q *Queue) tea.Cmd {
 	return func() tea.Msg {
 		items := []list.Item{
-			item{"Standard", "pd-standard"},
-			item{"Balanced", "pd-balanced"},
-			item{"SSD", "pd-sdd"},
+			item{"Standard", gcloud.ProvisioningStandard},
+			item{"Spot", gcloud.ProvisioningSpot},
+		}
+
+		return items
+	}
+}
+
+func getHostMaintenancePolicies(q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		items := []list.Item{
+			item{"Migrate to another host", gcloud.HostMaintenanceMigrate},
+			item{"Stop the instance", gcloud.HostMaintenanceTerminate},
+		}
+
+		return items
+	}
+}
+
+func getReplicaZones(q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		s := q.stack
+		project := s.GetSetting("project_id")
+		region := s.GetSetting("region")
+		zone := s.GetSetting("zone")
+
+		p, err := q.client.ZoneList(project, region)
+		if err != nil {
+			return errMsg{err: err}
+		}
+
+		constraints := orgPolicyConstraints(q)
+
+		items := []list.Item{}
+		for _, v := range p {
+			// The primary zone can't also be the replica
+			if v == zone || !orgPolicyAllows(constraints, gcloud.OrgPolicyResourceLocations, v) {
+				continue
+			}
+			items = append(items, item{
+				value: strings.TrimSpace(v),
+				label: strings.TrimSpace(v),
+			})
+		}
+
+		return items
+	}
+}
+
+func getNetworks(q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		project := q.stack.GetSetting("project_id")
+
+		networks, err := q.client.NetworkList(project)
+		if err != nil {
+			return errMsg{err: err}
+		}
+
+		items := []list.Item{}
+		for _, v := range networks {
+			items = append(items, item{
+				value: strings.TrimSpace(v.Value),
+				label: strings.TrimSpace(v.Label),
+			})
+		}
+
+		return items
+	}
+}
+
+func getSubnets(q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		project := q.stack.GetSetting("project_id")
+		region := q.stack.GetSetting("region")
+		network := q.stack.GetSetting("instance-network")
+
+		subnets, err := q.client.NetworkSubnetworkList(project, region, network)
+		if err != nil {
+			return errMsg{err: err}
+		}
+
+		if len(subnets) == 0 {
+			return errMsg{
+				usermsg: fmt.Sprintf("Network %s has no subnets in region %s, go back and pick another network or region", network, region),
+				err:     fmt.Errorf("getSubnets: network (%s) has no subnets in region (%s)", network, region),
+			}
+		}
+
+		items := []list.Item{}
+		for _, v := range subnets {
+			items = append(items, item{
+				value: strings.TrimSpace(v.Value),
+				label: strings.TrimSpace(v.Label),
+			})
+		}
+
+		return items
+	}
+}
+
+// getSecondaryRanges lists the secondary ranges of the subnet picked. Without
+// any there is nothing to pick, and the ranges are left for the product to
+// create.
+func getSecondaryRanges(q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		project := q.stack.GetSetting("project_id")
+		region := q.stack.GetSetting("region")
+		subnet := q.stack.GetSetting("instance-subnet")
+
+		ranges, err := q.client.SubnetSecondaryRanges(project, region, subnet)
+		if err != nil {
+			return errMsg{err: err}
+		}
+
+		if len(ranges) == 0 {
+			return successMsg{unset: true}
+		}
+
+		items := []list.Item{}
+		for _, v := range ranges {
+			items = append(items, item{
+				value: strings.TrimSpace(v.Value),
+				label: strings.TrimSpace(v.Label),
+			})
+		}
+
+		return items
+	}
+}
+
+func getAccelerators(q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		project := q.stack.GetSetting("project_id")
+		region := q.stack.GetSetting("region")
+		zone := q.stack.GetSetting("zone")
+
+		// The accelerator is normally picked before the zone, so that the
+		// zones can be narrowed to the ones that have it. Until then, offer
+		// what the region has.
+		if zone == "" && region != "" {
+			types, err := q.client.AcceleratorTypeRegionList(project, region)
+			if err != nil {
+				return errMsg{err: err}
+			}
+
+			if len(types) == 0 {
+				return errMsg{
+					usermsg: fmt.Sprintf("There are no accelerators in region %s, go back and pick another region", region),
+					err:     fmt.Errorf("getAccelerators: region (%s) has no accelerator types", region),
+				}
+			}
+
+			return acceleratorItems(types)
+		}
+
+		types, err := q.client.AcceleratorTypeList(project, zone)
+		if err != nil {
+			return errMsg{err: err}
+		}
+
+		if len(types) == 0 {
+			return errMsg{
+				usermsg: fmt.Sprintf("There are no accelerators in zone %s, go back and pick another zone", zone),
+				err:     fmt.Errorf("getAccelerators: zone (%s) has no accelerator types", zone),
+			}
+		}
+
+		return acceleratorItems(types)
+	}
+}
+
+func acceleratorItems(types gcloud.LabeledValues) []list.Item {
+	items := []list.Item{}
+	for _, v := range types {
+		items = append(items, item{
+			value: strings.TrimSpace(v.Value),
+			label: strings.TrimSpace(v.Label),
+		})
+	}
+
+	return items
+}
+
+func getNodeTypes(q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		project := q.stack.GetSetting("project_id")
+		zone := q.stack.GetSetting("zone")
+
+		types, err := q.client.NodeTypeList(project, zone)
+		if err != nil {
+			return errMsg{err: err}
+		}
+
+		items := []list.Item{}
+		for _, v := range types {
+			items = append(items, item{
+				value: strings.TrimSpace(v.Value),
+				label: strings.TrimSpace(v.Label),
+			})
+		}
+
+		return items
+	}
+}
+
+func enableServices(q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		project := q.stack.GetSetting("project_id")
+		results := []serviceResult{}
+		failed := false
+
+		for _, v := range q.stack.Config.RequiredServices() {
+			result := serviceResult{service: v}
+
+			svc, err := gcloud.ParseService(v)
+			if err != nil {
+				result.status = serviceStatusFailed
+				result.err = err
+				results = append(results, result)
+				failed = true
+				continue
+			}
+
+			enabled, err := q.client.ServiceIsEnabled(project, svc)
+			if err == nil && enabled {
+				result.status = serviceStatusAlreadyOn
+				results = append(results, result)
+				continue
+			}
+
+			if err := q.client.ServiceEnable(project, svc); err != nil {
+				result.status = serviceStatusFailed
+				result.err = err
+				results = append(results, result)
+				failed = true
+				continue
+			}
+
+			result.status = serviceStatusEnabled
+			results = append(results, result)
+		}
+
+		q.Save("serviceResults", results)
+
+		if failed {
+			return []list.Item{
+				item{"Retry", "retry"},
+				item{"Abort", "abort"},
+			}
+		}
+
+		return []list.Item{
+			item{"Continue", "continue"},
+		}
+	}
+}
+
+func getSecrets(q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		project := q.stack.GetSetting("project_id")
+
+		secrets, err := q.client.SecretList(project)
+		if err != nil {
+			return errMsg{err: err}
+		}
+
+		items := []list.Item{}
+		for _, v := range secrets {
+			items = append(items, item{
+				value: strings.TrimSpace(v.Value),
+				label: strings.TrimSpace(v.Label),
+			})
+		}
+
+		return items
+	}
+}
+
+// newResourceValue is the choice for having the stack create a resource
+// rather than reusing an existing one
+const newResourceValue = "create-new"
+
+func getAddresses(q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		project := q.stack.GetSetting("project_id")
+		region := q.stack.GetSetting("region")
+
+		addresses, err := q.client.AddressList(project, region)
+		if err != nil {
+			return errMsg{err: err}
+		}
+
+		items := []list.Item{
+			item{label: "Reserve a new static IP address", value: newResourceValue},
+		}
+		for _, v := range addresses {
+			items = append(items, item{
+				value: strings.TrimSpace(v.Value),
+				label: strings.TrimSpace(v.Label),
+			})
+		}
+
+		return items
+	}
+}
+
+func getDNSZones(q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		project := q.stack.GetSetting("project_id")
+
+		zones, err := q.client.DNSZoneList(project)
+		if err != nil {
+			return errMsg{err: err}
+		}
+
+		items := []list.Item{
+			item{label: "Create a new managed zone", value: newResourceValue},
+		}
+		for _, v := range zones {
+			items = append(items, item{
+				value: strings.TrimSpace(v.Value),
+				label: strings.TrimSpace(v.Label),
+			})
 		}
 
 		return items
@@ -285,6 +1105,41 @@ func getYesOrNo(q *Queue) tea.Cmd {
 	}
 }
 
+func getReviewActions(q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		items := []list.Item{
+			item{"Confirm", "confirm"},
+			item{"Edit", "edit"},
+		}
+
+		return items
+	}
+}
+
+// getReviewSettings lists the steps before the review that have stored a
+// setting, to pick one to answer again
+func getReviewSettings(q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		items := []list.Item{}
+
+		for _, v := range q.models {
+			key := v.getKey()
+			if key == reviewKey {
+				break
+			}
+
+			value := q.stack.GetSetting(key)
+			if value == "" {
+				continue
+			}
+
+			items = append(items, item{fmt.Sprintf("%s (%s)", key, value), key})
+		}
+
+		return items
+	}
+}
+
 func getNoOrYes(q *Queue) tea.Cmd {
 	return func() tea.Msg {
 		items := []list.Item{
@@ -296,6 +1151,26 @@ func getNoOrYes(q *Queue) tea.Cmd {
 	}
 }
 
+// describeProject fetches a snapshot of the chosen project for the summary
+// shown before anything is deployed. It's informational, so failures just
+// leave the summary off.
+func describeProject(q *Queue) tea.Cmd {
+	return func() tea.Msg {
+		project := q.stack.GetSetting("project_id")
+		if project == "" {
+			return ""
+		}
+
+		d, err := q.client.ProjectDescribe(project)
+		if err != nil {
+			return ""
+		}
+		q.Save("projectDescription", d)
+
+		return ""
+	}
+}
+
 func cleanUp(q *Queue) tea.Cmd {
 	return func() tea.Msg {
 		// // Don't let these get leaked to terraform
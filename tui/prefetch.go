@@ -0,0 +1,181 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tui
+
+import (
+	"sync"
+
+	"google.golang.org/api/compute/v1"
+)
+
+// prefetchCall is a lookup started ahead of the step that needs it. done is
+// closed once value and err are set.
+type prefetchCall struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+// prefetcher runs slow lookups in the background so the steps that need
+// them don't have to wait as long. Lookups are keyed, and a key is only
+// fetched once until it is dropped.
+type prefetcher struct {
+	mu    sync.Mutex
+	calls map[string]*prefetchCall
+}
+
+func newPrefetcher() *prefetcher {
+	return &prefetcher{calls: map[string]*prefetchCall{}}
+}
+
+// start runs fetch in the background under key, unless it is already
+// fetched or being fetched
+func (p *prefetcher) start(key string, fetch func() (interface{}, error)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.calls[key]; ok {
+		return
+	}
+
+	c := &prefetchCall{done: make(chan struct{})}
+	p.calls[key] = c
+
+	go func() {
+		c.value, c.err = fetch()
+		close(c.done)
+	}()
+}
+
+// get returns what was prefetched under key, waiting for it if it is still
+// running. When nothing was prefetched, or the prefetch failed, it falls
+// back to calling fetch itself.
+func (p *prefetcher) get(key string, fetch func() (interface{}, error)) (interface{}, error) {
+	p.mu.Lock()
+	c, ok := p.calls[key]
+	p.mu.Unlock()
+
+	if ok {
+		<-c.done
+		if c.err == nil {
+			return c.value, nil
+		}
+		p.drop(key)
+	}
+
+	return fetch()
+}
+
+// cached reports whether key has been fetched without error
+func (p *prefetcher) cached(key string) bool {
+	p.mu.Lock()
+	c, ok := p.calls[key]
+	p.mu.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	select {
+	case <-c.done:
+		return c.err == nil
+	default:
+		return false
+	}
+}
+
+// wait blocks until every prefetch started so far has finished
+func (p *prefetcher) wait() {
+	p.mu.Lock()
+	calls := make([]*prefetchCall, 0, len(p.calls))
+	for _, c := range p.calls {
+		calls = append(calls, c)
+	}
+	p.mu.Unlock()
+
+	for _, c := range calls {
+		<-c.done
+	}
+}
+
+// drop forgets whatever was prefetched under key
+func (p *prefetcher) drop(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.calls, key)
+}
+
+func machineTypesKey(project, zone string) string {
+	return "machineTypes/" + project + "/" + zone
+}
+
+func imagesKey(project, imageProject string) string {
+	return "images/" + project + "/" + imageProject
+}
+
+// prefetchGCE starts looking up the machine types for zone and the images of
+// the image project the user is likely to pick, at the same time, so they
+// are ready by the time the Compute Engine steps get to them
+func (q *Queue) prefetchGCE(project, zone string) {
+	if project == "" || zone == "" {
+		return
+	}
+
+	imageProject := q.stack.GetSetting("instance-image-project")
+	if imageProject == "" {
+		imageProject = diskProjects(q.stack).GetDefault().Value
+	}
+
+	q.prefetch.start(machineTypesKey(project, zone), func() (interface{}, error) {
+		return q.client.MachineTypeList(project, zone)
+	})
+
+	if imageProject == "" {
+		return
+	}
+
+	q.prefetch.start(imagesKey(project, imageProject), func() (interface{}, error) {
+		return q.client.ImageList(project, imageProject)
+	})
+}
+
+// machineTypeList returns the machine types for zone, using the prefetched
+// list when there is one
+func (q *Queue) machineTypeList(project, zone string) (*compute.MachineTypeList, error) {
+	v, err := q.prefetch.get(machineTypesKey(project, zone), func() (interface{}, error) {
+		return q.client.MachineTypeList(project, zone)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	types, _ := v.(*compute.MachineTypeList)
+	return types, nil
+}
+
+// imageList returns the images in imageProject, using the prefetched list
+// when there is one
+func (q *Queue) imageList(project, imageProject string) (*compute.ImageList, error) {
+	v, err := q.prefetch.get(imagesKey(project, imageProject), func() (interface{}, error) {
+		return q.client.ImageList(project, imageProject)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	images, _ := v.(*compute.ImageList)
+	return images, nil
+}
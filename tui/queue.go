@@ -15,6 +15,9 @@
 package tui
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/GoogleCloudPlatform/deploystack/config"
 	tea "github.com/charmbracelet/bubbletea"
 )
@@ -43,15 +46,54 @@ type Queue struct {
 	store   map[string]interface{}
 	index   []string
 	client  UIClient
+	keyMap  KeyMap
+
+	// windowHeight is the terminal height, once the terminal has told us.
+	// Picker lists are sized to fit it, within listHeightMin and listHeightMax.
+	windowHeight  int
+	listHeightMin int
+	listHeightMax int
+
+	// program is the bubbletea program running the queue, if there is one.
+	// Commands running in the background talk to the models through it.
+	program *tea.Program
+
+	// events is where progress is sent once someone asks for Events, until
+	// eventsClosed
+	events       chan Event
+	eventsClosed bool
+
+	// prefetch holds lookups started ahead of the steps that need them
+	prefetch *prefetcher
+
+	// history holds the keys of the steps the user moved on from, latest
+	// last, so going back returns to the step they came from
+	history []string
+
+	// skipping maps the keys of the steps to pass over to the key of the
+	// step whose answer left them nothing to ask
+	skipping map[string]string
 }
 
 // NewQueue creates a new queue. You should need only one per app
 func NewQueue(s *config.Stack, client UIClient) Queue {
 	q := Queue{stack: s, store: map[string]interface{}{}}
 	q.client = client
+	q.keyMap = DefaultKeyMap()
 	q.index = []string{}
+	q.listHeightMin = defaultListHeightMin
+	q.listHeightMax = defaultListHeightMax
+	q.prefetch = newPrefetcher()
+
+	// The project, region and zone the user set with gcloud make good
+	// defaults. None of them are required, so failing to read them is fine.
+	defaults, _ := client.ConfigDefaultsGet()
+	q.Save("configDefaults", defaults)
 
 	currentProject, _ := client.ProjectIDGet()
+	if currentProject == "" {
+		currentProject = defaults.Project
+	}
 
 	q.Save("currentProject", currentProject)
 	return q
@@ -69,6 +111,30 @@ func (q *Queue) Model(key string) QueueModel {
 	return nil
 }
 
+// SetKeyMap changes the key bindings used to navigate the queue
+func (q *Queue) SetKeyMap(k KeyMap) {
+	q.keyMap = k
+
+	for _, v := range q.models {
+		if p, ok := v.(*picker); ok {
+			k.applyTo(&p.list)
+		}
+	}
+}
+
+// SetListHeightRange sets the fewest and most rows a picker list can take up
+// when it is sized to fit the terminal
+func (q *Queue) SetListHeightRange(min, max int) {
+	q.listHeightMin = min
+	q.listHeightMax = max
+}
+
+// SetPreviousSettings hands the queue the settings of a previous run, so the
+// end page can show what changed on a rerun
+func (q *Queue) SetPreviousSettings(previous config.Settings) {
+	q.Save("previousSettings", previous)
+}
+
 // Save stores a value in a simple cache for communicating between operations
 // in the same process
 func (q *Queue) Save(key string, val interface{}) {
@@ -84,6 +150,14 @@ func (q *Queue) Get(key string) interface{} {
 	return val
 }
 
+// send hands msg to the running program, from any goroutine. Without a
+// program, such as in tests, msg is dropped.
+func (q *Queue) send(msg tea.Msg) {
+	if q.program != nil {
+		q.program.Send(msg)
+	}
+}
+
 func (q *Queue) removeModel(key string) {
 	for i, v := range q.index {
 		if v == key {
@@ -93,15 +167,48 @@ func (q *Queue) removeModel(key string) {
 	}
 }
 
+// skipSteps passes over the steps with keys from now on, as the answer to the
+// current step left them nothing to ask. Coming back to the current step
+// asks them again, so a different answer can need them after all.
+func (q *Queue) skipSteps(keys ...string) {
+	if q.skipping == nil {
+		q.skipping = map[string]string{}
+	}
+
+	owner := q.currentKey()
+	for _, v := range keys {
+		q.skipping[v] = owner
+	}
+}
+
+// unskipSteps stops passing over the steps the answer to the step with key
+// skipped
+func (q *Queue) unskipSteps(key string) {
+	for k, v := range q.skipping {
+		if v == key {
+			delete(q.skipping, k)
+		}
+	}
+}
+
+// skipped reports whether the step with key is passed over
+func (q *Queue) skipped(key string) bool {
+	_, ok := q.skipping[key]
+	return ok
+}
+
 func (q *Queue) goToModel(key string) (tea.Model, tea.Cmd) {
 	if key == "quit" {
+		q.closeEvents()
 		return q.models[q.current], tea.Quit
 	}
 
 	for i, v := range q.models {
 		if v.getKey() == key {
 			q.current = i
+			q.unskipSteps(key)
 			r := q.models[q.current]
+			q.emit(Event{Type: EventStepEntered, Key: key})
 			return r, r.Init()
 		}
 	}
@@ -123,24 +230,71 @@ func (q *Queue) clear(key string) {
 }
 
 func (q *Queue) next() (tea.Model, tea.Cmd) {
+	if q.current < len(q.models) {
+		q.history = append(q.history, q.models[q.current].getKey())
+	}
+
+	return q.skip()
+}
+
+// skip moves on like next, for a step that had nothing to ask, so going back
+// passes over it. Steps an earlier answer skipped are passed over too.
+func (q *Queue) skip() (tea.Model, tea.Cmd) {
 	q.current++
+	for q.current < len(q.models) && q.skipped(q.models[q.current].getKey()) {
+		q.current++
+	}
+
 	if q.current >= len(q.models) {
+		q.emit(Event{Type: EventCompleted})
+		q.closeEvents()
 		return q.models[len(q.models)-1], tea.Quit
 	}
 
 	r := q.models[q.current]
+	q.emit(Event{Type: EventStepEntered, Key: r.getKey()})
 	return r, r.Init()
 }
 
-func (q *Queue) prev() (tea.Model, tea.Cmd) {
-	q.current--
-	if q.current <= 0 {
-		return q.models[0], nil
+// previous goes back to the step the user moved on from to get to the
+// current one. The setting that step stored, and the settings that depend on
+// it, are cleared so the step asks again instead of skipping itself, along
+// with the steps its answer skipped. Steps skipped since, like the project
+// creation ones, are passed over. With nowhere to go back to, the current
+// step stays.
+func (q *Queue) previous() (tea.Model, tea.Cmd) {
+	current := q.currentKey()
+
+	for len(q.history) > 0 {
+		key := q.history[len(q.history)-1]
+		q.history = q.history[:len(q.history)-1]
+
+		if key == current || q.skipped(key) {
+			continue
+		}
+
+		for i, v := range q.models {
+			if v.getKey() != key {
+				continue
+			}
+
+			// The project creator stores the project it made under the
+			// selector's key
+			for _, k := range []string{key, strings.ReplaceAll(key, projNewSuffix, "")} {
+				q.clearDependents(k)
+				q.stack.DeleteSetting(k)
+			}
+
+			q.current = i
+			q.unskipSteps(key)
+			v.setValue("")
+			q.emit(Event{Type: EventStepEntered, Key: key})
+			return v, v.Init()
+		}
 	}
 
 	r := q.models[q.current]
-	r.setValue("")
-	return r, r.Init()
+	return r, nil
 }
 
 func (q *Queue) currentKey() string {
@@ -165,15 +319,18 @@ func (q *Queue) InitializeUI() {
 	descPage.showProgress = false
 
 	endpage := newPage("endpage", []component{
+		newSettingsDiff(q),
 		newTextBlock(titleStyle.Render("Project Settings")),
 		newSettingsTable(q.stack),
+		newProjectSummary(q),
 	})
-	endpage.addPreProcessor(cleanUp(q))
+	endpage.addPreProcessor(tea.Batch(cleanUp(q), describeProject(q)))
 
 	q.header = appHeader
 	q.add(&firstPage)
 	q.add(&descPage)
 	q.ProcessConfig()
+	newReviewScreen(q)
 	q.add(&endpage)
 }
 
@@ -193,6 +350,7 @@ func (q *Queue) exitPage() (tea.Model, tea.Cmd) {
 	q.Save("halted", true)
 
 	quit := func(string, *Queue) tea.Cmd {
+		q.closeEvents()
 		return tea.Quit
 	}
 
@@ -216,6 +374,14 @@ func (q *Queue) countTotalSteps() int {
 		if v.getKey() == "endpage" {
 			total--
 		}
+
+		if v.getKey() == reviewKey || v.getKey() == reviewEditKey {
+			total--
+		}
+
+		if q.skipped(v.getKey()) {
+			total--
+		}
 	}
 	return total
 }
@@ -278,15 +444,38 @@ func (q *Queue) ProcessConfig() error {
 		s.Config.Projects.Items = append(s.Config.Projects.Items, p)
 	}
 
+	// Cost labels come first so that everything created along the way,
+	// projects included, can be labeled
+	if s.Config.CostLabels {
+		newCostLabels(q)
+	}
+
 	if len(s.Config.Projects.Items) > 0 {
 
 		currentProject := q.Get("currentProject").(string)
+		uniqueSuffix := s.Config.Projects.UniqueSuffix
+		hideBillingDisabled := s.Config.Projects.HideBillingDisabled
 
 		for _, v := range s.Config.Projects.Items {
 			s := newProjectSelector(v.Name, v.UserPrompt, currentProject, getProjects(q))
+			s.hiding = hideBillingDisabled
+
+			// A read only client can't create a project, so only existing
+			// ones are offered
+			if q.client.IsReadOnly() {
+				s.list.RemoveItem(0)
+				q.add(&s)
+				continue
+			}
+
 			c := newProjectCreator(v.Name + projNewSuffix)
+			if uniqueSuffix {
+				c = newProjectCreatorWithSuffix(v.Name + projNewSuffix)
+			}
+			pp := newProjectParentSelector(v.Name+parentNewSuffix, getProjectParents(q))
+			pf := newProjectFolderSelector(v.Name+folderNewSuffix, getProjectFolders(q))
 			b := newBillingSelector(v.Name+billNewSuffix, getBillingAccounts(q), attachBilling)
-			q.add(&s, &c, &b)
+			q.add(&s, &pp, &pf, &c, &b)
 		}
 	}
 
@@ -296,6 +485,10 @@ func (q *Queue) ProcessConfig() error {
 		q.add(&b)
 	}
 
+	if len(s.Config.RequiredServices()) > 0 && !q.client.IsReadOnly() {
+		newServicesEnabler(q)
+	}
+
 	if s.Config.ConfigureGCEInstance {
 		newGCEInstance(q)
 	}
@@ -305,6 +498,12 @@ func (q *Queue) ProcessConfig() error {
 		newRegion(q)
 	}
 
+	// The zones are narrowed to the ones that offer the accelerator, so it
+	// has to be picked first
+	if s.Config.Accelerator {
+		newAccelerator(q)
+	}
+
 	zone = s.GetSetting("zone")
 	if s.Config.Zone && len(zone) == 0 {
 		newZone(q)
@@ -334,6 +533,11 @@ func (q *Queue) add(m ...QueueModel) {
 			continue
 		}
 
+		// Overridden settings aren't asked for
+		if q.overridden(v.getKey()) {
+			continue
+		}
+
 		v.addQueue(q)
 		q.models = append(q.models, v)
 		q.index = append(q.index, v.getKey())
@@ -353,5 +557,20 @@ func (q *Queue) insert(m ...QueueModel) {
 // Start returns the first model to the hosting application so that it can
 // be run through tea.NewProgram
 func (q *Queue) Start() QueueModel {
-	return q.models[0]
+	r := q.models[q.current]
+	q.emit(Event{Type: EventStepEntered, Key: r.getKey()})
+	return r
+}
+
+// startAt moves the queue to the model with the given key, so that Start
+// begins there instead of at the first model.
+func (q *Queue) startAt(key string) error {
+	for i, v := range q.models {
+		if v.getKey() == key {
+			q.current = i
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s", ErrorStepNotFound, key)
 }
@@ -15,11 +15,13 @@
 package tui
 
 import (
+	"fmt"
 	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/GoogleCloudPlatform/deploystack/config"
+	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/stretchr/testify/assert"
@@ -96,6 +98,71 @@ func TestQueueStart(t *testing.T) {
 	}
 }
 
+func TestQueueFrom(t *testing.T) {
+	tests := map[string]struct {
+		startKey    string
+		settings    map[string]string
+		answers     config.Settings
+		exkey       string
+		regionModel bool
+		wantRegion  string
+		err         error
+	}{
+		"zoneWithRegion": {
+			startKey:    "zone",
+			settings:    map[string]string{"region": "us-central1"},
+			exkey:       "zone",
+			regionModel: false,
+			wantRegion:  "us-central1",
+		},
+		"zoneFromAnswers": {
+			startKey: "zone",
+			answers: config.Settings{
+				{Name: "region", Value: "europe-west1", Type: "string"},
+				{Name: "zone", Value: "europe-west1-b", Type: "string"},
+			},
+			exkey:       "zone",
+			regionModel: false,
+			wantRegion:  "europe-west1",
+		},
+		"zoneWithoutRegion": {
+			startKey: "zone",
+			err:      ErrorAnswerMissing,
+		},
+		"missing": {
+			startKey: "notastep",
+			err:      ErrorStepNotFound,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := config.NewStack()
+			s.Config.Name = "test"
+			s.Config.Region = true
+			s.Config.RegionType = "compute"
+			s.Config.Zone = true
+			for k, v := range tc.settings {
+				s.AddSetting(k, v)
+			}
+
+			q, err := newQueueFrom(&s, GetMock(0), tc.startKey, tc.answers)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			assert.Nil(t, err)
+
+			got := q.Start()
+			assert.Equal(t, tc.exkey, got.getKey())
+			assert.Equal(t, tc.regionModel, q.Model("region") != nil)
+			assert.Equal(t, tc.wantRegion, s.GetSetting("region"))
+			// The answer for the step we start at is asked again
+			assert.Equal(t, "", s.GetSetting("zone"))
+		})
+	}
+}
+
 func TestQueueCalculateProgress(t *testing.T) {}
 
 func TestQueueRemoveModel(t *testing.T) {
@@ -155,22 +222,41 @@ func TestQueueProcess(t *testing.T) {
 			keys: []string{
 				"project_id",
 				"project_id_2",
+				"project_id" + parentNewSuffix,
+				"project_id_2" + parentNewSuffix,
+				"project_id" + folderNewSuffix,
+				"project_id_2" + folderNewSuffix,
 				"project_id" + projNewSuffix,
 				"project_id_2" + projNewSuffix,
 				"project_id" + billNewSuffix,
 				"project_id_2" + billNewSuffix,
 				"billing_account",
+				"enable-services",
 				"gce-use-defaults",
 				"instance-name",
 				"region",
 				"zone",
+				"instance-machine-type-search",
+				"instance-min-cpus",
+				"instance-min-memory",
 				"instance-machine-type-family",
+				"instance-custom-cpus",
+				"instance-custom-memory",
 				"instance-machine-type",
+				"instance-quota",
 				"instance-image-project",
+				"instance-image-search",
 				"instance-image-family",
+				"instance-image-architecture",
 				"instance-image",
 				"instance-disksize",
 				"instance-disktype",
+				"instance-disk-replication",
+				"instance-disk-replica-zones",
+				"instance-shielded-vm",
+				"instance-confidential-vm",
+				"instance-sole-tenant",
+				"instance-node-type",
 				"instance-webserver",
 				"domain",
 				"domain_email",
@@ -229,6 +315,48 @@ func TestQueueProcess(t *testing.T) {
 	}
 }
 
+func TestQueueProcessAcceleratorOrder(t *testing.T) {
+	tests := map[string]struct {
+		gce  bool
+		want []string
+	}{
+		"stack": {
+			want: []string{"region", "instance-accelerator-type", "zone"},
+		},
+		"gce": {
+			gce:  true,
+			want: []string{"region", "instance-accelerator-type", "zone"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+			q.stack.Config.Name = "test"
+			q.stack.Config.Region = true
+			q.stack.Config.Zone = true
+			q.stack.Config.Accelerator = true
+			q.stack.Config.ConfigureGCEInstance = tc.gce
+
+			if err := q.ProcessConfig(); err != nil {
+				t.Fatalf("expected no error, got %s", err)
+			}
+
+			got := []string{}
+			for _, v := range q.models {
+				switch v.getKey() {
+				case "region", "zone", "instance-accelerator-type":
+					got = append(got, v.getKey())
+				}
+			}
+
+			// getZones only narrows the zones to the accelerator's if the
+			// accelerator is already picked when the zones load
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
 func TestQueueInitialize(t *testing.T) {
 	tests := map[string]struct {
 		keys []string
@@ -237,6 +365,8 @@ func TestQueueInitialize(t *testing.T) {
 			keys: []string{
 				"firstpage",
 				"descpage",
+				"review",
+				"review-edit",
 				"endpage",
 			},
 		},
@@ -495,7 +625,7 @@ func TestQueueModel(t *testing.T) {
 	}
 }
 
-func TestQueuePrev(t *testing.T) {
+func TestQueuePrevious(t *testing.T) {
 	tests := map[string]struct {
 		in        []interface{}
 		key       string
@@ -540,14 +670,13 @@ func TestQueuePrev(t *testing.T) {
 			case page:
 				want.queue = &q
 				q.Start()
-				q.next()
-				q.next()
 
-				if tc.gotobegin {
-					q.current = 0
+				if !tc.gotobegin {
+					q.next()
+					q.next()
 				}
 
-				got, _ := q.prev()
+				got, _ := q.previous()
 
 				assert.Equal(t, &want, got)
 
@@ -560,3 +689,187 @@ func TestQueuePrev(t *testing.T) {
 		})
 	}
 }
+
+func TestQueuePreviousRestores(t *testing.T) {
+	q := getTestQueue(appTitle, "test")
+
+	region := newPicker("Pick a region", "", "region", "", nil)
+	region.state = "displaying"
+	region.list.SetItems([]list.Item{
+		item{label: "us-central1", value: "us-central1"},
+		item{label: "europe-west1", value: "europe-west1"},
+	})
+	// Already answered, so it skips itself on the way forward
+	skipped := newTextInput("Bucket", "", "bucket", "")
+	zone := newTextInput("Zone", "us-central1-a", "zone", "")
+	q.add(&region, &skipped, &zone)
+	q.stack.AddSetting("bucket", "files")
+	q.Start()
+
+	// Forward: pick the second region, pass the answered step, land on zone
+	raw, _ := region.Update(tea.KeyMsg{Type: tea.KeyDown})
+	raw, _ = raw.(picker).Update(tea.KeyMsg{Type: tea.KeyEnter})
+	assert.Equal(t, "bucket", q.currentKey())
+	assert.Equal(t, "europe-west1", q.stack.GetSetting("region"))
+
+	raw.(*textInput).Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
+	assert.Equal(t, "zone", q.currentKey())
+
+	// Back: from zone to region, over the step that skipped itself
+	got, _ := zone.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	assert.Equal(t, "region", q.currentKey())
+	assert.Equal(t, q.Model("region"), got)
+	assert.Equal(t, "", q.stack.GetSetting("region"))
+	assert.Equal(t, "files", q.stack.GetSetting("bucket"))
+	assert.Equal(t, 2, len(got.(*picker).list.Items()))
+
+	// Nothing before region to go back to
+	got, _ = q.previous()
+	assert.Equal(t, "region", q.currentKey())
+	assert.Equal(t, q.Model("region"), got)
+}
+
+func TestQueueSkipSteps(t *testing.T) {
+	q := getTestQueue(appTitle, "test")
+
+	first := newPage("first", nil)
+	second := newPage("second", nil)
+	third := newPage("third", nil)
+	last := newPage("last", nil)
+	q.add(&first, &second, &third, &last)
+	q.Start()
+
+	// The answer to the first step leaves the next two nothing to ask
+	q.skipSteps("second", "third")
+	assert.Equal(t, 2, q.countTotalSteps())
+
+	got, _ := q.next()
+	assert.Equal(t, "last", got.(QueueModel).getKey())
+
+	// Going back to the first step asks them again
+	got, _ = q.previous()
+	assert.Equal(t, "first", got.(QueueModel).getKey())
+	assert.False(t, q.skipped("second"))
+	assert.False(t, q.skipped("third"))
+	assert.Equal(t, 4, q.countTotalSteps())
+
+	got, _ = q.next()
+	assert.Equal(t, "second", got.(QueueModel).getKey())
+}
+
+func TestQueueEvents(t *testing.T) {
+	q := getTestQueue(appTitle, "test")
+	first := newTextInput("First", "alpha", "first", "")
+	second := newTextInput("Second", "beta", "second", "")
+	q.add(&first, &second)
+
+	events := q.Events()
+
+	q.Start()
+	first.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	failure := errMsg{err: errForced}
+	second.Update(failure)
+	second.Update(tea.KeyMsg{Type: tea.KeyEnter})
+
+	want := []Event{
+		{Type: EventStepEntered, Key: "first"},
+		{Type: EventSettingSet, Key: "first", Value: "alpha"},
+		{Type: EventStepEntered, Key: "second"},
+		{Type: EventErrorOccurred, Key: "second", Err: failure},
+		{Type: EventSettingSet, Key: "second", Value: "beta"},
+		{Type: EventCompleted},
+	}
+
+	// The channel is closed once the flow completes
+	got := []Event{}
+	for e := range events {
+		got = append(got, e)
+	}
+
+	assert.Equal(t, want, got)
+	assert.Equal(t, "alpha", q.stack.GetSetting("first"))
+	assert.Equal(t, "beta", q.stack.GetSetting("second"))
+}
+
+func TestQueueEventsDropOldest(t *testing.T) {
+	q := getTestQueue(appTitle, "test")
+	events := q.Events()
+
+	for i := 0; i < eventBuffer+2; i++ {
+		q.emit(Event{Type: EventSettingSet, Key: fmt.Sprintf("key%d", i)})
+	}
+
+	assert.Equal(t, eventBuffer, len(events))
+	assert.Equal(t, "key2", (<-events).Key)
+
+	q.closeEvents()
+	q.emit(Event{Type: EventCompleted})
+	q.closeEvents()
+}
+
+func TestQueueClearDependents(t *testing.T) {
+	tests := map[string]struct {
+		key     string
+		value   string
+		cleared []string
+		kept    []string
+	}{
+		"regionChanged": {
+			key:     "region",
+			value:   "europe-west1",
+			cleared: []string{"zone", "instance-machine-type", "instance-machine-type-family", "instance-disktype", "instance-disk-replica-zones"},
+			kept:    []string{"instance-image-project", "instance-image-family", "instance-image"},
+		},
+		"regionSame": {
+			key:   "region",
+			value: "us-central1",
+			kept:  []string{"zone", "instance-machine-type", "instance-machine-type-family"},
+		},
+		"familyChanged": {
+			key:     "instance-machine-type-family",
+			value:   "e2",
+			cleared: []string{"instance-machine-type"},
+			kept:    []string{"region", "zone", "instance-disktype"},
+		},
+		"zoneChanged": {
+			key:     "zone",
+			value:   "us-central1-b",
+			cleared: []string{"instance-disktype", "instance-disk-replica-zones", "instance-machine-type"},
+			kept:    []string{"region", "instance-image"},
+		},
+		"imageProjectChanged": {
+			key:     "instance-image-project",
+			value:   "ubuntu-os-cloud",
+			cleared: []string{"instance-image-family", "instance-image"},
+			kept:    []string{"region", "zone", "instance-machine-type"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+			q.stack.AddSetting("region", "us-central1")
+			q.stack.AddSetting("zone", "us-central1-a")
+			q.stack.AddSetting("instance-machine-type-family", "n2")
+			q.stack.AddSetting("instance-machine-type", "n2-standard-2")
+			q.stack.AddSetting("instance-image-project", "debian-cloud")
+			q.stack.AddSetting("instance-image-family", "debian-12")
+			q.stack.AddSetting("instance-image", "debian-cloud/debian-12-v20230912")
+			q.stack.AddSetting("instance-disktype", "pd-balanced")
+			q.stack.AddSetting("instance-disk-replica-zones", "us-central1-a,us-central1-c")
+			q.Save("instance-image-architecture", "X86_64")
+
+			q.setSetting(tc.key, tc.value)
+
+			assert.Equal(t, tc.value, q.stack.GetSetting(tc.key))
+			for _, v := range tc.cleared {
+				assert.Nil(t, q.stack.Settings.Find(v), v)
+			}
+			for _, v := range tc.kept {
+				assert.NotNil(t, q.stack.Settings.Find(v), v)
+			}
+			// The architecture goes with the machine type it was picked for
+			assert.Equal(t, q.stack.Settings.Find("instance-machine-type") == nil, q.Get("instance-image-architecture") == nil)
+		})
+	}
+}
@@ -26,10 +26,16 @@ import (
 )
 
 var (
-	projNewSuffix = "_new_project_creator"
-	billNewSuffix = "_new_billing_selector"
+	projNewSuffix   = "_new_project_creator"
+	billNewSuffix   = "_new_billing_selector"
+	parentNewSuffix = "_new_project_parent"
+	folderNewSuffix = "_new_project_folder"
 )
 
+// noParentValue is the project parent picker value for creating a project
+// outside of any organization or folder
+const noParentValue = "none"
+
 func newProjectCreator(key string) textInput {
 	r := newTextInput("Create New Project",
 		"",
@@ -37,6 +43,7 @@ func newProjectCreator(key string) textInput {
 		"Checking if project can be created",
 	)
 	r.addPostProcessor(createProject)
+	r.validator = gcloud.ProjectIDValidate
 
 	r.addContent("Project IDs are immutable and can be set only during project ")
 	r.addContent("creation. They must start with a lowercase letter and can have ")
@@ -47,12 +54,46 @@ func newProjectCreator(key string) textInput {
 	return r
 }
 
+func newProjectCreatorWithSuffix(key string) textInput {
+	r := newProjectCreator(key)
+	suffix := gcloud.ProjectIDSuffix()
+	r.transform = func(s string) string {
+		return gcloud.ProjectIDAddSuffix(s, suffix)
+	}
+
+	r.clearContent()
+	r.addContent("Project IDs are immutable and can be set only during project ")
+	r.addContent("creation. They must start with a lowercase letter and can have ")
+	r.addContent("lowercase ASCII letters, digits or hyphens. ")
+	r.addContent(fmt.Sprintf("A random suffix (-%s) will be added to the name you enter ", suffix))
+	r.addContent("to keep it unique, shortening the name if needed to stay within 30 characters. ")
+	r.addContent("\n\n")
+	r.addContent(textInputDefaultStyle.Render("Please enter a new project name to create:"))
+	return r
+}
+
 func newProjectSelector(key, listLabel, currentProject string, preProcessor tea.Cmd) picker {
 
 	result := newPicker(listLabel, "Retrieving Projects", key, currentProject, preProcessor)
 	create := item{"Create New Project", ""}
 	result.list.InsertItem(0, create)
 	result.addPostProcessor(processProjectSelection)
+	result.hideable = billingDisabled
+	result.hideText = "projects without billing"
+	return result
+}
+
+func newProjectParentSelector(key string, preProcessor tea.Cmd) picker {
+	result := newPicker("Choose where to create the new project", "Retrieving organizations", key, "", preProcessor)
+	result.omitFromSettings = true
+	result.addPostProcessor(processProjectParent)
+	return result
+}
+
+func newProjectFolderSelector(key string, preProcessor tea.Cmd) picker {
+	result := newPicker("Choose a folder in the organization for the new project", "Retrieving folders", key, "", preProcessor)
+	result.omitFromSettings = true
+	result.addPostProcessor(processProjectFolder)
 	return result
 }
 
@@ -83,13 +124,18 @@ func newCustom(c config.Custom) QueueModel {
 	switch c.Validation {
 	case validationPhoneNumber:
 		r.spinnerLabel = "Validating phone number"
-		r.addPostProcessor(validatePhoneNumber)
 	case validationYesOrNo:
 		r.spinnerLabel = "Validating yes or no"
-		r.addPostProcessor(validateYesOrNo)
 	case validationInteger:
 		r.spinnerLabel = "Validating integer"
-		r.addPostProcessor(validateInteger)
+	case validationEmail:
+		r.spinnerLabel = "Validating email address"
+	case validationURL:
+		r.spinnerLabel = "Validating URL"
+	}
+
+	if v := customValidator(c); v != nil {
+		r.addPostProcessor(v)
 	}
 
 	if c.PrependProject {
@@ -121,6 +167,7 @@ func newDomain(q *Queue) {
 			Name:         "domain_email",
 			Description:  "Enter an email address",
 			DefaultValue: "person@example.com",
+			Validator:    validateEmail,
 		},
 
 		{
@@ -178,6 +225,9 @@ func newDomain(q *Queue) {
 	if contact.AllContacts.Email == "" {
 		for _, v := range items {
 			t := newTextInput(v.Description, v.DefaultValue, v.Name, "")
+			if v.Validator != nil {
+				t.addPostProcessor(v.Validator)
+			}
 			q.add(&t)
 		}
 	}
@@ -218,10 +268,43 @@ func newDomain(q *Queue) {
 	q.add(&dy)
 }
 
+func newCostLabels(q *Queue) {
+	t := newTextInput(
+		"Enter labels for cost attribution as key=value pairs separated by commas, or none",
+		"none",
+		costLabelsKey,
+		"Validating labels",
+	)
+	t.omitFromSettings = true
+	t.postProcessor = processCostLabels
+	q.add(&t)
+}
+
 func newCustomPages(q *Queue) {
 	for _, v := range q.stack.Config.CustomSettings {
 		temp := q.stack.GetSetting(v.Name)
 
+		if v.Secret && len(temp) < 1 {
+			secretPage := newPicker(v.Description, "Retrieving secrets", v.Name, v.Default, getSecrets(q))
+			secretPage.addPostProcessor(storeSecret)
+			q.add(&secretPage)
+			continue
+		}
+
+		if v.StaticIP && len(temp) < 1 {
+			addressPage := newPicker(v.Description, "Retrieving static IP addresses", v.Name, "", getAddresses(q))
+			addressPage.addPostProcessor(processReuseOrNew)
+			q.add(&addressPage)
+			continue
+		}
+
+		if v.DNSZone && len(temp) < 1 {
+			zonePage := newPicker(v.Description, "Retrieving DNS zones", v.Name, "", getDNSZones(q))
+			zonePage.addPostProcessor(processReuseOrNew)
+			q.add(&zonePage)
+			continue
+		}
+
 		if len(v.Options) > 0 {
 
 			items := []list.Item{}
@@ -258,6 +341,13 @@ func newCustomPages(q *Queue) {
 	}
 }
 
+// usesMachineTypes reports whether a stack whose regions come from product
+// runs on Compute Engine machine types. Stacks that don't name a product are
+// treated as compute ones.
+func usesMachineTypes(product string) bool {
+	return product == "" || product == "compute"
+}
+
 func newGCEInstance(q *Queue) {
 	r := newPicker("Do you want to accept the default configuration? (Yes or No)", "", "gce-use-defaults", "", getYesOrNo(q))
 	r.omitFromSettings = true
@@ -284,22 +374,33 @@ trying out most use cases, or hand configure key settings.
 	)
 	q.add(&name)
 
-	newRegion(q)
-	newZone(q)
-	newMachineTypeManager(q)
+	newInstanceLocation(q)
+	conf := q.stack.Config
+	if usesMachineTypes(q.stack.Config.RegionType) {
+		newMachineTypeManager(q)
+	}
 	newDiskImageManager(q)
 
-	ds := newTextInput("Enter the size of the boot disk you want in GB",
+	ds := newUnitInput("Enter the size of the boot disk you want (e.g. 100GB or 2TB)",
 		"100",
 		"instance-disksize",
 		"",
+		storageUnits,
 	)
 	ds.addPostProcessor(validateInteger)
 	q.add(&ds)
 
 	dt := newPicker("Pick the type of the boot disk you want", "", "instance-disktype", gcloud.DefaultDiskType, getDiskTypes(q))
+	dt.addPostProcessor(validateDiskType)
 	q.add(&dt)
 
+	newDiskReplicationManager(q)
+	newShieldedVMManager(q)
+	newSoleTenantManager(q)
+	if conf.InstanceScheduling {
+		newSchedulingManager(q)
+	}
+
 	dy := newYesOrNo(
 		q,
 		"Do you want this to be a webserver (Expose ports 80 & 443)?",
@@ -307,28 +408,177 @@ trying out most use cases, or hand configure key settings.
 		false,
 		validateGCEConfiguration,
 	)
+	if q.stack.IsOverridden("instance-webserver") {
+		dy.preProcessor = validateGCEConfiguration("", q)
+	}
 	q.add(&dy)
 }
 
+// newInstanceLocation queues the region and zone of an instance, along with
+// its accelerator, network and subnet when the stack asks for them. The network can come
+// first, narrowing the regions to the ones it has subnets in, or after the
+// zone, narrowing its subnets to the region. network_before_region picks
+// which. collect_secondary_ranges adds the secondary ranges of the subnet.
+func newInstanceLocation(q *Queue) {
+	conf := q.stack.Config
+	if conf.InstanceNetwork && conf.NetworkBeforeRegion {
+		newNetwork(q)
+	}
+	newRegion(q)
+	// The zones are narrowed to the ones that offer the accelerator, so it
+	// has to be picked first
+	if conf.Accelerator {
+		newAccelerator(q)
+	}
+	newZone(q)
+	if conf.InstanceNetwork {
+		if !conf.NetworkBeforeRegion {
+			newNetwork(q)
+		}
+		newSubnet(q)
+		if conf.SecondaryRanges {
+			newSecondaryRanges(q)
+		}
+	}
+}
+
+// snapshotKey is where RunCopyFrom keeps the project config snapshot it
+// took in the queue's store
+const snapshotKey = "configSnapshot"
+
+// configSnapshot is a project config snapshot and the project it was taken
+// from
+type configSnapshot struct {
+	project string
+	values  map[string]string
+}
+
+// snapshotDefault overrides value with what the project config snapshot has
+// for key, if RunCopyFrom took one, along with a note saying where the
+// default came from
+func snapshotDefault(q *Queue, key, value string) (string, string) {
+	snapshot, ok := q.Get(snapshotKey).(configSnapshot)
+	if !ok || snapshot.values[key] == "" {
+		return value, ""
+	}
+
+	return snapshot.values[key], fmt.Sprintf("The default is copied from project %s.\n\n", snapshot.project)
+}
+
 func newRegion(q *Queue) {
-	r := newPicker("Pick a region", "Retrieving regions", "region", q.stack.Config.RegionDefault, getRegions(q))
+	region := q.stack.Config.RegionDefault
+	if defaults, ok := q.Get("configDefaults").(gcloud.ConfigDefaults); ok && region == "" {
+		region = defaults.Region
+	}
+	region, note := snapshotDefault(q, gcloud.SnapshotRegion, region)
+
+	r := newPicker("Pick a region", "Retrieving regions", "region", region, getRegions(q))
+	if note != "" {
+		r.addContent(note)
+	}
 	q.add(&r)
 }
 
 func newZone(q *Queue) {
-	z := newPicker("Pick a zone", "Retrieving zones", "zone", gcloud.DefaultZone, getZones(q))
+	zone := gcloud.DefaultZone
+	if defaults, ok := q.Get("configDefaults").(gcloud.ConfigDefaults); ok && defaults.Zone != "" {
+		zone = defaults.Zone
+	}
+	zone, note := snapshotDefault(q, gcloud.SnapshotZone, zone)
+
+	z := newPicker("Pick a zone", "Retrieving zones", "zone", zone, getZones(q))
+	if note != "" {
+		z.addContent(note)
+	}
+	z.addPostProcessor(processZone)
 	q.add(&z)
 }
 
+func newAccelerator(q *Queue) {
+	a := newPicker("Pick the accelerator to attach", "Retrieving accelerators", "instance-accelerator-type", "", getAccelerators(q))
+	a.addContent(textStyle.Bold(true).Render("Configure an accelerator"))
+	a.addContent("\n\n")
+	a.addContent("Only the GPUs offered in the region you picked are listed, and only the zones \n")
+	a.addContent("that offer the one you choose are listed next. For more information \n")
+	a.addContent("please refer to: \n")
+	a.addContent(url.Render("https://cloud.google.com/compute/docs/gpus"))
+	q.add(&a)
+}
+
+func newNetwork(q *Queue) {
+	network, note := snapshotDefault(q, gcloud.SnapshotNetwork, "default")
+
+	n := newPicker("Pick the VPC network for the instance", "Retrieving networks", "instance-network", network, getNetworks(q))
+	n.addContent(textStyle.Bold(true).Render("Configure a Compute Engine Instance"))
+	n.addContent("\n\n")
+	if note != "" {
+		n.addContent(note)
+	}
+	n.addContent("The instance is attached to a subnet of this network. For more information \n")
+	n.addContent("please refer to: \n")
+	n.addContent(url.Render("https://cloud.google.com/vpc/docs/vpc"))
+	q.add(&n)
+}
+
+func newSubnet(q *Queue) {
+	s := newPicker("Pick the subnet for the instance", "Retrieving subnets", "instance-subnet", "default", getSubnets(q))
+	q.add(&s)
+}
+
+func newSecondaryRanges(q *Queue) {
+	pods := newPicker("Pick the secondary range for pods", "Retrieving secondary ranges", "instance-subnet-pods-range", "", getSecondaryRanges(q))
+	pods.addContent(textStyle.Bold(true).Render("Configure secondary ranges"))
+	pods.addContent("\n\n")
+	pods.addContent("GKE puts pods and services on secondary ranges of the subnet. A subnet \n")
+	pods.addContent("without any skips these steps, and the ranges are created for you. For \n")
+	pods.addContent("more information please refer to: \n")
+	pods.addContent(url.Render("https://cloud.google.com/kubernetes-engine/docs/concepts/alias-ips"))
+	q.add(&pods)
+
+	services := newPicker("Pick the secondary range for services", "Retrieving secondary ranges", "instance-subnet-services-range", "", getSecondaryRanges(q))
+	q.add(&services)
+}
+
 func newMachineTypeManager(q *Queue) {
+	s := newPicker("Search for a Machine Type", "Retrieving machine types", "instance-machine-type-search", "", getAllMachineTypes(q))
+	s.omitFromSettings = true
+	s.addPostProcessor(processMachineTypeSearch)
+	s.addContent(textStyle.Bold(true).Render("Configure a Compute Engine Instance"))
+	s.addContent("\n\n")
+	s.addContent("If you already know the machine type you want, type '/' to filter them by \n")
+	s.addContent("name. Otherwise choose to browse the machine types family by family, or to \n")
+	s.addContent("give the vCPUs and memory you need and get the smallest type that fits. \n")
+	q.add(&s)
+
+	minCPU := newTextInput("Enter the fewest vCPUs the instance needs", "2", "instance-min-cpus", "")
+	minCPU.omitFromSettings = true
+	minCPU.addPostProcessor(processMinCPUs)
+	q.add(&minCPU)
+
+	minMem := newTextInput("Enter the least memory in GB the instance needs", "4", "instance-min-memory", "Finding a machine type")
+	minMem.omitFromSettings = true
+	minMem.addPostProcessor(processMachineTypeRequirements)
+	q.add(&minMem)
+
 	p := newPicker("Pick a Machine Type Family", "Retrieving machine type families", "instance-machine-type-family", gcloud.DefaultMachineFamily, getMachineTypeFamilies(q))
 	p.addContent(textStyle.Bold(true).Render("Configure a Compute Engine Instance"))
 	p.addContent("\n\n")
 	p.addContent("There are a large number of machine types to choose from. For more information \n")
 	p.addContent("please refer to the following link for more information about Machine types: \n")
 	p.addContent(url.Render("https://cloud.google.com/compute/docs/machine-types"))
+	p.addPostProcessor(processMachineTypeFamily)
 	q.add(&p)
 
+	cpus := newTextInput("Enter the number of vCPUs for the custom machine type (1 or an even number)", "2", "instance-custom-cpus", "")
+	cpus.omitFromSettings = true
+	cpus.addPostProcessor(processCustomCPUs)
+	q.add(&cpus)
+
+	mem := newTextInput("Enter the memory in MB for the custom machine type (a multiple of 256)", "4096", "instance-custom-memory", "Validating custom machine type")
+	mem.omitFromSettings = true
+	mem.addPostProcessor(processCustomMachineType)
+	q.add(&mem)
+
 	p2 := newPicker("Pick a Machine Type", "Retrieving machine types", "instance-machine-type", gcloud.DefaultMachineType, getMachineTypes(q))
 	p2.addContent(textStyle.Bold(true).Render("Configure a Compute Engine Instance"))
 	p2.addContent("\n\n")
@@ -336,10 +586,24 @@ func newMachineTypeManager(q *Queue) {
 	p2.addContent("please refer to the following link for more information about Machine types: \n")
 	p2.addContent(url.Render("https://cloud.google.com/compute/docs/machine-types"))
 	q.add(&p2)
+
+	quota := newPicker("There isn't enough vCPU quota left in the region for this machine type", "Checking quota", "instance-quota", "", getQuotaActions(q))
+	quota.omitFromSettings = true
+	quota.addPostProcessor(processQuota)
+	quota.addContent(textStyle.Bold(true).Render("Configure a Compute Engine Instance"))
+	quota.addContent("\n\n")
+	quota.addContent("Creating the instance would fail. Google reviews quota increase requests, \n")
+	quota.addContent("so an increase can take a while to apply. For more information see: \n")
+	quota.addContent(url.Render("https://cloud.google.com/compute/resource-usage"))
+	q.add(&quota)
 }
 
 func newDiskImageManager(q *Queue) {
-	p := newPicker("Pick an operating system", "Retrieving operating systems", "instance-image-project", gcloud.DefaultImageProject, getDiskProjects(q))
+	projects := diskProjects(q.stack)
+	defaultProject := projects.GetDefault().Value
+
+	p := newPicker("Pick an operating system", "Retrieving operating systems", "instance-image-project", defaultProject, getDiskProjects(q))
+	p.addPostProcessor(processImageProject)
 	p.addContent(textStyle.Bold(true).Render("Configure a Compute Engine Instance"))
 	p.addContent("\n\n")
 	p.addContent("There are a large number of machine images to choose from. For more information \n")
@@ -347,15 +611,37 @@ func newDiskImageManager(q *Queue) {
 	p.addContent(url.Render("https://cloud.google.com/compute/docs/images"))
 	q.add(&p)
 
-	p2 := newPicker("Pick a disk family", "Retrieving disk family", "instance-image-family", gcloud.DefaultImageFamily, getImageFamilies(q))
+	s := newPicker("Search for a disk image", "Retrieving disk images", "instance-image-search", "", getAllImages(q))
+	s.omitFromSettings = true
+	s.addPostProcessor(processImageSearch)
+	s.addContent(textStyle.Bold(true).Render("Configure a Compute Engine Instance"))
+	s.addContent("\n\n")
+	s.addContent("If you already know the image you want, type '/' to filter them by \n")
+	s.addContent("name. Otherwise choose to browse the images family by family. \n")
+	q.add(&s)
+
+	p2 := newPicker("Pick a disk family", "Retrieving disk family", "instance-image-family", gcloud.ImageFamilyDefault(defaultProject), getImageFamilies(q))
 	p2.addContent(textStyle.Bold(true).Render("Configure a Compute Engine Instance"))
 	p2.addContent("\n\n")
 	p2.addContent("There are a large number of machine images to choose from. For more information \n")
 	p2.addContent("please refer to the following link for more information about Machine images: \n")
 	p2.addContent(url.Render("https://cloud.google.com/compute/docs/images"))
+	p2.addPostProcessor(processImageFamily)
 	q.add(&p2)
 
+	pa := newPicker("Pick a CPU architecture", "Retrieving architectures", "instance-image-architecture", "", getImageArchitectures(q))
+	pa.omitFromSettings = true
+	pa.list.SetShowFilter(false)
+	pa.list.SetShowStatusBar(false)
+	pa.addPostProcessor(validateImageArchitecture)
+	pa.addContent(textStyle.Bold(true).Render("Configure a Compute Engine Instance"))
+	pa.addContent("\n\n")
+	pa.addContent("This image family is published for more than one CPU architecture. \n")
+	pa.addContent("The architecture must match the one of the machine type you picked. \n")
+	q.add(&pa)
+
 	p3 := newPicker("Pick a disk image", "Retrieving disk image", "instance-image", "", getImageDisks(q))
+	p3.addPostProcessor(processImagePin)
 	p3.addContent(textStyle.Bold(true).Render("Configure a Compute Engine Instance"))
 	p3.addContent("\n\n")
 	p3.addContent("There are a large number of machine images to choose from. For more information \n")
@@ -363,3 +649,153 @@ func newDiskImageManager(q *Queue) {
 	p3.addContent(url.Render("https://cloud.google.com/compute/docs/images"))
 	q.add(&p3)
 }
+
+func newDiskReplicationManager(q *Queue) {
+	p := newPicker("Pick the availability of the boot disk", "", "instance-disk-replication", gcloud.DefaultDiskReplication, getDiskReplicationTypes(q))
+	p.list.SetShowFilter(false)
+	p.list.SetShowStatusBar(false)
+	p.addPostProcessor(processDiskReplication)
+	p.addContent(textStyle.Bold(true).Render("Configure a Compute Engine Instance"))
+	p.addContent("\n\n")
+	p.addContent("Zonal disks live in a single zone. Regional disks are synchronously \n")
+	p.addContent("replicated to a second zone in the same region for high availability. \n")
+	p.addContent("please refer to the following link for more information about regional disks: \n")
+	p.addContent(url.Render("https://cloud.google.com/compute/docs/disks/high-availability-regional-persistent-disk"))
+	q.add(&p)
+
+	// validateReplicaZone stores both zones of the disk under this key, so
+	// the picker's own answer is left out of the settings
+	p2 := newPicker("Pick a zone to replicate the boot disk to", "Retrieving zones", "instance-disk-replica-zones", "", getReplicaZones(q))
+	p2.omitFromSettings = true
+	p2.addPostProcessor(validateReplicaZone)
+	q.add(&p2)
+}
+
+func newSoleTenantManager(q *Queue) {
+	st := newYesOrNo(
+		q,
+		"Do you want to deploy this instance on sole-tenant nodes?",
+		"instance-sole-tenant",
+		true,
+		processSoleTenant,
+	)
+	st.omitFromSettings = true
+	st.addContent(textStyle.Bold(true).Render("Configure a Compute Engine Instance"))
+	st.addContent("\n\n")
+	st.addContent("Sole-tenant nodes are physical servers dedicated to your project, for \n")
+	st.addContent("workloads with isolation or compliance needs. For more information please \n")
+	st.addContent("refer to: \n")
+	st.addContent(url.Render("https://cloud.google.com/compute/docs/nodes/sole-tenant-nodes"))
+	q.add(&st)
+
+	nt := newPicker("Pick the sole-tenant node type", "Retrieving node types", "instance-node-type", "", getNodeTypes(q))
+	nt.omitFromSettings = true
+	nt.addPostProcessor(processNodeType)
+	q.add(&nt)
+}
+
+// newSchedulingManager asks how the instance is provisioned and what happens
+// to it during host maintenance, which ends up in the instance-scheduling
+// setting
+func newSchedulingManager(q *Queue) {
+	pm := newPicker("Pick how the instance is provisioned", "", "instance-provisioning-model", gcloud.ProvisioningStandard, getProvisioningModels(q))
+	pm.omitFromSettings = true
+	pm.list.SetShowFilter(false)
+	pm.list.SetShowStatusBar(false)
+	pm.addPostProcessor(processProvisioningModel)
+	pm.addContent(textStyle.Bold(true).Render("Configure a Compute Engine Instance"))
+	pm.addContent("\n\n")
+	pm.addContent("Spot instances cost much less, but Compute Engine can stop them at any time. \n")
+	pm.addContent("For more information please refer to: \n")
+	pm.addContent(url.Render("https://cloud.google.com/compute/docs/instances/spot"))
+	q.add(&pm)
+
+	hm := newPicker("Pick what happens to the instance during host maintenance", "", "instance-on-host-maintenance", gcloud.HostMaintenanceMigrate, getHostMaintenancePolicies(q))
+	hm.omitFromSettings = true
+	hm.list.SetShowFilter(false)
+	hm.list.SetShowStatusBar(false)
+	hm.addPostProcessor(validateHostMaintenance)
+	hm.addContent(textStyle.Bold(true).Render("Configure a Compute Engine Instance"))
+	hm.addContent("\n\n")
+	hm.addContent("Compute Engine can live migrate the instance to another host, or stop it. \n")
+	hm.addContent("For more information please refer to: \n")
+	hm.addContent(url.Render("https://cloud.google.com/compute/docs/instances/setting-vm-host-options"))
+	q.add(&hm)
+
+	ar := newYesOrNo(
+		q,
+		"Should the instance restart automatically if Compute Engine stops it?",
+		"instance-automatic-restart",
+		false,
+		processScheduling,
+	)
+	ar.omitFromSettings = true
+	ar.preProcessor = getYesOrNo(q)
+	q.add(&ar)
+}
+
+const (
+	reviewKey          = "review"
+	reviewEditKey      = "review-edit"
+	reviewConfirmedKey = "reviewConfirmed"
+)
+
+// newReviewScreen adds the steps that show the settings collected before
+// they are written out. Confirm moves on, Edit lists the settings to go back
+// and answer again.
+func newReviewScreen(q *Queue) {
+	r := newPicker("Write out these settings?", "", reviewKey, "", getReviewActions(q))
+	r.omitFromSettings = true
+	r.list.SetShowStatusBar(false)
+	r.list.SetShowFilter(false)
+	r.addPostProcessor(processReview)
+	r.addContent(titleStyle.Render("Review your settings"))
+	r.content = append(r.content, newSettingsTable(q.stack))
+
+	e := newPicker("Pick the setting to change", "", reviewEditKey, "", getReviewSettings(q))
+	e.omitFromSettings = true
+	e.addPostProcessor(processReviewEdit)
+
+	q.add(&r, &e)
+}
+
+func newServicesEnabler(q *Queue) {
+	p := newPicker("Enabling the APIs required by this stack", "Enabling APIs", "enable-services", "", enableServices(q))
+	p.omitFromSettings = true
+	p.list.SetShowFilter(false)
+	p.list.SetShowStatusBar(false)
+	p.addPostProcessor(handleServiceResults)
+	p.content = append(p.content, newServiceResults(q))
+	q.add(&p)
+}
+
+func newShieldedVMManager(q *Queue) {
+	sv := newYesOrNo(
+		q,
+		"Do you want this to be a Shielded VM (Secure Boot, vTPM and integrity monitoring)?",
+		"instance-shielded-vm",
+		false,
+		processShieldedVM,
+	)
+	sv.preProcessor = getYesOrNo(q)
+	sv.addContent(textStyle.Bold(true).Render("Configure a Compute Engine Instance"))
+	sv.addContent("\n\n")
+	sv.addContent("Shielded VMs are hardened against rootkits and bootkits, and are recommended \n")
+	sv.addContent("for most workloads. For more information please refer to: \n")
+	sv.addContent(url.Render("https://cloud.google.com/compute/shielded-vm/docs/shielded-vm"))
+	q.add(&sv)
+
+	cv := newYesOrNo(
+		q,
+		"Do you want to enable Confidential Computing for this instance?",
+		"instance-confidential-vm",
+		true,
+		nil,
+	)
+	cv.addContent(textStyle.Bold(true).Render("Configure a Compute Engine Instance"))
+	cv.addContent("\n\n")
+	cv.addContent("Confidential VMs keep data encrypted in memory while it is being processed. \n")
+	cv.addContent("For more information please refer to: \n")
+	cv.addContent(url.Render("https://cloud.google.com/confidential-computing/confidential-vm/docs/about-cvm"))
+	q.add(&cv)
+}
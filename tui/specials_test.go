@@ -19,8 +19,12 @@ import (
 	"testing"
 
 	"github.com/GoogleCloudPlatform/deploystack/config"
+	"github.com/GoogleCloudPlatform/deploystack/gcloud"
+	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
 	"google.golang.org/api/cloudbilling/v1"
+	"google.golang.org/api/cloudresourcemanager/v1"
 )
 
 func TestNewProjectCreator(t *testing.T) {
@@ -52,6 +56,57 @@ func TestNewProjectCreator(t *testing.T) {
 	}
 }
 
+func TestNewProjectCreatorValidation(t *testing.T) {
+	tests := map[string]struct {
+		in  string
+		err error
+	}{
+		"toolong":  {in: "ds-test-project-that-is-way-too-long", err: gcloud.ErrorProjectCreateTooLong},
+		"badchars": {in: "ds-test-project!", err: gcloud.ErrorProjectInvalidCharacters},
+		"valid":    {in: "ds-test-project", err: nil},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+			out := newProjectCreator("project_id" + projNewSuffix)
+			q.add(&out)
+			out.ti.SetValue(tc.in)
+
+			raw, _ := out.Update(tea.KeyMsg{Type: tea.KeyEnter})
+			got := raw.(textInput)
+
+			if tc.err == nil {
+				assert.Nil(t, got.err)
+				assert.Equal(t, "querying", got.state)
+				return
+			}
+
+			assert.Equal(t, tc.err, got.err)
+			assert.Equal(t, "idle", got.state)
+			assert.Contains(t, got.View(), tc.err.Error())
+		})
+	}
+}
+
+func TestNewProjectCreatorWithSuffix(t *testing.T) {
+	q := getTestQueue(appTitle, "test")
+	out := newProjectCreatorWithSuffix("project_id" + projNewSuffix)
+	q.add(&out)
+	out.ti.SetValue("ds-test-project-that-is-way-too-long")
+
+	final := out.transform(out.ti.Value())
+	assert.LessOrEqual(t, len(final), 30)
+	assert.Nil(t, gcloud.ProjectIDValidate(final))
+	assert.Contains(t, out.View(), final)
+
+	raw, _ := out.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	got := raw.(textInput)
+
+	assert.Nil(t, got.err)
+	assert.Equal(t, final, got.value)
+}
+
 func TestNewProjectSelector(t *testing.T) {
 	tests := map[string]struct {
 		key          string
@@ -258,6 +313,109 @@ func TestNewProjectFlow(t *testing.T) {
 	}
 }
 
+func TestProjectParentFlow(t *testing.T) {
+	key := "project_id"
+
+	q := getTestQueue(appTitle, "test")
+	q.Save("currentProject", "ds-current")
+	p1 := newProjectSelector(key, "", "", getProjects(&q))
+	p2 := newProjectParentSelector(key+parentNewSuffix, getProjectParents(&q))
+	p3 := newProjectCreator(key + projNewSuffix)
+	p4 := newPage("dummy", []component{})
+	q.add(&p1, &p2, &p3, &p4)
+
+	q.Start()
+	tmp, _ := q.next()
+	assert.Equal(t, key+parentNewSuffix, tmp.(QueueModel).getKey())
+
+	items := getProjectParents(&q)().([]list.Item)
+	want := []list.Item{
+		item{value: "organization/298490623289", label: "Organization example.com (same as ds-current)"},
+		item{value: "organization/123456789012", label: "Organization other.example.com"},
+		item{value: noParentValue, label: "No parent organization or folder"},
+	}
+	assert.Equal(t, want, items)
+
+	tests := map[string]struct {
+		value string
+		want  *cloudresourcemanager.ResourceId
+	}{
+		"organization": {
+			value: "organization/123456789012",
+			want:  &cloudresourcemanager.ResourceId{Type: "organization", Id: "123456789012"},
+		},
+		"folder": {
+			value: "folder/456",
+			want:  &cloudresourcemanager.ResourceId{Type: "folder", Id: "456"},
+		},
+		"none": {
+			value: noParentValue,
+			want:  &cloudresourcemanager.ResourceId{},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			msg := processProjectParent(tc.value, &q)()
+			assert.Equal(t, successMsg{}, msg)
+			assert.Equal(t, tc.want, q.Get(key+parentNewSuffix))
+			assert.Equal(t, "", q.stack.GetSetting(key+parentNewSuffix))
+		})
+	}
+
+	msg := processProjectParent("bogus", &q)()
+	assert.IsType(t, errMsg{}, msg)
+
+	// Picking an existing project skips the whole creation flow
+	q.goToModel(key)
+	processProjectSelection("ds-existing", &q)()
+	assert.True(t, q.skipped(key+parentNewSuffix))
+	assert.True(t, q.skipped(key+projNewSuffix))
+}
+
+func TestProjectFolderFlow(t *testing.T) {
+	key := "project_id"
+
+	q := getTestQueue(appTitle, "test")
+	q.Save("currentProject", "ds-current")
+	p1 := newProjectSelector(key, "", "", getProjects(&q))
+	p2 := newProjectParentSelector(key+parentNewSuffix, getProjectParents(&q))
+	p3 := newProjectFolderSelector(key+folderNewSuffix, getProjectFolders(&q))
+	p4 := newProjectCreator(key + projNewSuffix)
+	p5 := newPage("dummy", []component{})
+	q.add(&p1, &p2, &p3, &p4, &p5)
+
+	q.Start()
+	q.next()
+
+	// Picking an organization moves on to the folders in it
+	assert.Equal(t, successMsg{}, processProjectParent("organization/298490623289", &q)())
+	tmp, _ := q.next()
+	assert.Equal(t, key+folderNewSuffix, tmp.(QueueModel).getKey())
+
+	items := getProjectFolders(&q)().([]list.Item)
+	want := []list.Item{
+		item{value: "organization/298490623289", label: "Directly in the organization, not in a folder"},
+		item{value: "folder/111", label: "Folder Development"},
+		item{value: "folder/222", label: "Folder Production"},
+	}
+	assert.Equal(t, want, items)
+
+	assert.Equal(t, successMsg{}, processProjectFolder("folder/222", &q)())
+	assert.Equal(t, &cloudresourcemanager.ResourceId{Type: "folder", Id: "222"}, q.Get(key+parentNewSuffix))
+	assert.Equal(t, "", q.stack.GetSetting(key+folderNewSuffix))
+
+	assert.Equal(t, successMsg{}, processProjectFolder("organization/298490623289", &q)())
+	assert.Equal(t, &cloudresourcemanager.ResourceId{Type: "organization", Id: "298490623289"}, q.Get(key+parentNewSuffix))
+
+	// Without an organization there are no folders to ask about
+	q.goToModel(key + parentNewSuffix)
+	assert.Equal(t, successMsg{}, processProjectParent(noParentValue, &q)())
+	assert.True(t, q.skipped(key+folderNewSuffix))
+	tmp, _ = q.next()
+	assert.Equal(t, key+projNewSuffix, tmp.(QueueModel).getKey())
+}
+
 func TestNewCustom(t *testing.T) {
 	tests := map[string]struct {
 		c          config.Custom
@@ -354,37 +512,83 @@ func TestQueueBatch(t *testing.T) {
 
 		"GCEInstance": {
 			f:     newGCEInstance,
-			count: 12,
+			count: 26,
 			keys: []string{
 				"gce-use-defaults",
 				"instance-name",
 				"region",
 				"zone",
+				"instance-machine-type-search",
+				"instance-min-cpus",
+				"instance-min-memory",
 				"instance-machine-type-family",
+				"instance-custom-cpus",
+				"instance-custom-memory",
 				"instance-machine-type",
+				"instance-quota",
 				"instance-image-project",
+				"instance-image-search",
 				"instance-image-family",
+				"instance-image-architecture",
 				"instance-image",
 				"instance-disktype",
 				"instance-disksize",
+				"instance-disk-replication",
+				"instance-disk-replica-zones",
+				"instance-shielded-vm",
+				"instance-confidential-vm",
+				"instance-sole-tenant",
+				"instance-node-type",
 				"instance-webserver",
 			},
 		},
+		"DiskReplicationManager": {
+			f:     newDiskReplicationManager,
+			count: 2,
+			keys: []string{
+				"instance-disk-replication",
+				"instance-disk-replica-zones",
+			},
+		},
+		"ShieldedVMManager": {
+			f:     newShieldedVMManager,
+			count: 2,
+			keys: []string{
+				"instance-shielded-vm",
+				"instance-confidential-vm",
+			},
+		},
+		"SoleTenantManager": {
+			f:     newSoleTenantManager,
+			count: 2,
+			keys: []string{
+				"instance-sole-tenant",
+				"instance-node-type",
+			},
+		},
 		"MachineTypeManager": {
 			f:     newMachineTypeManager,
-			count: 2,
+			count: 8,
 			keys: []string{
+				"instance-machine-type-search",
+				"instance-min-cpus",
+				"instance-min-memory",
 				"instance-machine-type-family",
+				"instance-custom-cpus",
+				"instance-custom-memory",
 				"instance-machine-type",
+				"instance-quota",
 			},
 		},
 
 		"DiskImageManager": {
 			f:     newDiskImageManager,
-			count: 3,
+			count: 5,
 			keys: []string{
 				"instance-image-project",
+				"instance-image-search",
 				"instance-image-family",
+				"instance-image-architecture",
 				"instance-image",
 			},
 		},
@@ -416,6 +620,52 @@ func TestQueueBatch(t *testing.T) {
 	}
 }
 
+func TestDomainEmailValidation(t *testing.T) {
+	q := getTestQueue(appTitle, "test")
+	newDomain(&q)
+	q.goToModel("domain_email")
+
+	email := q.Model("domain_email").(*textInput)
+	email.ti.SetValue("person.example.com")
+
+	raw, cmd := email.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if cmd == nil {
+		t.Fatalf("expected: the email to be validated got: no validation")
+	}
+
+	raw, _ = raw.(textInput).Update(cmd())
+	got := raw.(textInput)
+
+	assert.Equal(t, "domain_email", q.currentKey())
+	// The error is shown inline, like those of the other validators
+	assert.EqualError(t, got.err, "Your answer 'person.example.com' is not a valid email address")
+	assert.Equal(t, "", q.stack.GetSetting("domain_email"))
+}
+
+func TestGCEInstanceMachineTypes(t *testing.T) {
+	tests := map[string]struct {
+		regionType string
+		want       bool
+	}{
+		"unset":     {regionType: "", want: true},
+		"compute":   {regionType: "compute", want: true},
+		"run":       {regionType: "run", want: false},
+		"functions": {regionType: "functions", want: false},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+			q.stack.Config.RegionType = tc.regionType
+			newGCEInstance(&q)
+
+			for _, key := range []string{"instance-machine-type-search", "instance-machine-type-family", "instance-machine-type"} {
+				assert.Equal(t, tc.want, q.Model(key) != nil, key)
+			}
+		})
+	}
+}
+
 func TestCustomPages(t *testing.T) {
 	tests := map[string]struct {
 		config string
@@ -474,3 +724,216 @@ func TestCustomPages(t *testing.T) {
 		})
 	}
 }
+
+func TestInstanceLocationOrder(t *testing.T) {
+	tests := map[string]struct {
+		network         bool
+		networkFirst    bool
+		secondaryRanges bool
+		want            []string
+	}{
+		"noNetwork": {
+			want: []string{"region", "zone"},
+		},
+		"regionFirst": {
+			network: true,
+			want:    []string{"region", "zone", "instance-network", "instance-subnet"},
+		},
+		"networkFirst": {
+			network:      true,
+			networkFirst: true,
+			want:         []string{"instance-network", "region", "zone", "instance-subnet"},
+		},
+		"secondaryRanges": {
+			network:         true,
+			secondaryRanges: true,
+			want:            []string{"region", "zone", "instance-network", "instance-subnet", "instance-subnet-pods-range", "instance-subnet-services-range"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+			q.stack.Config.InstanceNetwork = tc.network
+			q.stack.Config.NetworkBeforeRegion = tc.networkFirst
+			q.stack.Config.SecondaryRanges = tc.secondaryRanges
+			newInstanceLocation(&q)
+
+			got := []string{}
+			for _, v := range q.models {
+				got = append(got, v.getKey())
+			}
+
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestGCEInstanceScheduling(t *testing.T) {
+	keys := []string{"instance-provisioning-model", "instance-on-host-maintenance", "instance-automatic-restart"}
+
+	for _, scheduling := range []bool{false, true} {
+		q := getTestQueue(appTitle, "test")
+		q.stack.Config.InstanceScheduling = scheduling
+		newGCEInstance(&q)
+
+		for _, v := range keys {
+			assert.Equal(t, scheduling, q.Model(v) != nil, "scheduling %t model %s", scheduling, v)
+		}
+	}
+}
+
+func TestConfigDefaults(t *testing.T) {
+	tests := map[string]struct {
+		defaults      gcloud.ConfigDefaults
+		regionDefault string
+		wantRegion    string
+		wantZone      string
+	}{
+		"none": {
+			wantRegion: "",
+			wantZone:   gcloud.DefaultZone,
+		},
+		"gcloud": {
+			defaults:   gcloud.ConfigDefaults{Region: "europe-west1", Zone: "europe-west1-b"},
+			wantRegion: "europe-west1",
+			wantZone:   "europe-west1-b",
+		},
+		"stackWins": {
+			defaults:      gcloud.ConfigDefaults{Region: "europe-west1", Zone: "europe-west1-b"},
+			regionDefault: "us-east1",
+			wantRegion:    "us-east1",
+			wantZone:      "europe-west1-b",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			stack := config.NewStack()
+			stack.Config.RegionDefault = tc.regionDefault
+			q := NewQueue(&stack, mock{configDefaults: tc.defaults})
+
+			newRegion(&q)
+			newZone(&q)
+
+			assert.Equal(t, tc.wantRegion, q.Model("region").(*picker).defaultValue)
+			assert.Equal(t, tc.wantZone, q.Model("zone").(*picker).defaultValue)
+		})
+	}
+}
+
+func TestSnapshotDefaults(t *testing.T) {
+	stack := config.NewStack()
+	stack.Config.Region = true
+	stack.Config.Zone = true
+	stack.Config.RegionDefault = "us-central1"
+
+	q, err := newQueueCopyFrom(&stack, mock{}, "ds-source")
+	if err != nil {
+		t.Fatalf("expected no error, got: %s", err)
+	}
+
+	region := q.Model("region").(*picker)
+	zone := q.Model("zone").(*picker)
+	assert.Equal(t, "us-east1", region.defaultValue)
+	assert.Equal(t, "us-east1-c", zone.defaultValue)
+	assert.Contains(t, region.View(), "copied from project ds-source")
+
+	q.goToModel("region")
+	region.Update([]list.Item{
+		item{label: "us-central1", value: "us-central1"},
+		item{label: "us-east1", value: "us-east1"},
+	})
+	region.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	assert.Equal(t, "us-east1", q.stack.GetSetting("region"))
+
+	_, err = newQueueCopyFrom(&stack, mock{forceErr: true}, "ds-source")
+	assert.ErrorIs(t, err, errForced)
+}
+
+func TestReviewScreen(t *testing.T) {
+	q := getTestQueue(appTitle, "test")
+	q.stack.AddSetting("project_id", "ds-review")
+	q.stack.AddSetting("region", "us-central1")
+	q.stack.AddSetting("nodes", "3")
+
+	nodes := newTextInput("How many nodes?", "3", "nodes", "")
+	endpage := newPage("endpage", nil)
+	q.add(&nodes)
+	newReviewScreen(&q)
+	q.add(&endpage)
+
+	q.goToModel(reviewKey)
+	review := q.Model(reviewKey).(*picker)
+	raw, _ := review.Update(review.preProcessor())
+	got := raw.(picker)
+
+	view := got.View()
+	for _, v := range []string{"Review your settings", "Project ID", "ds-review", "Region", "us-central1", "Nodes", "Confirm", "Edit"} {
+		assert.Contains(t, view, v)
+	}
+
+	labels := []string{}
+	for _, v := range got.list.Items() {
+		labels = append(labels, v.(item).label)
+	}
+	assert.Equal(t, []string{"Confirm", "Edit"}, labels)
+
+	// Edit lists the answered steps, and picking one goes back to it
+	edit := q.Model(reviewEditKey).(*picker)
+	assert.Equal(t, []list.Item{item{"nodes (3)", "nodes"}}, getReviewSettings(&q)())
+
+	q.goToModel(reviewEditKey)
+	msg := processReviewEdit("nodes", &q)()
+	edit.Update(msg)
+	assert.Equal(t, "nodes", q.currentKey())
+	assert.Equal(t, "", q.stack.GetSetting("nodes"))
+	assert.Nil(t, q.Get(reviewConfirmedKey))
+
+	// Confirm goes past the edit list, and lets the settings be written
+	q.goToModel(reviewKey)
+	msg = processReview("confirm", &q)()
+	assert.Equal(t, successMsg{unset: true, target: "endpage"}, msg)
+	review.Update(msg)
+	assert.Equal(t, "endpage", q.currentKey())
+	assert.Equal(t, true, q.Get(reviewConfirmedKey))
+}
+
+func TestGCEInstanceOverrides(t *testing.T) {
+	t.Setenv("DSTEST_REGION", "europe-west1")
+	t.Setenv("DSTEST_ZONE", "europe-west1-b")
+	t.Setenv("DSTEST_INSTANCE_MACHINE_TYPE", "e2-small")
+	t.Setenv("DSTEST_INSTANCE_WEBSERVER", "y")
+
+	q := getTestQueue(appTitle, "test")
+	q.stack.ApplyEnvOverrides("DSTEST_")
+	newGCEInstance(&q)
+
+	for _, v := range []string{
+		"region",
+		"zone",
+		"instance-machine-type-search",
+		"instance-min-cpus",
+		"instance-min-memory",
+		"instance-machine-type-family",
+		"instance-custom-cpus",
+		"instance-custom-memory",
+		"instance-machine-type",
+	} {
+		assert.Nil(t, q.Model(v), v)
+	}
+	assert.NotNil(t, q.Model("instance-name"))
+	assert.NotNil(t, q.Model("instance-image"))
+
+	// The webserver step still finishes off the settings, with the override
+	web := q.Model("instance-webserver").(*picker)
+	assert.Equal(t, successMsg{unset: true}, web.preProcessor())
+	assert.Equal(t, gcloud.HTTPServerTags, q.stack.GetSetting("instance-tags"))
+
+	// Taking the defaults leaves the overrides alone
+	assert.Equal(t, successMsg{}, validateGCEDefault("y", &q)())
+	assert.Equal(t, "europe-west1", q.stack.GetSetting("region"))
+	assert.Equal(t, "europe-west1-b", q.stack.GetSetting("zone"))
+	assert.Equal(t, "e2-small", q.stack.GetSetting("instance-machine-type"))
+	assert.Equal(t, gcloud.DefaultDiskSize, q.stack.GetSetting("instance-disksize"))
+}
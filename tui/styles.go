@@ -17,8 +17,10 @@ package tui
 import (
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 
+	"github.com/GoogleCloudPlatform/deploystack/config"
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/table"
 	"github.com/charmbracelet/lipgloss"
@@ -298,21 +300,175 @@ func (d dsStyle) BorderForeground(b lipgloss.TerminalColor) dsStyle {
 var (
 	width          = 100
 	hardWidthLimit = width
-	lgbasicText    = lipgloss.AdaptiveColor{Light: "0", Dark: "15"}
-	lggray         = lipgloss.AdaptiveColor{Light: "7", Dark: "8"}
-	lggrayWeak     = lipgloss.AdaptiveColor{Light: "8", Dark: "7"}
-	lgalert        = lipgloss.AdaptiveColor{Light: "1", Dark: "9"}
 
-	gray          = dsAdaptiveColor{light: textColors.color("white"), dark: textColors.color("dark grey")}
-	grayWeak      = dsAdaptiveColor{light: textColors.color("dark grey"), dark: textColors.color("white")}
 	simClearColor = dsAdaptiveColor{light: textColors.color("bright white"), dark: textColors.colorByID(0)}
-	highlight     = dsAdaptiveColor{light: textColors.color("cyan"), dark: textColors.color("bright cyan")}
-	basicText     = dsAdaptiveColor{light: textColors.color("black"), dark: textColors.color("light grey"), blankOnCloudShell: true}
-	alert         = dsAdaptiveColor{light: textColors.color("red"), dark: textColors.color("bright red")}
 	completeColor = dsAdaptiveColor{light: textColors.color("dark grey"), dark: textColors.color("dark grey")}
 	pendingColor  = dsAdaptiveColor{light: textColors.color("cyan"), dark: textColors.color("bright cyan")}
-	highlightBG   = dsAdaptiveColor{light: backgroundColors.color("bold on cyan"), dark: backgroundColors.color("cyan")}
 
+	// The colors the styles are built from. Until a Theme is set they adapt
+	// to the terminal background.
+	lgbasicText lipgloss.AdaptiveColor
+	lggray      lipgloss.AdaptiveColor
+	lggrayWeak  lipgloss.AdaptiveColor
+	lgalert     lipgloss.AdaptiveColor
+
+	gray        dsAdaptiveColor
+	grayWeak    dsAdaptiveColor
+	highlight   dsAdaptiveColor
+	basicText   dsAdaptiveColor
+	alert       dsAdaptiveColor
+	highlightBG dsAdaptiveColor
+	promptText  dsAdaptiveColor
+)
+
+// The styles, built by buildStyles from the colors above
+var (
+	strong                dsStyle
+	normal                dsStyle
+	url                   dsStyle
+	titleStyle            dsStyle
+	purchaseStyle         dsStyle
+	subTitleStyle         dsStyle
+	headerCopyStyle       dsStyle
+	headerStyle           dsStyle
+	cursorPromptStyle     dsStyle
+	bodyStyle             dsStyle
+	docStyle              dsStyle
+	promptStyle           dsStyle
+	alertStyle            dsStyle
+	alertStrongStyle      dsStyle
+	instructionStyle      dsStyle
+	textStyle             dsStyle
+	textInputDefaultStyle dsStyle
+	inputText             dsStyle
+	componentStyle        dsStyle
+	billingDisabledStyle  dsStyle
+	itemStyle             dsStyle
+	selectedItemStyle     dsStyle
+	quitTextStyle         dsStyle
+	spinnerStyle          dsStyle
+	completeStyle         dsStyle
+	pendingStyle          dsStyle
+
+	tableStyle      table.Styles
+	paginationStyle lipgloss.Style
+	helpStyle       lipgloss.Style
+	textInputPrompt lipgloss.Style
+	errorAlertStyle lipgloss.Style
+	boldAlert       lipgloss.Style
+	cmdStyle        lipgloss.Style
+)
+
+// Theme holds the colors the tui draws with, in place of the ones picked to
+// suit the terminal background. Each is the name of one of the 16 ANSI
+// colors, like "cyan", "bright red" or "dark grey".
+type Theme struct {
+	// Text is the color of most of the text
+	Text string
+	// Highlight marks values, links, the progress made and the spinner
+	Highlight string
+	// Alert is the color of errors
+	Alert string
+	// Muted is for borders and items that can't be picked
+	Muted string
+	// Subtle is for help text and the progress still to go
+	Subtle string
+	// Selected is the background of the selected item and of prompts
+	Selected string
+	// PromptText is the color of the text on prompts
+	PromptText string
+}
+
+var (
+	// ThemeDark suits terminals with a dark background
+	ThemeDark = Theme{
+		Text:       "light grey",
+		Highlight:  "bright cyan",
+		Alert:      "bright red",
+		Muted:      "dark grey",
+		Subtle:     "white",
+		Selected:   "cyan",
+		PromptText: "white",
+	}
+
+	// ThemeLight suits terminals with a light background
+	ThemeLight = Theme{
+		Text:       "black",
+		Highlight:  "cyan",
+		Alert:      "red",
+		Muted:      "white",
+		Subtle:     "dark grey",
+		Selected:   "bold on cyan",
+		PromptText: "white",
+	}
+
+	// Themes are the built in themes by the name config.EnvTheme takes
+	Themes = map[string]Theme{
+		"dark":  ThemeDark,
+		"light": ThemeLight,
+	}
+)
+
+// SetTheme draws the tui in the colors of t from then on, whatever the
+// terminal background
+func SetTheme(t Theme) {
+	setColors(&t)
+	buildStyles()
+}
+
+// setColors sets the colors the styles are built from to those of t, or, with
+// no theme, to ones that adapt to the terminal background
+func setColors(t *Theme) {
+	if t == nil {
+		lgbasicText = lipgloss.AdaptiveColor{Light: "0", Dark: "15"}
+		lggray = lipgloss.AdaptiveColor{Light: "7", Dark: "8"}
+		lggrayWeak = lipgloss.AdaptiveColor{Light: "8", Dark: "7"}
+		lgalert = lipgloss.AdaptiveColor{Light: "1", Dark: "9"}
+
+		gray = dsAdaptiveColor{light: textColors.color("white"), dark: textColors.color("dark grey")}
+		grayWeak = dsAdaptiveColor{light: textColors.color("dark grey"), dark: textColors.color("white")}
+		highlight = dsAdaptiveColor{light: textColors.color("cyan"), dark: textColors.color("bright cyan")}
+		basicText = dsAdaptiveColor{light: textColors.color("black"), dark: textColors.color("light grey"), blankOnCloudShell: true}
+		alert = dsAdaptiveColor{light: textColors.color("red"), dark: textColors.color("bright red")}
+		highlightBG = dsAdaptiveColor{light: backgroundColors.color("bold on cyan"), dark: backgroundColors.color("cyan")}
+		promptText = dsAdaptiveColor{light: textColors.color("white"), dark: textColors.color("white")}
+		return
+	}
+
+	lgbasicText = themeLipglossColor(t.Text)
+	lggray = themeLipglossColor(t.Muted)
+	lggrayWeak = themeLipglossColor(t.Subtle)
+	lgalert = themeLipglossColor(t.Alert)
+
+	gray = themeColor(textColors, t.Muted)
+	grayWeak = themeColor(textColors, t.Subtle)
+	highlight = themeColor(textColors, t.Highlight)
+	basicText = themeColor(textColors, t.Text)
+	alert = themeColor(textColors, t.Alert)
+	highlightBG = themeColor(backgroundColors, t.Selected)
+	promptText = themeColor(textColors, t.PromptText)
+}
+
+// themeColor is the color called name in colors, whatever the background
+func themeColor(colors ansi16colors, name string) dsAdaptiveColor {
+	c := colors.color(name)
+	return dsAdaptiveColor{light: c, dark: c}
+}
+
+// themeLipglossColor is the text color called name as a lipgloss color,
+// whatever the background. An unknown name leaves the color unset.
+func themeLipglossColor(name string) lipgloss.AdaptiveColor {
+	c := textColors.color(name)
+	if c.name == "" {
+		return lipgloss.AdaptiveColor{}
+	}
+
+	id := strconv.Itoa(c.id)
+	return lipgloss.AdaptiveColor{Light: id, Dark: id}
+}
+
+// buildStyles builds the styles from the current colors
+func buildStyles() {
 	strong = newDsStyle().
 		Foreground(highlight)
 
@@ -324,126 +480,121 @@ var (
 		Underline(true)
 
 	titleStyle = newDsStyle().
-			Bold(true).
-			Foreground(basicText)
+		Bold(true).
+		Foreground(basicText)
 
 	purchaseStyle = newDsStyle().
-			Bold(true).
-			Foreground(alert).
-			Background(gray)
+		Bold(true).
+		Foreground(alert).
+		Background(gray)
 
 	subTitleStyle = newDsStyle().
-			MaxWidth(hardWidthLimit).
-			Bold(false).
-			Foreground(basicText)
+		MaxWidth(hardWidthLimit).
+		Bold(false).
+		Foreground(basicText)
 
 	headerCopyStyle = newDsStyle().
-			MaxWidth(hardWidthLimit)
+		MaxWidth(hardWidthLimit)
 
 	headerStyle = newDsStyle().
-			MarginLeft(0).
-			MarginRight(0).
-			Padding(0, 3).
-			BorderStyle(lipgloss.ThickBorder()).
-			BorderTop(false).
-			BorderLeft(false).
-			BorderRight(false).
-			BorderBottom(true).
-			MaxWidth(hardWidthLimit).
-			BorderForeground(lggray).
-			Width(width)
+		MarginLeft(0).
+		MarginRight(0).
+		Padding(0, 3).
+		BorderStyle(lipgloss.ThickBorder()).
+		BorderTop(false).
+		BorderLeft(false).
+		BorderRight(false).
+		BorderBottom(true).
+		MaxWidth(hardWidthLimit).
+		BorderForeground(lggray).
+		Width(hardWidthLimit)
 
 	cursorPromptStyle = newDsStyle().
-				Foreground(highlight)
+		Foreground(highlight)
 
 	bodyStyle = newDsStyle().
-			MarginLeft(0).
-			MarginRight(0).
-			Padding(0, 3).
-			Foreground(basicText).
-			Width(width).
-			MaxWidth(hardWidthLimit)
+		MarginLeft(0).
+		MarginRight(0).
+		Padding(0, 3).
+		Foreground(basicText).
+		Width(hardWidthLimit).
+		MaxWidth(hardWidthLimit)
 
 	docStyle = newDsStyle().
-			Foreground(basicText).
-			Padding(0, 2)
+		Foreground(basicText).
+		Padding(0, 2)
 
 	promptStyle = newDsStyle().
-			Bold(true).
-			Background(highlightBG).
-			Foreground(dsAdaptiveColor{light: textColors.color("white"), dark: textColors.color("white")})
+		Bold(true).
+		Background(highlightBG).
+		Foreground(promptText)
 
 	alertStyle = bodyStyle.Copy().
-			Foreground(alert)
+		Foreground(alert)
 
 	alertStrongStyle = bodyStyle.Copy().
-				Foreground(alert).
-				PaddingLeft(3).Bold(true)
+		Foreground(alert).
+		PaddingLeft(3).Bold(true)
 
 	instructionStyle = newDsStyle().
-				PaddingLeft(3)
+		PaddingLeft(3)
 
 	textStyle = newDsStyle().
-			Foreground(basicText)
+		Foreground(basicText)
 
 	textInputDefaultStyle = newDsStyle().
-				Foreground(highlight)
+		Foreground(highlight)
 
 	tableStyle = table.DefaultStyles()
 
 	inputText = bodyStyle.Copy().
-			Foreground(highlight)
+		Foreground(highlight)
 
 	componentStyle = newDsStyle().
-			PaddingLeft(1).
-			MarginLeft(0)
+		PaddingLeft(1).
+		MarginLeft(0)
 
 	billingDisabledStyle = newDsStyle().
-				Foreground(gray)
+		Foreground(gray)
 
 	itemStyle = newDsStyle().
-			PaddingLeft(4)
+		PaddingLeft(4)
 
 	selectedItemStyle = newDsStyle().
-				PaddingLeft(2).
-				Background(highlightBG).
-				Foreground(basicText)
+		PaddingLeft(2).
+		Background(highlightBG).
+		Foreground(basicText)
 
 	paginationStyle = list.DefaultStyles().
-			PaginationStyle.PaddingLeft(4)
+		PaginationStyle.PaddingLeft(4)
 
 	helpStyle = list.DefaultStyles().
-			HelpStyle.
-			PaddingLeft(4).
-			PaddingBottom(1).
-			Foreground(lggrayWeak)
+		HelpStyle.
+		PaddingLeft(4).
+		PaddingBottom(1).
+		Foreground(lggrayWeak)
 
 	quitTextStyle = newDsStyle().
-			Margin(1, 0, 2, 4)
+		Margin(1, 0, 2, 4)
 
 	spinnerStyle = newDsStyle().Foreground(highlight)
 
 	textInputPrompt = helpStyle.Copy().
-			PaddingLeft(3)
+		PaddingLeft(3)
 
 	completeStyle = newDsStyle().Foreground(highlight)
 
 	pendingStyle = newDsStyle().Foreground(grayWeak)
 
 	errorAlertStyle = lipgloss.NewStyle().
-			Width(100).
-			Border(lipgloss.NormalBorder()).
-			BorderForeground(lgalert).
-			PaddingLeft(3).
-			Foreground(lggrayWeak)
+		Width(100).
+		Border(lipgloss.NormalBorder()).
+		BorderForeground(lgalert).
+		PaddingLeft(3).
+		Foreground(lggrayWeak)
 
 	boldAlert = lipgloss.NewStyle().Bold(true).Foreground(lgalert)
-	cmdStyle  = lipgloss.NewStyle().Background(lggrayWeak).Foreground(lgalert)
-)
-
-func init() {
-
-	width, _, _ = term.GetSize(int(os.Stdout.Fd()))
+	cmdStyle = lipgloss.NewStyle().Background(lggrayWeak).Foreground(lgalert)
 
 	tableStyle.Header.
 		BorderStyle(lipgloss.HiddenBorder()).
@@ -457,3 +608,13 @@ func init() {
 		Padding(0)
 	tableStyle.Header.Padding(0)
 }
+
+func init() {
+	setColors(nil)
+	if t, ok := Themes[strings.ToLower(os.Getenv(config.EnvTheme))]; ok {
+		setColors(&t)
+	}
+	buildStyles()
+
+	width, _, _ = term.GetSize(int(os.Stdout.Fd()))
+}
@@ -16,8 +16,11 @@ package tui
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
@@ -26,8 +29,10 @@ import (
 type textInput struct {
 	dynamicPage
 
-	label string
-	ti    textinput.Model
+	label     string
+	ti        textinput.Model
+	validator func(string) error
+	transform func(string) string
 }
 
 func newTextInput(label, defaultValue, key, spinnerLabel string) textInput {
@@ -53,6 +58,68 @@ func newTextInput(label, defaultValue, key, spinnerLabel string) textInput {
 	return t
 }
 
+// storageUnits converts storage sizes to GB, the unit disk size settings expect
+var storageUnits = map[string]float64{
+	"GB": 1,
+	"TB": 1024,
+}
+
+// newUnitInput creates a text input that accepts a number with an optional
+// unit suffix, like 500GB or 2TB, and stores it as a bare number in the unit
+// whose multiplier in units is 1. A bare number is taken to already be in
+// that unit.
+func newUnitInput(label, defaultValue, key, spinnerLabel string, units map[string]float64) textInput {
+	t := newTextInput(label, defaultValue, key, spinnerLabel)
+	t.transform = func(s string) string {
+		normalized, err := normalizeUnits(s, units)
+		if err != nil {
+			return s
+		}
+		return normalized
+	}
+	t.validator = func(s string) error {
+		_, err := normalizeUnits(s, units)
+		return err
+	}
+
+	return t
+}
+
+// normalizeUnits converts a value like 2TB into the base unit of units
+func normalizeUnits(value string, units map[string]float64) (string, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+
+	i := strings.IndexFunc(value, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+
+	number, unit := value, ""
+	if i >= 0 {
+		number, unit = strings.TrimSpace(value[:i]), strings.TrimSpace(value[i:])
+	}
+
+	multiplier := 1.0
+	if unit != "" {
+		m, ok := units[unit]
+		if !ok {
+			valid := []string{}
+			for k := range units {
+				valid = append(valid, k)
+			}
+			sort.Strings(valid)
+			return "", fmt.Errorf("unknown unit (%s), use one of: %s", unit, strings.Join(valid, ", "))
+		}
+		multiplier = m
+	}
+
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return "", fmt.Errorf("(%s) is not a valid number", number)
+	}
+
+	return strconv.FormatFloat(n*multiplier, 'f', -1, 64), nil
+}
+
 func (p textInput) Init() tea.Cmd {
 	return tea.Batch(textinput.Blink, p.spinner.Tick)
 }
@@ -65,16 +132,21 @@ func (p textInput) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	// if the intended key for this setting is already set, skip
 	if p.queue.stack.GetSetting(p.key) != "" ||
 		p.queue.stack.GetSetting(keyTarget) != "" {
-		return p.queue.next()
+		return p.queue.skip()
 	}
 
 	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		p.queue.windowHeight = msg.Height
+		return p, nil
 	case tea.KeyMsg:
+		if key.Matches(msg, p.queue.keyMap.Back) {
+			return p.queue.previous()
+		}
+
 		switch keypress := msg.String(); keypress {
 		case "ctrl+c":
 			return p.queue.exitPage()
-		case "alt+b", "ctrl+b":
-			return p.queue.prev()
 		case "enter":
 			val := p.ti.Value()
 			if val == "" {
@@ -85,6 +157,17 @@ func (p textInput) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				p.err = fmt.Errorf("You must enter a value")
 				return p, nil
 			}
+
+			if p.transform != nil {
+				val = p.transform(val)
+			}
+
+			if p.validator != nil {
+				if err := p.validator(val); err != nil {
+					p.err = err
+					return p, nil
+				}
+			}
 			p.value = val
 
 			// TODO: see if you can figure out a test for these untested bits
@@ -98,7 +181,7 @@ func (p textInput) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return p, nil
 			}
 			if !p.omitFromSettings {
-				p.queue.stack.AddSetting(p.key, p.value)
+				p.queue.setSetting(p.key, p.value)
 			}
 			return p.queue.next()
 		}
@@ -107,8 +190,10 @@ func (p textInput) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case errMsg:
 		p.err = msg
 		p.state = "idle"
+		p.queue.reportError(p.key, msg)
 
 		if msg.quit {
+			p.queue.closeEvents()
 			return p, tea.Quit
 		}
 
@@ -126,7 +211,7 @@ func (p textInput) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 		if !p.omitFromSettings {
-			p.queue.stack.AddSetting(newKey, newValue)
+			p.queue.setSetting(newKey, newValue)
 		}
 		return p.queue.next()
 
@@ -170,6 +255,12 @@ func (p textInput) View() string {
 	doc.WriteString(inputText.Render(p.ti.View()))
 	doc.WriteString("\n")
 
+	if p.transform != nil && p.ti.Value() != "" {
+		final := textInputDefaultStyle.Render(p.transform(p.ti.Value()))
+		doc.WriteString(textInputPrompt.Render(fmt.Sprintf("This will be entered as '%s'", final)))
+		doc.WriteString("\n")
+	}
+
 	if p.err != nil {
 		height := len(p.err.Error()) / width
 		doc.WriteString("\n")
@@ -183,3 +183,60 @@ func TestTextInputPreviewFunc(t *testing.T) {
 	assert.Equal(t, "test", page.getValue())
 
 }
+
+func TestNormalizeUnits(t *testing.T) {
+	tests := map[string]struct {
+		in   string
+		want string
+		err  error
+	}{
+		"gb":        {in: "500GB", want: "500"},
+		"tb":        {in: "2TB", want: "2048"},
+		"lowercase": {in: "2tb", want: "2048"},
+		"space":     {in: "1 TB", want: "1024"},
+		"bare":      {in: "100", want: "100"},
+		"badUnit":   {in: "5PB", err: fmt.Errorf("unknown unit (PB), use one of: GB, TB")},
+		"badNumber": {in: "lotsGB", err: fmt.Errorf("unknown unit (LOTSGB), use one of: GB, TB")},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := normalizeUnits(tc.in, storageUnits)
+			if tc.err != nil {
+				assert.EqualError(t, err, tc.err.Error())
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestUnitInputStoresNormalized(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		"gb":      {in: "500GB", want: "500"},
+		"tb":      {in: "2TB", want: "2048"},
+		"bare":    {in: "100", want: "100"},
+		"badUnit": {in: "5PB", want: "", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := getTestQueue(appTitle, "test")
+			ti := newUnitInput("Disk size", "100", "instance-disksize", "", storageUnits)
+			q.add(&ti)
+			ti.ti.SetValue(tc.in)
+
+			raw, _ := ti.Update(tea.KeyMsg{Type: tea.KeyEnter})
+
+			assert.Equal(t, tc.want, q.stack.GetSetting("instance-disksize"))
+			if tc.wantErr {
+				assert.NotNil(t, raw.(textInput).err)
+			}
+		})
+	}
+}
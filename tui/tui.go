@@ -18,8 +18,11 @@ package tui
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"cloud.google.com/go/domains/apiv1beta1/domainspb"
 	"github.com/GoogleCloudPlatform/deploystack/config"
@@ -35,9 +38,11 @@ const (
 	explainText           = "DeployStack will walk you through setting some options for the stack this solutions installs. Most questions have a default that you can choose by hitting the Enter key."
 	appTitle              = "DeployStack"
 	contactfile           = "contact.yaml.tmp"
-	validationPhoneNumber = "phonenumber"
-	validationYesOrNo     = "yesorno"
-	validationInteger     = "integer"
+	validationPhoneNumber = config.ValidationPhoneNumber
+	validationYesOrNo     = config.ValidationYesOrNo
+	validationInteger     = config.ValidationInteger
+	validationEmail       = config.ValidationEmail
+	validationURL         = config.ValidationURL
 )
 
 var (
@@ -48,6 +53,17 @@ var (
 // number validation.
 var ErrorCustomNotValidPhoneNumber = fmt.Errorf("not a valid phone number")
 
+// ErrorStepNotFound is the error when asked to start at a step that isn't
+// part of the flow for a stack
+var ErrorStepNotFound = fmt.Errorf("step is not part of this stack")
+
+// ErrorAnswerMissing is the error when RunFrom would skip a step that asks
+// for a setting the stack config requires, and nothing answers it
+var ErrorAnswerMissing = fmt.Errorf("no answer for a skipped step")
+
+// ErrWarnings is wrapped by WarningsError, for matching with errors.Is
+var ErrWarnings = fmt.Errorf("stack completed with warnings")
+
 type errMsg struct {
 	err     error
 	quit    bool
@@ -57,9 +73,17 @@ type errMsg struct {
 
 func (e errMsg) Error() string { return e.err.Error() }
 
+// slowMsg tells the step with the given key why its query is taking a while
+type slowMsg struct {
+	key  string
+	text string
+}
+
 type successMsg struct {
 	msg   string
 	unset bool
+	// target, when set, is the key of the step to go to instead of the next
+	target string
 }
 
 // UIClient interface encapsulates all of the calls to gcloud that one needs to
@@ -67,45 +91,99 @@ type successMsg struct {
 type UIClient interface {
 	// CloudResourceManager
 	ProjectIDGet() (string, error)
+	ConfigDefaultsGet() (gcloud.ConfigDefaults, error)
+	ProjectConfigSnapshot(projectID string) (map[string]string, error)
 	ProjectList() ([]gcloud.ProjectWithBilling, error)
+	ProjectListNotify(notify func(attempt int, wait time.Duration)) ([]gcloud.ProjectWithBilling, error)
 	ProjectParentGet(project string) (*cloudresourcemanager.ResourceId, error)
-	ProjectCreate(project, parent, parentType string) error
+	OrganizationList() (gcloud.LabeledValues, error)
+	FolderList(parent string) (gcloud.LabeledValues, error)
+	ProjectCreate(project, parent, parentType, billingAccount string) error
+	ProjectCreateWithLabels(project, parent, parentType, billingAccount string, labels map[string]string) error
 	ProjectNumberGet(id string) (string, error)
+	ProjectDescribe(projectID string) (gcloud.ProjectDescription, error)
 	ProjectIDSet(id string) error
+	OrgPolicyConstraints(project string) (map[string][]string, error)
 	// Compute Engine
 	RegionList(project, product string) ([]string, error)
 	ZoneList(project, region string) ([]string, error)
+	ZonesWithAccelerator(project, acceleratorType string) ([]string, error)
+	AcceleratorTypeList(project, zone string) (gcloud.LabeledValues, error)
+	AcceleratorTypeRegionList(project, region string) (gcloud.LabeledValues, error)
+	DiskTypeList(project, zone string) (gcloud.LabeledValues, error)
+	NetworkList(project string) (gcloud.LabeledValues, error)
+	NetworkSubnetworkList(project, region, network string) (gcloud.LabeledValues, error)
+	NetworkRegions(project, network string) ([]string, error)
+	SubnetSecondaryRanges(project, region, subnet string) (gcloud.LabeledValues, error)
 	ImageLatestGet(project, imageproject, imagefamily string) (string, error)
 	MachineTypeList(project, zone string) (*compute.MachineTypeList, error)
+	MachineTypeMatch(project, zone string, minCPU int64, minMemMB int64) (string, error)
+	InvalidateMachineTypes(project, zone string)
+	MachineTypePricing(region string) error
+	NodeTypeList(project, zone string) (gcloud.LabeledValues, error)
+	RegionQuota(project, region, metric string) (*compute.Quota, error)
 	MachineTypeFamilyList(imgs *compute.MachineTypeList) gcloud.LabeledValues
 	MachineTypeListByFamily(imgs *compute.MachineTypeList, family string) gcloud.LabeledValues
 	ImageList(project, imageproject string) (*compute.ImageList, error)
 	ImageTypeListByFamily(imgs *compute.ImageList, project, family string) gcloud.LabeledValues
 	ImageFamilyList(imgs *compute.ImageList) gcloud.LabeledValues
+	ImageFamilyListForProject(imgs *compute.ImageList, project string) gcloud.LabeledValues
+	ImageFamilyArchitectures(project, imageproject, family string) ([]string, error)
 	// Billing
 	BillingAccountList() ([]*cloudbilling.BillingAccount, error)
 	BillingAccountAttach(project, account string) error
 	// Domains
 	DomainIsAvailable(project, domain string) (*domainspb.RegisterParameters, error)
 	DomainIsVerified(project, domain string) (bool, error)
+	DomainVerified(domain string) (bool, error)
 	DomainRegister(project string, domaininfo *domainspb.RegisterParameters, contact gcloud.ContactData) error
+	// SecretManager
+	SecretList(project string) (gcloud.LabeledValues, error)
+	AddressList(project, region string) (gcloud.LabeledValues, error)
+	DNSZoneList(project string) (gcloud.LabeledValues, error)
 	// ServiceUsage
 	ServiceEnable(project string, service gcloud.Service) error
 	ServiceIsEnabled(project string, service gcloud.Service) (bool, error)
+	// Quotas
+	QuotaIncreaseRequest(project, region, metric string, requested int64) error
+	// Modes
+	IsReadOnly() bool
 }
 
 // Run takes a deploystack configuration and walks someone through all of the
 // input needed to run the eventual terraform
 func Run(s *config.Stack, useMock bool) {
-	if len(os.Getenv("DEBUG")) > 0 {
-		f, err := tea.LogToFile("debug.log", "debug")
-		if err != nil {
-			fmt.Println("fatal:", err)
-			os.Exit(1)
+	q := newRunQueue(s, useMock)
+
+	if err := run(s, &q); err != nil {
+		Fatal(err)
+	}
+}
+
+// RunStrict runs the interactive flow like Run, then checks the collected
+// settings with ValidateConsistency. It returns a *WarningsError if there
+// were any warnings, so CI can pass ExitCode to os.Exit and tell a stack
+// that needs a second look from a clean one. Failing to write the settings
+// is returned as is, which ExitCode maps to ExitFailed.
+func RunStrict(s *config.Stack, useMock bool) error {
+	q := newRunQueue(s, useMock)
+
+	if err := run(s, &q); err != nil {
+		return err
+	}
+
+	err := Check(s)
+	if err != nil {
+		fmt.Print("\n")
+		for _, v := range err.(*WarningsError).Warnings {
+			fmt.Println(alertStyle.Render(fmt.Sprintf("Warning: %s", v)))
 		}
-		defer f.Close()
 	}
 
+	return err
+}
+
+func newRunQueue(s *config.Stack, useMock bool) Queue {
 	defaultUserAgent := fmt.Sprintf("deploystack/%s", s.Config.Name)
 
 	client := gcloud.NewClient(context.Background(), defaultUserAgent)
@@ -117,7 +195,187 @@ func Run(s *config.Stack, useMock bool) {
 
 	q.InitializeUI()
 
+	return q
+}
+
+// RunReadOnly runs the interactive flow like Run, with a client that can't
+// change anything in Google Cloud. The steps that would create a project,
+// attach billing or enable APIs are left out, so only the settings are
+// collected and written out.
+func RunReadOnly(s *config.Stack) error {
+	client := gcloud.NewClient(context.Background(), fmt.Sprintf("deploystack/%s", s.Config.Name))
+	client.ReadOnly()
+
+	q := NewQueue(s, &client)
+	q.InitializeUI()
+
+	return run(s, &q)
+}
+
+// Exit codes for a finished run
+const (
+	ExitOK       = 0
+	ExitFailed   = 1
+	ExitWarnings = 3
+)
+
+// WarningsError is returned when a stack was collected and written, but its
+// settings produced consistency warnings.
+type WarningsError struct {
+	Warnings []string
+}
+
+func (e *WarningsError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrWarnings, strings.Join(e.Warnings, "; "))
+}
+
+func (e *WarningsError) Unwrap() error {
+	return ErrWarnings
+}
+
+// Check runs ValidateConsistency on the stack, returning a *WarningsError
+// if it found anything
+func Check(s *config.Stack) error {
+	warnings := s.ValidateConsistency()
+	if len(warnings) == 0 {
+		return nil
+	}
+
+	return &WarningsError{Warnings: warnings}
+}
+
+// ExitCode maps the result of RunStrict to the code the process should exit
+// with: ExitOK on success, ExitWarnings for warnings, and ExitFailed for
+// any other error.
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, ErrWarnings):
+		return ExitWarnings
+	default:
+		return ExitFailed
+	}
+}
+
+// AnswersFile is the file, in the stack's work dir, that RunFrom reads the
+// answers of an earlier run from
+const AnswersFile = "terraform.tfvars"
+
+// RunFrom starts the interactive flow at the step with the key startKey
+// rather than at the beginning. The steps before it are answered from
+// AnswersFile, as written by an earlier run, along with any settings already
+// on the stack.
+func RunFrom(s *config.Stack, client UIClient, startKey string) error {
+	answers, err := config.ReadTFVars(s.OutputPath(AnswersFile))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("could not read answers: %w", err)
+	}
+
+	q, err := newQueueFrom(s, client, startKey, answers)
+	if err != nil {
+		return err
+	}
+
+	return run(s, &q)
+}
+
+// RunCopyFrom runs the interactive flow like Run, offering the region, zone
+// and network of the project source as defaults, as read by
+// ProjectConfigSnapshot. Copying is best effort: whatever could be read is
+// offered, and the pickers say which project their default came from.
+func RunCopyFrom(s *config.Stack, client UIClient, source string) error {
+	q, err := newQueueCopyFrom(s, client, source)
+	if err != nil {
+		return err
+	}
+
+	return run(s, &q)
+}
+
+func newQueueCopyFrom(s *config.Stack, client UIClient, source string) (Queue, error) {
+	snapshot, err := client.ProjectConfigSnapshot(source)
+	if err != nil && len(snapshot) == 0 {
+		return Queue{}, fmt.Errorf("could not copy the config of project (%s): %w", source, err)
+	}
+
+	q := NewQueue(s, client)
+	q.Save(snapshotKey, configSnapshot{project: source, values: snapshot})
+	q.InitializeUI()
+
+	return q, nil
+}
+
+func newQueueFrom(s *config.Stack, client UIClient, startKey string, answers config.Settings) (Queue, error) {
+	// Build the flow once on a copy of the stack, to find out which steps
+	// come before startKey without touching the real settings
+	probeStack := *s
+	probeStack.Settings = append(config.Settings{}, s.Settings...)
+	probe := NewQueue(&probeStack, client)
+	probe.InitializeUI()
+
+	if err := probe.startAt(startKey); err != nil {
+		return probe, err
+	}
+
+	skipped := probe.index[:probe.current]
+	kept := map[string]bool{}
+	for _, v := range probe.index[probe.current:] {
+		kept[v] = true
+	}
+
+	// Answers for startKey and the steps after it are left out, so that
+	// those steps are still asked
+	for _, v := range answers {
+		if kept[v.Name] || s.Settings.Find(v.Name) != nil {
+			continue
+		}
+		s.AddSettingComplete(v)
+	}
+
+	required := requiredSettings(s.Config)
+	for _, v := range skipped {
+		if required[v] && s.GetSetting(v) == "" {
+			return probe, fmt.Errorf("%w: %s", ErrorAnswerMissing, v)
+		}
+	}
+
+	q := NewQueue(s, client)
+	q.InitializeUI()
+
+	if err := q.startAt(startKey); err != nil {
+		return q, err
+	}
+
+	return q, nil
+}
+
+// requiredSettings are the settings the stack config itself asks for, as
+// opposed to the ones the steps work out along the way
+func requiredSettings(c config.Config) map[string]bool {
+	r := map[string]bool{}
+
+	for _, v := range c.RequiredSettings() {
+		r[v] = true
+	}
+
+	return r
+}
+
+func run(s *config.Stack, q *Queue) error {
+	if len(os.Getenv("DEBUG")) > 0 {
+		f, err := tea.LogToFile("debug.log", "debug")
+		if err != nil {
+			fmt.Println("fatal:", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+	}
+
+	loadPreviousSettings(s, q)
+
 	p := tea.NewProgram(q.Start(), tea.WithAltScreen())
+	q.program = p
 	if _, err := p.Run(); err != nil {
 		Fatal(err)
 	}
@@ -126,7 +384,14 @@ func Run(s *config.Stack, useMock bool) {
 		Fatal(nil)
 	}
 
-	s.TerraformFile("terraform.tfvars")
+	// The settings are only written once the user confirmed them
+	if q.Model(reviewKey) != nil && q.Get(reviewConfirmedKey) == nil {
+		Fatal(nil)
+	}
+
+	if err := writeAnswers(s); err != nil {
+		return err
+	}
 
 	fmt.Print("\n\n")
 	fmt.Print(titleStyle.Render("Deploystack"))
@@ -135,16 +400,50 @@ func Run(s *config.Stack, useMock bool) {
 	fmt.Print("\n")
 	fmt.Print(strong.Render("Installation will proceed with these settings"))
 	fmt.Print(q.getSettings())
+
+	return nil
+}
+
+// loadPreviousSettings hands the queue the settings an earlier run wrote to
+// AnswersFile, if there are any, so the end page can show what changed
+func loadPreviousSettings(s *config.Stack, q *Queue) {
+	previous, err := config.ReadTFVars(s.OutputPath(AnswersFile))
+	if err != nil {
+		return
+	}
+
+	q.SetPreviousSettings(previous)
+}
+
+// writeAnswers writes the collected settings out to AnswersFile
+func writeAnswers(s *config.Stack) error {
+	if err := s.TerraformFile(AnswersFile); err != nil {
+		return fmt.Errorf("could not write settings to %s: %w", AnswersFile, err)
+	}
+
+	return nil
 }
 
 // PreCheck handles presenting a choice to a user amongst multiple stacks
 func PreCheck(reports []config.Report) string {
-
 	q := NewQueue(nil, GetMock(0))
 	q.Save("reports", reports)
 
+	return pickStack(&q, handleReports(&q))
+}
+
+// PickStack presents a choice amongst the stacks found by
+// config.DiscoverStacks, and returns the path of the one picked
+func PickStack(stacks []config.StackInfo) string {
+	q := NewQueue(nil, GetMock(0))
+	q.Save("stacks", stacks)
+
+	return pickStack(&q, getStacks(&q))
+}
+
+func pickStack(q *Queue, preProcessor tea.Cmd) string {
 	appHeader := newHeader(appTitle, "Multiple Stacks Detected")
-	firstPage := newPicker("Please pick a stack to use", "Finding stacks", "stack", "", handleReports(&q))
+	firstPage := newPicker("Please pick a stack to use", "Finding stacks", "stack", "", preProcessor)
 	firstPage.showProgress = false
 	firstPage.omitFromSettings = true
 	firstPage.addPostProcessor(handleStackSelection)
@@ -169,7 +468,6 @@ func PreCheck(reports []config.Report) string {
 	fmt.Print("\n")
 
 	return response
-
 }
 
 // Fatal stops processing of Deploystack and halts the calling process. All
@@ -15,11 +15,17 @@
 package tui
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/deploystack/config"
+	"github.com/GoogleCloudPlatform/deploystack/gcloud"
+	"github.com/stretchr/testify/assert"
 )
 
 var testFilesDir = filepath.Join(os.Getenv("DEPLOYSTACK_PATH"), "testdata")
@@ -43,3 +49,113 @@ func writeDebugFile(content string, target string) {
 		log.Printf("err: %s", err)
 	}
 }
+
+func TestExitCode(t *testing.T) {
+	clean := config.NewStack()
+	clean.AddSetting("region", "us-central1")
+	clean.AddSetting("zone", "us-central1-a")
+
+	warned := config.NewStack()
+	warned.AddSetting("region", "us-central1")
+	warned.AddSetting("zone", "us-east1-b")
+
+	tests := map[string]struct {
+		err  error
+		want int
+	}{
+		"success":   {err: Check(&clean), want: ExitOK},
+		"warnings":  {err: Check(&warned), want: ExitWarnings},
+		"hardError": {err: errors.New("could not write terraform.tfvars"), want: ExitFailed},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, ExitCode(tc.err))
+		})
+	}
+
+	err := Check(&warned)
+	var warnings *WarningsError
+	if assert.True(t, errors.As(err, &warnings)) {
+		assert.Equal(t, []string{"zone (us-east1-b) is not in region (us-central1)"}, warnings.Warnings)
+	}
+}
+
+func TestWriteAnswers(t *testing.T) {
+	s := config.NewStack()
+	s.AddSetting("region", "us-central1")
+
+	s.WorkDir = t.TempDir()
+	assert.Nil(t, writeAnswers(&s))
+	_, err := os.Stat(filepath.Join(s.WorkDir, AnswersFile))
+	assert.Nil(t, err)
+
+	// A failed write is a hard failure, even in strict mode
+	s.WorkDir = filepath.Join(t.TempDir(), "missing")
+	err = writeAnswers(&s)
+	assert.NotNil(t, err)
+	assert.Equal(t, ExitFailed, ExitCode(err))
+}
+
+func TestLoadPreviousSettings(t *testing.T) {
+	q := getTestQueue(appTitle, "test")
+	q.stack.WorkDir = t.TempDir()
+
+	// Nothing written yet, nothing to compare against
+	loadPreviousSettings(q.stack, &q)
+	assert.Nil(t, q.Get("previousSettings"))
+
+	q.stack.AddSetting("region", "us-central1")
+	assert.Nil(t, writeAnswers(q.stack))
+
+	loadPreviousSettings(q.stack, &q)
+	previous, ok := q.Get("previousSettings").(config.Settings)
+	if assert.True(t, ok) {
+		assert.Equal(t, "us-central1", previous.Find("region").Value)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	m := GetMock(0)
+	m.readOnly = true
+
+	q := getTestQueue(appTitle, "test")
+	q.client = m
+	q.stack.Config.Name = "test"
+	q.stack.Config.Project = true
+	q.stack.Config.Region = true
+	q.stack.Config.Services = []string{"compute"}
+	q.stack.WorkDir = t.TempDir()
+
+	if err := q.ProcessConfig(); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+
+	// Nothing that would create a project or enable APIs is asked
+	for _, v := range []string{"project_id" + projNewSuffix, "project_id" + billNewSuffix, "enable-services"} {
+		assert.Nil(t, q.Model(v), v)
+	}
+
+	p := q.Model("project_id").(*picker)
+	for _, v := range p.list.Items() {
+		assert.NotEqual(t, "Create New Project", v.(item).label)
+	}
+
+	assert.ErrorIs(t, m.ProjectCreate("ds-readonly", "", "", ""), gcloud.ErrReadOnly)
+	assert.ErrorIs(t, m.ServiceEnable("ds-readonly", gcloud.Compute), gcloud.ErrReadOnly)
+	assert.ErrorIs(t, m.BillingAccountAttach("ds-readonly", "000000-000000-000000"), gcloud.ErrReadOnly)
+	assert.ErrorIs(t, m.ProjectIDSet("ds-readonly"), gcloud.ErrReadOnly)
+
+	// Picking a project doesn't try to change the gcloud config
+	q.goToModel("project_id")
+	assert.Equal(t, successMsg{}, processProjectSelection("ds-readonly", &q)())
+
+	// The settings are still written out
+	q.stack.AddSetting("project_id", "ds-readonly")
+	q.stack.AddSetting("region", "us-central1")
+	assert.Nil(t, writeAnswers(q.stack))
+
+	answers, err := config.ReadTFVars(filepath.Join(q.stack.WorkDir, AnswersFile))
+	assert.Nil(t, err)
+	assert.Equal(t, "ds-readonly", answers.Find("project_id").Value)
+}